@@ -2,25 +2,74 @@ package app
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/client"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/config"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/db"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/job"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/eventbus"
+	kafkabus "github.com/ujjwalkirti/mini-vercel-api-server/internal/eventbus/kafka"
+	redisbus "github.com/ujjwalkirti/mini-vercel-api-server/internal/eventbus/redis"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/kafka/consumer"
 	repository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/deployment"
+	eventRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/event"
+	gitCredentialRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/gitcredential"
+	jobRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/job"
+	logsRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/logs"
+	projectRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/project"
+	webhookRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/webhook"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/router"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/buildauth"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/deployment"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/dispatch"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/ecs"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/ecsevents"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/encryption"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/events"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/executor"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/failure"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/gitcredentials"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/gitprovider"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/giturl"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/logs"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/redact"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/webhook"
 )
 
 type App struct {
-	server      *http.Server
-	cancelKafka context.CancelFunc
+	server         *http.Server
+	bus            eventbus.Bus
+	cancelBus      context.CancelFunc
+	busDone        chan struct{}
+	cancelDispatch context.CancelFunc
+	dispatchDone   chan struct{}
+	cancelEvents   context.CancelFunc
+	eventsDone     chan struct{}
+	cancelRelay    context.CancelFunc
+	relayDone      chan struct{}
+	cancelWebhooks context.CancelFunc
+	webhooksDone   chan struct{}
+	cancelBackfill context.CancelFunc
+	backfillDone   chan struct{}
+	cancelGit      context.CancelFunc
+	gitDone        chan struct{}
+	cancelLogs     context.CancelFunc
+	logsDone       chan struct{}
+	logSvc         *logs.Service
+	stopped        chan struct{}
 }
 
 func New() *App {
@@ -33,41 +82,234 @@ func New() *App {
 		log.Fatal("Failed to initialize Supabase:", err)
 	}
 
-	// Initialize ClickHouse repository for logs
-	logRepo, err := client.NewLogRepository(client.ClickHouseRepository)
+	// Initialize the log repository selected by LOG_STORE
+	logStore := client.LogRepositoryType(config.GetLogStore())
+	logRepo, err := client.NewLogStore(logStore, database)
 	if err != nil {
 		log.Fatal("Failed to create log repository:", err)
 	} else {
-		log.Println("Log repository created successfully")
+		log.Printf("Log repository created successfully (%s)", logStore)
+	}
+
+	// Monthly log partitions are created ahead of time for as long as the
+	// server runs, not only at startup
+	var (
+		cancelLogs context.CancelFunc
+		logsDone   chan struct{}
+	)
+	if store, ok := logRepo.(*logsRepository.PostgresStore); ok {
+		var logsCtx context.Context
+		logsCtx, cancelLogs = context.WithCancel(context.Background())
+		logsDone = make(chan struct{})
+
+		go func() {
+			defer close(logsDone)
+			store.MaintainPartitions(logsCtx, time.Hour, logsRepository.PartitionMonthsAhead)
+		}()
 	}
 
 	// Initialize services
+	// Kafka ingestion goes through a batch writer so the store sees a few
+	// large inserts instead of one per log line
 	logSvc := logs.New(logRepo)
+	batchCfg := config.GetLogBatchConfig()
+	if batchCfg.Enabled {
+		writer := logs.NewBatchWriter(logRepo, logs.BatchConfig{
+			MaxSize:       batchCfg.MaxSize,
+			FlushInterval: batchCfg.FlushInterval,
+			MaxRetries:    batchCfg.MaxRetries,
+		})
+		logSvc = logs.NewWithBatchWriter(logRepo, writer)
+	}
+
+	// Lines are stamped with their project's plan retention on ingest
+	projectRepo := projectRepository.New(database)
+	retentionCfg := config.GetLogRetentionConfig()
+	logSvc.SetRetentionPolicy(logs.NewRetentionPolicy(
+		retentionCfg.DefaultDays,
+		retentionCfg.PlanDays,
+		func(ctx context.Context, projectID string) (string, error) {
+			plan, err := projectRepo.GetPlan(ctx, projectID)
+			return string(plan), err
+		},
+	))
 	deploymentRepo := repository.New(database)
 	deploymentSvc := deployment.NewDeploymentService(deploymentRepo)
 
-	// Initialize Kafka processor
-	processor := consumer.NewProcessor(deploymentSvc, logSvc)
+	// Initialize build log processor
+	redactor := redact.New(redact.StaticSecrets(config.GetBuilderSecrets()))
+	processor := consumer.NewProcessor(deploymentSvc, logSvc, failure.NewClassifier(), redactor)
+
+	// Consume build logs from the event bus in background. Without a bus,
+	// builds deliver logs over HTTP or, with the local executor, straight
+	// to the processor.
+	busCfg := config.GetEventBusConfig()
+	var (
+		cancelBus context.CancelFunc
+		busDone   chan struct{}
+	)
+	bus := newEventBus(busCfg)
+	if bus != nil {
+		var busCtx context.Context
+		busCtx, cancelBus = context.WithCancel(context.Background())
+		busDone = make(chan struct{})
 
-	// Start Kafka consumer in background
-	kafkaConfig := config.LoadKafkaConfig()
-	ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			defer close(busDone)
+			log.Printf("Starting build log consumer on %s topic %s...", bus.Name(), busCfg.BuildLogsTopic)
+			if err := bus.Subscribe(busCtx, busCfg.BuildLogsTopic, busCfg.BuildLogsGroup, processor); err != nil {
+				log.Printf("Build log consumer stopped: %v", err)
+			}
+		}()
+	} else {
+		log.Println("Event bus disabled; build logs are only accepted over HTTP")
+	}
+
+	// Start the build dispatcher, which starts builds for queued deployments
+	dispatchCfg := config.GetDispatcherConfig()
+	queueCfg := config.GetBuildQueueConfig()
+	buildAuthCfg := config.GetBuildAuthConfig()
+	buildTokens := buildauth.New(deploymentRepo, buildAuthCfg.TokenTTL)
+	dispatcher := dispatch.New(jobRepository.New(database), deploymentSvc, newBuildExecutor(processor), buildTokens, dispatch.Config{
+		PollInterval: dispatchCfg.PollInterval,
+		BatchSize:    dispatchCfg.BatchSize,
+		MaxAttempts:  dispatchCfg.MaxAttempts,
+		RetryBackoff: dispatchCfg.RetryBackoff,
+		Lease:        dispatchCfg.Lease,
+		Limits: job.Limits{
+			Global:     queueCfg.GlobalLimit,
+			PerUser:    queueCfg.UserLimit,
+			PerProject: queueCfg.ProjectLimit,
+		},
+		SkipSuperseded: queueCfg.SkipSuperseded,
+		StaleAfter:     queueCfg.StaleAfter,
+		APIURL:         buildAuthCfg.APIURL,
+	})
+	dispatchCtx, cancelDispatch := context.WithCancel(context.Background())
+	dispatchDone := make(chan struct{})
 
 	go func() {
-		log.Println("Starting Kafka consumer...")
-		consumer.StartConsumer(ctx, kafkaConfig, processor)
+		defer close(dispatchDone)
+		log.Println("Starting build dispatcher...")
+		dispatcher.Run(dispatchCtx)
 	}()
 
-	// Handle graceful shutdown
+	// Reconcile deployments with ECS task state change events, if a queue is configured
+	var (
+		cancelEvents context.CancelFunc
+		eventsDone   chan struct{}
+	)
+	if reconciler := newECSEventsReconciler(deploymentSvc); reconciler != nil {
+		var eventsCtx context.Context
+		eventsCtx, cancelEvents = context.WithCancel(context.Background())
+		eventsDone = make(chan struct{})
+
+		go func() {
+			defer close(eventsDone)
+			log.Println("Starting ECS event reconciler...")
+			reconciler.Run(eventsCtx)
+		}()
+	}
+
+	// Publish domain events from the outbox to the event bus. Without a bus
+	// the relay only prunes them.
+	eventsCfg := config.GetDomainEventsConfig()
+	relay := events.New(eventRepository.New(database), bus, events.Config{
+		Topic:        eventsCfg.Topic,
+		PollInterval: eventsCfg.PollInterval,
+		BatchSize:    eventsCfg.BatchSize,
+		Retention:    eventsCfg.Retention,
+	})
+	relayCtx, cancelRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+
 	go func() {
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-		<-sigChan
-		log.Println("Shutting down Kafka consumer...")
-		cancel()
+		defer close(relayDone)
+		if bus != nil {
+			log.Printf("Starting domain event relay to %s topic %s...", bus.Name(), eventsCfg.Topic)
+		}
+		relay.Run(relayCtx)
+	}()
+
+	// Repository and webhook URLs may only point to public addresses
+	gitURLCfg := config.GetGitURLConfig()
+	gitURLs := giturl.New(giturl.Config{
+		AllowedHosts:      gitURLCfg.AllowedHosts,
+		AllowPrivateHosts: gitURLCfg.AllowPrivateHosts,
+		AllowLocalPaths:   gitURLCfg.AllowLocalPaths,
+	})
+
+	// Store git URLs of projects created before URLs were normalized in
+	// canonical form, so duplicate detection covers them
+	backfillCtx, cancelBackfill := context.WithCancel(context.Background())
+	backfillDone := make(chan struct{})
+
+	go func() {
+		defer close(backfillDone)
+		result, err := gitURLs.Backfill(backfillCtx, projectRepo, 0)
+		if err != nil && backfillCtx.Err() == nil {
+			log.Printf("Failed to normalize project git URLs: %v", err)
+		}
+		if result.Normalized > 0 {
+			log.Printf("Normalized the git URLs of %d projects", result.Normalized)
+		}
+		for _, projectID := range result.Duplicates {
+			log.Printf("Warning: project %s has the same repository as another project of its owner", projectID)
+		}
+		for _, projectID := range result.Invalid {
+			log.Printf("Warning: project %s has a git URL that is no longer allowed; its deployments are refused", projectID)
+		}
 	}()
 
-	r := router.New(database)
+	// Send webhook deliveries
+	webhookCfg := config.GetWebhookConfig()
+	webhookWorker := webhook.New(webhookRepository.New(database), nil, webhook.Config{
+		PollInterval: webhookCfg.PollInterval,
+		BatchSize:    webhookCfg.BatchSize,
+		Timeout:      webhookCfg.Timeout,
+		Lease:        webhookCfg.Lease,
+		MaxAttempts:  webhookCfg.MaxAttempts,
+		RetryBackoff: webhookCfg.RetryBackoff,
+		MaxBackoff:   webhookCfg.MaxBackoff,
+		Retention:    webhookCfg.Retention,
+		Dial:         gitURLs.DialContext,
+	})
+	webhooksCtx, cancelWebhooks := context.WithCancel(context.Background())
+	webhooksDone := make(chan struct{})
+
+	go func() {
+		defer close(webhooksDone)
+		log.Println("Starting webhook worker...")
+		webhookWorker.Run(webhooksCtx)
+	}()
+
+	// Report deployments of commits back to the git provider. Reports follow
+	// the domain events, so they need an event bus.
+	var (
+		cancelGit context.CancelFunc
+		gitDone   chan struct{}
+	)
+	github := newGitHub(database)
+	if reporter := newGitReporter(deploymentRepo, github); reporter != nil {
+		if bus != nil {
+			gitCfg := config.GetGitProviderConfig()
+			var gitCtx context.Context
+			gitCtx, cancelGit = context.WithCancel(context.Background())
+			gitDone = make(chan struct{})
+
+			go func() {
+				defer close(gitDone)
+				log.Printf("Starting git status reporter on %s topic %s...", bus.Name(), eventsCfg.Topic)
+				if err := bus.Subscribe(gitCtx, eventsCfg.Topic, gitCfg.Group, reporter); err != nil {
+					log.Printf("Git status reporter stopped: %v", err)
+				}
+			}()
+		} else {
+			log.Println("Warning: Git status reporting needs an event bus; set EVENT_BUS")
+		}
+	}
+
+	r := router.New(database, logSvc, processor, newGitCredentials(database, github, gitURLs), gitURLs)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -79,20 +321,393 @@ func New() *App {
 		Handler: r,
 	}
 
-	return &App{
-		server:      server,
-		cancelKafka: cancel,
+	application := &App{
+		server:         server,
+		bus:            bus,
+		cancelBus:      cancelBus,
+		busDone:        busDone,
+		cancelDispatch: cancelDispatch,
+		dispatchDone:   dispatchDone,
+		cancelEvents:   cancelEvents,
+		eventsDone:     eventsDone,
+		cancelRelay:    cancelRelay,
+		relayDone:      relayDone,
+		cancelWebhooks: cancelWebhooks,
+		webhooksDone:   webhooksDone,
+		cancelBackfill: cancelBackfill,
+		backfillDone:   backfillDone,
+		cancelGit:      cancelGit,
+		gitDone:        gitDone,
+		cancelLogs:     cancelLogs,
+		logsDone:       logsDone,
+		logSvc:         logSvc,
+		stopped:        make(chan struct{}),
 	}
+
+	// Handle graceful shutdown
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+		<-sigChan
+		application.Shutdown()
+	}()
+
+	return application
 }
 
 func (a *App) Run() {
 	log.Println("Server starting on", a.server.Addr)
-	log.Fatal(a.server.ListenAndServe())
+	if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	// ListenAndServe returns as soon as Shutdown starts; wait for it to finish
+	<-a.stopped
 }
 
+// Shutdown stops consuming, flushes buffered logs so their offsets are
+// committed, then drains in-flight HTTP requests
 func (a *App) Shutdown() {
-	if a.cancelKafka != nil {
-		log.Println("Cancelling Kafka consumer context...")
-		a.cancelKafka()
+	defer close(a.stopped)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if a.cancelBus != nil {
+		log.Println("Shutting down build log consumer...")
+		a.cancelBus()
+		select {
+		case <-a.busDone:
+		case <-ctx.Done():
+			log.Println("Timed out waiting for build log consumer to stop")
+		}
+	}
+
+	if a.cancelDispatch != nil {
+		log.Println("Shutting down build dispatcher...")
+		a.cancelDispatch()
+		select {
+		case <-a.dispatchDone:
+		case <-ctx.Done():
+			log.Println("Timed out waiting for build dispatcher to stop")
+		}
+	}
+
+	if a.cancelEvents != nil {
+		log.Println("Shutting down ECS event reconciler...")
+		a.cancelEvents()
+		select {
+		case <-a.eventsDone:
+		case <-ctx.Done():
+			log.Println("Timed out waiting for ECS event reconciler to stop")
+		}
+	}
+
+	if a.cancelRelay != nil {
+		log.Println("Shutting down domain event relay...")
+		a.cancelRelay()
+		select {
+		case <-a.relayDone:
+		case <-ctx.Done():
+			log.Println("Timed out waiting for domain event relay to stop")
+		}
+	}
+
+	if a.cancelWebhooks != nil {
+		log.Println("Shutting down webhook worker...")
+		a.cancelWebhooks()
+		select {
+		case <-a.webhooksDone:
+		case <-ctx.Done():
+			log.Println("Timed out waiting for webhook worker to stop")
+		}
+	}
+
+	if a.cancelBackfill != nil {
+		a.cancelBackfill()
+		select {
+		case <-a.backfillDone:
+		case <-ctx.Done():
+			log.Println("Timed out waiting for git URL normalization to stop")
+		}
+	}
+
+	if a.cancelGit != nil {
+		log.Println("Shutting down git status reporter...")
+		a.cancelGit()
+		select {
+		case <-a.gitDone:
+		case <-ctx.Done():
+			log.Println("Timed out waiting for git status reporter to stop")
+		}
+	}
+
+	if a.cancelLogs != nil {
+		a.cancelLogs()
+		select {
+		case <-a.logsDone:
+		case <-ctx.Done():
+			log.Println("Timed out waiting for log partition maintenance to stop")
+		}
+	}
+
+	// In-flight requests, such as build events posted over HTTP, still
+	// write to the log service
+	if err := a.server.Shutdown(ctx); err != nil {
+		log.Printf("HTTP server shutdown error: %v", err)
+	}
+
+	if a.logSvc != nil {
+		if err := a.logSvc.Close(ctx); err != nil {
+			log.Printf("Failed to flush buffered logs: %v", err)
+		}
 	}
+
+	if a.bus != nil {
+		if err := a.bus.Close(); err != nil {
+			log.Printf("Failed to close event bus: %v", err)
+		}
+	}
+}
+
+// newEventBus creates the bus selected by EVENT_BUS, or nil if it is
+// disabled or cannot be initialized. Connecting happens in the background,
+// so an unreachable broker is retried rather than stopping the server.
+func newEventBus(cfg config.EventBusConfig) eventbus.Bus {
+	switch cfg.Backend {
+	case "kafka":
+		kafkaCfg := config.LoadKafkaConfig()
+		saramaCfg, err := config.NewSaramaConfig(kafkaCfg)
+		if err != nil {
+			log.Printf("Warning: Failed to configure Kafka event bus: %v", err)
+			return nil
+		}
+		return kafkabus.New(kafkaCfg.Brokers, saramaCfg, cfg.Workers)
+
+	case "redis":
+		bus, err := redisbus.NewFromURL(cfg.RedisURL, int64(cfg.RedisMaxLen))
+		if err != nil {
+			log.Printf("Warning: Failed to configure Redis event bus: %v", err)
+			return nil
+		}
+		return bus
+
+	case "memory":
+		return eventbus.NewMemoryBus()
+
+	case "none", "":
+		return nil
+
+	default:
+		log.Printf("Warning: Unknown EVENT_BUS %q", cfg.Backend)
+		return nil
+	}
+}
+
+// newGitHub creates the GitHub App client, or nil if no app is configured.
+// It is shared by commit status reporting and clone credentials, so both
+// reuse its cached installations. Both only use installations the project
+// owner linked.
+func newGitHub(database *sql.DB) *gitprovider.GitHub {
+	cfg := config.GetGitProviderConfig()
+	if cfg.GitHubAppID == 0 {
+		return nil
+	}
+
+	key, err := gitprovider.ParsePrivateKey(cfg.GitHubPrivateKey)
+	if err != nil {
+		log.Printf("Warning: Failed to load GITHUB_APP_PRIVATE_KEY: %v", err)
+		return nil
+	}
+
+	log.Printf("GitHub App %d configured", cfg.GitHubAppID)
+	if cfg.GitHubClientID == "" || cfg.GitHubClientSecret == "" {
+		log.Println("Warning: GITHUB_APP_CLIENT_ID or GITHUB_APP_CLIENT_SECRET is not set; users cannot link installations")
+	}
+	return gitprovider.NewGitHub(gitprovider.GitHubConfig{
+		AppID:        cfg.GitHubAppID,
+		PrivateKey:   key,
+		APIURL:       cfg.GitHubAPIURL,
+		Host:         cfg.GitHubHost,
+		WebURL:       cfg.GitHubWebURL,
+		ClientID:     cfg.GitHubClientID,
+		ClientSecret: cfg.GitHubClientSecret,
+		Slug:         cfg.GitHubAppSlug,
+		Links:        gitCredentialRepository.New(database),
+	}, nil)
+}
+
+// newGitReporter creates the reporter of deployment statuses to git
+// providers, or nil if no provider is configured
+func newGitReporter(deploymentRepo *repository.Repository, github *gitprovider.GitHub) *gitprovider.Reporter {
+	cfg := config.GetGitProviderConfig()
+
+	var providers []gitprovider.Provider
+	if github != nil {
+		providers = append(providers, github)
+	}
+	if len(providers) == 0 {
+		return nil
+	}
+
+	return gitprovider.New(deploymentRepo, providers, gitprovider.Config{
+		StatusContext: cfg.StatusContext,
+		PreviewURL:    cfg.PreviewURL,
+		Comments:      cfg.PullRequestComments,
+		MaxAttempts:   cfg.MaxAttempts,
+		RetryBackoff:  cfg.RetryBackoff,
+	})
+}
+
+// newGitCredentials creates the service builds get their clone credentials
+// from. Deploy keys need GIT_CREDENTIALS_KEY and github_app credentials need
+// the GitHub App; public repositories work without either.
+func newGitCredentials(database *sql.DB, github *gitprovider.GitHub, gitURLs *giturl.Validator) *gitcredentials.Service {
+	cfg := config.GetGitCredentialsConfig()
+
+	var cipher *encryption.Cipher
+	if cfg.EncryptionKey != "" {
+		var err error
+		if cipher, err = encryption.New(cfg.EncryptionKey); err != nil {
+			log.Printf("Warning: Invalid GIT_CREDENTIALS_KEY, deploy keys are disabled: %v", err)
+		}
+	} else {
+		log.Println("Warning: GIT_CREDENTIALS_KEY is not set; deploy keys are disabled")
+	}
+
+	return gitcredentials.New(gitCredentialRepository.New(database), cipher, github, gitcredentials.Config{
+		CheckAccess: cfg.CheckAccess,
+		Timeout:     cfg.CheckTimeout,
+		Dial:        gitURLs.DialContext,
+	})
+}
+
+// newBuildExecutor creates the backend selected by BUILD_EXECUTOR, or nil if
+// it cannot be initialized. The local executor feeds its output straight to
+// processor instead of going through the event bus.
+func newBuildExecutor(processor *consumer.Processor) executor.BuildExecutor {
+	cfg := config.GetExecutorConfig()
+
+	switch cfg.Backend {
+	case "ecs":
+		ecsService := newECSService()
+		if ecsService == nil {
+			return nil
+		}
+		return executor.NewECSExecutor(ecsService)
+
+	case "kubernetes":
+		client, err := executor.NewKubernetesClient(cfg.KubeConfig)
+		if err != nil {
+			log.Printf("Warning: Failed to create Kubernetes client: %v", err)
+			return nil
+		}
+		log.Printf("Kubernetes build executor initialized in namespace %s", cfg.K8sNamespace)
+		return executor.NewKubernetesExecutor(client, executor.KubernetesConfig{
+			Kubeconfig:              cfg.KubeConfig,
+			Namespace:               cfg.K8sNamespace,
+			Image:                   cfg.K8sImage,
+			ServiceAccount:          cfg.K8sServiceAccount,
+			TTLSecondsAfterFinished: int32(cfg.K8sJobTTLSeconds),
+			ActiveDeadlineSeconds:   int64(cfg.K8sActiveDeadline),
+		})
+
+	case "docker":
+		log.Printf("Docker build executor initialized with image %s", cfg.DockerImage)
+		return executor.NewDockerExecutor(executor.DockerConfig{
+			Binary:  cfg.DockerBinary,
+			Image:   cfg.DockerImage,
+			Network: cfg.DockerNetwork,
+		})
+
+	case "local":
+		log.Printf("Local build executor initialized; artifacts go to %s", cfg.LocalOriginDir)
+		return executor.NewLocalExecutor(executor.LocalConfig{
+			WorkDir:        cfg.LocalWorkDir,
+			OriginDir:      cfg.LocalOriginDir,
+			InstallCommand: cfg.LocalInstallCommand,
+			BuildCommand:   cfg.LocalBuildCommand,
+			OutputDir:      cfg.LocalOutputDir,
+			KeepWorkspace:  cfg.LocalKeepWorkspace,
+		}, func(ctx context.Context, event buildlog.Event) error {
+			return processor.HandleEvent(ctx, event, func(error) {})
+		})
+
+	default:
+		log.Printf("Warning: Unknown BUILD_EXECUTOR %q", cfg.Backend)
+		return nil
+	}
+}
+
+// newECSEventsReconciler creates the consumer of ECS task state change
+// events, or nil if ECS_EVENTS_QUEUE_URL is not set
+func newECSEventsReconciler(deploymentSvc *deployment.DeploymentService) *ecsevents.Reconciler {
+	cfg := config.GetECSEventsConfig()
+	if cfg.QueueURL == "" {
+		return nil
+	}
+
+	awsCfg, err := awsConfig.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Printf("Warning: Failed to load AWS config for ECS events: %v", err)
+		return nil
+	}
+
+	client := sqs.NewFromConfig(awsCfg, func(o *sqs.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+	queue := ecsevents.NewSQSQueue(client, cfg.QueueURL, cfg.WaitTime, cfg.MaxMessages)
+
+	// Task tags are looked up when the event does not carry them
+	var lookupTags ecsevents.TagLookup
+	if ecsService := newECSService(); ecsService != nil {
+		lookupTags = ecsService.TaskTags
+	}
+
+	return ecsevents.NewReconciler(queue, deploymentSvc, lookupTags)
+}
+
+// newECSService creates the ECS client used to run builds, or nil if AWS is
+// not configured
+func newECSService() *ecs.Service {
+	awsCfg, err := awsConfig.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Printf("Warning: Failed to load AWS config: %v", err)
+		return nil
+	}
+
+	// Parse subnets (comma-separated)
+	ecsConfig := config.GetECSConfig()
+	subnets := []string{}
+	if ecsConfig.Subnets != "" {
+		subnets = strings.Split(ecsConfig.Subnets, ",")
+		for i := range subnets {
+			subnets[i] = strings.TrimSpace(subnets[i])
+		}
+	}
+
+	log.Printf("ECS service initialized with config.")
+
+	service := ecs.New(
+		awsCfg,
+		ecsConfig.Cluster,
+		ecsConfig.TaskDefinition,
+		subnets,
+		ecsConfig.SecurityGroup,
+		ecsConfig.AssignPublicIP,
+		ecsConfig.LaunchType,
+		ecsConfig.ImageName,
+		ecsConfig.Count,
+	)
+
+	spotStrategy, err := ecs.ParseCapacityProviderStrategy(ecsConfig.SpotStrategy)
+	if err != nil {
+		// Spot builds run with the launch type instead
+		log.Printf("Warning: Invalid ECS_CAPACITY_PROVIDER_STRATEGY: %v", err)
+	}
+	service.SetCapacityProviders(spotStrategy, ecsConfig.OnDemandCapacityProvider)
+	service.SetTags(ecsConfig.Tags)
+
+	return service
 }
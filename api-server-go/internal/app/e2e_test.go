@@ -0,0 +1,322 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/apitoken"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/kafka/consumer"
+	apiTokenRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/apitoken"
+	repository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/deployment"
+	jobRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/job"
+	logsRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/logs"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/router"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/apitokens"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/buildauth"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/deployment"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/dispatch"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/failure"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/giturl"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/logs"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
+)
+
+const e2ePage = "<h1>hello from mini-vercel</h1>\n"
+
+// TestLocalDeployEndToEnd creates a project from a local directory through
+// the API, deploys it with the local executor, waits for READY and fetches
+// the page through the reverse proxy. It needs no network, only the
+// PostgreSQL database at E2E_TEST_POSTGRES_DSN; the reverse proxy is built
+// from ../reverse-proxy-go.
+func TestLocalDeployEndToEnd(t *testing.T) {
+	dsn := os.Getenv("E2E_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("E2E_TEST_POSTGRES_DSN is not set")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is needed to build the reverse proxy")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	database, dbURL := openE2EDatabase(t, dsn)
+
+	// The project: a static site whose build copies index.html to dist/
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "index.html"), []byte(e2ePage), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	origin := t.TempDir()
+
+	t.Setenv("BUILD_EXECUTOR", "local")
+	t.Setenv("LOCAL_BUILD_DIR", t.TempDir())
+	t.Setenv("LOCAL_ORIGIN_DIR", origin)
+	t.Setenv("LOCAL_INSTALL_COMMAND", "true")
+	t.Setenv("LOCAL_BUILD_COMMAND", "mkdir -p dist && cp index.html dist/")
+
+	// The same wiring as New, with the log store in memory and no event bus
+	logSvc := logs.New(logsRepository.NewMemoryStore())
+	deploymentRepo := repository.New(database)
+	deploymentSvc := deployment.NewDeploymentService(deploymentRepo)
+	processor := consumer.NewProcessor(deploymentSvc, logSvc, failure.NewClassifier(), nil)
+
+	dispatcher := dispatch.New(jobRepository.New(database), deploymentSvc, newBuildExecutor(processor), buildauth.New(deploymentRepo, 0), dispatch.Config{
+		PollInterval: 50 * time.Millisecond,
+		MaxAttempts:  1,
+	})
+	dispatchCtx, stopDispatch := context.WithCancel(ctx)
+	dispatchDone := make(chan struct{})
+	go func() {
+		defer close(dispatchDone)
+		dispatcher.Run(dispatchCtx)
+	}()
+	t.Cleanup(func() {
+		stopDispatch()
+		<-dispatchDone
+	})
+
+	gitURLs := giturl.New(giturl.Config{AllowLocalPaths: true})
+	api := httptest.NewServer(router.New(database, logSvc, processor, newGitCredentials(database, nil, gitURLs), gitURLs))
+	t.Cleanup(api.Close)
+
+	tokens := apitokens.New(apiTokenRepository.New(database))
+	token, err := tokens.Create(ctx, &apitoken.Token{
+		UserID: "e2e-user",
+		Name:   "e2e",
+		Scopes: []apitoken.Scope{apitoken.ProjectsWrite, apitoken.DeploymentsWrite},
+	})
+	if err != nil {
+		t.Fatalf("create API token: %v", err)
+	}
+
+	// create project → deploy
+	var project struct {
+		ID        string `json:"id"`
+		SubDomain string `json:"subDomain"`
+	}
+	e2eRequest(t, api.URL, token, http.MethodPost, "/projects", map[string]string{
+		"name":       "e2e",
+		"github_url": source,
+	}, http.StatusCreated, &project)
+
+	var deploy struct {
+		DeploymentID string `json:"deploymentId"`
+	}
+	e2eRequest(t, api.URL, token, http.MethodPost, "/deploy", map[string]string{
+		"project_id": project.ID,
+	}, http.StatusOK, &deploy)
+
+	// → READY
+	for {
+		var d struct {
+			Status string `json:"status"`
+		}
+		e2eRequest(t, api.URL, token, http.MethodGet, "/deployments/"+deploy.DeploymentID, nil, http.StatusOK, &d)
+		if d.Status == "READY" {
+			break
+		}
+		if d.Status == "FAIL" {
+			t.Fatalf("deployment failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			t.Fatalf("deployment is %s, want READY", d.Status)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// → fetch through the reverse proxy
+	proxyURL := startReverseProxy(t, ctx, dbURL, origin)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, proxyURL+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = project.SubDomain + ".localhost"
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("fetch through proxy: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != e2ePage {
+		t.Fatalf("proxy returned %d %q, want 200 %q", resp.StatusCode, body, e2ePage)
+	}
+}
+
+// e2eRequest calls the API with an API token and decodes the data of the
+// response into out
+func e2eRequest(t *testing.T, baseURL, token, method, path string, body any, wantStatus int, out any) {
+	t.Helper()
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, baseURL+path, reqBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("%s %s returned %d, want %d: %s", method, path, resp.StatusCode, wantStatus, raw)
+	}
+
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	if err := json.Unmarshal(envelope.Data, out); err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+}
+
+// openE2EDatabase applies every migration in a new schema and returns a
+// connection using it, with its URL for the reverse proxy. The schema is
+// dropped after the test.
+func openE2EDatabase(t *testing.T, dsn string) (*sql.DB, string) {
+	t.Helper()
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := "e2e_" + strings.ReplaceAll(utils.GenerateUUID(), "-", "")
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Logf("drop schema: %v", err)
+		}
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" {
+		t.Fatalf("E2E_TEST_POSTGRES_DSN must be a postgres:// URL")
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	dbURL := u.String()
+
+	database, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	migrations, err := filepath.Glob("../../migrations/*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(migrations)
+	for _, path := range migrations {
+		migration, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := database.Exec(string(migration)); err != nil {
+			t.Fatalf("apply %s: %v", filepath.Base(path), err)
+		}
+	}
+
+	return database, dbURL
+}
+
+// startReverseProxy builds and runs ../reverse-proxy-go serving from the
+// local origin and returns its URL
+func startReverseProxy(t *testing.T, ctx context.Context, dbURL, origin string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "reverse-proxy")
+	build := exec.CommandContext(ctx, "go", "build", "-o", binary, ".")
+	build.Dir = "../../../reverse-proxy-go"
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("build reverse proxy: %v\n%s", err, out)
+	}
+
+	// Pick a free port for the proxy
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	var output bytes.Buffer
+	proxy := exec.Command(binary)
+	// Run outside the source tree so a developer's .env is not loaded
+	proxy.Dir = dir
+	proxy.Env = append(os.Environ(),
+		"DATABASE_URL="+dbURL,
+		"LOCAL_ORIGIN_DIR="+origin,
+		fmt.Sprintf("PORT=%d", port),
+	)
+	proxy.Stdout = &output
+	proxy.Stderr = &output
+	if err := proxy.Start(); err != nil {
+		t.Fatalf("start reverse proxy: %v", err)
+	}
+	t.Cleanup(func() {
+		proxy.Process.Kill()
+		proxy.Wait()
+		if t.Failed() {
+			t.Logf("reverse proxy output:\n%s", output.String())
+		}
+	})
+
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return "http://" + addr
+		}
+
+		select {
+		case <-ctx.Done():
+			t.Fatalf("reverse proxy did not start: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
@@ -2,16 +2,26 @@ package client
 
 import (
 	"context"
-	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/logs"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
 )
 
-// ClickHouseAdapter adapts ClickHouse driver.Conn to implement common database interfaces
-// This allows the ClickHouse client to be used with generic repository interfaces
+// defaultRetentionDays matches the retention_days column default
+const defaultRetentionDays = 30
+
+// ClickHouseAdapter implements logs.LogStore on top of a ClickHouse connection
 type ClickHouseAdapter struct {
 	conn driver.Conn
+
+	mu   sync.Mutex
+	last time.Time
 }
 
 // NewClickHouseAdapter creates a new adapter wrapping a ClickHouse connection
@@ -21,78 +31,182 @@ func NewClickHouseAdapter(conn driver.Conn) *ClickHouseAdapter {
 	}
 }
 
-// QueryContext executes a query that returns rows
-func (a *ClickHouseAdapter) QueryContext(ctx context.Context, query string, args ...any) (logs.Rows, error) {
-	rows, err := a.conn.Query(ctx, query, args...)
-	if err != nil {
-		return nil, err
-	}
-	return &clickhouseRows{rows: rows}, nil
+// Conn returns the underlying ClickHouse connection
+func (a *ClickHouseAdapter) Conn() driver.Conn {
+	return a.conn
 }
 
-// QueryRowContext executes a query that is expected to return at most one row
-func (a *ClickHouseAdapter) QueryRowContext(ctx context.Context, query string, args ...any) logs.Row {
-	return &clickhouseRow{
-		conn:  a.conn,
-		ctx:   ctx,
-		query: query,
-		args:  args,
+// Append inserts a batch of log lines with the native batch API
+// Lines are ordered by received_at, which is assigned here and strictly
+// increases so lines keep their order within and across batches. timestamp
+// is a MATERIALIZED column set by the server; it only partitions the table.
+// retention_days drives the table TTL, so every row carries its plan's retention.
+func (a *ClickHouseAdapter) Append(ctx context.Context, events []buildlog.LogEvent) error {
+	if len(events) == 0 {
+		return nil
 	}
-}
 
-// ExecContext executes a query without returning any rows
-func (a *ClickHouseAdapter) ExecContext(ctx context.Context, query string, args ...any) (logs.Result, error) {
-	err := a.conn.Exec(ctx, query, args...)
+	batch, err := a.conn.PrepareBatch(ctx, "INSERT INTO log_events (event_id, deployment_id, project_id, log, stream, retention_days, redacted, received_at)")
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	for _, e := range events {
+		eventID := e.EventID
+		if eventID == "" {
+			eventID = utils.GenerateUUID()
+		}
+		stream := e.Stream
+		if stream == "" {
+			stream = buildlog.StreamStdout
+		}
+		retention := e.RetentionDays
+		if retention <= 0 {
+			retention = defaultRetentionDays
+		}
+		receivedAt := a.receivedAt()
+		if e.Timestamp != nil {
+			receivedAt = e.Timestamp.UTC()
+		}
+		if err := batch.Append(eventID, e.DeploymentID, e.ProjectID, e.Log, string(stream), uint16(retention), e.Redacted, receivedAt); err != nil {
+			_ = batch.Abort()
+			return fmt.Errorf("failed to append to batch: %w", err)
+		}
 	}
-	return &clickhouseResult{}, nil
-}
 
-// clickhouseRows wraps driver.Rows
-type clickhouseRows struct {
-	rows driver.Rows
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send batch: %w", err)
+	}
+
+	return nil
 }
 
-func (r *clickhouseRows) Close() error {
-	return r.rows.Close()
+// receivedAt returns the current time, strictly after the previous call
+func (a *ClickHouseAdapter) receivedAt() time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now().UTC()
+	if !now.After(a.last) {
+		now = a.last.Add(time.Nanosecond)
+	}
+	a.last = now
+	return now
 }
 
-func (r *clickhouseRows) Next() bool {
-	return r.rows.Next()
+// Query returns matching lines in ascending order
+func (a *ClickHouseAdapter) Query(ctx context.Context, deploymentID string, filter logs.Filter) ([]buildlog.LogEvent, error) {
+	where, args := clickhouseWhere(deploymentID, filter, true)
+
+	query := `
+		SELECT event_id, deployment_id, project_id, log, stream, redacted, received_at
+		FROM log_events
+		WHERE ` + where + `
+		ORDER BY received_at ASC, event_id ASC`
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	return a.scan(ctx, query, args...)
 }
 
-func (r *clickhouseRows) Scan(dest ...any) error {
-	return r.rows.Scan(dest...)
+// Tail returns the last n matching lines in ascending order
+func (a *ClickHouseAdapter) Tail(ctx context.Context, deploymentID string, filter logs.Filter, n int) ([]buildlog.LogEvent, error) {
+	where, args := clickhouseWhere(deploymentID, filter, false)
+	args = append(args, n)
+
+	query := `
+		SELECT * FROM (
+			SELECT event_id, deployment_id, project_id, log, stream, redacted, received_at
+			FROM log_events
+			WHERE ` + where + `
+			ORDER BY received_at DESC, event_id DESC
+			LIMIT ?
+		)
+		ORDER BY received_at ASC, event_id ASC`
+
+	return a.scan(ctx, query, args...)
 }
 
-func (r *clickhouseRows) Err() error {
-	return r.rows.Err()
+// Count returns the number of stored lines for a deployment
+func (a *ClickHouseAdapter) Count(ctx context.Context, deploymentID string) (int64, error) {
+	var count uint64
+	err := a.conn.QueryRow(ctx, `SELECT count() FROM log_events WHERE deployment_id = ?`, deploymentID).Scan(&count)
+	return int64(count), err
 }
 
-// clickhouseRow implements Row interface for single row queries
-type clickhouseRow struct {
-	conn  driver.Conn
-	ctx   context.Context
-	query string
-	args  []any
+// DeleteByDeployment removes every line of a deployment
+// ClickHouse applies ALTER ... DELETE asynchronously as a mutation
+func (a *ClickHouseAdapter) DeleteByDeployment(ctx context.Context, deploymentID string) error {
+	return a.conn.Exec(ctx, `ALTER TABLE log_events DELETE WHERE deployment_id = ?`, deploymentID)
 }
 
-func (r *clickhouseRow) Scan(dest ...any) error {
-	row := r.conn.QueryRow(r.ctx, r.query, r.args...)
-	return row.Scan(dest...)
+// DeleteByProject removes every line of a project and of deploymentIDs.
+// Lines written before migration 0003 have an empty project_id and are
+// matched by deployment only.
+func (a *ClickHouseAdapter) DeleteByProject(ctx context.Context, projectID string, deploymentIDs []string) error {
+	if len(deploymentIDs) == 0 {
+		return a.conn.Exec(ctx, `ALTER TABLE log_events DELETE WHERE project_id = ?`, projectID)
+	}
+	return a.conn.Exec(ctx,
+		`ALTER TABLE log_events DELETE WHERE project_id = ? OR deployment_id IN (?)`,
+		projectID, deploymentIDs)
 }
 
-// clickhouseResult implements Result interface
-type clickhouseResult struct{}
+func (a *ClickHouseAdapter) scan(ctx context.Context, query string, args ...any) ([]buildlog.LogEvent, error) {
+	rows, err := a.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]buildlog.LogEvent, 0)
+	for rows.Next() {
+		var e buildlog.LogEvent
+		var ts time.Time
+		if err := rows.Scan(&e.EventID, &e.DeploymentID, &e.ProjectID, &e.Log, &e.Stream, &e.Redacted, &ts); err != nil {
+			return nil, err
+		}
+		ts = ts.UTC()
+		e.Timestamp = &ts
+		out = append(out, e)
+	}
 
-func (r *clickhouseResult) LastInsertId() (int64, error) {
-	// ClickHouse doesn't support LastInsertId
-	return 0, sql.ErrNoRows
+	return out, rows.Err()
 }
 
-func (r *clickhouseResult) RowsAffected() (int64, error) {
-	// ClickHouse doesn't provide affected rows count in the same way
-	// This could be enhanced if needed
-	return 0, nil
+// clickhouseWhere renders a filter as a WHERE clause.
+// Timestamps are bound as Unix nanoseconds so sub-second cursors stay exact.
+func clickhouseWhere(deploymentID string, filter logs.Filter, withCursor bool) (string, []any) {
+	clauses := []string{"deployment_id = ?"}
+	args := []any{deploymentID}
+
+	if withCursor && filter.After != nil {
+		clauses = append(clauses, "(received_at, event_id) > (fromUnixTimestamp64Nano(?), ?)")
+		args = append(args, filter.After.Timestamp.UnixNano(), filter.After.EventID)
+	}
+	if filter.Since != nil {
+		clauses = append(clauses, "received_at >= fromUnixTimestamp64Nano(?)")
+		args = append(args, filter.Since.UnixNano())
+	}
+	if filter.Until != nil {
+		clauses = append(clauses, "received_at <= fromUnixTimestamp64Nano(?)")
+		args = append(args, filter.Until.UnixNano())
+	}
+	if filter.Stream != "" {
+		clauses = append(clauses, "stream = ?")
+		args = append(args, string(filter.Stream))
+	}
+	if filter.Search != "" {
+		if filter.Regex {
+			clauses = append(clauses, "match(log, ?)")
+			args = append(args, filter.Search)
+		} else {
+			clauses = append(clauses, "log ILIKE ?")
+			args = append(args, "%"+logs.EscapeLike(filter.Search)+"%")
+		}
+	}
+
+	return strings.Join(clauses, " AND "), args
 }
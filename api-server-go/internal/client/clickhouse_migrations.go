@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// clickhouseMigration is one versioned schema file
+type clickhouseMigration struct {
+	version uint32
+	name    string
+	sql     string
+}
+
+// MigrateClickHouse applies every migration in fsys that has not been
+// recorded in schema_migrations yet, in version order.
+// Migrations must be idempotent (IF NOT EXISTS) since two API servers
+// starting at once may both apply the same version.
+func MigrateClickHouse(ctx context.Context, conn driver.Conn, fsys fs.FS) error {
+	if err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version UInt32,
+			name String,
+			applied_at DateTime DEFAULT now()
+		) ENGINE = ReplacingMergeTree()
+		ORDER BY version
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	migrations, err := loadClickHouseMigrations(fsys)
+	if err != nil {
+		return err
+	}
+
+	applied := make(map[uint32]bool)
+	rows, err := conn.Query(ctx, `SELECT DISTINCT version FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	for rows.Next() {
+		var v uint32
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[v] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+
+		for _, stmt := range splitStatements(m.sql) {
+			if err := conn.Exec(ctx, stmt); err != nil {
+				return fmt.Errorf("migration %s failed: %w", m.name, err)
+			}
+		}
+
+		if err := conn.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.version, m.name); err != nil {
+			return fmt.Errorf("failed to record migration %s: %w", m.name, err)
+		}
+		log.Printf("Applied ClickHouse migration %s", m.name)
+	}
+
+	return nil
+}
+
+// loadClickHouseMigrations reads NNNN_name.up.sql files sorted by version
+func loadClickHouseMigrations(fsys fs.FS) ([]clickhouseMigration, error) {
+	files, err := fs.Glob(fsys, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]clickhouseMigration, 0, len(files))
+	for _, file := range files {
+		prefix, _, ok := strings.Cut(path.Base(file), "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: missing version prefix", file)
+		}
+		version, err := strconv.ParseUint(prefix, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", file, err)
+		}
+
+		body, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, clickhouseMigration{
+			version: uint32(version),
+			name:    strings.TrimSuffix(file, ".up.sql"),
+			sql:     string(body),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version == migrations[i-1].version {
+			return nil, fmt.Errorf("duplicate migration version %d", migrations[i].version)
+		}
+	}
+
+	return migrations, nil
+}
+
+// splitStatements splits a migration file on semicolons, dropping comment
+// lines, since the ClickHouse driver executes one statement per call
+func splitStatements(sql string) []string {
+	var lines []string
+	for _, line := range strings.Split(sql, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	var stmts []string
+	for _, stmt := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
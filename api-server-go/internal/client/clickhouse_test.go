@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/logs"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/logs/logstoretest"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
+	chmigrations "github.com/ujjwalkirti/mini-vercel-api-server/migrations/clickhouse"
+)
+
+// TestClickHouseAdapter runs the log store suite in a new database of the
+// server at LOGSTORE_TEST_CLICKHOUSE_DSN, e.g. clickhouse://localhost:9000
+func TestClickHouseAdapter(t *testing.T) {
+	dsn := os.Getenv("LOGSTORE_TEST_CLICKHOUSE_DSN")
+	if dsn == "" {
+		t.Skip("LOGSTORE_TEST_CLICKHOUSE_DSN is not set")
+	}
+
+	opts, err := clickhouse.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	admin, err := clickhouse.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	database := "logstoretest_" + strings.ReplaceAll(utils.GenerateUUID(), "-", "")
+	if err := admin.Exec(ctx, `CREATE DATABASE `+database); err != nil {
+		t.Fatalf("create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := admin.Exec(context.Background(), `DROP DATABASE `+database); err != nil {
+			t.Logf("drop database: %v", err)
+		}
+	})
+
+	opts.Auth.Database = database
+	conn, err := clickhouse.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if err := MigrateClickHouse(ctx, conn, chmigrations.FS); err != nil {
+		t.Fatalf("MigrateClickHouse: %v", err)
+	}
+
+	logstoretest.Run(t, func(t *testing.T) logs.LogStore {
+		return NewClickHouseAdapter(conn)
+	})
+}
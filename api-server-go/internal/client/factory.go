@@ -1,9 +1,14 @@
 package client
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"time"
 
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/config"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/logs"
+	chmigrations "github.com/ujjwalkirti/mini-vercel-api-server/migrations/clickhouse"
 )
 
 // LogRepositoryType represents the type of log repository to create
@@ -12,24 +17,45 @@ type LogRepositoryType string
 const (
 	// ClickHouseRepository uses ClickHouse for log storage
 	ClickHouseRepository LogRepositoryType = "clickhouse"
-	// PostgresRepository uses PostgreSQL for log storage (future implementation)
+	// PostgresRepository uses the application's PostgreSQL database for log storage
 	PostgresRepository LogRepositoryType = "postgres"
+	// MemoryRepository keeps logs in process memory (development and tests)
+	MemoryRepository LogRepositoryType = "memory"
 )
 
-// NewLogRepository creates a new log repository based on the specified type
+// NewLogStore creates a new log store based on the specified type
 // This factory pattern allows easy switching between different storage backends
-func NewLogRepository(repoType LogRepositoryType) (logs.Repository, error) {
+// database is only used by the postgres backend and may be nil otherwise
+func NewLogStore(repoType LogRepositoryType, database *sql.DB) (logs.LogStore, error) {
 	switch repoType {
 	case ClickHouseRepository:
 		client, err := NewClickHouseClient()
 		if err != nil {
 			return nil, fmt.Errorf("failed to create clickhouse client: %w", err)
 		}
+		if config.GetClickHouseConfig().AutoMigrate {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			if err := MigrateClickHouse(ctx, client.GetConn(), chmigrations.FS); err != nil {
+				return nil, fmt.Errorf("failed to migrate clickhouse schema: %w", err)
+			}
+		}
 		return NewClickHouseAdapter(client.GetConn()), nil
 
 	case PostgresRepository:
-		// Future implementation for PostgreSQL WAL or other backends
-		return nil, fmt.Errorf("postgres repository not yet implemented")
+		if database == nil {
+			return nil, fmt.Errorf("postgres log repository requires a database connection")
+		}
+		store := logs.NewPostgresStore(database)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := store.EnsurePartitions(ctx, time.Now(), logs.PartitionMonthsAhead); err != nil {
+			return nil, fmt.Errorf("failed to prepare log partitions: %w", err)
+		}
+		return store, nil
+
+	case MemoryRepository:
+		return logs.NewMemoryStore(), nil
 
 	default:
 		return nil, fmt.Errorf("unsupported repository type: %s", repoType)
@@ -0,0 +1,43 @@
+package config
+
+import "time"
+
+// BuildAuthConfig controls the credentials build containers receive
+type BuildAuthConfig struct {
+	// TokenTTL bounds a build token even if its deployment never finishes
+	TokenTTL time.Duration
+	// UploadURLTTL is how long a presigned upload URL is valid
+	UploadURLTTL time.Duration
+	// APIURL is where build containers reach this server
+	APIURL string
+}
+
+// GetBuildAuthConfig returns build credential configuration from environment variables
+func GetBuildAuthConfig() BuildAuthConfig {
+	return BuildAuthConfig{
+		TokenTTL:     getEnvAsDuration("BUILD_TOKEN_TTL", 2*time.Hour),
+		UploadURLTTL: getEnvAsDuration("BUILD_UPLOAD_URL_TTL", 15*time.Minute),
+		APIURL:       getEnvOrDefault("BUILDER_API_URL", "http://localhost:8080"),
+	}
+}
+
+// R2Config locates the artifact bucket
+type R2Config struct {
+	AccountID       string
+	AccessKeyID     string
+	SecretAccessKey string
+	BucketName      string
+	// Endpoint overrides the R2 endpoint, e.g. for MinIO
+	Endpoint string
+}
+
+// GetR2Config returns artifact bucket configuration from environment variables
+func GetR2Config() R2Config {
+	return R2Config{
+		AccountID:       getEnvOrDefault("R2_ACCOUNT_ID", ""),
+		AccessKeyID:     getEnvOrDefault("R2_ACCESS_KEY_ID", ""),
+		SecretAccessKey: getEnvOrDefault("R2_SECRET_ACCESS_KEY", ""),
+		BucketName:      getEnvOrDefault("R2_BUCKET_NAME", ""),
+		Endpoint:        getEnvOrDefault("R2_ENDPOINT", ""),
+	}
+}
@@ -11,6 +11,8 @@ type ClickHouseConfig struct {
 	Database string
 	Username string
 	Password string
+	// AutoMigrate applies the embedded schema migrations on startup
+	AutoMigrate bool
 }
 
 // GetClickHouseConfig returns ClickHouse configuration from environment variables
@@ -22,5 +24,7 @@ func GetClickHouseConfig() ClickHouseConfig {
 		Database: os.Getenv("CLICKHOUSE_DATABASE"),
 		Username: os.Getenv("CLICKHOUSE_USERNAME"),
 		Password: os.Getenv("CLICKHOUSE_PASSWORD"),
+
+		AutoMigrate: getEnvOrDefault("CLICKHOUSE_AUTO_MIGRATE", "true") == "true",
 	}
 }
@@ -0,0 +1,65 @@
+package config
+
+import "time"
+
+// DispatcherConfig controls the loop that starts builds from the outbox
+type DispatcherConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	Lease        time.Duration
+}
+
+// GetDispatcherConfig returns build dispatcher configuration from environment variables
+func GetDispatcherConfig() DispatcherConfig {
+	return DispatcherConfig{
+		PollInterval: getEnvAsDuration("DISPATCH_POLL_INTERVAL", time.Second),
+		BatchSize:    getEnvAsInt("DISPATCH_BATCH_SIZE", 10),
+		MaxAttempts:  getEnvAsInt("DISPATCH_MAX_ATTEMPTS", 5),
+		RetryBackoff: getEnvAsDuration("DISPATCH_RETRY_BACKOFF", 5*time.Second),
+		Lease:        getEnvAsDuration("DISPATCH_LEASE", 2*time.Minute),
+	}
+}
+
+// BuildQueueConfig limits how many builds run at once. A limit of 0 means
+// unlimited.
+type BuildQueueConfig struct {
+	GlobalLimit    int
+	UserLimit      int
+	ProjectLimit   int
+	SkipSuperseded bool
+	StaleAfter     time.Duration
+}
+
+// GetBuildQueueConfig returns build queue configuration from environment variables
+func GetBuildQueueConfig() BuildQueueConfig {
+	return BuildQueueConfig{
+		GlobalLimit:    getEnvAsInt("BUILD_QUEUE_GLOBAL_LIMIT", 20),
+		UserLimit:      getEnvAsInt("BUILD_QUEUE_USER_LIMIT", 3),
+		ProjectLimit:   getEnvAsInt("BUILD_QUEUE_PROJECT_LIMIT", 1),
+		SkipSuperseded: getEnvOrDefault("BUILD_QUEUE_SKIP_SUPERSEDED", "true") == "true",
+		StaleAfter:     getEnvAsDuration("BUILD_QUEUE_STALE_AFTER", time.Hour),
+	}
+}
+
+// BuildResourceConfig holds the build defaults of projects that do not set
+// their own
+type BuildResourceConfig struct {
+	// PlanPresets is the build preset of each plan
+	PlanPresets map[string]string
+	// UseSpot runs builds on Spot capacity
+	UseSpot bool
+}
+
+// GetBuildResourceConfig returns build resource defaults from environment variables
+func GetBuildResourceConfig() BuildResourceConfig {
+	return BuildResourceConfig{
+		PlanPresets: map[string]string{
+			"hobby":      getEnvOrDefault("BUILD_PRESET_HOBBY", "small"),
+			"pro":        getEnvOrDefault("BUILD_PRESET_PRO", "medium"),
+			"enterprise": getEnvOrDefault("BUILD_PRESET_ENTERPRISE", "large"),
+		},
+		UseSpot: getEnvOrDefault("BUILD_USE_SPOT", "false") == "true",
+	}
+}
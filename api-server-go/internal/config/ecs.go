@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type ECSConfig struct {
@@ -14,6 +16,15 @@ type ECSConfig struct {
 	ImageName      string
 	LaunchType     string
 	Count          int
+
+	// SpotStrategy is the capacity provider strategy of Spot builds, as
+	// name:weight[:base] entries; empty disables Spot
+	SpotStrategy string
+	// OnDemandCapacityProvider runs Spot builds that found no capacity;
+	// empty falls back to the launch type
+	OnDemandCapacityProvider string
+	// Tags are static key=value pairs added to every task
+	Tags map[string]string
 }
 
 func getEnvOrDefault(key, defaultValue string) string {
@@ -42,5 +53,42 @@ func GetECSConfig() ECSConfig {
 		ImageName:      getEnvOrDefault("ECS_IMAGE_NAME", ""),
 		LaunchType:     getEnvOrDefault("ECS_LAUNCH_TYPE", "FARGATE"),
 		Count:          getEnvAsInt("ECS_COUNT", 1),
+
+		SpotStrategy:             getEnvOrDefault("ECS_CAPACITY_PROVIDER_STRATEGY", "FARGATE_SPOT:1"),
+		OnDemandCapacityProvider: getEnvOrDefault("ECS_ONDEMAND_CAPACITY_PROVIDER", ""),
+		Tags:                     getEnvAsMap("ECS_TAGS"),
+	}
+}
+
+// getEnvAsMap parses comma-separated key=value pairs
+func getEnvAsMap(key string) map[string]string {
+	values := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && k != "" {
+			values[k] = v
+		}
+	}
+	return values
+}
+
+// ECSEventsConfig configures the queue of ECS task state change events
+type ECSEventsConfig struct {
+	// QueueURL is the SQS queue fed by the EventBridge rule; empty disables
+	// reconciliation
+	QueueURL string
+	// Endpoint overrides the SQS endpoint, e.g. for LocalStack or ElasticMQ
+	Endpoint    string
+	WaitTime    time.Duration
+	MaxMessages int
+}
+
+// GetECSEventsConfig returns ECS event queue configuration from environment variables
+func GetECSEventsConfig() ECSEventsConfig {
+	return ECSEventsConfig{
+		QueueURL:    getEnvOrDefault("ECS_EVENTS_QUEUE_URL", ""),
+		Endpoint:    getEnvOrDefault("ECS_EVENTS_SQS_ENDPOINT", ""),
+		WaitTime:    getEnvAsDuration("ECS_EVENTS_WAIT_TIME", 20*time.Second),
+		MaxMessages: getEnvAsInt("ECS_EVENTS_MAX_MESSAGES", 10),
 	}
 }
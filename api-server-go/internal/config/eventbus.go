@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// EventBusConfig selects the event bus and the names the server uses on it
+type EventBusConfig struct {
+	// Backend is "kafka", "redis", "memory" or "none"
+	Backend string
+	// Workers bounds how many messages of a subscription are handled at once
+	Workers int
+
+	BuildLogsTopic string
+	BuildLogsGroup string
+
+	RedisURL string
+	// RedisMaxLen trims streams to about this many entries; 0 keeps all
+	RedisMaxLen int
+}
+
+// GetEventBusConfig returns event bus configuration from environment variables
+func GetEventBusConfig() EventBusConfig {
+	// Installs that predate EVENT_BUS consume from Kafka when it is configured
+	backend := "none"
+	if os.Getenv("KAFKA_BROKERS") != "" {
+		backend = "kafka"
+	}
+
+	return EventBusConfig{
+		Backend:        getEnvOrDefault("EVENT_BUS", backend),
+		Workers:        getEnvAsInt("EVENT_BUS_WORKERS", 50),
+		BuildLogsTopic: getBuildLogsTopic(),
+		BuildLogsGroup: getEnvOrDefault("BUILD_LOGS_GROUP", "mini-vercel-build-logs-go"),
+		RedisURL:       getEnvOrDefault("REDIS_URL", "redis://localhost:6379/0"),
+		RedisMaxLen:    getEnvAsInt("REDIS_STREAM_MAXLEN", 1000000),
+	}
+}
+
+// getBuildLogsTopic is the topic builds publish logs to and the server
+// consumes them from
+func getBuildLogsTopic() string {
+	return getEnvOrDefault("BUILD_LOGS_TOPIC", "mini-vercel-build-logs")
+}
+
+// DomainEventsConfig controls the relay that publishes domain events from
+// the outbox
+type DomainEventsConfig struct {
+	Topic        string
+	PollInterval time.Duration
+	BatchSize    int
+	// Retention is how long events stay in the outbox after they occur
+	Retention time.Duration
+}
+
+// GetDomainEventsConfig returns domain event configuration from environment variables
+func GetDomainEventsConfig() DomainEventsConfig {
+	return DomainEventsConfig{
+		Topic:        getEnvOrDefault("DOMAIN_EVENTS_TOPIC", "mini-vercel-domain-events"),
+		PollInterval: getEnvAsDuration("DOMAIN_EVENTS_POLL_INTERVAL", time.Second),
+		BatchSize:    getEnvAsInt("DOMAIN_EVENTS_BATCH_SIZE", 100),
+		Retention:    getEnvAsDuration("DOMAIN_EVENTS_RETENTION", 7*24*time.Hour),
+	}
+}
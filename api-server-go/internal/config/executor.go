@@ -0,0 +1,50 @@
+package config
+
+// ExecutorConfig selects and configures the backend that runs builds
+type ExecutorConfig struct {
+	// Backend is one of "ecs", "kubernetes", "docker" or "local"
+	Backend string
+
+	KubeConfig        string
+	K8sNamespace      string
+	K8sImage          string
+	K8sServiceAccount string
+	K8sJobTTLSeconds  int
+	K8sActiveDeadline int
+
+	DockerBinary  string
+	DockerImage   string
+	DockerNetwork string
+
+	LocalWorkDir        string
+	LocalOriginDir      string
+	LocalInstallCommand string
+	LocalBuildCommand   string
+	LocalOutputDir      string
+	LocalKeepWorkspace  bool
+}
+
+// GetExecutorConfig returns build executor configuration from environment variables
+func GetExecutorConfig() ExecutorConfig {
+	return ExecutorConfig{
+		Backend: getEnvOrDefault("BUILD_EXECUTOR", "ecs"),
+
+		KubeConfig:        getEnvOrDefault("K8S_KUBECONFIG", ""),
+		K8sNamespace:      getEnvOrDefault("K8S_NAMESPACE", "default"),
+		K8sImage:          getEnvOrDefault("K8S_BUILD_IMAGE", ""),
+		K8sServiceAccount: getEnvOrDefault("K8S_SERVICE_ACCOUNT", ""),
+		K8sJobTTLSeconds:  getEnvAsInt("K8S_JOB_TTL_SECONDS", 3600),
+		K8sActiveDeadline: getEnvAsInt("K8S_ACTIVE_DEADLINE_SECONDS", 0),
+
+		DockerBinary:  getEnvOrDefault("DOCKER_BINARY", "docker"),
+		DockerImage:   getEnvOrDefault("DOCKER_BUILD_IMAGE", "mini-vercel-builder:latest"),
+		DockerNetwork: getEnvOrDefault("DOCKER_NETWORK", ""),
+
+		LocalWorkDir:        getEnvOrDefault("LOCAL_BUILD_DIR", ""),
+		LocalOriginDir:      getEnvOrDefault("LOCAL_ORIGIN_DIR", "./local-origin"),
+		LocalInstallCommand: getEnvOrDefault("LOCAL_INSTALL_COMMAND", "npm install"),
+		LocalBuildCommand:   getEnvOrDefault("LOCAL_BUILD_COMMAND", "npm run build"),
+		LocalOutputDir:      getEnvOrDefault("LOCAL_OUTPUT_DIR", "dist"),
+		LocalKeepWorkspace:  getEnvOrDefault("LOCAL_KEEP_WORKSPACE", "false") == "true",
+	}
+}
@@ -0,0 +1,24 @@
+package config
+
+import "time"
+
+// GitCredentialsConfig controls how builds authenticate to private
+// repositories
+type GitCredentialsConfig struct {
+	// EncryptionKey is the base64 AES-256 key deploy keys are encrypted
+	// with; deploy keys are disabled without it
+	EncryptionKey string
+	// CheckAccess checks that a repository can be read when a project is
+	// created or its credential changes
+	CheckAccess  bool
+	CheckTimeout time.Duration
+}
+
+// GetGitCredentialsConfig returns git credential configuration from environment variables
+func GetGitCredentialsConfig() GitCredentialsConfig {
+	return GitCredentialsConfig{
+		EncryptionKey: getEnvOrDefault("GIT_CREDENTIALS_KEY", ""),
+		CheckAccess:   getEnvOrDefault("GIT_CHECK_ACCESS", "true") == "true",
+		CheckTimeout:  getEnvAsDuration("GIT_CHECK_TIMEOUT", 10*time.Second),
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+// GitProviderConfig controls reporting deployments back to the git host
+type GitProviderConfig struct {
+	// Group is the consumer group on the domain events topic
+	Group         string
+	StatusContext string
+	// PreviewURL is the URL of a ready deployment; {subdomain} is replaced
+	PreviewURL          string
+	PullRequestComments bool
+	MaxAttempts         int
+	RetryBackoff        time.Duration
+
+	// GitHub App; reporting to GitHub is disabled without an app ID
+	GitHubAppID int64
+	// GitHubPrivateKey is the app's PEM private key or the path of its file
+	GitHubPrivateKey string
+	GitHubAPIURL     string
+	GitHubHost       string
+	// GitHubWebURL is where users authorize the app; empty means
+	// https://{GitHubHost}
+	GitHubWebURL string
+	// The app's OAuth client, for users to link installations
+	GitHubClientID     string
+	GitHubClientSecret string
+	GitHubAppSlug      string
+}
+
+// GetGitProviderConfig returns git provider configuration from environment variables
+func GetGitProviderConfig() GitProviderConfig {
+	appID, _ := strconv.ParseInt(getEnvOrDefault("GITHUB_APP_ID", "0"), 10, 64)
+
+	return GitProviderConfig{
+		Group:               getEnvOrDefault("GIT_STATUS_GROUP", "mini-vercel-git-status"),
+		StatusContext:       getEnvOrDefault("GIT_STATUS_CONTEXT", "mini-vercel"),
+		PreviewURL:          getEnvOrDefault("PREVIEW_URL_TEMPLATE", "http://{subdomain}.localhost:8001"),
+		PullRequestComments: getEnvOrDefault("GIT_PR_COMMENTS", "true") == "true",
+		MaxAttempts:         getEnvAsInt("GIT_STATUS_MAX_ATTEMPTS", 3),
+		RetryBackoff:        getEnvAsDuration("GIT_STATUS_RETRY_BACKOFF", 2*time.Second),
+		GitHubAppID:         appID,
+		GitHubPrivateKey:    getEnvOrDefault("GITHUB_APP_PRIVATE_KEY", ""),
+		GitHubAPIURL:        getEnvOrDefault("GITHUB_API_URL", "https://api.github.com"),
+		GitHubHost:          getEnvOrDefault("GITHUB_HOST", "github.com"),
+		GitHubWebURL:        getEnvOrDefault("GITHUB_WEB_URL", ""),
+		GitHubClientID:      getEnvOrDefault("GITHUB_APP_CLIENT_ID", ""),
+		GitHubClientSecret:  getEnvOrDefault("GITHUB_APP_CLIENT_SECRET", ""),
+		GitHubAppSlug:       getEnvOrDefault("GITHUB_APP_SLUG", ""),
+	}
+}
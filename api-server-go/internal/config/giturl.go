@@ -0,0 +1,32 @@
+package config
+
+import "strings"
+
+// GitURLConfig controls which repository URLs projects may use
+type GitURLConfig struct {
+	// AllowedHosts are the git hosts repositories may be on; "*" allows any
+	// host that resolves to a public address
+	AllowedHosts []string
+	// AllowPrivateHosts allows hosts that resolve to private, loopback or
+	// link-local addresses, e.g. a git server on the internal network
+	AllowPrivateHosts bool
+	// AllowLocalPaths allows absolute paths on the build host, for the local
+	// executor
+	AllowLocalPaths bool
+}
+
+// GetGitURLConfig returns repository URL validation configuration from environment variables
+func GetGitURLConfig() GitURLConfig {
+	var hosts []string
+	for _, host := range strings.Split(getEnvOrDefault("GIT_ALLOWED_HOSTS", "github.com,gitlab.com,bitbucket.org"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return GitURLConfig{
+		AllowedHosts:      hosts,
+		AllowPrivateHosts: getEnvOrDefault("GIT_ALLOW_PRIVATE_HOSTS", "false") == "true",
+		AllowLocalPaths:   getEnvOrDefault("GIT_ALLOW_LOCAL_PATHS", "false") == "true",
+	}
+}
@@ -10,6 +10,18 @@ type KafkaConfig struct {
 	ClientID string
 	Username string
 	Password string
+
+	// SecurityProtocol is PLAINTEXT, SSL, SASL_PLAINTEXT or SASL_SSL
+	SecurityProtocol string
+	// SASLMechanism is PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512
+	SASLMechanism string
+
+	// CA, ClientCert and ClientKey are inline PEM or PEM file paths. A
+	// client certificate enables mTLS.
+	CA            string
+	ClientCert    string
+	ClientKey     string
+	TLSServerName string
 }
 
 func LoadKafkaConfig() KafkaConfig {
@@ -18,5 +30,25 @@ func LoadKafkaConfig() KafkaConfig {
 		ClientID: os.Getenv("KAFKA_CLIENT_ID"),
 		Username: os.Getenv("KAFKA_USERNAME"),
 		Password: os.Getenv("KAFKA_PASSWORD"),
+
+		SecurityProtocol: strings.ToUpper(getEnvOrDefault("KAFKA_SECURITY_PROTOCOL", "SASL_SSL")),
+		SASLMechanism:    strings.ToUpper(getEnvOrDefault("KAFKA_SASL_MECHANISM", "PLAIN")),
+		CA:               getEnvOrDefault("KAFKA_CA", defaultKafkaCA()),
+		ClientCert:       os.Getenv("KAFKA_CLIENT_CERT"),
+		ClientKey:        os.Getenv("KAFKA_CLIENT_KEY"),
+		TLSServerName:    os.Getenv("KAFKA_TLS_SERVER_NAME"),
+	}
+}
+
+// defaultKafkaCA is the CA file deployments have mounted before KAFKA_CA
+// existed, if it is present. Without one the system roots are used.
+func defaultKafkaCA() string {
+	path := "ca.pem"
+	if os.Getenv("ENV") == "production" {
+		path = "/secrets/kafka-consumer-ca"
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ""
 	}
+	return path
 }
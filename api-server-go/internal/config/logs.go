@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// GetLogStore returns the configured log storage backend: clickhouse, postgres or memory
+func GetLogStore() string {
+	return getEnvOrDefault("LOG_STORE", "clickhouse")
+}
+
+// LogBatchConfig controls how build logs are buffered before insertion
+type LogBatchConfig struct {
+	Enabled       bool
+	MaxSize       int
+	FlushInterval time.Duration
+	MaxRetries    int
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// GetLogBatchConfig returns log batching configuration from environment variables
+func GetLogBatchConfig() LogBatchConfig {
+	return LogBatchConfig{
+		Enabled:       getEnvOrDefault("LOG_BATCH_ENABLED", "true") == "true",
+		MaxSize:       getEnvAsInt("LOG_BATCH_SIZE", 1000),
+		FlushInterval: getEnvAsDuration("LOG_BATCH_FLUSH_INTERVAL", time.Second),
+		MaxRetries:    getEnvAsInt("LOG_BATCH_MAX_RETRIES", 3),
+	}
+}
+
+// LogRetentionConfig holds how many days build logs are kept for each plan
+type LogRetentionConfig struct {
+	DefaultDays int
+	PlanDays    map[string]int
+}
+
+// GetLogRetentionConfig returns log retention configuration from environment variables
+func GetLogRetentionConfig() LogRetentionConfig {
+	return LogRetentionConfig{
+		DefaultDays: getEnvAsInt("LOG_RETENTION_DAYS", 30),
+		PlanDays: map[string]int{
+			"hobby":      getEnvAsInt("LOG_RETENTION_DAYS_HOBBY", 7),
+			"pro":        getEnvAsInt("LOG_RETENTION_DAYS_PRO", 30),
+			"enterprise": getEnvAsInt("LOG_RETENTION_DAYS_ENTERPRISE", 90),
+		},
+	}
+}
+
+// GetBuilderSecrets returns the values of the environment variables named in
+// LOG_REDACT_ENV (comma-separated). These are platform credentials a build
+// could get hold of, such as the log producer password, and are masked in
+// build logs.
+func GetBuilderSecrets() []string {
+	names := getEnvOrDefault("LOG_REDACT_ENV", "KAFKA_PASSWORD,R2_ACCESS_KEY_ID,R2_SECRET_ACCESS_KEY")
+
+	var secrets []string
+	for _, name := range strings.Split(names, ",") {
+		if value := os.Getenv(strings.TrimSpace(name)); value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+	return secrets
+}
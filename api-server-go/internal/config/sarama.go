@@ -1,9 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/IBM/sarama"
+	kafka_sasl "github.com/ujjwalkirti/mini-vercel-api-server/internal/kafka/sasl"
 	kafka_tls "github.com/ujjwalkirti/mini-vercel-api-server/internal/kafka/tls"
 )
 
@@ -17,23 +19,67 @@ func NewSaramaConfig(env KafkaConfig) (*sarama.Config, error) {
 	cfg.Net.ReadTimeout = 30 * time.Second
 	cfg.Net.WriteTimeout = 30 * time.Second
 
-	cfg.Net.SASL.Enable = true
-	cfg.Net.SASL.Mechanism = sarama.SASLTypePlaintext
-	cfg.Net.SASL.User = env.Username
-	cfg.Net.SASL.Password = env.Password
-
 	// Consumer group configuration
 	cfg.Consumer.Group.Rebalance.Strategy = sarama.NewBalanceStrategyRange()
 	cfg.Consumer.Offsets.Initial = sarama.OffsetOldest
 	cfg.Consumer.Return.Errors = true
 
-	tlsCfg, err := kafka_tls.NewTLSConfig()
-	if err != nil {
-		return nil, err
+	var useSASL, useTLS bool
+	switch env.SecurityProtocol {
+	case "PLAINTEXT":
+	case "SSL":
+		useTLS = true
+	case "SASL_PLAINTEXT":
+		useSASL = true
+	case "SASL_SSL":
+		useSASL, useTLS = true, true
+	default:
+		return nil, fmt.Errorf("unknown KAFKA_SECURITY_PROTOCOL %q (want PLAINTEXT, SSL, SASL_PLAINTEXT or SASL_SSL)", env.SecurityProtocol)
+	}
+
+	if useSASL {
+		if env.Username == "" || env.Password == "" {
+			return nil, fmt.Errorf("KAFKA_USERNAME and KAFKA_PASSWORD are required with %s", env.SecurityProtocol)
+		}
+
+		cfg.Net.SASL.Enable = true
+		cfg.Net.SASL.User = env.Username
+		cfg.Net.SASL.Password = env.Password
+
+		switch env.SASLMechanism {
+		case "PLAIN":
+			cfg.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		case "SCRAM-SHA-256":
+			cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+			cfg.Net.SASL.SCRAMClientGeneratorFunc = kafka_sasl.SHA256
+		case "SCRAM-SHA-512":
+			cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+			cfg.Net.SASL.SCRAMClientGeneratorFunc = kafka_sasl.SHA512
+		default:
+			return nil, fmt.Errorf("unknown KAFKA_SASL_MECHANISM %q (want PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512)", env.SASLMechanism)
+		}
 	}
 
-	cfg.Net.TLS.Enable = true
-	cfg.Net.TLS.Config = tlsCfg
+	if useTLS {
+		tlsCfg, err := kafka_tls.NewTLSConfig(kafka_tls.Options{
+			CA:         env.CA,
+			ClientCert: env.ClientCert,
+			ClientKey:  env.ClientKey,
+			ServerName: env.TLSServerName,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.Net.TLS.Enable = true
+		cfg.Net.TLS.Config = tlsCfg
+	} else if env.ClientCert != "" {
+		return nil, fmt.Errorf("KAFKA_CLIENT_CERT needs TLS, but KAFKA_SECURITY_PROTOCOL is %s", env.SecurityProtocol)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid Kafka configuration: %w", err)
+	}
 
 	return cfg, nil
 }
@@ -0,0 +1,29 @@
+package config
+
+import "time"
+
+// WebhookConfig controls the worker that sends webhook deliveries
+type WebhookConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	Timeout      time.Duration
+	Lease        time.Duration
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+	Retention    time.Duration
+}
+
+// GetWebhookConfig returns webhook configuration from environment variables
+func GetWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		PollInterval: getEnvAsDuration("WEBHOOK_POLL_INTERVAL", time.Second),
+		BatchSize:    getEnvAsInt("WEBHOOK_BATCH_SIZE", 20),
+		Timeout:      getEnvAsDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		Lease:        getEnvAsDuration("WEBHOOK_LEASE", time.Minute),
+		MaxAttempts:  getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		RetryBackoff: getEnvAsDuration("WEBHOOK_RETRY_BACKOFF", 10*time.Second),
+		MaxBackoff:   getEnvAsDuration("WEBHOOK_MAX_BACKOFF", time.Hour),
+		Retention:    getEnvAsDuration("WEBHOOK_DELIVERY_RETENTION", 30*24*time.Hour),
+	}
+}
@@ -0,0 +1,62 @@
+package apitoken
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scope is what an API token may do
+type Scope string
+
+const (
+	ProjectsRead     Scope = "projects:read"
+	ProjectsWrite    Scope = "projects:write"
+	DeploymentsRead  Scope = "deployments:read"
+	DeploymentsWrite Scope = "deployments:write"
+	WebhooksRead     Scope = "webhooks:read"
+	WebhooksWrite    Scope = "webhooks:write"
+)
+
+// Scopes lists every scope, in the order they are documented
+var Scopes = []Scope{ProjectsRead, ProjectsWrite, DeploymentsRead, DeploymentsWrite, WebhooksRead, WebhooksWrite}
+
+// ParseScope validates a scope coming from user input
+func ParseScope(s string) (Scope, error) {
+	for _, scope := range Scopes {
+		if Scope(s) == scope {
+			return scope, nil
+		}
+	}
+	return "", fmt.Errorf("invalid scope %q", s)
+}
+
+// Allows reports whether a token with scopes may act with scope. A write
+// scope includes the read scope of the same resource.
+func Allows(scopes []Scope, scope Scope) bool {
+	resource, _, _ := strings.Cut(string(scope), ":")
+	for _, s := range scopes {
+		if s == scope || (strings.HasSuffix(string(scope), ":read") && s == Scope(resource+":write")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Token is a personal access token a user created for scripts and CI
+type Token struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+	Name   string `json:"name"`
+	// Prefix is the start of the token, to recognize it in lists
+	Prefix string  `json:"prefix"`
+	Scopes []Scope `json:"scopes"`
+	// ProjectID restricts the token to one project when set
+	ProjectID  *string    `json:"projectId"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	// TokenHash is the hex SHA-256 of the token; the token itself is never
+	// stored
+	TokenHash string `json:"-"`
+}
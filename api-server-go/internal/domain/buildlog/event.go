@@ -4,4 +4,9 @@ type Event struct {
 	ProjectID    string `json:"project_id"`
 	DeploymentID string `json:"deployment_id"`
 	Log          string `json:"log"`
+	// Stream is "stdout" or "stderr"; older builders omit it
+	Stream string `json:"stream,omitempty"`
+	// Seq numbers the events of a deployment from 1. It is required over
+	// HTTP, where it makes retried batches idempotent.
+	Seq int64 `json:"seq,omitempty"`
 }
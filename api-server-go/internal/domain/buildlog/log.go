@@ -0,0 +1,114 @@
+package buildlog
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stream identifies which output stream of the build a log line came from
+type Stream string
+
+const (
+	StreamStdout Stream = "stdout"
+	StreamStderr Stream = "stderr"
+)
+
+// ParseStream validates a stream name coming from user input
+func ParseStream(s string) (Stream, error) {
+	switch Stream(s) {
+	case StreamStdout, StreamStderr:
+		return Stream(s), nil
+	default:
+		return "", fmt.Errorf("invalid stream %q: must be stdout or stderr", s)
+	}
+}
+
+// Scan implements sql.Scanner so the stream column can be read by any driver
+func (s *Stream) Scan(src any) error {
+	switch v := src.(type) {
+	case string:
+		*s = Stream(v)
+	case []byte:
+		*s = Stream(v)
+	case nil:
+		*s = ""
+	default:
+		return fmt.Errorf("cannot scan %T into Stream", src)
+	}
+	return nil
+}
+
+// LogEvent is a stored build log line
+type LogEvent struct {
+	EventID      string     `json:"event_id"`
+	DeploymentID string     `json:"deployment_id"`
+	ProjectID    string     `json:"project_id,omitempty"`
+	Log          string     `json:"log"`
+	Stream       Stream     `json:"stream,omitempty"`
+	Timestamp    *time.Time `json:"timestamp,omitempty"`
+	// Redacted reports that secrets were masked in Log before it was stored
+	Redacted bool `json:"redacted,omitempty"`
+	// RetentionDays is how long the line is kept; 0 means the store default
+	RetentionDays int `json:"-"`
+}
+
+// ErrInvalidCursor is returned when a cursor string cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in a deployment's log stream.
+// Logs are ordered by (timestamp, event_id) so the pair is unique and stable.
+type Cursor struct {
+	Timestamp time.Time
+	EventID   string
+}
+
+// CursorOf returns the position of a stored log line
+func CursorOf(e LogEvent) Cursor {
+	c := Cursor{EventID: e.EventID}
+	if e.Timestamp != nil {
+		c.Timestamp = *e.Timestamp
+	}
+	return c
+}
+
+// Encode returns an opaque, URL-safe representation of the cursor
+func (c Cursor) Encode() string {
+	raw := strconv.FormatInt(c.Timestamp.UnixNano(), 10) + ":" + c.EventID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor previously produced by Cursor.Encode
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	nanos, eventID, ok := strings.Cut(string(raw), ":")
+	if !ok || eventID == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return Cursor{Timestamp: time.Unix(0, n).UTC(), EventID: eventID}, nil
+}
+
+// Before reports whether the line at c sorts before the given line
+func (c Cursor) Before(e LogEvent) bool {
+	ts := time.Time{}
+	if e.Timestamp != nil {
+		ts = *e.Timestamp
+	}
+	if !c.Timestamp.Equal(ts) {
+		return c.Timestamp.Before(ts)
+	}
+	return c.EventID < e.EventID
+}
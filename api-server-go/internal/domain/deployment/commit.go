@@ -0,0 +1,18 @@
+package deployment
+
+// CommitReport is a deployment triggered from a commit, with what the git
+// provider is told about it
+type CommitReport struct {
+	DeploymentID string
+	Status       Status
+	CommitSHA    string
+	GitRef       *string
+	PullRequest  *int
+
+	ProjectID   string
+	ProjectName string
+	// UserID owns the project; only installations they linked are used
+	UserID    string
+	GitURL    string
+	SubDomain string
+}
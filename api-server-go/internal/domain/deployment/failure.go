@@ -0,0 +1,18 @@
+package deployment
+
+// FailureReason classifies why a build failed
+type FailureReason string
+
+const (
+	FailureDependencyInstall FailureReason = "DEPENDENCY_INSTALL_FAILED"
+	FailureBuildScript       FailureReason = "BUILD_SCRIPT_FAILED"
+	FailureMissingOutput     FailureReason = "OUTPUT_DIRECTORY_MISSING"
+	FailureOutOfMemory       FailureReason = "OUT_OF_MEMORY"
+	FailureTimeout           FailureReason = "TIMEOUT"
+	FailureCloneFailed       FailureReason = "CLONE_FAILED"
+	FailureDispatch          FailureReason = "DISPATCH_FAILED"
+	// FailureBuilderStopped means the build container stopped without
+	// reporting a result, e.g. an image pull error or a capacity stop
+	FailureBuilderStopped FailureReason = "BUILDER_STOPPED"
+	FailureUnknown        FailureReason = "UNKNOWN"
+)
@@ -0,0 +1,12 @@
+package deployment
+
+// IngestProgress is what one batch of build events ingested over HTTP
+// stored
+type IngestProgress struct {
+	// Last is the highest sequence number stored with every event of the
+	// batch before it
+	Last int64
+	// Above are the sequence numbers stored after an event that was not,
+	// which retries of the batch skip
+	Above []int64
+}
@@ -10,6 +10,8 @@ const (
 	InProgress Status = "IN_PROGRESS"
 	Ready      Status = "READY"
 	Fail       Status = "FAIL"
+	Canceled   Status = "CANCELED"
+	TimedOut   Status = "TIMED_OUT"
 )
 
 type Deployment struct {
@@ -18,4 +20,26 @@ type Deployment struct {
 	Status    Status    `json:"status"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
+
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+
+	// Executor and BuildID identify the build on the backend that ran it
+	Executor *string `json:"executor,omitempty"`
+	BuildID  *string `json:"buildId,omitempty"`
+
+	// CommitSHA, GitRef and PullRequest name the commit the deployment was
+	// triggered from, if the caller gave one
+	CommitSHA   *string `json:"commitSha,omitempty"`
+	GitRef      *string `json:"gitRef,omitempty"`
+	PullRequest *int    `json:"pullRequest,omitempty"`
+
+	// QueuePosition is set while the build waits for capacity; 1 is next
+	QueuePosition *int `json:"queuePosition,omitempty"`
+
+	// FailureReason and FailureExcerpt are set when a FAIL build is classified
+	FailureReason  *FailureReason `json:"failureReason,omitempty"`
+	FailureExcerpt []string       `json:"failureExcerpt,omitempty"`
+	Timings        *Timings       `json:"timings,omitempty"`
+	Events         []Event        `json:"events,omitempty"`
 }
@@ -0,0 +1,93 @@
+package deployment
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidTransition is returned when a status change is not allowed from
+// the deployment's current status
+var ErrInvalidTransition = errors.New("invalid deployment status transition")
+
+// transitions lists the statuses each status may move to. A build only
+// succeeds or fails once it is IN_PROGRESS; a queued deployment can only be
+// canceled or time out.
+var transitions = map[Status][]Status{
+	NotStarted: {Queued, Canceled},
+	Queued:     {InProgress, Canceled, TimedOut},
+	InProgress: {Ready, Fail, Canceled, TimedOut},
+}
+
+// CanTransition reports whether a deployment may move from one status to another
+func CanTransition(from, to Status) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// SourcesOf returns every status that may move to the given status
+func SourcesOf(to Status) []Status {
+	var from []Status
+	for s := range transitions {
+		if CanTransition(s, to) {
+			from = append(from, s)
+		}
+	}
+	return from
+}
+
+// Terminal reports whether no further transitions are possible
+func (s Status) Terminal() bool {
+	return len(transitions[s]) == 0
+}
+
+// Event is a recorded status transition
+type Event struct {
+	ID           int64     `json:"id"`
+	DeploymentID string    `json:"deploymentId"`
+	FromStatus   *Status   `json:"fromStatus"`
+	ToStatus     Status    `json:"toStatus"`
+	Reason       string    `json:"reason"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Timings are the phase durations of a deployment in milliseconds.
+// Phases that are still running are measured up to now.
+type Timings struct {
+	QueueTimeMs     *int64 `json:"queueTimeMs"`
+	BuildTimeMs     *int64 `json:"buildTimeMs"`
+	TotalDurationMs *int64 `json:"totalDurationMs"`
+}
+
+// ComputeTimings derives phase durations from the deployment timestamps
+func (d Deployment) ComputeTimings(now time.Time) Timings {
+	var t Timings
+
+	end := now
+	if d.FinishedAt != nil {
+		end = *d.FinishedAt
+	}
+
+	if !d.CreatedAt.IsZero() {
+		queueEnd := end
+		if d.StartedAt != nil {
+			queueEnd = *d.StartedAt
+		}
+		t.QueueTimeMs = millis(queueEnd.Sub(d.CreatedAt))
+		t.TotalDurationMs = millis(end.Sub(d.CreatedAt))
+	}
+
+	if d.StartedAt != nil {
+		t.BuildTimeMs = millis(end.Sub(*d.StartedAt))
+	}
+
+	return t
+}
+
+func millis(d time.Duration) *int64 {
+	ms := max(d.Milliseconds(), 0)
+	return &ms
+}
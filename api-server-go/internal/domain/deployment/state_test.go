@@ -0,0 +1,93 @@
+package deployment
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	statuses := []Status{NotStarted, Queued, InProgress, Ready, Fail, Canceled, TimedOut}
+	allowed := map[Status][]Status{
+		NotStarted: {Queued, Canceled},
+		Queued:     {InProgress, Canceled, TimedOut},
+		InProgress: {Ready, Fail, Canceled, TimedOut},
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := false
+			for _, s := range allowed[from] {
+				want = want || s == to
+			}
+			if got := CanTransition(from, to); got != want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestCanTransitionRejects(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		why      string
+	}{
+		{Queued, Ready, "a build must be started before it succeeds"},
+		{Queued, Fail, "a build must be started before it fails"},
+		{NotStarted, InProgress, "a deployment is queued before it is built"},
+		{Ready, InProgress, "a late start line must not reopen a finished build"},
+		{Fail, Ready, "terminal statuses are final"},
+		{Canceled, Queued, "terminal statuses are final"},
+		{TimedOut, Fail, "terminal statuses are final"},
+		{InProgress, InProgress, "a repeated start line is not a transition"},
+		{InProgress, Queued, "a build does not go back to the queue"},
+	}
+
+	for _, tt := range tests {
+		if CanTransition(tt.from, tt.to) {
+			t.Errorf("CanTransition(%s, %s) = true; %s", tt.from, tt.to, tt.why)
+		}
+	}
+}
+
+func TestSourcesOf(t *testing.T) {
+	tests := []struct {
+		to   Status
+		want []Status
+	}{
+		{Queued, []Status{NotStarted}},
+		{InProgress, []Status{Queued}},
+		{Ready, []Status{InProgress}},
+		{Fail, []Status{InProgress}},
+		{Canceled, []Status{InProgress, NotStarted, Queued}},
+		{TimedOut, []Status{InProgress, Queued}},
+		{NotStarted, nil},
+	}
+
+	for _, tt := range tests {
+		got := SourcesOf(tt.to)
+		sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+		if len(got) != len(tt.want) {
+			t.Errorf("SourcesOf(%s) = %v, want %v", tt.to, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("SourcesOf(%s) = %v, want %v", tt.to, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	for _, s := range []Status{Ready, Fail, Canceled, TimedOut} {
+		if !s.Terminal() {
+			t.Errorf("%s is not terminal", s)
+		}
+	}
+	for _, s := range []Status{NotStarted, Queued, InProgress} {
+		if s.Terminal() {
+			t.Errorf("%s is terminal", s)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package deployment
+
+import "time"
+
+// BuildCredential is the build token a deployment was issued, along with
+// what is needed to decide whether it is still valid
+type BuildCredential struct {
+	DeploymentID string
+	ProjectID    string
+	Status       Status
+	// TokenHash is the hex SHA-256 of the token; nil if none was issued
+	TokenHash *string
+	// PreviousTokenHashes are the tokens of earlier dispatch attempts, whose
+	// builds may still be running
+	PreviousTokenHashes []string
+	ExpiresAt           *time.Time
+}
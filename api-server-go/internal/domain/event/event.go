@@ -0,0 +1,166 @@
+// Package event defines the domain events the API server publishes for
+// other services. The JSON form is a public contract: fields may be added
+// within a version, but renaming or removing one needs a new version.
+package event
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/project"
+)
+
+// Source identifies this service in the envelope
+const Source = "mini-vercel-api-server"
+
+// Type names what happened, as "<entity>.<change>"
+type Type string
+
+const (
+	ProjectCreated Type = "project.created"
+	ProjectUpdated Type = "project.updated"
+	ProjectDeleted Type = "project.deleted"
+
+	DeploymentQueued   Type = "deployment.queued"
+	DeploymentStarted  Type = "deployment.started"
+	DeploymentReady    Type = "deployment.ready"
+	DeploymentFailed   Type = "deployment.failed"
+	DeploymentCanceled Type = "deployment.canceled"
+
+	// DomainVerified is reserved for custom domain verification, which the
+	// API server does not perform yet
+	DomainVerified Type = "domain.verified"
+)
+
+// Version is the schema version of the data of every event type
+const Version = 1
+
+// Event is the envelope of a domain event
+type Event struct {
+	// ID is unique per event; consumers deduplicate redeliveries with it
+	ID      string `json:"id"`
+	Type    Type   `json:"type"`
+	Version int    `json:"version"`
+	Source  string `json:"source"`
+	// ProjectID is also the message key, so the events of a project are
+	// delivered in order
+	ProjectID  string          `json:"projectId"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// ProjectData is the data of project.created and project.updated
+type ProjectData struct {
+	ProjectID    string  `json:"projectId"`
+	UserID       string  `json:"userId"`
+	Name         string  `json:"name"`
+	GitURL       string  `json:"gitUrl"`
+	SubDomain    string  `json:"subDomain"`
+	CustomDomain *string `json:"customDomain"`
+	Plan         string  `json:"plan"`
+	// BuildPreset and UseSpot are null when the plan's defaults apply
+	BuildPreset *string `json:"buildPreset"`
+	UseSpot     *bool   `json:"useSpot"`
+}
+
+// ProjectDeletedData is the data of project.deleted
+type ProjectDeletedData struct {
+	ProjectID string `json:"projectId"`
+	UserID    string `json:"userId"`
+}
+
+// DeploymentData is the data of the deployment.* events
+type DeploymentData struct {
+	DeploymentID string `json:"deploymentId"`
+	ProjectID    string `json:"projectId"`
+	UserID       string `json:"userId"`
+	// Status is the new status; deployment.failed covers FAIL and TIMED_OUT
+	Status         deployment.Status  `json:"status"`
+	PreviousStatus *deployment.Status `json:"previousStatus"`
+	Reason         string             `json:"reason"`
+	// CommitSHA, GitRef and PullRequest are set for deployments triggered
+	// from a commit
+	CommitSHA   string `json:"commitSha,omitempty"`
+	GitRef      string `json:"gitRef,omitempty"`
+	PullRequest int    `json:"pullRequest,omitempty"`
+}
+
+// DomainVerifiedData is the data of domain.verified
+type DomainVerifiedData struct {
+	ProjectID string `json:"projectId"`
+	UserID    string `json:"userId"`
+	Domain    string `json:"domain"`
+}
+
+// New creates an event that happened now. The outbox assigns its ID.
+func New(t Type, projectID string, data any) (Event, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		Type:       t,
+		Version:    Version,
+		Source:     Source,
+		ProjectID:  projectID,
+		OccurredAt: time.Now().UTC(),
+		Data:       body,
+	}, nil
+}
+
+// NewProjectData describes a project
+func NewProjectData(p project.Project) ProjectData {
+	data := ProjectData{
+		ProjectID:    p.ID,
+		UserID:       p.UserID,
+		Name:         p.Name,
+		GitURL:       p.GitURL,
+		SubDomain:    p.SubDomain,
+		CustomDomain: p.CustomDomain,
+		Plan:         string(p.Plan),
+		UseSpot:      p.UseSpot,
+	}
+	if p.BuildPreset != nil {
+		preset := string(*p.BuildPreset)
+		data.BuildPreset = &preset
+	}
+	return data
+}
+
+// DeploymentType returns the event published when a deployment reaches
+// status, and false if none is
+func DeploymentType(status deployment.Status) (Type, bool) {
+	switch status {
+	case deployment.Queued:
+		return DeploymentQueued, true
+	case deployment.InProgress:
+		return DeploymentStarted, true
+	case deployment.Ready:
+		return DeploymentReady, true
+	case deployment.Fail, deployment.TimedOut:
+		return DeploymentFailed, true
+	case deployment.Canceled:
+		return DeploymentCanceled, true
+	}
+	return "", false
+}
+
+// Types lists every event type
+func Types() []Type {
+	return []Type{
+		ProjectCreated, ProjectUpdated, ProjectDeleted,
+		DeploymentQueued, DeploymentStarted, DeploymentReady, DeploymentFailed, DeploymentCanceled,
+		DomainVerified,
+	}
+}
+
+// Valid reports whether t is a known event type
+func (t Type) Valid() bool {
+	for _, known := range Types() {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
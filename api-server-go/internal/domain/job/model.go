@@ -0,0 +1,59 @@
+package job
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Kind identifies what a job does
+type Kind string
+
+const (
+	// KindDispatchBuild starts the build of a QUEUED deployment
+	KindDispatchBuild Kind = "dispatch_build"
+)
+
+type Status string
+
+const (
+	Pending Status = "pending"
+	Running Status = "running"
+	Done    Status = "done"
+	Failed  Status = "failed"
+)
+
+// Job is a unit of work written to the outbox table in the same transaction
+// as the state change that requires it
+type Job struct {
+	ID           int64           `json:"id"`
+	Kind         Kind            `json:"kind"`
+	DeploymentID string          `json:"deploymentId"`
+	Payload      json.RawMessage `json:"payload"`
+	Status       Status          `json:"status"`
+	Attempts     int             `json:"attempts"`
+	LastError    *string         `json:"lastError"`
+	RunAfter     time.Time       `json:"runAfter"`
+	CreatedAt    time.Time       `json:"createdAt"`
+
+	// SupersededBy is set by the scheduler when a newer deployment of the
+	// same project makes this build unnecessary
+	SupersededBy string `json:"-"`
+}
+
+// BuildPayload is the payload of a KindDispatchBuild job.
+// Credentials are not stored; they are added when the build is started.
+type BuildPayload struct {
+	ProjectID    string `json:"projectId"`
+	DeploymentID string `json:"deploymentId"`
+	GitURL       string `json:"gitUrl"`
+	// CommitSHA is checked out instead of the default branch when set
+	CommitSHA string `json:"commitSha,omitempty"`
+
+	// Build settings resolved when the deployment was created. Jobs written
+	// before they existed run with the executor's defaults.
+	Plan     string `json:"plan,omitempty"`
+	Preset   string `json:"preset,omitempty"`
+	CPU      int    `json:"cpu,omitempty"`
+	MemoryMB int    `json:"memoryMb,omitempty"`
+	Spot     bool   `json:"spot,omitempty"`
+}
@@ -0,0 +1,69 @@
+package job
+
+// Limits caps how many builds run at once. Zero means unlimited.
+type Limits struct {
+	Global     int
+	PerUser    int
+	PerProject int
+}
+
+// Candidate is a runnable job together with the owner of its deployment
+type Candidate struct {
+	Job
+	UserID    string
+	ProjectID string
+}
+
+// Usage counts the builds that currently hold a slot
+type Usage struct {
+	Global   int
+	Users    map[string]int
+	Projects map[string]int
+}
+
+// NewUsage returns empty usage
+func NewUsage() Usage {
+	return Usage{Users: make(map[string]int), Projects: make(map[string]int)}
+}
+
+// Add records one running build
+func (u *Usage) Add(userID, projectID string) {
+	u.Global++
+	u.Users[userID]++
+	u.Projects[projectID]++
+}
+
+// Select picks up to n candidates to run. Candidates must already be in
+// round-robin order (each user's oldest job first, then each user's second,
+// and so on), so skipping a user at their limit lets the next user through.
+// Builds that will be skipped as superseded, and jobs that do not start a
+// build, take no slot. usage is updated with the selected builds.
+func Select(candidates []Candidate, usage Usage, limits Limits, n int) []Candidate {
+	selected := make([]Candidate, 0, n)
+
+	for _, c := range candidates {
+		if len(selected) == n {
+			break
+		}
+
+		if c.Kind != KindDispatchBuild || c.SupersededBy != "" {
+			selected = append(selected, c)
+			continue
+		}
+
+		if limits.Global > 0 && usage.Global >= limits.Global {
+			continue
+		}
+		if limits.PerUser > 0 && usage.Users[c.UserID] >= limits.PerUser {
+			continue
+		}
+		if limits.PerProject > 0 && usage.Projects[c.ProjectID] >= limits.PerProject {
+			continue
+		}
+
+		usage.Add(c.UserID, c.ProjectID)
+		selected = append(selected, c)
+	}
+
+	return selected
+}
@@ -0,0 +1,132 @@
+package job
+
+import (
+	"reflect"
+	"testing"
+)
+
+func build(id int64, userID, projectID string) Candidate {
+	return Candidate{Job: Job{ID: id, Kind: KindDispatchBuild}, UserID: userID, ProjectID: projectID}
+}
+
+func superseded(c Candidate) Candidate {
+	c.SupersededBy = "newer"
+	return c
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []Candidate
+		usage      func(u *Usage)
+		limits     Limits
+		n          int
+		want       []int64
+	}{
+		{
+			name:       "unlimited",
+			candidates: []Candidate{build(1, "u1", "p1"), build(2, "u1", "p1"), build(3, "u2", "p2")},
+			n:          10,
+			want:       []int64{1, 2, 3},
+		},
+		{
+			name:       "batch size",
+			candidates: []Candidate{build(1, "u1", "p1"), build(2, "u2", "p2"), build(3, "u3", "p3")},
+			n:          2,
+			want:       []int64{1, 2},
+		},
+		{
+			name:       "global limit",
+			candidates: []Candidate{build(1, "u1", "p1"), build(2, "u2", "p2"), build(3, "u3", "p3")},
+			limits:     Limits{Global: 2},
+			n:          10,
+			want:       []int64{1, 2},
+		},
+		{
+			name:       "global limit counts running builds",
+			candidates: []Candidate{build(1, "u1", "p1"), build(2, "u2", "p2")},
+			usage:      func(u *Usage) { u.Add("u3", "p3") },
+			limits:     Limits{Global: 2},
+			n:          10,
+			want:       []int64{1},
+		},
+		{
+			name: "per-user limit lets the next user through",
+			// Round-robin order: each user's oldest, then each user's second
+			candidates: []Candidate{build(1, "u1", "p1"), build(2, "u2", "p2"), build(3, "u1", "p1"), build(4, "u2", "p3")},
+			limits:     Limits{PerUser: 1},
+			n:          10,
+			want:       []int64{1, 2},
+		},
+		{
+			name:       "per-user limit counts running builds",
+			candidates: []Candidate{build(1, "u1", "p1"), build(2, "u2", "p2")},
+			usage:      func(u *Usage) { u.Add("u1", "p9") },
+			limits:     Limits{PerUser: 1},
+			n:          10,
+			want:       []int64{2},
+		},
+		{
+			name:       "per-project limit",
+			candidates: []Candidate{build(1, "u1", "p1"), build(2, "u1", "p2"), build(3, "u1", "p1")},
+			limits:     Limits{PerProject: 1},
+			n:          10,
+			want:       []int64{1, 2},
+		},
+		{
+			name:       "per-project limit counts running builds",
+			candidates: []Candidate{build(1, "u1", "p1"), build(2, "u2", "p2")},
+			usage:      func(u *Usage) { u.Add("u1", "p1") },
+			limits:     Limits{PerProject: 1},
+			n:          10,
+			want:       []int64{2},
+		},
+		{
+			name:       "superseded builds take no slot",
+			candidates: []Candidate{superseded(build(1, "u1", "p1")), superseded(build(2, "u1", "p1")), build(3, "u1", "p1")},
+			limits:     Limits{Global: 1, PerUser: 1, PerProject: 1},
+			n:          10,
+			want:       []int64{1, 2, 3},
+		},
+		{
+			name:       "superseded builds are selected at the limit",
+			candidates: []Candidate{superseded(build(1, "u1", "p1"))},
+			usage:      func(u *Usage) { u.Add("u1", "p1") },
+			limits:     Limits{Global: 1},
+			n:          10,
+			want:       []int64{1},
+		},
+		{
+			name:       "superseded builds count toward the batch size",
+			candidates: []Candidate{superseded(build(1, "u1", "p1")), build(2, "u1", "p1")},
+			n:          1,
+			want:       []int64{1},
+		},
+		{
+			name:       "other jobs take no slot",
+			candidates: []Candidate{{Job: Job{ID: 1, Kind: "other"}, UserID: "u1", ProjectID: "p1"}, build(2, "u1", "p1")},
+			limits:     Limits{Global: 1},
+			n:          10,
+			want:       []int64{1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage := NewUsage()
+			if tt.usage != nil {
+				tt.usage(&usage)
+			}
+
+			selected := Select(tt.candidates, usage, tt.limits, tt.n)
+
+			got := make([]int64, len(selected))
+			for i, c := range selected {
+				got[i] = c.ID
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("selected %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
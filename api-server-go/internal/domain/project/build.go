@@ -0,0 +1,105 @@
+package project
+
+import "fmt"
+
+// BuildPreset names the CPU and memory a build gets
+type BuildPreset string
+
+const (
+	PresetSmall  BuildPreset = "small"
+	PresetMedium BuildPreset = "medium"
+	PresetLarge  BuildPreset = "large"
+	PresetXLarge BuildPreset = "xlarge"
+)
+
+// presetOrder ranks presets from smallest to largest
+var presetOrder = []BuildPreset{PresetSmall, PresetMedium, PresetLarge, PresetXLarge}
+
+// Resources are the CPU and memory of a build. The values are valid Fargate
+// task sizes.
+type Resources struct {
+	// CPU is in ECS CPU units; 1024 is one vCPU
+	CPU int `json:"cpu"`
+	// MemoryMB is the memory limit in MiB
+	MemoryMB int `json:"memoryMb"`
+}
+
+var presetResources = map[BuildPreset]Resources{
+	PresetSmall:  {CPU: 1024, MemoryMB: 2048},
+	PresetMedium: {CPU: 2048, MemoryMB: 4096},
+	PresetLarge:  {CPU: 4096, MemoryMB: 8192},
+	PresetXLarge: {CPU: 8192, MemoryMB: 16384},
+}
+
+// planMaxPreset is the largest preset each plan may use
+var planMaxPreset = map[Plan]BuildPreset{
+	PlanHobby:      PresetMedium,
+	PlanPro:        PresetLarge,
+	PlanEnterprise: PresetXLarge,
+}
+
+// ParseBuildPreset validates a preset name coming from user input
+func ParseBuildPreset(s string) (BuildPreset, error) {
+	p := BuildPreset(s)
+	if _, ok := presetResources[p]; !ok {
+		return "", fmt.Errorf("invalid build preset %q: must be small, medium, large or xlarge", s)
+	}
+	return p, nil
+}
+
+// Resources returns the CPU and memory of the preset
+func (p BuildPreset) Resources() Resources {
+	return presetResources[p]
+}
+
+func (p BuildPreset) rank() int {
+	for i, o := range presetOrder {
+		if o == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// MaxPreset is the largest preset the plan allows
+func (p Plan) MaxPreset() BuildPreset {
+	if preset, ok := planMaxPreset[p]; ok {
+		return preset
+	}
+	return PresetSmall
+}
+
+// Allows reports whether the plan may use preset
+func (p Plan) Allows(preset BuildPreset) bool {
+	return preset.rank() >= 0 && preset.rank() <= p.MaxPreset().rank()
+}
+
+// BuildDefaults are the settings used when a project does not choose its own
+type BuildDefaults struct {
+	// PlanPresets is the preset of each plan
+	PlanPresets map[Plan]BuildPreset
+	// UseSpot runs builds on Spot capacity
+	UseSpot bool
+}
+
+// BuildSettings resolves the preset and Spot usage of the project's builds.
+// A preset above what the plan allows (e.g. after a downgrade) is capped.
+func (p Project) BuildSettings(defaults BuildDefaults) (BuildPreset, bool) {
+	preset := defaults.PlanPresets[p.Plan]
+	if p.BuildPreset != nil {
+		preset = *p.BuildPreset
+	}
+	if preset.rank() < 0 {
+		preset = PresetSmall
+	}
+	if !p.Plan.Allows(preset) {
+		preset = p.Plan.MaxPreset()
+	}
+
+	useSpot := defaults.UseSpot
+	if p.UseSpot != nil {
+		useSpot = *p.UseSpot
+	}
+
+	return preset, useSpot
+}
@@ -0,0 +1,44 @@
+package project
+
+import (
+	"fmt"
+	"time"
+)
+
+// GitCredentialType is how builds authenticate to a project's repository
+type GitCredentialType string
+
+const (
+	// GitCredentialNone clones anonymously; only public repositories work
+	GitCredentialNone GitCredentialType = "none"
+	// GitCredentialDeployKey clones over SSH with a key generated for the
+	// project and added to the repository as a read-only deploy key
+	GitCredentialDeployKey GitCredentialType = "deploy_key"
+	// GitCredentialGitHubApp clones with an installation token of the
+	// platform's GitHub App
+	GitCredentialGitHubApp GitCredentialType = "github_app"
+)
+
+// ParseGitCredentialType validates a credential type coming from user input
+func ParseGitCredentialType(s string) (GitCredentialType, error) {
+	switch t := GitCredentialType(s); t {
+	case GitCredentialNone, GitCredentialDeployKey, GitCredentialGitHubApp:
+		return t, nil
+	}
+	return "", fmt.Errorf("invalid git credential %q: must be none, deploy_key or github_app", s)
+}
+
+// GitCredential is the credential of a project's repository
+type GitCredential struct {
+	ProjectID string            `json:"projectId"`
+	Type      GitCredentialType `json:"type"`
+	// PublicKey is the deploy key to add to the repository. It is only
+	// returned when the key is generated.
+	PublicKey   string `json:"publicKey,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// PrivateKey is the encrypted private deploy key; it never leaves the
+	// server except to a running build
+	PrivateKey []byte    `json:"-"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
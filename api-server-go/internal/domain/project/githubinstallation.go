@@ -0,0 +1,14 @@
+package project
+
+import "time"
+
+// GitHubInstallation is a GitHub App installation a user linked to their
+// account. Only repositories of installations the owner of a project linked
+// can be cloned with github_app credentials or reported to.
+type GitHubInstallation struct {
+	ID int64 `json:"id"`
+	// Account is the login of the user or organization the app is
+	// installed on
+	Account  string    `json:"account"`
+	LinkedAt time.Time `json:"linkedAt"`
+}
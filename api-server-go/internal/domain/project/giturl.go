@@ -0,0 +1,9 @@
+package project
+
+// LegacyGitURL is the git URL of a project created before URLs were
+// normalized, which may not be in canonical form
+type LegacyGitURL struct {
+	ProjectID string
+	UserID    string
+	GitURL    string
+}
@@ -1,11 +1,25 @@
 package project
 
 import (
+	"errors"
 	"time"
 
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
 )
 
+// Plan is the billing plan of a project
+type Plan string
+
+const (
+	PlanHobby      Plan = "hobby"
+	PlanPro        Plan = "pro"
+	PlanEnterprise Plan = "enterprise"
+)
+
+// ErrDuplicateGitURL is returned when a user creates a second project for
+// the same repository
+var ErrDuplicateGitURL = errors.New("a project for this repository already exists")
+
 type Project struct {
 	ID           string                  `json:"id"`
 	Name         string                  `json:"name"`
@@ -13,7 +27,16 @@ type Project struct {
 	SubDomain    string                  `json:"subDomain"`
 	CustomDomain *string                 `json:"customDomain"`
 	UserID       string                  `json:"userId"`
+	Plan         Plan                    `json:"plan"`
 	CreatedAt    time.Time               `json:"createdAt"`
 	UpdatedAt    time.Time               `json:"updatedAt"`
 	Deployments  []deployment.Deployment `json:"Deployment,omitempty"`
+
+	// BuildPreset and UseSpot override the plan's build defaults when set
+	BuildPreset *BuildPreset `json:"buildPreset,omitempty"`
+	UseSpot     *bool        `json:"useSpot,omitempty"`
+
+	// GitCredential is only set in the response that creates the project,
+	// to return a generated deploy key
+	GitCredential *GitCredential `json:"gitCredential,omitempty"`
 }
@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/event"
+)
+
+// Webhook is a project's subscription to its domain events
+type Webhook struct {
+	ID        string `json:"id"`
+	ProjectID string `json:"projectId"`
+	URL       string `json:"url"`
+	// Secret is only returned when the webhook is created
+	Secret string `json:"secret,omitempty"`
+	// Events filters the delivered event types; empty delivers all
+	Events    []event.Type `json:"events"`
+	Active    bool         `json:"active"`
+	CreatedAt time.Time    `json:"createdAt"`
+	UpdatedAt time.Time    `json:"updatedAt"`
+}
+
+type DeliveryStatus string
+
+const (
+	Pending   DeliveryStatus = "pending"
+	Sending   DeliveryStatus = "sending"
+	Succeeded DeliveryStatus = "succeeded"
+	Failed    DeliveryStatus = "failed"
+)
+
+// Delivery is one event sent, or to be sent, to a webhook
+type Delivery struct {
+	ID            string          `json:"id"`
+	WebhookID     string          `json:"webhookId"`
+	EventID       string          `json:"eventId"`
+	EventType     event.Type      `json:"eventType"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        DeliveryStatus  `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt *time.Time      `json:"nextAttemptAt,omitempty"`
+
+	// The result of the last attempt
+	ResponseCode *int    `json:"responseCode"`
+	Error        *string `json:"error,omitempty"`
+	DurationMs   *int    `json:"durationMs,omitempty"`
+
+	RedeliveryOf *string    `json:"redeliveryOf,omitempty"`
+	DeliveredAt  *time.Time `json:"deliveredAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+// Attempt is the outcome of sending a delivery once
+type Attempt struct {
+	ResponseCode int
+	Err          error
+	Duration     time.Duration
+}
+
+// OK reports whether the receiver accepted the delivery
+func (a Attempt) OK() bool {
+	return a.Err == nil && a.ResponseCode >= 200 && a.ResponseCode < 300
+}
+
+// Target is a delivery claimed for sending, with where it goes
+type Target struct {
+	Delivery
+	URL    string
+	Secret string
+}
@@ -0,0 +1,50 @@
+// Package eventbus moves messages between services through a topic with
+// consumer groups. Kafka (eventbus/kafka), Redis Streams (eventbus/redis)
+// and an in-process bus (MemoryBus) implement it; EVENT_BUS selects one.
+package eventbus
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrClosed is returned by a bus that has been closed
+var ErrClosed = errors.New("event bus is closed")
+
+// Message is one message on a topic
+type Message struct {
+	Topic string
+	Key   []byte
+	Value []byte
+	// ID identifies the message on its backend, e.g. "3/1042" (partition and
+	// offset) or a stream entry ID
+	ID string
+}
+
+// AckFunc settles a delivered message. nil means it was handled and is not
+// delivered again; an error leaves it to be redelivered.
+type AckFunc func(err error)
+
+// Handler consumes messages of a subscription. Messages may be handled
+// concurrently and acknowledged out of order.
+type Handler interface {
+	// Handle starts processing a message and calls ack exactly once, possibly
+	// later from another goroutine. A returned error means the message can
+	// never be processed; it is logged and skipped, and ack is not called.
+	Handle(ctx context.Context, msg Message, ack AckFunc) error
+	// Flush is called before the bus stops delivering, on shutdown or
+	// rebalance, so pending acks complete while they can still be recorded
+	Flush(ctx context.Context) error
+}
+
+// Bus publishes messages and delivers them to consumer groups. Each message
+// of a topic reaches one subscriber of every group.
+type Bus interface {
+	// Name identifies the backend, e.g. "kafka"
+	Name() string
+	Publish(ctx context.Context, topic string, key, value []byte) error
+	// Subscribe delivers messages of topic to handler as a member of group
+	// until ctx is cancelled. Connection failures are retried, not returned.
+	Subscribe(ctx context.Context, topic, group string, handler Handler) error
+	Close() error
+}
@@ -0,0 +1,158 @@
+// Package kafka implements eventbus.Bus on Kafka consumer groups
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/eventbus"
+)
+
+const (
+	// retryMin and retryMax bound the wait between reconnection attempts
+	retryMin = time.Second
+	retryMax = time.Minute
+)
+
+// Bus publishes and consumes through a Kafka cluster
+type Bus struct {
+	brokers []string
+	cfg     *sarama.Config
+	workers int
+
+	mu       sync.Mutex
+	producer sarama.SyncProducer
+	closed   bool
+}
+
+// New creates a bus. Nothing connects until the first Publish or
+// Subscribe, so an unreachable cluster does not stop the server starting.
+// workers bounds how many messages of one subscription are handled at once.
+func New(brokers []string, cfg *sarama.Config, workers int) *Bus {
+	// SyncProducer requires both
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.Return.Errors = true
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Bus{brokers: brokers, cfg: cfg, workers: workers}
+}
+
+func (b *Bus) Name() string {
+	return "kafka"
+}
+
+// Publish sends a message and waits for the cluster to store it. The key
+// picks the partition, so messages with the same key stay in order.
+func (b *Bus) Publish(ctx context.Context, topic string, key, value []byte) error {
+	producer, err := b.syncProducer()
+	if err != nil {
+		return err
+	}
+
+	msg := &sarama.ProducerMessage{Topic: topic, Value: sarama.ByteEncoder(value)}
+	if key != nil {
+		msg.Key = sarama.ByteEncoder(key)
+	}
+	if _, _, err := producer.SendMessage(msg); err != nil {
+		return fmt.Errorf("publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// syncProducer connects the producer on first use
+func (b *Bus) syncProducer() (sarama.SyncProducer, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, eventbus.ErrClosed
+	}
+	if b.producer == nil {
+		producer, err := sarama.NewSyncProducer(b.brokers, b.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("connect kafka producer: %w", err)
+		}
+		b.producer = producer
+	}
+	return b.producer, nil
+}
+
+// Subscribe joins group and consumes topic until ctx is cancelled. Failures
+// to reach the cluster are logged and retried with backoff.
+func (b *Bus) Subscribe(ctx context.Context, topic, group string, handler eventbus.Handler) error {
+	gh := newGroupHandler(handler, b.workers, b.Publish)
+	backoff := retryMin
+
+	for {
+		err := b.consume(ctx, topic, group, gh)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, eventbus.ErrClosed) {
+			return err
+		}
+
+		log.Printf("WARN: Kafka consumer %s of %s failed, retrying in %s: %v", group, topic, backoff, err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, retryMax)
+	}
+}
+
+// consume runs one consumer group until the group fails or ctx ends
+func (b *Bus) consume(ctx context.Context, topic, group string, gh *groupHandler) error {
+	if b.isClosed() {
+		return eventbus.ErrClosed
+	}
+
+	cg, err := sarama.NewConsumerGroup(b.brokers, group, b.cfg)
+	if err != nil {
+		return err
+	}
+	// Closing the group commits the offsets marked so far
+	defer cg.Close()
+
+	go func() {
+		for err := range cg.Errors() {
+			log.Printf("WARN: Kafka consumer %s: %v", group, err)
+		}
+	}()
+
+	for {
+		if err := cg.Consume(ctx, []string{topic}, gh); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+func (b *Bus) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// Close closes the producer. Subscriptions stop with their context.
+func (b *Bus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	if b.producer == nil {
+		return nil
+	}
+	err := b.producer.Close()
+	b.producer = nil
+	return err
+}
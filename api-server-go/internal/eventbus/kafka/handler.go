@@ -0,0 +1,157 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/eventbus"
+)
+
+const (
+	// maxDeliveries is how often a message acknowledged with an error is
+	// handled before it goes to the dead-letter topic
+	maxDeliveries = 5
+	// DeadLetterSuffix names the dead-letter topic of a topic
+	DeadLetterSuffix = ".dead-letter"
+)
+
+// publishFunc sends a message, e.g. Bus.Publish
+type publishFunc func(ctx context.Context, topic string, key, value []byte) error
+
+// groupHandler adapts an eventbus.Handler to a sarama consumer group
+type groupHandler struct {
+	pool    *WorkerPool
+	handler eventbus.Handler
+	// publish sends messages to the dead-letter topic
+	publish publishFunc
+	// retryMin and retryMax bound the wait before a message is handled again
+	retryMin time.Duration
+	retryMax time.Duration
+}
+
+func newGroupHandler(handler eventbus.Handler, workers int, publish publishFunc) *groupHandler {
+	return &groupHandler{
+		pool:     NewWorkerPool(workers),
+		handler:  handler,
+		publish:  publish,
+		retryMin: time.Second,
+		retryMax: 30 * time.Second,
+	}
+}
+
+// Called when a new consumer session starts (rebalance)
+func (h *groupHandler) Setup(s sarama.ConsumerGroupSession) error {
+	// no-op for now
+	return nil
+}
+
+// Called when a consumer session ends (rebalance / shutdown)
+func (h *groupHandler) Cleanup(s sarama.ConsumerGroupSession) error {
+	// no-op for now
+	return nil
+}
+
+// Called once per partition
+// Messages with the same key are handled in partition order, one at a time,
+// so e.g. the lines of one deployment are stored in the order they were
+// produced; messages with different keys are handled concurrently.
+// Offsets are only marked once the handler acknowledges a message, and only
+// up to the first message that is still in flight. A message acknowledged
+// with an error is handled again with backoff, and after maxDeliveries goes
+// to the dead-letter topic, so one bad message cannot stop the partition
+// committing.
+func (h *groupHandler) ConsumeClaim(
+	s sarama.ConsumerGroupSession,
+	c sarama.ConsumerGroupClaim,
+) error {
+	tracker := newOffsetTracker()
+	commit := func(msg *sarama.ConsumerMessage) {
+		if offset, ok := tracker.ack(msg.Offset); ok {
+			s.MarkOffset(msg.Topic, msg.Partition, offset+1, "")
+		}
+	}
+
+	var wg sync.WaitGroup
+	for msg := range c.Messages() {
+		tracker.add(msg.Offset)
+		wg.Add(1)
+		h.pool.SubmitOrdered(string(msg.Key), func() {
+			defer wg.Done()
+			h.handle(s, msg, 1, commit)
+		})
+	}
+
+	// The claim is ending (rebalance or shutdown): push buffered work out
+	// while the session can still commit its offsets
+	wg.Wait()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := h.handler.Flush(ctx); err != nil {
+		log.Printf("WARN: Failed to flush partition %d: %v", c.Partition(), err)
+	}
+
+	return nil
+}
+
+// handle delivers a message to the handler for the given time
+func (h *groupHandler) handle(s sarama.ConsumerGroupSession, msg *sarama.ConsumerMessage, delivery int, commit func(*sarama.ConsumerMessage)) {
+	var once sync.Once
+	done := func(err error) {
+		once.Do(func() {
+			if err == nil {
+				commit(msg)
+				return
+			}
+			h.retry(s, msg, delivery, err, commit)
+		})
+	}
+
+	err := h.handler.Handle(s.Context(), eventbus.Message{
+		Topic: msg.Topic,
+		Key:   msg.Key,
+		Value: msg.Value,
+		ID:    fmt.Sprintf("%d/%d", msg.Partition, msg.Offset),
+	}, done)
+	if err != nil {
+		// Unprocessable messages are skipped rather than blocking the partition
+		log.Printf("ERROR: Failed to process message at offset %d: %v", msg.Offset, err)
+		done(nil)
+	}
+}
+
+// retry handles a message again after a failed delivery, or moves it to the
+// dead-letter topic once it failed maxDeliveries times. Retries stop with the
+// session; the message is then uncommitted and redelivered after the
+// rebalance.
+func (h *groupHandler) retry(s sarama.ConsumerGroupSession, msg *sarama.ConsumerMessage, delivery int, cause error, commit func(*sarama.ConsumerMessage)) {
+	if delivery >= maxDeliveries {
+		topic := msg.Topic + DeadLetterSuffix
+		err := h.publish(s.Context(), topic, msg.Key, msg.Value)
+		if err == nil {
+			log.Printf("ERROR: Message at offset %d of %s/%d failed %d times, moved to %s: %v", msg.Offset, msg.Topic, msg.Partition, delivery, topic, cause)
+			commit(msg)
+			return
+		}
+		// Keep retrying rather than lose the message
+		log.Printf("ERROR: Failed to move message at offset %d of %s/%d to %s: %v", msg.Offset, msg.Topic, msg.Partition, topic, err)
+	}
+
+	wait := h.retryMin << min(delivery-1, 16)
+	if wait > h.retryMax || wait <= 0 {
+		wait = h.retryMax
+	}
+	log.Printf("WARN: Message at offset %d of %s/%d failed, retrying in %s: %v", msg.Offset, msg.Topic, msg.Partition, wait, cause)
+
+	go func() {
+		select {
+		case <-s.Context().Done():
+			return
+		case <-time.After(wait):
+		}
+		h.handle(s, msg, delivery+1, commit)
+	}()
+}
@@ -0,0 +1,284 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/eventbus"
+)
+
+// fakeSession records marked offsets
+type fakeSession struct {
+	ctx context.Context
+
+	mu     sync.Mutex
+	marked int64
+}
+
+func (s *fakeSession) Claims() map[string][]int32                                   { return nil }
+func (s *fakeSession) MemberID() string                                             { return "member" }
+func (s *fakeSession) GenerationID() int32                                          { return 1 }
+func (s *fakeSession) ResetOffset(topic string, partition int32, o int64, m string) {}
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string)     {}
+func (s *fakeSession) Commit()                                                      {}
+func (s *fakeSession) Context() context.Context                                     { return s.ctx }
+
+func (s *fakeSession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marked = max(s.marked, offset)
+}
+
+func (s *fakeSession) Marked() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.marked
+}
+
+// fakeClaim delivers a fixed list of messages
+type fakeClaim struct {
+	messages chan *sarama.ConsumerMessage
+}
+
+func newFakeClaim(n int) *fakeClaim {
+	c := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, n)}
+	for i := 0; i < n; i++ {
+		c.messages <- &sarama.ConsumerMessage{Topic: "build-logs", Offset: int64(i), Value: []byte{byte(i)}}
+	}
+	close(c.messages)
+	return c
+}
+
+func (c *fakeClaim) Topic() string                            { return "build-logs" }
+func (c *fakeClaim) Partition() int32                         { return 0 }
+func (c *fakeClaim) InitialOffset() int64                     { return 0 }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return int64(cap(c.messages)) }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+// fakeHandler acknowledges each message with the result of ack
+type fakeHandler struct {
+	ack func(value byte, delivery int) error
+
+	mu         sync.Mutex
+	deliveries map[byte]int
+}
+
+func (h *fakeHandler) Handle(ctx context.Context, msg eventbus.Message, ack eventbus.AckFunc) error {
+	h.mu.Lock()
+	if h.deliveries == nil {
+		h.deliveries = make(map[byte]int)
+	}
+	h.deliveries[msg.Value[0]]++
+	delivery := h.deliveries[msg.Value[0]]
+	h.mu.Unlock()
+
+	// Acknowledge later, from another goroutine, like the log processor
+	go ack(h.ack(msg.Value[0], delivery))
+	return nil
+}
+
+func (h *fakeHandler) Flush(ctx context.Context) error { return nil }
+
+func (h *fakeHandler) Deliveries(value byte) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.deliveries[value]
+}
+
+func newTestGroupHandler(handler eventbus.Handler, publish publishFunc) *groupHandler {
+	gh := newGroupHandler(handler, 4, publish)
+	gh.retryMin = time.Millisecond
+	gh.retryMax = 5 * time.Millisecond
+	return gh
+}
+
+func waitForOffset(t *testing.T, s *fakeSession, want int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Marked() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("marked offset = %d, want %d", s.Marked(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConsumeClaimCommitsAllAcknowledged(t *testing.T) {
+	s := &fakeSession{ctx: context.Background()}
+	h := &fakeHandler{ack: func(byte, int) error { return nil }}
+
+	if err := newTestGroupHandler(h, nil).ConsumeClaim(s, newFakeClaim(20)); err != nil {
+		t.Fatalf("ConsumeClaim: %v", err)
+	}
+	waitForOffset(t, s, 20)
+}
+
+func TestConsumeClaimRedeliversFailedMessage(t *testing.T) {
+	s := &fakeSession{ctx: context.Background()}
+	// Message 3 fails twice, then is stored
+	h := &fakeHandler{ack: func(value byte, delivery int) error {
+		if value == 3 && delivery <= 2 {
+			return errors.New("clickhouse unavailable")
+		}
+		return nil
+	}}
+
+	if err := newTestGroupHandler(h, nil).ConsumeClaim(s, newFakeClaim(10)); err != nil {
+		t.Fatalf("ConsumeClaim: %v", err)
+	}
+	waitForOffset(t, s, 10)
+	if n := h.Deliveries(3); n != 3 {
+		t.Fatalf("message 3 delivered %d times, want 3", n)
+	}
+	if n := h.Deliveries(4); n != 1 {
+		t.Fatalf("message 4 delivered %d times, want 1", n)
+	}
+}
+
+func TestConsumeClaimDeadLettersPoisonMessage(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		if msg.Topic != "build-logs"+DeadLetterSuffix {
+			return errors.New("unexpected topic " + msg.Topic)
+		}
+		value, _ := msg.Value.Encode()
+		if len(value) != 1 || value[0] != 5 {
+			return errors.New("unexpected message")
+		}
+		return nil
+	})
+	bus := &Bus{producer: producer}
+
+	s := &fakeSession{ctx: context.Background()}
+	h := &fakeHandler{ack: func(value byte, delivery int) error {
+		if value == 5 {
+			return errors.New("always fails")
+		}
+		return nil
+	}}
+
+	if err := newTestGroupHandler(h, bus.Publish).ConsumeClaim(s, newFakeClaim(8)); err != nil {
+		t.Fatalf("ConsumeClaim: %v", err)
+	}
+	waitForOffset(t, s, 8)
+	if n := h.Deliveries(5); n != maxDeliveries {
+		t.Fatalf("message 5 delivered %d times, want %d", n, maxDeliveries)
+	}
+	if err := producer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConsumeClaimStopsRetryingWithSession(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &fakeSession{ctx: ctx}
+	h := &fakeHandler{ack: func(value byte, delivery int) error {
+		if value == 1 {
+			return errors.New("always fails")
+		}
+		return nil
+	}}
+
+	gh := newTestGroupHandler(h, func(context.Context, string, []byte, []byte) error {
+		t.Error("message was dead-lettered after the session ended")
+		return nil
+	})
+	gh.retryMin = 50 * time.Millisecond
+	gh.retryMax = 50 * time.Millisecond
+	if err := gh.ConsumeClaim(s, newFakeClaim(3)); err != nil {
+		t.Fatalf("ConsumeClaim: %v", err)
+	}
+	waitForOffset(t, s, 1)
+	cancel()
+
+	time.Sleep(150 * time.Millisecond)
+	// Offset 1 stays uncommitted so the next owner of the partition gets it
+	if s.Marked() != 1 {
+		t.Fatalf("marked offset = %d, want 1", s.Marked())
+	}
+	if n := h.Deliveries(1); n > 2 {
+		t.Fatalf("message 1 delivered %d times after the session ended", n)
+	}
+}
+
+// orderHandler records the order in which messages of each key are handled
+type orderHandler struct {
+	mu    sync.Mutex
+	order map[string][]byte
+	busy  map[string]bool
+	t     *testing.T
+}
+
+func (h *orderHandler) Handle(ctx context.Context, msg eventbus.Message, ack eventbus.AckFunc) error {
+	key := string(msg.Key)
+	h.mu.Lock()
+	if h.busy[key] {
+		h.t.Errorf("message %d of %s handled while another of the key was", msg.Value[0], key)
+	}
+	h.busy[key] = true
+	h.mu.Unlock()
+
+	// Later messages finish first if they are handled concurrently
+	time.Sleep(time.Duration(10-msg.Value[0]%10) * 100 * time.Microsecond)
+
+	h.mu.Lock()
+	h.order[key] = append(h.order[key], msg.Value[0])
+	h.busy[key] = false
+	h.mu.Unlock()
+
+	go ack(nil)
+	return nil
+}
+
+func (h *orderHandler) Flush(ctx context.Context) error { return nil }
+
+func TestConsumeClaimKeepsOrderWithinKey(t *testing.T) {
+	keys := []string{"dep-1", "dep-2", "dep-3"}
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 60)}
+	for i := 0; i < 60; i++ {
+		claim.messages <- &sarama.ConsumerMessage{Topic: "build-logs", Offset: int64(i), Key: []byte(keys[i%len(keys)]), Value: []byte{byte(i)}}
+	}
+	close(claim.messages)
+
+	s := &fakeSession{ctx: context.Background()}
+	h := &orderHandler{order: make(map[string][]byte), busy: make(map[string]bool), t: t}
+
+	if err := newTestGroupHandler(h, nil).ConsumeClaim(s, claim); err != nil {
+		t.Fatalf("ConsumeClaim: %v", err)
+	}
+	waitForOffset(t, s, 60)
+
+	for k, key := range keys {
+		got := h.order[key]
+		if len(got) != 20 {
+			t.Fatalf("%s: handled %d messages, want 20", key, len(got))
+		}
+		for i, value := range got {
+			if want := byte(k + i*len(keys)); value != want {
+				t.Fatalf("%s: handled %v, want offsets in order", key, got)
+			}
+		}
+	}
+}
+
+func TestWorkerPoolRunsKeysConcurrently(t *testing.T) {
+	pool := NewWorkerPool(2)
+	blocked := make(chan struct{})
+	done := make(chan struct{})
+
+	pool.SubmitOrdered("a", func() { <-blocked })
+	pool.SubmitOrdered("b", func() { close(done) })
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a blocked key held up another key")
+	}
+	close(blocked)
+}
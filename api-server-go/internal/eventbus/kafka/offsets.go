@@ -0,0 +1,44 @@
+package kafka
+
+import "sync"
+
+// offsetTracker records which offsets of a partition have been acknowledged
+// and reports the highest offset below which everything is done. Messages are
+// processed concurrently, so acks arrive out of order; committing only the
+// contiguous prefix keeps unacknowledged messages from being skipped.
+type offsetTracker struct {
+	mu      sync.Mutex
+	pending []int64
+	acked   map[int64]bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{acked: make(map[int64]bool)}
+}
+
+// add registers an offset in arrival order
+func (t *offsetTracker) add(offset int64) {
+	t.mu.Lock()
+	t.pending = append(t.pending, offset)
+	t.mu.Unlock()
+}
+
+// ack marks an offset done. It returns the highest offset that is safe to
+// commit and true if that watermark advanced.
+func (t *offsetTracker) ack(offset int64) (int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.acked[offset] = true
+
+	var committed int64
+	advanced := false
+	for len(t.pending) > 0 && t.acked[t.pending[0]] {
+		committed = t.pending[0]
+		delete(t.acked, committed)
+		t.pending = t.pending[1:]
+		advanced = true
+	}
+
+	return committed, advanced
+}
@@ -0,0 +1,55 @@
+package kafka
+
+import "sync"
+
+type WorkerPool struct {
+	sem chan struct{}
+
+	// queues holds the work waiting behind a running function of the same
+	// key
+	mu     sync.Mutex
+	queues map[string][]func()
+}
+
+func NewWorkerPool(size int) *WorkerPool {
+	return &WorkerPool{sem: make(chan struct{}, size), queues: make(map[string][]func())}
+}
+
+func (p *WorkerPool) Submit(fn func()) {
+	p.sem <- struct{}{}
+	go func() {
+		defer func() { <-p.sem }()
+		fn()
+	}()
+}
+
+// SubmitOrdered runs fn after every function submitted before it with the
+// same key has returned. Functions of different keys run concurrently.
+func (p *WorkerPool) SubmitOrdered(key string, fn func()) {
+	p.mu.Lock()
+	queue, running := p.queues[key]
+	p.queues[key] = append(queue, fn)
+	p.mu.Unlock()
+
+	if !running {
+		p.Submit(func() { p.drain(key) })
+	}
+}
+
+// drain runs the functions queued for key until none are left
+func (p *WorkerPool) drain(key string) {
+	for {
+		p.mu.Lock()
+		queue := p.queues[key]
+		if len(queue) == 0 {
+			delete(p.queues, key)
+			p.mu.Unlock()
+			return
+		}
+		fn := queue[0]
+		p.queues[key] = queue[1:]
+		p.mu.Unlock()
+
+		fn()
+	}
+}
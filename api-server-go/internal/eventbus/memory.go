@@ -0,0 +1,162 @@
+package eventbus
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+)
+
+// MemoryBus is an in-process bus for tests and single-process development.
+// Topics keep every message; a group created by its first Subscribe starts
+// from the oldest one, like a new Kafka consumer group.
+type MemoryBus struct {
+	mu     sync.Mutex
+	topics map[string]*memoryTopic
+	closed bool
+	// notify is closed and replaced whenever something changes
+	notify chan struct{}
+}
+
+type memoryTopic struct {
+	messages []Message
+	groups   map[string]*memoryGroup
+}
+
+// memoryGroup tracks delivery within one consumer group
+type memoryGroup struct {
+	next int
+	// retry holds messages acknowledged with an error
+	retry []Message
+}
+
+// NewMemoryBus creates an empty bus
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		topics: make(map[string]*memoryTopic),
+		notify: make(chan struct{}),
+	}
+}
+
+func (b *MemoryBus) Name() string {
+	return "memory"
+}
+
+func (b *MemoryBus) topic(name string) *memoryTopic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &memoryTopic{groups: make(map[string]*memoryGroup)}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// wake signals waiting subscribers; b.mu must be held
+func (b *MemoryBus) wake() {
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+func (b *MemoryBus) Publish(ctx context.Context, topic string, key, value []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+
+	t := b.topic(topic)
+	t.messages = append(t.messages, Message{
+		Topic: topic,
+		Key:   key,
+		Value: value,
+		ID:    strconv.Itoa(len(t.messages)),
+	})
+	b.wake()
+	return nil
+}
+
+func (b *MemoryBus) Subscribe(ctx context.Context, topic, group string, handler Handler) error {
+	defer func() {
+		if err := handler.Flush(context.WithoutCancel(ctx)); err != nil {
+			log.Printf("WARN: Failed to flush %s subscriber of %s: %v", group, topic, err)
+		}
+	}()
+
+	for {
+		msg, notify, ok := b.next(topic, group)
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-notify:
+				if b.isClosed() {
+					return ErrClosed
+				}
+			}
+			continue
+		}
+
+		err := handler.Handle(ctx, msg, func(err error) {
+			if err != nil {
+				b.requeue(topic, group, msg)
+			}
+		})
+		if err != nil {
+			log.Printf("ERROR: Failed to process message %s of %s: %v", msg.ID, topic, err)
+		}
+	}
+}
+
+// next takes the next message for a group. When there is none it returns
+// the channel that is closed once that may change.
+func (b *MemoryBus) next(topic, group string) (Message, <-chan struct{}, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t := b.topic(topic)
+	g, ok := t.groups[group]
+	if !ok {
+		g = &memoryGroup{}
+		t.groups[group] = g
+	}
+
+	if len(g.retry) > 0 {
+		msg := g.retry[0]
+		g.retry = g.retry[1:]
+		return msg, nil, true
+	}
+	if g.next < len(t.messages) {
+		msg := t.messages[g.next]
+		g.next++
+		return msg, nil, true
+	}
+	return Message{}, b.notify, false
+}
+
+func (b *MemoryBus) requeue(topic, group string, msg Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.topic(topic).groups[group]
+	g.retry = append(g.retry, msg)
+	b.wake()
+}
+
+func (b *MemoryBus) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// Close stops all subscriptions
+func (b *MemoryBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.closed {
+		b.closed = true
+		b.wake()
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+// Package redis implements eventbus.Bus on Redis Streams. Each topic is a
+// stream and each group a stream consumer group.
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/eventbus"
+)
+
+const (
+	// readCount and readBlock bound one XREADGROUP call
+	readCount = 100
+	readBlock = 5 * time.Second
+
+	// Entries left pending this long, because their handler failed or their
+	// consumer died, are claimed and delivered again
+	claimIdle     = time.Minute
+	claimInterval = 30 * time.Second
+
+	// retryMin and retryMax bound the wait between reconnection attempts
+	retryMin = time.Second
+	retryMax = time.Minute
+
+	ackTimeout = 10 * time.Second
+
+	fieldKey   = "key"
+	fieldValue = "value"
+)
+
+// Bus publishes and consumes through Redis Streams
+type Bus struct {
+	client *goredis.Client
+	// maxLen caps stream length approximately; 0 keeps every entry
+	maxLen   int64
+	consumer string
+}
+
+// New creates a bus on client. Streams are trimmed to about maxLen entries
+// when maxLen is positive.
+func New(client *goredis.Client, maxLen int64) *Bus {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "api-server"
+	}
+	return &Bus{
+		client:   client,
+		maxLen:   maxLen,
+		consumer: fmt.Sprintf("%s-%d", host, os.Getpid()),
+	}
+}
+
+// NewFromURL creates a bus from a redis:// or rediss:// URL
+func NewFromURL(url string, maxLen int64) (*Bus, error) {
+	opts, err := goredis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("parse REDIS_URL: %w", err)
+	}
+	return New(goredis.NewClient(opts), maxLen), nil
+}
+
+func (b *Bus) Name() string {
+	return "redis"
+}
+
+func (b *Bus) Publish(ctx context.Context, topic string, key, value []byte) error {
+	args := &goredis.XAddArgs{
+		Stream: topic,
+		Values: map[string]any{fieldKey: key, fieldValue: value},
+	}
+	if b.maxLen > 0 {
+		args.MaxLen = b.maxLen
+		args.Approx = true
+	}
+	if err := b.client.XAdd(ctx, args).Err(); err != nil {
+		if errors.Is(err, goredis.ErrClosed) {
+			return eventbus.ErrClosed
+		}
+		return fmt.Errorf("publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Subscribe reads topic as a member of group until ctx is cancelled. The
+// group is created at the start of the stream if it does not exist. Errors
+// reaching Redis are logged and retried with backoff.
+func (b *Bus) Subscribe(ctx context.Context, topic, group string, handler eventbus.Handler) error {
+	defer func() {
+		if err := handler.Flush(context.WithoutCancel(ctx)); err != nil {
+			log.Printf("WARN: Failed to flush %s subscriber of %s: %v", group, topic, err)
+		}
+	}()
+
+	backoff := retryMin
+	var lastClaim time.Time
+
+	for ctx.Err() == nil {
+		err := b.ensureGroup(ctx, topic, group)
+		if err == nil && time.Since(lastClaim) >= claimInterval {
+			err = b.claim(ctx, topic, group, handler)
+			lastClaim = time.Now()
+		}
+		if err == nil {
+			err = b.read(ctx, topic, group, handler)
+		}
+
+		switch {
+		case err == nil:
+			backoff = retryMin
+			continue
+		case ctx.Err() != nil:
+			return nil
+		case errors.Is(err, goredis.ErrClosed):
+			return eventbus.ErrClosed
+		}
+
+		log.Printf("WARN: Redis consumer %s of %s failed, retrying in %s: %v", group, topic, backoff, err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, retryMax)
+	}
+	return nil
+}
+
+// ensureGroup creates the consumer group, and the stream with it
+func (b *Bus) ensureGroup(ctx context.Context, topic, group string) error {
+	err := b.client.XGroupCreateMkStream(ctx, topic, group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("create group %s: %w", group, err)
+	}
+	return nil
+}
+
+// read delivers the next new entries
+func (b *Bus) read(ctx context.Context, topic, group string, handler eventbus.Handler) error {
+	streams, err := b.client.XReadGroup(ctx, &goredis.XReadGroupArgs{
+		Group:    group,
+		Consumer: b.consumer,
+		Streams:  []string{topic, ">"},
+		Count:    readCount,
+		Block:    readBlock,
+	}).Result()
+	if errors.Is(err, goredis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, stream := range streams {
+		for _, entry := range stream.Messages {
+			b.deliver(ctx, topic, group, entry, handler)
+		}
+	}
+	return nil
+}
+
+// claim takes over entries that stayed pending too long and delivers them
+func (b *Bus) claim(ctx context.Context, topic, group string, handler eventbus.Handler) error {
+	start := "0-0"
+	for {
+		entries, next, err := b.client.XAutoClaim(ctx, &goredis.XAutoClaimArgs{
+			Stream:   topic,
+			Group:    group,
+			Consumer: b.consumer,
+			MinIdle:  claimIdle,
+			Start:    start,
+			Count:    readCount,
+		}).Result()
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			b.deliver(ctx, topic, group, entry, handler)
+		}
+		if next == "0-0" || len(entries) == 0 {
+			return nil
+		}
+		start = next
+	}
+}
+
+func (b *Bus) deliver(ctx context.Context, topic, group string, entry goredis.XMessage, handler eventbus.Handler) {
+	ack := func(err error) {
+		if err != nil {
+			// Left pending; claim delivers it again once it is idle
+			return
+		}
+		ackCtx, cancel := context.WithTimeout(context.Background(), ackTimeout)
+		defer cancel()
+		if err := b.client.XAck(ackCtx, topic, group, entry.ID).Err(); err != nil {
+			log.Printf("WARN: Failed to acknowledge %s of %s: %v", entry.ID, topic, err)
+		}
+	}
+
+	msg := eventbus.Message{
+		Topic: topic,
+		Key:   field(entry, fieldKey),
+		Value: field(entry, fieldValue),
+		ID:    entry.ID,
+	}
+	if err := handler.Handle(ctx, msg, ack); err != nil {
+		// Unprocessable entries are skipped rather than redelivered forever
+		log.Printf("ERROR: Failed to process message %s of %s: %v", entry.ID, topic, err)
+		ack(nil)
+	}
+}
+
+func field(entry goredis.XMessage, name string) []byte {
+	if v, ok := entry.Values[name].(string); ok {
+		return []byte(v)
+	}
+	return nil
+}
+
+func (b *Bus) Close() error {
+	return b.client.Close()
+}
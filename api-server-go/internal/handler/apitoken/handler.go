@@ -0,0 +1,162 @@
+package apitoken
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/apitoken"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/middleware"
+	projectRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/project"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/apitokens"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
+)
+
+// maxNameLength bounds token names, which are only labels
+const maxNameLength = 100
+
+type Handler struct {
+	tokens      *apitokens.Service
+	projectRepo *projectRepository.Repository
+}
+
+func NewHandler(tokens *apitokens.Service, projectRepo *projectRepository.Repository) *Handler {
+	return &Handler{
+		tokens:      tokens,
+		projectRepo: projectRepo,
+	}
+}
+
+// ListTokens handles GET /tokens
+// Returns the user's API tokens with their scopes, restrictions and when
+// they were last used. The tokens themselves are not stored.
+func (h *Handler) ListTokens(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, "Unauthorized")
+		return
+	}
+
+	tokens, err := h.tokens.List(r.Context(), user.ID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to fetch API tokens")
+		return
+	}
+
+	utils.Success(w, tokens)
+}
+
+// CreateToken handles POST /tokens
+// Request body: { "name": string, "scopes": string[], "project_id"?: string, "expires_at"?: string }
+// project_id restricts the token to one of the user's projects; expires_at
+// is an RFC3339 time in the future. The token is only returned in this
+// response, as token.
+func (h *Handler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, "Unauthorized")
+		return
+	}
+
+	type CreateTokenRequest struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ProjectID *string    `json:"project_id"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	var req CreateTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Name == "" || len(req.Name) > maxNameLength {
+		utils.BadRequest(w, "Invalid request body. name is required and at most 100 characters")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		utils.BadRequest(w, "Invalid request body. At least one scope is required")
+		return
+	}
+	scopes := make([]apitoken.Scope, 0, len(req.Scopes))
+	seen := make(map[apitoken.Scope]bool, len(req.Scopes))
+	for _, s := range req.Scopes {
+		scope, err := apitoken.ParseScope(s)
+		if err != nil {
+			utils.BadRequest(w, "Invalid request body. "+err.Error())
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		utils.BadRequest(w, "Invalid request body. expires_at must be in the future")
+		return
+	}
+
+	if req.ProjectID != nil {
+		if !utils.IsValidUUID(*req.ProjectID) {
+			utils.BadRequest(w, "Invalid request body. Invalid project ID")
+			return
+		}
+		if _, err := h.projectRepo.GetByIDAndUserID(r.Context(), *req.ProjectID, user.ID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.NotFound(w, "Project not found")
+				return
+			}
+			utils.InternalServerError(w, "Failed to fetch project")
+			return
+		}
+	}
+
+	t := &apitoken.Token{
+		UserID:    user.ID,
+		Name:      req.Name,
+		Scopes:    scopes,
+		ProjectID: req.ProjectID,
+		ExpiresAt: req.ExpiresAt,
+	}
+	token, err := h.tokens.Create(r.Context(), t)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to create API token")
+		return
+	}
+
+	utils.Created(w, struct {
+		*apitoken.Token
+		Secret string `json:"token"`
+	}{t, token}, "API token created successfully. Copy it now; it is not shown again")
+}
+
+// DeleteToken handles DELETE /tokens/:id
+// Revokes a token; requests made with it fail from then on
+func (h *Handler) DeleteToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, "Unauthorized")
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if !utils.IsValidUUID(id) {
+		utils.BadRequest(w, "Invalid token ID")
+		return
+	}
+
+	if err := h.tokens.Revoke(r.Context(), user.ID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.NotFound(w, "API token not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to revoke API token")
+		return
+	}
+
+	utils.Success(w, nil, "API token revoked successfully")
+}
@@ -0,0 +1,28 @@
+package apitoken
+
+import (
+	"database/sql"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/auth"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/middleware"
+	projectRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/project"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/apitokens"
+)
+
+// Routes serves the signed-in user's API tokens. Tokens cannot manage
+// tokens, so a leaked one cannot mint more.
+func Routes(db *sql.DB, jwks *auth.JWKSCache, tokens *apitokens.Service) chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middleware.AuthMiddleware(jwks, tokens))
+	r.Use(middleware.RequireSession)
+
+	h := NewHandler(tokens, projectRepository.New(db))
+
+	r.Get("/", h.ListTokens)
+	r.Post("/", h.CreateToken)
+	r.Delete("/{id}", h.DeleteToken)
+
+	return r
+}
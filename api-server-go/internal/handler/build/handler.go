@@ -0,0 +1,235 @@
+package build
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/config"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/middleware"
+	projectRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/project"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/artifacts"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/gitcredentials"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/gitprovider"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/ingest"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
+)
+
+// maxUploadsPerRequest bounds how many URLs one request signs
+const maxUploadsPerRequest = 500
+
+// Limits of one batch of build events
+const (
+	maxEventsPerRequest = 5000
+	maxEventBodyBytes   = 10 << 20
+	maxEventLineBytes   = 1 << 20
+)
+
+// Handler serves the endpoints build containers call with their build token
+type Handler struct {
+	presigner      *artifacts.Presigner
+	ingester       *ingest.Ingester
+	projects       *projectRepository.Repository
+	gitCredentials *gitcredentials.Service
+	cfg            config.BuildAuthConfig
+}
+
+func NewHandler(presigner *artifacts.Presigner, ingester *ingest.Ingester, projects *projectRepository.Repository, gitCredentials *gitcredentials.Service, cfg config.BuildAuthConfig) *Handler {
+	return &Handler{
+		presigner:      presigner,
+		ingester:       ingester,
+		projects:       projects,
+		gitCredentials: gitCredentials,
+		cfg:            cfg,
+	}
+}
+
+// CreateUploads handles POST /internal/deployments/:id/uploads
+// Returns presigned PUT URLs for build output, limited to the deployment's
+// artifact prefix (<project_id>/<deployment_id>/) and expiring before the
+// build token does, so a build cannot overwrite other deployments' files
+// Request body: { "files": [{ "path": string, "contentType"?: string }] }
+func (h *Handler) CreateUploads(w http.ResponseWriter, r *http.Request) {
+	grant, ok := middleware.GetBuildGrantFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, "Unauthorized")
+		return
+	}
+
+	if h.presigner == nil {
+		utils.Error(w, http.StatusServiceUnavailable, "Artifact storage is not configured", "")
+		return
+	}
+
+	type File struct {
+		Path        string `json:"path"`
+		ContentType string `json:"contentType"`
+	}
+	type CreateUploadsRequest struct {
+		Files []File `json:"files"`
+	}
+
+	var req CreateUploadsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body")
+		return
+	}
+	if len(req.Files) == 0 || len(req.Files) > maxUploadsPerRequest {
+		utils.BadRequest(w, "Invalid request body. Between 1 and 500 files are required")
+		return
+	}
+
+	ttl := h.cfg.UploadURLTTL
+	if untilExpiry := time.Until(grant.ExpiresAt); untilExpiry < ttl {
+		ttl = untilExpiry
+	}
+	expiresAt := time.Now().Add(ttl)
+
+	uploads := make([]artifacts.Upload, 0, len(req.Files))
+	for _, f := range req.Files {
+		upload, err := h.presigner.PresignUpload(r.Context(), artifacts.Prefix(grant.ProjectID, grant.DeploymentID), f.Path, f.ContentType, ttl)
+		if err != nil {
+			if errors.Is(err, artifacts.ErrInvalidPath) {
+				utils.BadRequest(w, err.Error())
+				return
+			}
+			utils.InternalServerError(w, "Failed to sign upload")
+			return
+		}
+		uploads = append(uploads, upload)
+	}
+
+	utils.Success(w, map[string]interface{}{
+		"uploads":   uploads,
+		"expiresAt": expiresAt,
+	})
+}
+
+// GetLogCredentials handles GET /internal/deployments/:id/credentials/logs
+// Returns where the build posts its logs: IngestEvents, authenticated with
+// the build token. Builds have no Kafka credential, so every line they send
+// is attributed to the deployment of the token and is refused once it
+// expires or the deployment finishes.
+func (h *Handler) GetLogCredentials(w http.ResponseWriter, r *http.Request) {
+	grant, ok := middleware.GetBuildGrantFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, "Unauthorized")
+		return
+	}
+
+	utils.Success(w, map[string]interface{}{
+		"transport": "http",
+		"url":       strings.TrimSuffix(h.cfg.APIURL, "/") + "/internal/deployments/" + grant.DeploymentID + "/events",
+		"expiresAt": grant.ExpiresAt,
+	})
+}
+
+// GetGitCredentials handles GET /internal/deployments/:id/credentials/git
+// Returns what the build clones the project's repository with: nothing for
+// a public repository, the project's deploy key, or a GitHub App token that
+// can only read the repository and expires within the hour. Credentials are
+// decrypted or created per request and never stored in the build's
+// environment.
+func (h *Handler) GetGitCredentials(w http.ResponseWriter, r *http.Request) {
+	grant, ok := middleware.GetBuildGrantFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, "Unauthorized")
+		return
+	}
+
+	gitURL, userID, err := h.projects.GetGitURL(r.Context(), grant.ProjectID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.NotFound(w, "Project not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to fetch project")
+		return
+	}
+
+	credential, err := h.gitCredentials.ForBuild(r.Context(), userID, grant.ProjectID, gitURL)
+	if err != nil {
+		switch {
+		case errors.Is(err, gitprovider.ErrNotInstalled):
+			utils.Error(w, http.StatusUnprocessableEntity, "The GitHub App is not installed on the repository", "")
+		case errors.Is(err, gitprovider.ErrNotLinked):
+			utils.Error(w, http.StatusUnprocessableEntity, "The project owner has not linked the GitHub App installation of the repository", "")
+		case errors.Is(err, gitcredentials.ErrDeployKeysDisabled), errors.Is(err, gitcredentials.ErrGitHubAppDisabled):
+			utils.Error(w, http.StatusServiceUnavailable, "Git credential is not available", err.Error())
+		default:
+			log.Printf("ERROR: failed to issue git credential for deployment %s: %v", grant.DeploymentID, err)
+			utils.InternalServerError(w, "Failed to issue git credential")
+		}
+		return
+	}
+
+	utils.Success(w, credential)
+}
+
+// IngestEvents handles POST /internal/deployments/:id/events
+// Accepts a batch of build events as NDJSON, one
+// { "seq": number, "log": string, "stream"?: "stdout" | "stderr" } per line.
+// seq numbers the deployment's events from 1; events already stored are
+// skipped, so a batch that failed or timed out can be sent again as is.
+// Responds once the new events are durable.
+func (h *Handler) IngestEvents(w http.ResponseWriter, r *http.Request) {
+	grant, ok := middleware.GetBuildGrantFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, "Unauthorized")
+		return
+	}
+
+	scanner := bufio.NewScanner(http.MaxBytesReader(w, r.Body, maxEventBodyBytes))
+	scanner.Buffer(make([]byte, 64*1024), maxEventLineBytes)
+
+	var events []buildlog.Event
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		var event buildlog.Event
+		if err := json.Unmarshal(text, &event); err != nil {
+			utils.BadRequest(w, fmt.Sprintf("Invalid event on line %d", line))
+			return
+		}
+		if event.Seq < 1 {
+			utils.BadRequest(w, fmt.Sprintf("Invalid event on line %d. seq must be a positive integer", line))
+			return
+		}
+		if event.Stream != "" {
+			if _, err := buildlog.ParseStream(event.Stream); err != nil {
+				utils.BadRequest(w, fmt.Sprintf("Invalid event on line %d. %v", line, err))
+				return
+			}
+		}
+
+		events = append(events, event)
+		if len(events) > maxEventsPerRequest {
+			utils.BadRequest(w, fmt.Sprintf("Too many events. At most %d are accepted per request", maxEventsPerRequest))
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	result, err := h.ingester.Ingest(r.Context(), grant.DeploymentID, grant.ProjectID, events)
+	if err != nil {
+		log.Printf("ERROR: failed to ingest build events for deployment %s: %v", grant.DeploymentID, err)
+		utils.Error(w, http.StatusServiceUnavailable, "Failed to store build events; retry the batch", "")
+		return
+	}
+
+	utils.Success(w, result)
+}
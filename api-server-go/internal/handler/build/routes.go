@@ -0,0 +1,60 @@
+package build
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/config"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/kafka/consumer"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/middleware"
+	repository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/deployment"
+	projectRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/project"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/artifacts"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/buildauth"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/gitcredentials"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/ingest"
+)
+
+// Routes are called by build containers, not users; they are authenticated
+// with the deployment's build token. Events posted over HTTP are applied by
+// processor, like those read from Kafka.
+func Routes(db *sql.DB, processor *consumer.Processor, gitCredentials *gitcredentials.Service) chi.Router {
+	r := chi.NewRouter()
+
+	cfg := config.GetBuildAuthConfig()
+	deploymentRepo := repository.New(db)
+	tokens := buildauth.New(deploymentRepo, cfg.TokenTTL)
+
+	r2 := config.GetR2Config()
+	presigner := artifacts.NewPresigner(artifacts.Config{
+		AccountID:       r2.AccountID,
+		AccessKeyID:     r2.AccessKeyID,
+		SecretAccessKey: r2.SecretAccessKey,
+		Bucket:          r2.BucketName,
+		Endpoint:        r2.Endpoint,
+	})
+	if presigner == nil {
+		log.Println("Warning: R2 is not configured; builds cannot upload artifacts")
+	}
+
+	h := NewHandler(presigner, ingest.New(deploymentRepo, processor.HandleEvent), projectRepository.New(db), gitCredentials, cfg)
+
+	r.Route("/deployments/{id}", func(r chi.Router) {
+		r.Use(middleware.BuildTokenMiddleware(tokens))
+
+		// POST /internal/deployments/:id/uploads - Presign artifact uploads
+		r.Post("/uploads", h.CreateUploads)
+
+		// GET /internal/deployments/:id/credentials/logs - Get the log producer credential
+		r.Get("/credentials/logs", h.GetLogCredentials)
+
+		// GET /internal/deployments/:id/credentials/git - Get the repository clone credential
+		r.Get("/credentials/git", h.GetGitCredentials)
+
+		// POST /internal/deployments/:id/events - Ingest build events (NDJSON)
+		r.Post("/events", h.IngestEvents)
+	})
+
+	return r
+}
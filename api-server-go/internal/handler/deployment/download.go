@@ -0,0 +1,175 @@
+package deployment
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/middleware"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/logs"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
+)
+
+const (
+	downloadFormatText   = "text"
+	downloadFormatNDJSON = "ndjson"
+)
+
+var unsafeFilenameChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// DownloadDeploymentLogs handles GET /deployments/:id/logs/download
+// Streams the full build log as an attachment
+// Query params:
+//   - format: text (default) | ndjson
+//   - gzip: true to compress the response body
+//
+// Rows are written to the response as they are read from ClickHouse,
+// so memory use stays flat regardless of log size.
+func (h *Handler) DownloadDeploymentLogs(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, "Unauthorized")
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if !utils.IsValidUUID(id) {
+		utils.BadRequest(w, "Invalid deployment ID")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = downloadFormatText
+	}
+	if format != downloadFormatText && format != downloadFormatNDJSON {
+		utils.BadRequest(w, "format must be text or ndjson")
+		return
+	}
+
+	compress := false
+	if v := r.URL.Query().Get("gzip"); v != "" {
+		var err error
+		if compress, err = strconv.ParseBool(v); err != nil {
+			utils.BadRequest(w, "gzip must be true or false")
+			return
+		}
+	}
+
+	deployment, err := h.repo.GetByIDWithProject(r.Context(), id, user.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.NotFound(w, "Deployment not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to fetch deployment")
+		return
+	}
+	if !user.CanAccessProject(deployment.ProjectID) {
+		utils.NotFound(w, "Deployment not found")
+		return
+	}
+
+	project, err := h.projectRepo.GetByIDAndUserID(r.Context(), deployment.ProjectID, user.ID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to fetch project")
+		return
+	}
+
+	if h.logsService == nil {
+		utils.Error(w, http.StatusServiceUnavailable, "Log storage is not configured", "Service unavailable")
+		return
+	}
+
+	h.streamLogDownload(w, r, id, logFilename(project.Name, deployment.ID, format, compress), format, compress)
+}
+
+// streamLogDownload writes a deployment's logs as an attachment named
+// filename, reading them a page at a time
+func (h *Handler) streamLogDownload(w http.ResponseWriter, r *http.Request, id, filename, format string, compress bool) {
+	contentType := "text/plain; charset=utf-8"
+	if format == downloadFormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	if compress {
+		contentType = "application/gzip"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	var out io.Writer = w
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(w)
+		out = gz
+	}
+	buf := bufio.NewWriterSize(out, 32*1024)
+
+	var writeLine func(logs.LogEvent) error
+	if format == downloadFormatNDJSON {
+		enc := json.NewEncoder(buf)
+		writeLine = func(e logs.LogEvent) error { return enc.Encode(e) }
+	} else {
+		writeLine = func(e logs.LogEvent) error {
+			return writeTextLine(buf, e)
+		}
+	}
+
+	err := h.logsService.StreamDeploymentLogs(r.Context(), id, writeLine)
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		// Headers are already sent; the truncated body is all the client gets
+		log.Printf("Failed to stream logs for deployment %s: %v", id, err)
+	}
+}
+
+// writeTextLine renders a log event as "<timestamp> [<stream>] <line>"
+func writeTextLine(w *bufio.Writer, e logs.LogEvent) error {
+	ts := ""
+	if e.Timestamp != nil {
+		ts = e.Timestamp.UTC().Format(time.RFC3339Nano)
+	}
+	stream := e.Stream
+	if stream == "" {
+		stream = logs.StreamStdout
+	}
+	_, err := fmt.Fprintf(w, "%s [%s] %s\n", ts, stream, strings.TrimRight(e.Log, "\r\n"))
+	return err
+}
+
+// logFilename builds "<project>-<deployment>.<ext>" with a filesystem-safe project name
+func logFilename(projectName, deploymentID, format string, compress bool) string {
+	name := strings.Trim(unsafeFilenameChars.ReplaceAllString(strings.ToLower(projectName), "-"), "-")
+	if name == "" {
+		name = "deployment"
+	}
+
+	ext := ".log"
+	if format == downloadFormatNDJSON {
+		ext = ".ndjson"
+	}
+	if compress {
+		ext += ".gz"
+	}
+
+	return name + "-" + deploymentID + ext
+}
@@ -0,0 +1,124 @@
+package deployment
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	logsRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/logs"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/logs"
+)
+
+const testDeploymentID = "0b6f2c1e-4d8a-4f3b-9c27-5e1a7d9b3f60"
+
+func TestLogFilename(t *testing.T) {
+	tests := []struct {
+		project  string
+		format   string
+		compress bool
+		want     string
+	}{
+		{"site", downloadFormatText, false, "site-" + testDeploymentID + ".log"},
+		{"site", downloadFormatNDJSON, false, "site-" + testDeploymentID + ".ndjson"},
+		{"site", downloadFormatText, true, "site-" + testDeploymentID + ".log.gz"},
+		{"site", downloadFormatNDJSON, true, "site-" + testDeploymentID + ".ndjson.gz"},
+		{"My Site", downloadFormatText, false, "my-site-" + testDeploymentID + ".log"},
+		{"--my_site--", downloadFormatText, false, "my_site-" + testDeploymentID + ".log"},
+		{"Café & Bar", downloadFormatText, false, "caf-bar-" + testDeploymentID + ".log"},
+		// Nothing that could end the quoted filename or escape a directory
+		{`a"b\c/../d`, downloadFormatText, false, "a-b-c-d-" + testDeploymentID + ".log"},
+		{"!!!", downloadFormatText, false, "deployment-" + testDeploymentID + ".log"},
+		{"", downloadFormatText, false, "deployment-" + testDeploymentID + ".log"},
+	}
+
+	for _, tt := range tests {
+		if got := logFilename(tt.project, testDeploymentID, tt.format, tt.compress); got != tt.want {
+			t.Errorf("logFilename(%q, %s, gzip %v) = %q, want %q", tt.project, tt.format, tt.compress, got, tt.want)
+		}
+	}
+}
+
+func TestStreamLogDownload(t *testing.T) {
+	// More than two 5000-row pages, so the stream follows its cursor twice
+	const lines = 12001
+
+	store := logsRepository.NewMemoryStore()
+	events := make([]logs.LogEvent, lines)
+	for i := range events {
+		events[i] = logs.LogEvent{EventID: fmt.Sprintf("e%05d", i), DeploymentID: testDeploymentID, Log: fmt.Sprintf("line %05d", i)}
+	}
+	if err := store.Append(context.Background(), events); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	h := &Handler{logsService: logs.New(store)}
+
+	tests := []struct {
+		format      string
+		compress    bool
+		contentType string
+	}{
+		{downloadFormatText, false, "text/plain; charset=utf-8"},
+		{downloadFormatNDJSON, false, "application/x-ndjson"},
+		{downloadFormatText, true, "application/gzip"},
+		{downloadFormatNDJSON, true, "application/gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s gzip %v", tt.format, tt.compress), func(t *testing.T) {
+			filename := logFilename("site", testDeploymentID, tt.format, tt.compress)
+			r := httptest.NewRequest("GET", "/deployments/"+testDeploymentID+"/logs/download", nil)
+			w := httptest.NewRecorder()
+
+			h.streamLogDownload(w, r, testDeploymentID, filename, tt.format, tt.compress)
+
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Fatalf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if got, want := w.Header().Get("Content-Disposition"), `attachment; filename="`+filename+`"`; got != want {
+				t.Fatalf("Content-Disposition = %q, want %q", got, want)
+			}
+			if got := w.Header().Get("Cache-Control"); got != "no-store" {
+				t.Fatalf("Cache-Control = %q, want no-store", got)
+			}
+
+			var body io.Reader = w.Body
+			if tt.compress {
+				gz, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("gzip: %v", err)
+				}
+				body = gz
+			}
+
+			n := 0
+			scanner := bufio.NewScanner(body)
+			for scanner.Scan() {
+				want := fmt.Sprintf("line %05d", n)
+				if tt.format == downloadFormatNDJSON {
+					var e logs.LogEvent
+					if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+						t.Fatalf("line %d: %v", n, err)
+					}
+					if e.Log != want || e.DeploymentID != testDeploymentID {
+						t.Fatalf("line %d = %+v, want %q", n, e, want)
+					}
+				} else if !strings.HasSuffix(scanner.Text(), " [stdout] "+want) {
+					t.Fatalf("line %d = %q, want %q", n, scanner.Text(), want)
+				}
+				n++
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if n != lines {
+				t.Fatalf("downloaded %d lines, want %d", n, lines)
+			}
+		})
+	}
+}
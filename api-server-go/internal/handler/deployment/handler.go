@@ -1,36 +1,43 @@
 package deployment
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
 	"net/http"
-	"os"
+	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/job"
+	projectdomain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/project"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/middleware"
 	repository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/deployment"
 	projectRepo "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/project"
-	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/ecs"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/giturl"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/logs"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
 )
 
 type Handler struct {
-	repo        *repository.Repository
-	projectRepo *projectRepo.Repository
-	ecsService  *ecs.Service
-	logsService *logs.Service
+	repo          *repository.Repository
+	projectRepo   *projectRepo.Repository
+	logsService   *logs.Service
+	buildDefaults projectdomain.BuildDefaults
+	gitURLs       *giturl.Validator
 }
 
-func NewHandler(repo *repository.Repository, projectRepo *projectRepo.Repository, ecsService *ecs.Service, logsService *logs.Service) *Handler {
+func NewHandler(repo *repository.Repository, projectRepo *projectRepo.Repository, logsService *logs.Service, buildDefaults projectdomain.BuildDefaults, gitURLs *giturl.Validator) *Handler {
 	return &Handler{
-		repo:        repo,
-		projectRepo: projectRepo,
-		ecsService:  ecsService,
-		logsService: logsService,
+		repo:          repo,
+		projectRepo:   projectRepo,
+		logsService:   logsService,
+		buildDefaults: buildDefaults,
+		gitURLs:       gitURLs,
 	}
 }
 
@@ -51,6 +58,10 @@ func (h *Handler) GetDeploymentsByProject(w http.ResponseWriter, r *http.Request
 		utils.BadRequest(w, "Invalid project ID")
 		return
 	}
+	if !user.CanAccessProject(projectID) {
+		utils.NotFound(w, "Project not found")
+		return
+	}
 
 	// TODO: Fetch all deployments for the project
 	deployments, err := h.repo.GetByProjectID(r.Context(), projectID, user.ID)
@@ -69,7 +80,7 @@ func (h *Handler) GetDeploymentsByProject(w http.ResponseWriter, r *http.Request
 }
 
 // GetDeployment handles GET /deployments/:id
-// Returns a specific deployment
+// Returns a specific deployment with its status history and phase timings
 // Verifies user owns the parent project
 func (h *Handler) GetDeployment(w http.ResponseWriter, r *http.Request) {
 	user, ok := middleware.GetUserFromContext(r.Context())
@@ -89,18 +100,104 @@ func (h *Handler) GetDeployment(w http.ResponseWriter, r *http.Request) {
 	// TODO: Fetch deployment with project data
 	deployment, err := h.repo.GetByIDWithProject(r.Context(), id, user.ID)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.NotFound(w, "Deployment not found")
+			return
+		}
 		utils.InternalServerError(w, "Failed to fetch deployment")
 		return
 	}
+	if !user.CanAccessProject(deployment.ProjectID) {
+		utils.NotFound(w, "Deployment not found")
+		return
+	}
+
+	// Status history and phase durations
+	events, err := h.repo.ListEvents(r.Context(), deployment.ID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to fetch deployment events")
+		return
+	}
+	timings := deployment.ComputeTimings(time.Now())
+	deployment.Events = events
+	deployment.Timings = &timings
+
+	// Builds wait in the queue until the concurrency limits allow them to start
+	deployment.QueuePosition, err = h.queuePosition(r.Context(), deployment)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to fetch queue position")
+		return
+	}
 
 	// Return deployment object (matching Express API response)
 	utils.Success(w, deployment)
 }
 
+// queuePosition returns where a QUEUED deployment waits for a build slot, or
+// nil once its build has been started
+func (h *Handler) queuePosition(ctx context.Context, d deployment.Deployment) (*int, error) {
+	if d.Status != deployment.Queued {
+		return nil, nil
+	}
+
+	position, err := h.repo.QueuePosition(ctx, d.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &position, nil
+}
+
+// DeleteDeployment handles DELETE /deployments/:id
+// Deletes a deployment and schedules removal of its logs
+// Verifies user owns the parent project
+func (h *Handler) DeleteDeployment(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, "Unauthorized")
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	if !utils.IsValidUUID(id) {
+		utils.BadRequest(w, "Invalid deployment ID")
+		return
+	}
+
+	deployment, err := h.repo.GetByIDWithProject(r.Context(), id, user.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.NotFound(w, "Deployment not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to fetch deployment")
+		return
+	}
+	if !user.CanAccessProject(deployment.ProjectID) {
+		utils.NotFound(w, "Deployment not found")
+		return
+	}
+
+	if err := h.repo.Delete(r.Context(), deployment.ID); err != nil {
+		utils.InternalServerError(w, "Failed to delete deployment")
+		return
+	}
+
+	if h.logsService != nil {
+		h.logsService.ScheduleDeploymentLogDeletion(deployment.ID)
+	}
+
+	utils.Success(w, nil, "Deployment deleted successfully")
+}
+
 // CreateDeployment handles POST /deploy
 // Creates a new deployment and triggers the build process
-// Request body: { "project_id": string }
-// Queues the deployment to AWS ECS
+// Request body: { "project_id": string, "commit_sha"?: string, "ref"?: string, "pull_request"?: number }
+// Queues the deployment; the build dispatcher starts it on AWS ECS. With a
+// commit_sha that commit is built and its status reported to the git provider.
 func (h *Handler) CreateDeployment(w http.ResponseWriter, r *http.Request) {
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
@@ -109,7 +206,10 @@ func (h *Handler) CreateDeployment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type CreateDeploymentRequest struct {
-		ProjectID string `json:"project_id"`
+		ProjectID   string  `json:"project_id"`
+		CommitSHA   *string `json:"commit_sha"`
+		Ref         *string `json:"ref"`
+		PullRequest *int    `json:"pull_request"`
 	}
 
 	var req CreateDeploymentRequest
@@ -124,6 +224,24 @@ func (h *Handler) CreateDeployment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.CommitSHA != nil && !commitSHAPattern.MatchString(*req.CommitSHA) {
+		utils.BadRequest(w, "Invalid request body. commit_sha must be a full hexadecimal commit hash")
+		return
+	}
+	if (req.Ref != nil || req.PullRequest != nil) && req.CommitSHA == nil {
+		utils.BadRequest(w, "Invalid request body. ref and pull_request require commit_sha")
+		return
+	}
+	if req.PullRequest != nil && *req.PullRequest <= 0 {
+		utils.BadRequest(w, "Invalid request body. pull_request must be a positive number")
+		return
+	}
+
+	if !user.CanAccessProject(req.ProjectID) {
+		utils.NotFound(w, "Project not found")
+		return
+	}
+
 	// TODO: Verify project exists and user owns it
 	project, err := h.projectRepo.GetByIDAndUserID(r.Context(), req.ProjectID, user.ID)
 	if err != nil {
@@ -135,29 +253,41 @@ func (h *Handler) CreateDeployment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The repository is checked again before every build: its host may
+	// resolve differently now, and projects created before URLs were
+	// validated may point anywhere
+	gitURL, err := h.gitURLs.Validate(r.Context(), project.GitURL)
+	if err != nil {
+		utils.Error(w, http.StatusUnprocessableEntity, "Project repository URL is not allowed", err.Error())
+		return
+	}
+
 	// TODO: Create deployment with status "QUEUED"
-	deployment, err := h.repo.Create(r.Context(), &deployment.Deployment{ProjectID: req.ProjectID, Status: "QUEUED"})
-
-	// TODO: Prepare environment variables for ECS task
-	envVars := []ecs.EnvVar{
-		{Name: "PROJECT_ID", Value: req.ProjectID},
-		{Name: "GIT_REPOSITORY_URL", Value: project.GitURL},
-		{Name: "DEPLOYMENT_ID", Value: deployment.ID},
-		{Name: "KAFKA_BROKERS", Value: os.Getenv("KAFKA_BROKERS")},
-		{Name: "KAFKA_CLIENT_ID", Value: os.Getenv("KAFKA_CLIENT_ID")},
-		{Name: "KAFKA_USERNAME", Value: os.Getenv("KAFKA_USERNAME")},
-		{Name: "KAFKA_PASSWORD", Value: os.Getenv("KAFKA_PASSWORD")},
-		{Name: "R2_ACCOUNT_ID", Value: os.Getenv("R2_ACCOUNT_ID")},
-		{Name: "R2_ACCESS_KEY_ID", Value: os.Getenv("R2_ACCESS_KEY_ID")},
-		{Name: "R2_SECRET_ACCESS_KEY", Value: os.Getenv("R2_SECRET_ACCESS_KEY")},
-		{Name: "R2_BUCKET_NAME", Value: os.Getenv("R2_BUCKET_NAME")},
-	}
-
-	// TODO: Trigger AWS ECS task to build and deploy
-	_, err = h.ecsService.RunTask(r.Context(), envVars)
+	// The build is started by the dispatcher from the job written alongside
+	// the deployment, so a crash here can no longer orphan a QUEUED row
+	// Build settings are fixed when the deployment is created, so a later
+	// project change does not resize a queued build
+	preset, spot := project.BuildSettings(h.buildDefaults)
+	resources := preset.Resources()
+	payload := job.BuildPayload{
+		GitURL:    gitURL,
+		CommitSHA: derefString(req.CommitSHA),
+		Plan:      string(project.Plan),
+		Preset:    string(preset),
+		CPU:       resources.CPU,
+		MemoryMB:  resources.MemoryMB,
+		Spot:      spot,
+	}
+
+	deployment, err := h.repo.CreateWithBuildJob(r.Context(), &deployment.Deployment{
+		ProjectID:   req.ProjectID,
+		Status:      deployment.Queued,
+		CommitSHA:   req.CommitSHA,
+		GitRef:      req.Ref,
+		PullRequest: req.PullRequest,
+	}, payload)
 	if err != nil {
-		log.Printf("Failed to trigger ECS task: %v", err)
-		utils.InternalServerError(w, "Failed to trigger ECS task")
+		utils.InternalServerError(w, "Failed to create deployment")
 		return
 	}
 
@@ -170,9 +300,26 @@ func (h *Handler) CreateDeployment(w http.ResponseWriter, r *http.Request) {
 	}, "Build queued successfully")
 }
 
+// commitSHAPattern matches a full SHA-1 or SHA-256 commit hash
+var commitSHAPattern = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
 // GetDeploymentLogs handles GET /deployments/:id/logs
-// Returns logs for a specific deployment from ClickHouse
+// Returns one page of logs for a specific deployment from the log store
 // Verifies user owns the parent project
+// Query params:
+//   - cursor: opaque nextCursor from a previous page
+//   - limit: page size (default 500, max 5000)
+//   - since, until: RFC3339 timestamps bounding the log time
+//   - stream: stdout | stderr
+//   - search: case-insensitive substring, or RE2 pattern when regex=true
+//   - tail: return the last N matching lines instead of paging forward
 func (h *Handler) GetDeploymentLogs(w http.ResponseWriter, r *http.Request) {
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
@@ -188,6 +335,12 @@ func (h *Handler) GetDeploymentLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	query, err := parseLogQuery(r)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
 	// TODO: Fetch deployment with project data
 	deployment, err := h.repo.GetByIDWithProject(r.Context(), id, user.ID)
 	if err != nil {
@@ -198,26 +351,86 @@ func (h *Handler) GetDeploymentLogs(w http.ResponseWriter, r *http.Request) {
 		utils.InternalServerError(w, "Failed to fetch deployment")
 		return
 	}
+	if !user.CanAccessProject(deployment.ProjectID) {
+		utils.NotFound(w, "Deployment not found")
+		return
+	}
 
-	// Query logs service for deployment logs
-	// Always initialize as empty array to prevent null in JSON response
-	logEvents := make([]logs.LogEvent, 0)
+	if h.logsService == nil {
+		utils.Error(w, http.StatusServiceUnavailable, "Log storage is not configured", "Service unavailable")
+		return
+	}
 
-	if h.logsService != nil {
-		fetchedLogs, err := h.logsService.GetDeploymentLogs(r.Context(), id)
-		if err != nil {
-			utils.InternalServerError(w, "Failed to fetch logs")
-			return
-		}
-		// Only assign if we got results, otherwise keep empty array
-		if fetchedLogs != nil {
-			logEvents = fetchedLogs
-		}
+	page, err := h.logsService.QueryLogs(r.Context(), id, query)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to fetch logs")
+		return
 	}
 
-	// Return logs response (matching Express API response)
+	// Return logs response (matching Express API response, plus paging fields)
 	utils.Success(w, map[string]interface{}{
 		"deployment": deployment,
-		"logs":       logEvents,
+		"logs":       page.Logs,
+		"nextCursor": page.NextCursor,
+		"hasMore":    page.HasMore,
 	})
 }
+
+// parseLogQuery builds a logs.Query from the request's query string
+func parseLogQuery(r *http.Request) (logs.Query, error) {
+	params := r.URL.Query()
+	var q logs.Query
+
+	if v := params.Get("cursor"); v != "" {
+		cursor, err := logs.DecodeCursor(v)
+		if err != nil {
+			return q, err
+		}
+		q.After = &cursor
+	}
+
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return q, fmt.Errorf("limit must be a positive integer")
+		}
+		q.Limit = n
+	}
+
+	if v := params.Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return q, fmt.Errorf("tail must be a positive integer")
+		}
+		q.Tail = n
+	}
+
+	for name, dst := range map[string]**time.Time{"since": &q.Since, "until": &q.Until} {
+		if v := params.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return q, fmt.Errorf("%s must be an RFC3339 timestamp", name)
+			}
+			*dst = &t
+		}
+	}
+
+	if v := params.Get("stream"); v != "" {
+		stream, err := logs.ParseStream(v)
+		if err != nil {
+			return q, err
+		}
+		q.Stream = stream
+	}
+
+	q.Search = params.Get("search")
+	if v := params.Get("regex"); v != "" {
+		regex, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, fmt.Errorf("regex must be true or false")
+		}
+		q.Regex = regex
+	}
+
+	return q, q.Validate()
+}
@@ -0,0 +1,92 @@
+package deployment
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/logs"
+)
+
+func TestParseLogQuery(t *testing.T) {
+	cursor := logs.Cursor{Timestamp: time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC), EventID: "e1"}
+
+	tests := []struct {
+		name  string
+		query string
+		ok    bool
+		check func(t *testing.T, q logs.Query)
+	}{
+		{"empty", "", true, func(t *testing.T, q logs.Query) {
+			if q.Limit != logs.DefaultPageSize || q.After != nil || q.Tail != 0 {
+				t.Fatalf("query = %+v, want the defaults", q)
+			}
+		}},
+		{"limit", "limit=25", true, func(t *testing.T, q logs.Query) {
+			if q.Limit != 25 {
+				t.Fatalf("limit = %d, want 25", q.Limit)
+			}
+		}},
+		{"limit capped", "limit=100000", true, func(t *testing.T, q logs.Query) {
+			if q.Limit != logs.MaxPageSize {
+				t.Fatalf("limit = %d, want %d", q.Limit, logs.MaxPageSize)
+			}
+		}},
+		{"zero limit", "limit=0", false, nil},
+		{"limit not a number", "limit=ten", false, nil},
+		{"tail", "tail=100", true, func(t *testing.T, q logs.Query) {
+			if q.Tail != 100 {
+				t.Fatalf("tail = %d, want 100", q.Tail)
+			}
+		}},
+		{"negative tail", "tail=-1", false, nil},
+		{"cursor", "cursor=" + cursor.Encode(), true, func(t *testing.T, q logs.Query) {
+			if q.After == nil || !q.After.Timestamp.Equal(cursor.Timestamp) || q.After.EventID != cursor.EventID {
+				t.Fatalf("after = %+v, want %+v", q.After, cursor)
+			}
+		}},
+		{"malformed cursor", "cursor=%21%21%21", false, nil},
+		{"tail with cursor", "tail=10&cursor=" + cursor.Encode(), false, nil},
+		{"time range", "since=2026-03-01T08:00:00Z&until=2026-03-01T09:00:00.5Z", true, func(t *testing.T, q logs.Query) {
+			if q.Since == nil || q.Until == nil || q.Until.Sub(*q.Since) != time.Hour+500*time.Millisecond {
+				t.Fatalf("since %v until %v", q.Since, q.Until)
+			}
+		}},
+		{"since not RFC3339", "since=yesterday", false, nil},
+		{"until before since", "since=2026-03-01T09:00:00Z&until=2026-03-01T08:00:00Z", false, nil},
+		{"stream", "stream=stderr", true, func(t *testing.T, q logs.Query) {
+			if q.Stream != logs.StreamStderr {
+				t.Fatalf("stream = %q, want stderr", q.Stream)
+			}
+		}},
+		{"unknown stream", "stream=stdin", false, nil},
+		{"substring search", "search=" + url.QueryEscape("npm ERR! (code"), true, func(t *testing.T, q logs.Query) {
+			if q.Search != "npm ERR! (code" || q.Regex {
+				t.Fatalf("search %q regex %v", q.Search, q.Regex)
+			}
+		}},
+		{"regex search", "regex=true&search=" + url.QueryEscape(`^error\s`), true, func(t *testing.T, q logs.Query) {
+			if q.Search != `^error\s` || !q.Regex {
+				t.Fatalf("search %q regex %v", q.Search, q.Regex)
+			}
+		}},
+		{"regex flag not a bool", "regex=yes&search=x", false, nil},
+		{"regex without pattern", "regex=true", false, nil},
+		{"invalid regex", "regex=true&search=" + url.QueryEscape("(code"), false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/deployments/x/logs?"+tt.query, nil)
+
+			q, err := parseLogQuery(r)
+			if (err == nil) != tt.ok {
+				t.Fatalf("parseLogQuery(%q) = %v, want ok %v", tt.query, err, tt.ok)
+			}
+			if tt.check != nil {
+				tt.check(t, q)
+			}
+		})
+	}
+}
@@ -1,90 +1,61 @@
 package deployment
 
 import (
-	"context"
 	"database/sql"
-	"log"
-	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/go-chi/chi/v5"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/auth"
-	"github.com/ujjwalkirti/mini-vercel-api-server/internal/client"
-	appConfig "github.com/ujjwalkirti/mini-vercel-api-server/internal/config"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/config"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/apitoken"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/project"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/middleware"
 	repository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/deployment"
 	projectRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/project"
-	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/ecs"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/apitokens"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/giturl"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/logs"
 )
 
-func Routes(db *sql.DB, jwks *auth.JWKSCache) chi.Router {
+func Routes(db *sql.DB, jwks *auth.JWKSCache, tokens *apitokens.Service, logsService *logs.Service, gitURLs *giturl.Validator) chi.Router {
 	r := chi.NewRouter()
 
 	// Apply auth middleware to all deployment routes
-	r.Use(middleware.AuthMiddleware(jwks))
+	r.Use(middleware.AuthMiddleware(jwks, tokens))
 
 	repository := repository.New(db)
 	projectRepo := projectRepository.New(db)
 
-	// Initialize ECS service
-	var ecsService *ecs.Service
-	awsCfg, err := config.LoadDefaultConfig(context.Background())
-	if err != nil {
-		log.Printf("Warning: Failed to load AWS config: %v", err)
-	} else {
-		// Parse subnets (comma-separated)
-		ecsConfig := appConfig.GetECSConfig()
-		subnets := []string{}
-		if ecsConfig.Subnets != "" {
-			subnets = strings.Split(ecsConfig.Subnets, ",")
-			for i := range subnets {
-				subnets[i] = strings.TrimSpace(subnets[i])
-			}
-		}
-
-		ecsService = ecs.New(
-			awsCfg,
-			ecsConfig.Cluster,
-			ecsConfig.TaskDefinition,
-			subnets,
-			ecsConfig.SecurityGroup,
-			ecsConfig.AssignPublicIP,
-			ecsConfig.LaunchType,
-			ecsConfig.ImageName,
-			ecsConfig.Count,
-		)
-
-		log.Printf("ECS service initialized with config.")
+	buildCfg := config.GetBuildResourceConfig()
+	buildDefaults := project.BuildDefaults{
+		PlanPresets: make(map[project.Plan]project.BuildPreset, len(buildCfg.PlanPresets)),
+		UseSpot:     buildCfg.UseSpot,
 	}
-
-	// Initialize logs service
-	var logsService *logs.Service
-	clickhouseCfg := appConfig.GetClickHouseConfig()
-	if clickhouseCfg.Host != "" {
-		logRepo, err := client.NewLogRepository(client.ClickHouseRepository)
-		if err != nil {
-			log.Printf("Warning: Failed to initialize ClickHouse client: %v", err)
-		} else {
-			logsService = logs.New(logRepo)
-		}
-	} else {
-		log.Printf("Warning: ClickHouse not configured - logs endpoint will return empty logs")
+	for plan, preset := range buildCfg.PlanPresets {
+		buildDefaults.PlanPresets[project.Plan(plan)] = project.BuildPreset(preset)
 	}
 
-	h := NewHandler(repository, projectRepo, ecsService, logsService)
+	h := NewHandler(repository, projectRepo, logsService, buildDefaults, gitURLs)
+
+	read := r.With(middleware.RequireScope(apitoken.DeploymentsRead))
+	write := r.With(middleware.RequireScope(apitoken.DeploymentsWrite))
 
 	// GET /projects/:projectId/deployments - Get all deployments for a project
-	r.Get("/projects/{projectId}", h.GetDeploymentsByProject)
+	read.Get("/projects/{projectId}", h.GetDeploymentsByProject)
 
 	// GET /deployments/:id - Get specific deployment
-	r.Get("/deployments/{id}", h.GetDeployment)
+	read.Get("/deployments/{id}", h.GetDeployment)
+
+	// DELETE /deployments/:id - Delete a deployment and its logs
+	write.Delete("/deployments/{id}", h.DeleteDeployment)
 
 	// POST /deploy - Create new deployment
-	r.Post("/deploy", h.CreateDeployment)
+	write.Post("/deploy", h.CreateDeployment)
 
 	// GET /deployments/:id/logs - Get deployment logs
-	r.Get("/deployments/{id}/logs", h.GetDeploymentLogs)
+	read.Get("/deployments/{id}/logs", h.GetDeploymentLogs)
+
+	// GET /deployments/:id/logs/download - Download the full build log
+	read.Get("/deployments/{id}/logs/download", h.DownloadDeploymentLogs)
 
 	return r
 }
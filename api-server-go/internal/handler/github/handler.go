@@ -0,0 +1,143 @@
+package github
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/middleware"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/gitcredentials"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/gitprovider"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
+)
+
+type Handler struct {
+	gitCredentials *gitcredentials.Service
+}
+
+func NewHandler(gitCredentials *gitcredentials.Service) *Handler {
+	return &Handler{gitCredentials: gitCredentials}
+}
+
+// ListInstallations handles GET /github/installations
+// Returns the GitHub App installations linked to the user. github_app
+// credentials and commit statuses only work for repositories of these.
+func (h *Handler) ListInstallations(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, "Unauthorized")
+		return
+	}
+
+	installations, err := h.gitCredentials.GitHubInstallations(r.Context(), user.ID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to fetch GitHub installations")
+		return
+	}
+
+	utils.Success(w, installations)
+}
+
+// StartLink handles POST /github/installations/link
+// Returns authorizeUrl, and installUrl when the app's slug is configured,
+// to send the user to. GitHub redirects back to the app's callback URL with
+// code and state, which the client passes to POST /github/installations
+// within 10 minutes.
+func (h *Handler) StartLink(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, "Unauthorized")
+		return
+	}
+
+	link, err := h.gitCredentials.StartGitHubLink(r.Context(), user.ID)
+	if err != nil {
+		linkError(w, err)
+		return
+	}
+
+	utils.Success(w, link)
+}
+
+// CompleteLink handles POST /github/installations
+// Request body: { "code": string, "state": string }
+// Links the installations the GitHub user who authorized the app can
+// access, replacing those linked before. state must come from
+// POST /github/installations/link of the same user.
+func (h *Handler) CompleteLink(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, "Unauthorized")
+		return
+	}
+
+	type CompleteLinkRequest struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+
+	var req CompleteLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body")
+		return
+	}
+	if req.Code == "" || req.State == "" {
+		utils.BadRequest(w, "Invalid request body. code and state are required")
+		return
+	}
+
+	installations, err := h.gitCredentials.CompleteGitHubLink(r.Context(), user.ID, req.State, req.Code)
+	if err != nil {
+		linkError(w, err)
+		return
+	}
+
+	utils.Success(w, installations, "GitHub installations linked successfully")
+}
+
+// UnlinkInstallation handles DELETE /github/installations/:installationId
+// Builds of the user's projects in the installation's repositories can no
+// longer clone with github_app credentials
+func (h *Handler) UnlinkInstallation(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, "Unauthorized")
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "installationId"), 10, 64)
+	if err != nil {
+		utils.BadRequest(w, "Invalid installation ID")
+		return
+	}
+
+	if err := h.gitCredentials.UnlinkGitHubInstallation(r.Context(), user.ID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.NotFound(w, "GitHub installation not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to unlink GitHub installation")
+		return
+	}
+
+	utils.Success(w, nil, "GitHub installation unlinked successfully")
+}
+
+// linkError responds to an error linking installations
+func linkError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gitcredentials.ErrInvalidLinkState):
+		utils.BadRequest(w, "Invalid request body. "+err.Error())
+	case errors.Is(err, gitprovider.ErrAuthorizationFailed):
+		utils.Error(w, http.StatusUnprocessableEntity, "GitHub did not accept the authorization", err.Error())
+	case errors.Is(err, gitcredentials.ErrGitHubAppDisabled), errors.Is(err, gitcredentials.ErrLinkingDisabled):
+		utils.Error(w, http.StatusServiceUnavailable, "GitHub linking is not available", err.Error())
+	default:
+		log.Printf("ERROR: failed to link GitHub installations: %v", err)
+		utils.InternalServerError(w, "Failed to link GitHub installations")
+	}
+}
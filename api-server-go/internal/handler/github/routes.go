@@ -0,0 +1,27 @@
+package github
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/auth"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/middleware"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/apitokens"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/gitcredentials"
+)
+
+// Routes serves the GitHub App installations linked to the signed-in
+// user. Linking grants access to repositories, so API tokens cannot do it.
+func Routes(jwks *auth.JWKSCache, tokens *apitokens.Service, gitCredentials *gitcredentials.Service) chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middleware.AuthMiddleware(jwks, tokens))
+	r.Use(middleware.RequireSession)
+
+	h := NewHandler(gitCredentials)
+
+	r.Get("/installations", h.ListInstallations)
+	r.Post("/installations/link", h.StartLink)
+	r.Post("/installations", h.CompleteLink)
+	r.Delete("/installations/{installationId}", h.UnlinkInstallation)
+
+	return r
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -12,6 +13,9 @@ import (
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/middleware"
 	deploymentRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/deployment"
 	projectRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/project"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/gitcredentials"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/giturl"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/logs"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
 )
 
@@ -19,12 +23,18 @@ type Handler struct {
 	repo *projectRepository.Repository
 
 	deploymentRepo *deploymentRepository.Repository
+	logsService    *logs.Service
+	gitCredentials *gitcredentials.Service
+	gitURLs        *giturl.Validator
 }
 
-func NewHandler(repo *projectRepository.Repository, deploymentRepo *deploymentRepository.Repository) *Handler {
+func NewHandler(repo *projectRepository.Repository, deploymentRepo *deploymentRepository.Repository, logsService *logs.Service, gitCredentials *gitcredentials.Service, gitURLs *giturl.Validator) *Handler {
 	return &Handler{
 		repo:           repo,
 		deploymentRepo: deploymentRepo,
+		logsService:    logsService,
+		gitCredentials: gitCredentials,
+		gitURLs:        gitURLs,
 	}
 }
 
@@ -51,6 +61,17 @@ func (h *Handler) GetProjects(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A token restricted to a project only lists that project
+	if user.ProjectID != "" {
+		filtered := projects[:0]
+		for _, p := range projects {
+			if p.ID == user.ProjectID {
+				filtered = append(filtered, p)
+			}
+		}
+		projects = filtered
+	}
+
 	// Return projects array directly (matching Express API response)
 	utils.Success(w, projects)
 }
@@ -72,6 +93,11 @@ func (h *Handler) GetProject(w http.ResponseWriter, r *http.Request) {
 		utils.BadRequest(w, "Invalid project ID")
 		return
 	}
+	// A token restricted to another project cannot see this one
+	if !user.CanAccessProject(id) {
+		utils.NotFound(w, "Project not found")
+		return
+	}
 
 	// TODO: Fetch project by ID and verify ownership
 	project, err := h.repo.GetByIDAndUserID(r.Context(), id, user.ID)
@@ -96,8 +122,13 @@ func generateSubdomain() string {
 
 // CreateProject handles POST /projects
 // Creates a new project for the authenticated user
-// Request body: { "name": string, "github_url": string }
+// Request body: { "name": string, "github_url": string, "git_credential"?: "none" | "deploy_key" | "github_app" }
 // Generates a random subdomain for the project
+// github_url must be an https or ssh URL on an allowed git host; it is
+// stored in its canonical https form, and a user has one project per
+// repository
+// Checks that the repository can be read with the chosen credential; a
+// generated deploy key is returned once, as gitCredential.publicKey
 func (h *Handler) CreateProject(w http.ResponseWriter, r *http.Request) {
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
@@ -105,9 +136,15 @@ func (h *Handler) CreateProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if user.ProjectID != "" {
+		utils.Forbidden(w, "An API token restricted to a project cannot create projects")
+		return
+	}
+
 	type CreateProjectRequest struct {
-		Name      string `json:"name"`
-		GithubURL string `json:"github_url"`
+		Name          string `json:"name"`
+		GithubURL     string `json:"github_url"`
+		GitCredential string `json:"git_credential"`
 	}
 
 	var req CreateProjectRequest
@@ -122,20 +159,46 @@ func (h *Handler) CreateProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	gitURL, err := h.gitURLs.Validate(r.Context(), req.GithubURL)
+	if err != nil {
+		utils.BadRequest(w, "Invalid request body. "+err.Error())
+		return
+	}
+
+	credentialType := project.GitCredentialNone
+	if req.GitCredential != "" {
+		if credentialType, err = project.ParseGitCredentialType(req.GitCredential); err != nil {
+			utils.BadRequest(w, "Invalid request body. "+err.Error())
+			return
+		}
+	}
+
 	// TODO: Generate random subdomain (3-word slug)
 	subdomain := generateSubdomain()
 
-	// Create project in database
+	// Create project in database. The ID is chosen up front because a deploy
+	// key is encrypted for its project.
 	newProject := &project.Project{
+		ID:        utils.GenerateUUID(),
 		Name:      req.Name,
-		GitURL:    req.GithubURL,
+		GitURL:    gitURL,
 		SubDomain: subdomain,
 		UserID:    user.ID,
 	}
 
-	err := h.repo.Create(r.Context(), newProject)
+	credential, err := h.gitCredentials.Prepare(r.Context(), newProject.UserID, newProject.ID, newProject.GitURL, credentialType)
+	if err != nil {
+		gitCredentialError(w, err)
+		return
+	}
+
+	err = h.repo.CreateWithGitCredential(r.Context(), newProject, credential)
 
 	if err != nil {
+		if errors.Is(err, project.ErrDuplicateGitURL) {
+			utils.Error(w, http.StatusConflict, "A project for this repository already exists", "")
+			return
+		}
 		if errors.Is(err, sql.ErrNoRows) {
 			utils.BadRequest(w, "Error creating porject: "+err.Error())
 			return
@@ -144,12 +207,16 @@ func (h *Handler) CreateProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	newProject.GitCredential = credential
+
 	// Return the created project object (matching Express API response)
 	utils.Created(w, newProject, "Project created successfully")
 }
 
 // UpdateProject handles PUT /projects/:id
 // Updates an existing project
+// Request body: { "name"?: string, "buildPreset"?: string | null, "useSpot"?: bool | null }
+// A null buildPreset or useSpot returns the project to the plan defaults
 // Verifies user owns the project
 func (h *Handler) UpdateProject(w http.ResponseWriter, r *http.Request) {
 	user, ok := middleware.GetUserFromContext(r.Context())
@@ -160,15 +227,79 @@ func (h *Handler) UpdateProject(w http.ResponseWriter, r *http.Request) {
 
 	id := chi.URLParam(r, "id")
 
-	// TODO: Decode request body
-	// TODO: Validate ownership
-	// TODO: Update project in database
+	if !utils.IsValidUUID(id) {
+		utils.BadRequest(w, "Invalid project ID")
+		return
+	}
+	// A token restricted to another project cannot see this one
+	if !user.CanAccessProject(id) {
+		utils.NotFound(w, "Project not found")
+		return
+	}
+
+	// Fields are decoded as raw JSON to tell an explicit null from an
+	// omitted field
+	var req map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body")
+		return
+	}
 
-	// Placeholder response
-	utils.Success(w, map[string]interface{}{
-		"id":     id,
-		"userId": user.ID,
-	}, "Project updated successfully")
+	existing, err := h.repo.GetByIDAndUserID(r.Context(), id, user.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.NotFound(w, "Project not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to fetch project")
+		return
+	}
+
+	if raw, ok := req["name"]; ok {
+		var name string
+		if err := json.Unmarshal(raw, &name); err != nil || name == "" {
+			utils.BadRequest(w, "Invalid request body. Name must be a non-empty string")
+			return
+		}
+		existing.Name = name
+	}
+
+	if raw, ok := req["buildPreset"]; ok {
+		var name *string
+		if err := json.Unmarshal(raw, &name); err != nil {
+			utils.BadRequest(w, "Invalid request body. buildPreset must be a string")
+			return
+		}
+		existing.BuildPreset = nil
+		if name != nil {
+			preset, err := project.ParseBuildPreset(*name)
+			if err != nil {
+				utils.BadRequest(w, err.Error())
+				return
+			}
+			if !existing.Plan.Allows(preset) {
+				utils.Forbidden(w, "Build preset "+string(preset)+" is not available on the "+string(existing.Plan)+" plan")
+				return
+			}
+			existing.BuildPreset = &preset
+		}
+	}
+
+	if raw, ok := req["useSpot"]; ok {
+		var useSpot *bool
+		if err := json.Unmarshal(raw, &useSpot); err != nil {
+			utils.BadRequest(w, "Invalid request body. useSpot must be a boolean")
+			return
+		}
+		existing.UseSpot = useSpot
+	}
+
+	if err := h.repo.Update(r.Context(), &existing); err != nil {
+		utils.InternalServerError(w, "Failed to update project")
+		return
+	}
+
+	utils.Success(w, existing, "Project updated successfully")
 }
 
 // DeleteProject handles DELETE /projects/:id
@@ -188,6 +319,11 @@ func (h *Handler) DeleteProject(w http.ResponseWriter, r *http.Request) {
 		utils.BadRequest(w, "Invalid project ID")
 		return
 	}
+	// A token restricted to another project cannot see this one
+	if !user.CanAccessProject(id) {
+		utils.NotFound(w, "Project not found")
+		return
+	}
 
 	// TODO: Verify project exists and user owns it
 	project, err := h.repo.GetByIDAndUserID(r.Context(), id, user.ID)
@@ -201,7 +337,11 @@ func (h *Handler) DeleteProject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// TODO: Delete all deployments for this project
-	err = h.deploymentRepo.DeleteByProjectID(r.Context(), id)
+	deploymentIDs, err := h.deploymentRepo.DeleteByProjectID(r.Context(), id)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to delete deployments")
+		return
+	}
 
 	// TODO: Delete the project
 	err = h.repo.Delete(r.Context(), project.ID)
@@ -216,6 +356,154 @@ func (h *Handler) DeleteProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Logs live outside the main database, so they are removed separately
+	if h.logsService != nil {
+		h.logsService.ScheduleProjectLogDeletion(project.ID, deploymentIDs)
+	}
+
 	// Placeholder response
 	utils.Success(w, nil, "Project deleted successfully")
 }
+
+// GetGitCredential handles GET /projects/:id/git-credential
+// Returns how builds authenticate to the project's repository. The public
+// key of a deploy key is not returned again; its fingerprint identifies it.
+func (h *Handler) GetGitCredential(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.ownedProject(w, r)
+	if !ok {
+		return
+	}
+
+	credential, err := h.gitCredentials.Get(r.Context(), p.ID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to fetch git credential")
+		return
+	}
+	if credential == nil {
+		credential = &project.GitCredential{ProjectID: p.ID, Type: project.GitCredentialNone}
+	}
+
+	utils.Success(w, credential)
+}
+
+// UpdateGitCredential handles PUT /projects/:id/git-credential
+// Replaces the credential builds clone the repository with
+// Request body: { "type": "none" | "deploy_key" | "github_app" }
+// A deploy_key generates a new key, returned once as publicKey; the previous
+// key of the project stops working
+func (h *Handler) UpdateGitCredential(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.ownedProject(w, r)
+	if !ok {
+		return
+	}
+
+	type UpdateGitCredentialRequest struct {
+		Type string `json:"type"`
+	}
+
+	var req UpdateGitCredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body")
+		return
+	}
+	credentialType, err := project.ParseGitCredentialType(req.Type)
+	if err != nil {
+		utils.BadRequest(w, "Invalid request body. "+err.Error())
+		return
+	}
+
+	credential, err := h.gitCredentials.Prepare(r.Context(), p.UserID, p.ID, p.GitURL, credentialType)
+	if err != nil {
+		gitCredentialError(w, err)
+		return
+	}
+	if err := h.gitCredentials.Save(r.Context(), p.ID, credential); err != nil {
+		utils.InternalServerError(w, "Failed to save git credential")
+		return
+	}
+	if credential == nil {
+		credential = &project.GitCredential{ProjectID: p.ID, Type: project.GitCredentialNone}
+	}
+
+	utils.Success(w, credential, "Git credential updated successfully")
+}
+
+// DeleteGitCredential handles DELETE /projects/:id/git-credential
+// Removes the credential; builds clone the repository anonymously
+func (h *Handler) DeleteGitCredential(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.ownedProject(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.gitCredentials.Save(r.Context(), p.ID, nil); err != nil {
+		utils.InternalServerError(w, "Failed to delete git credential")
+		return
+	}
+
+	utils.Success(w, nil, "Git credential deleted successfully")
+}
+
+// VerifyGitCredential handles POST /projects/:id/git-credential/verify
+// Checks that builds can read the repository with the project's credential,
+// e.g. after adding a generated deploy key to the repository
+func (h *Handler) VerifyGitCredential(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.ownedProject(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.gitCredentials.Verify(r.Context(), p.UserID, p.ID, p.GitURL); err != nil {
+		gitCredentialError(w, err)
+		return
+	}
+
+	utils.Success(w, map[string]bool{"accessible": true}, "Repository is accessible")
+}
+
+// ownedProject returns the project of the :id route parameter if the
+// authenticated user owns it, and responds with an error otherwise
+func (h *Handler) ownedProject(w http.ResponseWriter, r *http.Request) (project.Project, bool) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, "Unauthorized")
+		return project.Project{}, false
+	}
+
+	id := chi.URLParam(r, "id")
+	if !utils.IsValidUUID(id) {
+		utils.BadRequest(w, "Invalid project ID")
+		return project.Project{}, false
+	}
+	if !user.CanAccessProject(id) {
+		utils.NotFound(w, "Project not found")
+		return project.Project{}, false
+	}
+
+	p, err := h.repo.GetByIDAndUserID(r.Context(), id, user.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.NotFound(w, "Project not found")
+			return project.Project{}, false
+		}
+		utils.InternalServerError(w, "Failed to fetch project")
+		return project.Project{}, false
+	}
+	return p, true
+}
+
+// gitCredentialError responds to an error preparing or verifying a git
+// credential
+func gitCredentialError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gitcredentials.ErrNoAccess):
+		utils.Error(w, http.StatusUnprocessableEntity, "Repository is not accessible", err.Error())
+	case errors.Is(err, gitcredentials.ErrInvalidURL), errors.Is(err, gitcredentials.ErrNotGitHub):
+		utils.BadRequest(w, "Invalid request body. "+err.Error())
+	case errors.Is(err, gitcredentials.ErrDeployKeysDisabled), errors.Is(err, gitcredentials.ErrGitHubAppDisabled):
+		utils.Error(w, http.StatusServiceUnavailable, "Git credential is not available", err.Error())
+	default:
+		log.Printf("ERROR: failed to check repository access: %v", err)
+		utils.InternalServerError(w, "Failed to check repository access")
+	}
+}
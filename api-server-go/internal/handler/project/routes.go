@@ -5,25 +5,38 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/auth"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/apitoken"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/middleware"
 	deploymentRepo "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/deployment"
 	repo "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/project"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/apitokens"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/gitcredentials"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/giturl"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/logs"
 )
 
-func Routes(db *sql.DB, jwks *auth.JWKSCache) chi.Router {
+func Routes(db *sql.DB, jwks *auth.JWKSCache, tokens *apitokens.Service, logsService *logs.Service, gitCredentials *gitcredentials.Service, gitURLs *giturl.Validator) chi.Router {
 	r := chi.NewRouter()
 
-	r.Use(middleware.AuthMiddleware(jwks))
+	r.Use(middleware.AuthMiddleware(jwks, tokens))
 
 	repository := repo.New(db)
 	deploymentRepository := deploymentRepo.New(db)
-	h := NewHandler(repository, deploymentRepository)
+	h := NewHandler(repository, deploymentRepository, logsService, gitCredentials, gitURLs)
 
-	r.Get("/", h.GetProjects)
-	r.Get("/{id}", h.GetProject)
-	r.Post("/", h.CreateProject)
-	r.Put("/{id}", h.UpdateProject)
-	r.Delete("/{id}", h.DeleteProject)
+	read := r.With(middleware.RequireScope(apitoken.ProjectsRead))
+	write := r.With(middleware.RequireScope(apitoken.ProjectsWrite))
+
+	read.Get("/", h.GetProjects)
+	read.Get("/{id}", h.GetProject)
+	write.Post("/", h.CreateProject)
+	write.Put("/{id}", h.UpdateProject)
+	write.Delete("/{id}", h.DeleteProject)
+
+	read.Get("/{id}/git-credential", h.GetGitCredential)
+	write.Put("/{id}/git-credential", h.UpdateGitCredential)
+	write.Delete("/{id}/git-credential", h.DeleteGitCredential)
+	write.Post("/{id}/git-credential/verify", h.VerifyGitCredential)
 
 	return r
 }
@@ -0,0 +1,359 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/event"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/webhook"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/middleware"
+	projectRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/project"
+	webhookRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/webhook"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/giturl"
+	webhookService "github.com/ujjwalkirti/mini-vercel-api-server/internal/service/webhook"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
+)
+
+// Delivery history page size
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+// minSecretLength is the shortest signing secret a caller may choose
+const minSecretLength = 16
+
+type Handler struct {
+	repo        *webhookRepository.Repository
+	projectRepo *projectRepository.Repository
+	urls        *giturl.Validator
+}
+
+// NewHandler creates the webhook handlers. urls checks that webhook hosts
+// resolve to public addresses.
+func NewHandler(repo *webhookRepository.Repository, projectRepo *projectRepository.Repository, urls *giturl.Validator) *Handler {
+	return &Handler{
+		repo:        repo,
+		projectRepo: projectRepo,
+		urls:        urls,
+	}
+}
+
+// authorize checks that the authenticated user owns the project in the
+// URL and returns its ID. It writes the error response when it fails.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request) (string, bool) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, "Unauthorized")
+		return "", false
+	}
+
+	projectID := chi.URLParam(r, "projectId")
+	if !utils.IsValidUUID(projectID) {
+		utils.BadRequest(w, "Invalid project ID")
+		return "", false
+	}
+	if !user.CanAccessProject(projectID) {
+		utils.NotFound(w, "Project not found")
+		return "", false
+	}
+
+	if _, err := h.projectRepo.GetByIDAndUserID(r.Context(), projectID, user.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.NotFound(w, "Project not found")
+			return "", false
+		}
+		utils.InternalServerError(w, "Failed to fetch project")
+		return "", false
+	}
+	return projectID, true
+}
+
+// loadWebhook returns the webhook in the URL after authorize
+func (h *Handler) loadWebhook(w http.ResponseWriter, r *http.Request) (webhook.Webhook, bool) {
+	projectID, ok := h.authorize(w, r)
+	if !ok {
+		return webhook.Webhook{}, false
+	}
+
+	id := chi.URLParam(r, "webhookId")
+	if !utils.IsValidUUID(id) {
+		utils.BadRequest(w, "Invalid webhook ID")
+		return webhook.Webhook{}, false
+	}
+
+	hook, err := h.repo.Get(r.Context(), projectID, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.NotFound(w, "Webhook not found")
+			return webhook.Webhook{}, false
+		}
+		utils.InternalServerError(w, "Failed to fetch webhook")
+		return webhook.Webhook{}, false
+	}
+	return hook, true
+}
+
+// ListWebhooks handles GET /projects/:projectId/webhooks
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	webhooks, err := h.repo.ListByProject(r.Context(), projectID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to fetch webhooks")
+		return
+	}
+
+	utils.Success(w, webhooks)
+}
+
+// CreateWebhook handles POST /projects/:projectId/webhooks
+// Request body: { "url": string, "events"?: string[], "secret"?: string, "active"?: bool }
+// An empty events list subscribes to every event type. The signing secret
+// is generated unless given, and only returned in this response.
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	type CreateWebhookRequest struct {
+		URL    string       `json:"url"`
+		Events []event.Type `json:"events"`
+		Secret string       `json:"secret"`
+		Active *bool        `json:"active"`
+	}
+
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if err := h.validateURL(r.Context(), req.URL); err != nil {
+		utils.BadRequest(w, "Invalid request body. "+err.Error())
+		return
+	}
+	if err := validateEvents(req.Events); err != nil {
+		utils.BadRequest(w, "Invalid request body. "+err.Error())
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = webhookService.NewSecret(); err != nil {
+			utils.InternalServerError(w, "Failed to generate webhook secret")
+			return
+		}
+	} else if len(secret) < minSecretLength {
+		utils.BadRequest(w, "Invalid request body. secret must be at least 16 characters")
+		return
+	}
+
+	hook := &webhook.Webhook{
+		ProjectID: projectID,
+		URL:       req.URL,
+		Secret:    secret,
+		Events:    req.Events,
+		Active:    req.Active == nil || *req.Active,
+	}
+	if hook.Events == nil {
+		hook.Events = []event.Type{}
+	}
+
+	if err := h.repo.Create(r.Context(), hook); err != nil {
+		utils.InternalServerError(w, "Failed to create webhook")
+		return
+	}
+
+	utils.Created(w, hook, "Webhook created successfully")
+}
+
+// UpdateWebhook handles PATCH /projects/:projectId/webhooks/:webhookId
+// Request body: { "url"?: string, "events"?: string[], "active"?: bool }
+func (h *Handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.loadWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	type UpdateWebhookRequest struct {
+		URL    *string       `json:"url"`
+		Events *[]event.Type `json:"events"`
+		Active *bool         `json:"active"`
+	}
+
+	var req UpdateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.URL != nil {
+		if err := h.validateURL(r.Context(), *req.URL); err != nil {
+			utils.BadRequest(w, "Invalid request body. "+err.Error())
+			return
+		}
+		hook.URL = *req.URL
+	}
+	if req.Events != nil {
+		if err := validateEvents(*req.Events); err != nil {
+			utils.BadRequest(w, "Invalid request body. "+err.Error())
+			return
+		}
+		hook.Events = *req.Events
+		if hook.Events == nil {
+			hook.Events = []event.Type{}
+		}
+	}
+	if req.Active != nil {
+		hook.Active = *req.Active
+	}
+
+	if err := h.repo.Update(r.Context(), &hook); err != nil {
+		utils.InternalServerError(w, "Failed to update webhook")
+		return
+	}
+
+	utils.Success(w, hook, "Webhook updated successfully")
+}
+
+// DeleteWebhook handles DELETE /projects/:projectId/webhooks/:webhookId
+// Deletes a webhook and its delivery history
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.loadWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.Delete(r.Context(), hook.ProjectID, hook.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.NotFound(w, "Webhook not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to delete webhook")
+		return
+	}
+
+	utils.Success(w, nil, "Webhook deleted successfully")
+}
+
+// ListDeliveries handles GET /projects/:projectId/webhooks/:webhookId/deliveries
+// Returns the most recent deliveries, newest first, with the response of
+// their last attempt. Query: limit (default 50, at most 200)
+func (h *Handler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.loadWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultDeliveryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDeliveryLimit {
+			utils.BadRequest(w, "Invalid limit. Must be between 1 and 200")
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.repo.ListDeliveries(r.Context(), hook.ID, limit)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to fetch deliveries")
+		return
+	}
+
+	utils.Success(w, deliveries)
+}
+
+// GetDelivery handles GET /projects/:projectId/webhooks/:webhookId/deliveries/:deliveryId
+func (h *Handler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	delivery, ok := h.loadDelivery(w, r)
+	if !ok {
+		return
+	}
+
+	utils.Success(w, delivery)
+}
+
+// RedeliverDelivery handles POST /projects/:projectId/webhooks/:webhookId/deliveries/:deliveryId/redeliver
+// Queues a new delivery of the same payload; the original is unchanged.
+func (h *Handler) RedeliverDelivery(w http.ResponseWriter, r *http.Request) {
+	delivery, ok := h.loadDelivery(w, r)
+	if !ok {
+		return
+	}
+
+	redelivery, err := h.repo.Redeliver(r.Context(), delivery)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to redeliver")
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusAccepted, utils.APIResponse{
+		Success: true,
+		Data:    redelivery,
+		Message: "Delivery queued",
+	})
+}
+
+func (h *Handler) loadDelivery(w http.ResponseWriter, r *http.Request) (webhook.Delivery, bool) {
+	hook, ok := h.loadWebhook(w, r)
+	if !ok {
+		return webhook.Delivery{}, false
+	}
+
+	id := chi.URLParam(r, "deliveryId")
+	if !utils.IsValidUUID(id) {
+		utils.BadRequest(w, "Invalid delivery ID")
+		return webhook.Delivery{}, false
+	}
+
+	delivery, err := h.repo.GetDelivery(r.Context(), hook.ID, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.NotFound(w, "Delivery not found")
+			return webhook.Delivery{}, false
+		}
+		utils.InternalServerError(w, "Failed to fetch delivery")
+		return webhook.Delivery{}, false
+	}
+	return delivery, true
+}
+
+// validateURL checks a webhook URL. Its host must not resolve to a private,
+// loopback or link-local address; the worker checks the address again when
+// it connects, since the name may resolve differently by then.
+func (h *Handler) validateURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+	if err := h.urls.CheckPublicHost(ctx, u.Hostname()); err != nil {
+		return errors.New("url must point to a public address")
+	}
+	return nil
+}
+
+func validateEvents(events []event.Type) error {
+	for _, e := range events {
+		if !e.Valid() {
+			return fmt.Errorf("unknown event type %q", e)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package webhook
+
+import (
+	"database/sql"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/auth"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/apitoken"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/middleware"
+	projectRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/project"
+	webhookRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/webhook"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/apitokens"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/giturl"
+)
+
+// Routes serves a project's webhooks; mount it at /projects/{projectId}/webhooks
+func Routes(db *sql.DB, jwks *auth.JWKSCache, tokens *apitokens.Service, urls *giturl.Validator) chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(middleware.AuthMiddleware(jwks, tokens))
+
+	h := NewHandler(webhookRepository.New(db), projectRepository.New(db), urls)
+
+	read := r.With(middleware.RequireScope(apitoken.WebhooksRead))
+	write := r.With(middleware.RequireScope(apitoken.WebhooksWrite))
+
+	read.Get("/", h.ListWebhooks)
+	write.Post("/", h.CreateWebhook)
+	write.Patch("/{webhookId}", h.UpdateWebhook)
+	write.Delete("/{webhookId}", h.DeleteWebhook)
+
+	read.Get("/{webhookId}/deliveries", h.ListDeliveries)
+	read.Get("/{webhookId}/deliveries/{deliveryId}", h.GetDelivery)
+	write.Post("/{webhookId}/deliveries/{deliveryId}/redeliver", h.RedeliverDelivery)
+
+	return r
+}
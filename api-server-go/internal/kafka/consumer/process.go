@@ -3,32 +3,53 @@ package consumer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"strings"
+	"time"
 
-	"github.com/IBM/sarama"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
+	deploymentdomain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/eventbus"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/deployment"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/failure"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/logs"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/redact"
 )
 
+// failureLogTail is how many trailing log lines are classified after a failure
+const failureLogTail = 1000
+
 type Processor struct {
 	deploymentSvc *deployment.DeploymentService
 	logSvc        *logs.Service
+	classifier    *failure.Classifier
+	redactor      *redact.Redactor
 }
 
 func NewProcessor(
 	deploymentSvc *deployment.DeploymentService,
 	logSvc *logs.Service,
+	classifier *failure.Classifier,
+	redactor *redact.Redactor,
 ) *Processor {
 	return &Processor{
 		deploymentSvc: deploymentSvc,
 		logSvc:        logSvc,
+		classifier:    classifier,
+		redactor:      redactor,
 	}
 }
 
-func (p *Processor) Process(msg *sarama.ConsumerMessage) error {
+// Handle handles one build log message from the event bus. The log line is
+// handed to the batched ingestion path and ack is called once it is
+// durable; any error returned means the message was not enqueued and ack
+// will not be called. The deployment_id of the message is trusted, so only
+// the platform may produce to the topic; dispatched builds post their logs
+// over HTTP, where the build token decides the deployment.
+func (p *Processor) Handle(ctx context.Context, msg eventbus.Message, ack eventbus.AckFunc) error {
 	if msg.Key == nil || msg.Value == nil {
+		ack(nil)
 		return nil // skip silently
 	}
 
@@ -37,38 +58,106 @@ func (p *Processor) Process(msg *sarama.ConsumerMessage) error {
 		return err
 	}
 
-	ctx := context.Background()
+	return p.HandleEvent(context.WithoutCancel(ctx), event, logs.AckFunc(ack))
+}
+
+// HandleEvent applies one build log line: status transitions, redaction and
+// storage. Builders that do not go through the event bus, such as the local
+// executor, call it directly.
+func (p *Processor) HandleEvent(ctx context.Context, event buildlog.Event, ack logs.AckFunc) error {
 	logLower := strings.ToLower(event.Log)
 
 	// ---- status transitions ----
 	if logLower == "info: starting build pipeline..." {
-		if err := p.deploymentSvc.MarkInProgress(ctx, event.DeploymentID); err != nil {
+		if err := p.deploymentSvc.MarkInProgress(ctx, event.DeploymentID); err != nil && !p.ignoreTransition(event.DeploymentID, err) {
 			return err
 		}
 	}
 
 	if logLower == "info: pipeline completed successfully." {
-		if err := p.deploymentSvc.MarkReady(ctx, event.DeploymentID); err != nil {
+		if err := p.deploymentSvc.MarkReady(ctx, event.DeploymentID); err != nil && !p.ignoreTransition(event.DeploymentID, err) {
 			return err
 		}
 	}
 
+	failed := false
 	if strings.HasPrefix(logLower, "error:") &&
 		strings.Contains(logLower, "pipeline failed") {
-		if err := p.deploymentSvc.MarkFailed(ctx, event.DeploymentID); err != nil {
+		err := p.deploymentSvc.MarkFailed(ctx, event.DeploymentID)
+		if err != nil && !p.ignoreTransition(event.DeploymentID, err) {
 			return err
 		}
+		failed = err == nil
 	}
 
 	// ---- always insert log ----
-	if err := p.logSvc.Insert(ctx, event.DeploymentID, event.Log); err != nil {
-		// Log the error but don't fail message processing
-		// This prevents message reprocessing and allows other logs to continue
-		log.Printf("ERROR: Failed to insert log for deployment %s: %v | Log preview: %q",
-			event.DeploymentID, err, truncateString(event.Log, 100))
+	stream := logs.StreamStdout
+	if event.Stream == string(logs.StreamStderr) {
+		stream = logs.StreamStderr
 	}
 
-	return nil
+	// Secrets are masked before the line leaves this process; only the fact
+	// that something was masked is kept
+	text, redacted := event.Log, false
+	if p.redactor != nil {
+		text, redacted = p.redactor.Redact(ctx, event.DeploymentID, event.Log)
+	}
+
+	logEvent := logs.LogEvent{
+		DeploymentID: event.DeploymentID,
+		ProjectID:    event.ProjectID,
+		Log:          text,
+		Stream:       stream,
+		Redacted:     redacted,
+	}
+
+	return p.logSvc.Enqueue(ctx, logEvent, func(err error) {
+		if err != nil {
+			log.Printf("ERROR: Failed to insert log for deployment %s: %v | Log preview: %q",
+				event.DeploymentID, err, truncateString(text, 100))
+		}
+		// Classify once the failure line itself is stored; this runs on the
+		// writer's goroutine, so the query happens in the background
+		if err == nil && failed && p.classifier != nil {
+			go p.classifyFailure(event.DeploymentID)
+		}
+		ack(err)
+	})
+}
+
+// classifyFailure records why a build failed from the end of its log
+func (p *Processor) classifyFailure(deploymentID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	page, err := p.logSvc.QueryLogs(ctx, deploymentID, logs.Query{Tail: failureLogTail})
+	if err != nil {
+		log.Printf("ERROR: Failed to read logs to classify deployment %s: %v", deploymentID, err)
+		return
+	}
+
+	result := p.classifier.Classify(page.Logs)
+	if err := p.deploymentSvc.RecordFailure(ctx, deploymentID, result.Reason, result.Excerpt); err != nil {
+		log.Printf("ERROR: Failed to record failure reason for deployment %s: %v", deploymentID, err)
+		return
+	}
+
+	log.Printf("Deployment %s failed: %s", deploymentID, result.Reason)
+}
+
+// ignoreTransition reports whether a status change error can be skipped.
+// Late or duplicate status lines are expected and must not drop the log line.
+func (p *Processor) ignoreTransition(deploymentID string, err error) bool {
+	if errors.Is(err, deploymentdomain.ErrInvalidTransition) {
+		log.Printf("Ignoring status line for deployment %s: %v", deploymentID, err)
+		return true
+	}
+	return false
+}
+
+// Flush forces buffered log events to storage
+func (p *Processor) Flush(ctx context.Context) error {
+	return p.logSvc.Flush(ctx)
 }
 
 // truncateString truncates a string to maxLen characters
@@ -0,0 +1,41 @@
+// Package sasl provides the SCRAM client sarama needs for SCRAM-SHA-256
+// and SCRAM-SHA-512 authentication
+package sasl
+
+import (
+	"github.com/IBM/sarama"
+	"github.com/xdg-go/scram"
+)
+
+// SHA256 creates SCRAM-SHA-256 clients for
+// sarama.Config.Net.SASL.SCRAMClientGeneratorFunc
+func SHA256() sarama.SCRAMClient {
+	return &scramClient{hashGen: scram.SHA256}
+}
+
+// SHA512 creates SCRAM-SHA-512 clients
+func SHA512() sarama.SCRAMClient {
+	return &scramClient{hashGen: scram.SHA512}
+}
+
+type scramClient struct {
+	hashGen      scram.HashGeneratorFcn
+	conversation *scram.ClientConversation
+}
+
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	client, err := c.hashGen.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.conversation = client.NewConversation()
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.conversation.Step(challenge)
+}
+
+func (c *scramClient) Done() bool {
+	return c.conversation.Done()
+}
@@ -3,24 +3,104 @@ package kafka_tls
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 )
 
-func NewTLSConfig() (*tls.Config, error) {
-	path := "ca.pem"
-	if os.Getenv("ENV") == "production" {
-		path = "/secrets/kafka-consumer-ca"
+// Options locates the certificates of a TLS connection. Each value is
+// either inline PEM or the path of a PEM file.
+type Options struct {
+	// CA verifies the brokers; empty uses the system roots
+	CA string
+	// ClientCert and ClientKey authenticate this client (mTLS); both or
+	// neither must be set
+	ClientCert string
+	ClientKey  string
+	// ServerName overrides the host name certificates are verified against
+	ServerName string
+}
+
+func NewTLSConfig(opts Options) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: opts.ServerName,
 	}
 
-	caCert, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
+	if opts.CA != "" {
+		caCert, err := LoadPEM(opts.CA)
+		if err != nil {
+			return nil, fmt.Errorf("kafka CA: %w", err)
+		}
+		pool, err := parseCertPool(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("kafka CA: %w", err)
+		}
+		cfg.RootCAs = pool
 	}
 
+	if (opts.ClientCert == "") != (opts.ClientKey == "") {
+		return nil, errors.New("kafka client certificate and key must be set together")
+	}
+	if opts.ClientCert != "" {
+		certPEM, err := LoadPEM(opts.ClientCert)
+		if err != nil {
+			return nil, fmt.Errorf("kafka client certificate: %w", err)
+		}
+		keyPEM, err := LoadPEM(opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("kafka client key: %w", err)
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("kafka client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// parseCertPool parses every block of a PEM bundle. Unlike
+// AppendCertsFromPEM it fails on any block that is not a valid certificate
+// instead of skipping it.
+func parseCertPool(data []byte) (*x509.CertPool, error) {
 	pool := x509.NewCertPool()
-	pool.AppendCertsFromPEM(caCert)
+	found := 0
+	for n := 1; ; n++ {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("PEM block %d is a %s, not a CERTIFICATE", n, block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("PEM block %d: %w", n, err)
+		}
+		pool.AddCert(cert)
+		found++
+	}
+	if found == 0 {
+		return nil, errors.New("no PEM certificates found")
+	}
+	return pool, nil
+}
 
-	return &tls.Config{
-		RootCAs: pool,
-	}, nil
+// LoadPEM returns value itself if it is inline PEM, and otherwise reads
+// the file it names
+func LoadPEM(value string) ([]byte, error) {
+	if strings.Contains(value, "-----BEGIN ") {
+		// Inline PEM from an environment variable may have escaped newlines
+		return []byte(strings.ReplaceAll(value, `\n`, "\n")), nil
+	}
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
 }
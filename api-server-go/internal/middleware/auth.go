@@ -2,14 +2,23 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/auth"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/apitoken"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/apitokens"
 )
 
-func AuthMiddleware(jwks *auth.JWKSCache, allowedRoles ...string) func(http.Handler) http.Handler {
+// tokenRole is the role of users authenticated with an API token, the role
+// Supabase gives signed-in users
+const tokenRole = "authenticated"
+
+// AuthMiddleware authenticates users by a Supabase JWT or, for scripts and
+// CI, a personal access token. Both populate the same AuthUser.
+func AuthMiddleware(jwks *auth.JWKSCache, tokens *apitokens.Service, allowedRoles ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
@@ -20,18 +29,48 @@ func AuthMiddleware(jwks *auth.JWKSCache, allowedRoles ...string) func(http.Hand
 
 			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
-			claims, err := auth.VerifyToken(tokenString, jwks)
-			if err != nil {
-				log.Printf("Error in verifying token: %s", err.Error())
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
+			var user *AuthUser
+			if strings.HasPrefix(tokenString, apitokens.TokenPrefix) {
+				t, err := tokens.Authenticate(r.Context(), tokenString)
+				switch {
+				case errors.Is(err, apitokens.ErrInvalidToken):
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				case err != nil:
+					log.Printf("Error in verifying API token: %s", err.Error())
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
+
+				user = &AuthUser{
+					ID:      t.UserID,
+					Role:    tokenRole,
+					TokenID: t.ID,
+					Scopes:  t.Scopes,
+				}
+				if t.ProjectID != nil {
+					user.ProjectID = *t.ProjectID
+				}
+			} else {
+				claims, err := auth.VerifyToken(tokenString, jwks)
+				if err != nil {
+					log.Printf("Error in verifying token: %s", err.Error())
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+
+				user = &AuthUser{
+					ID:    claims.Subject,
+					Email: claims.Email,
+					Role:  claims.Role,
+				}
 			}
 
 			// Role / policy validation
 			if len(allowedRoles) > 0 {
 				allowed := false
 				for _, role := range allowedRoles {
-					if claims.Role == role {
+					if user.Role == role {
 						allowed = true
 						break
 					}
@@ -42,14 +81,45 @@ func AuthMiddleware(jwks *auth.JWKSCache, allowedRoles ...string) func(http.Hand
 				}
 			}
 
-			user := &AuthUser{
-				ID:    claims.Subject,
-				Email: claims.Email,
-				Role:  claims.Role,
-			}
-
 			ctx := context.WithValue(r.Context(), UserContextKey, user)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// RequireScope rejects requests authenticated with an API token that lacks
+// scope. Use it after AuthMiddleware.
+func RequireScope(scope apitoken.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := GetUserFromContext(r.Context())
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if !user.Can(scope) {
+				http.Error(w, "Forbidden: the API token lacks the "+string(scope)+" scope", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSession rejects requests authenticated with an API token, for
+// endpoints only a signed-in user may call, such as managing tokens. Use it
+// after AuthMiddleware.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := GetUserFromContext(r.Context())
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if user.TokenID != "" {
+			http.Error(w, "Forbidden: API tokens cannot be used here", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,351 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/auth"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/apitoken"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/apitokens"
+)
+
+const testKeyID = "key-1"
+
+// tokenStore holds API tokens by hash
+type tokenStore struct {
+	tokens  map[string]apitoken.Token
+	lookups atomic.Int32
+	err     error
+}
+
+func (s *tokenStore) Create(ctx context.Context, t *apitoken.Token) error {
+	s.tokens[t.TokenHash] = *t
+	return nil
+}
+
+func (s *tokenStore) GetByHash(ctx context.Context, tokenHash string) (apitoken.Token, error) {
+	s.lookups.Add(1)
+	if s.err != nil {
+		return apitoken.Token{}, s.err
+	}
+	t, ok := s.tokens[tokenHash]
+	if !ok {
+		return apitoken.Token{}, sql.ErrNoRows
+	}
+	return t, nil
+}
+
+func (s *tokenStore) ListByUser(ctx context.Context, userID string) ([]apitoken.Token, error) {
+	return nil, nil
+}
+
+func (s *tokenStore) TouchLastUsed(ctx context.Context, id string) error { return nil }
+
+func (s *tokenStore) Delete(ctx context.Context, userID, id string) error { return nil }
+
+// authTest signs Supabase JWTs with a key served as the JWKS, and issues
+// API tokens
+type authTest struct {
+	key         *ecdsa.PrivateKey
+	issuer      string
+	jwks        *auth.JWKSCache
+	jwksFetches atomic.Int32
+	store       *tokenStore
+	tokens      *apitokens.Service
+}
+
+func newAuthTest(t *testing.T) *authTest {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &authTest{key: key, store: &tokenStore{tokens: make(map[string]apitoken.Token)}}
+	a.tokens = apitokens.New(a.store)
+
+	coordinate := func(n interface{ FillBytes([]byte) []byte }) string {
+		return base64.RawURLEncoding.EncodeToString(n.FillBytes(make([]byte, 32)))
+	}
+	jwks := auth.JWKS{Keys: []auth.JWK{{
+		Kid: testKeyID, Kty: "EC", Alg: "ES256", Use: "sig", Crv: "P-256",
+		X: coordinate(key.PublicKey.X), Y: coordinate(key.PublicKey.Y),
+	}}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.jwksFetches.Add(1)
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(srv.Close)
+
+	// VerifyToken reads the issuer from the environment
+	t.Setenv("SUPABASE_URL", srv.URL)
+	a.issuer = srv.URL + "/auth/v1"
+	a.jwks = auth.NewJWKSCache(srv.URL+"/auth/v1/.well-known/jwks.json", time.Hour)
+
+	return a
+}
+
+func (a *authTest) jwt(t *testing.T, key *ecdsa.PrivateKey, claims auth.SupabaseClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (a *authTest) session(subject, role string, expiresIn time.Duration) auth.SupabaseClaims {
+	return auth.SupabaseClaims{
+		Email: subject + "@example.com",
+		Role:  role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    a.issuer,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+		},
+	}
+}
+
+func (a *authTest) apiToken(t *testing.T, token apitoken.Token) string {
+	t.Helper()
+
+	secret, err := a.tokens.Create(context.Background(), &token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+// serve runs a request with authorization through handlers and returns the
+// status and the user the last handler saw
+func serve(authorization string, handlers ...func(http.Handler) http.Handler) (int, *AuthUser) {
+	var seen *AuthUser
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = GetUserFromContext(r.Context())
+	})
+	for i := len(handlers) - 1; i >= 0; i-- {
+		h = handlers[i](h)
+	}
+
+	r := httptest.NewRequest("GET", "/projects", nil)
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code, seen
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	a := newAuthTest(t)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	project := "project-1"
+	past := time.Now().Add(-time.Minute)
+	valid := a.apiToken(t, apitoken.Token{
+		ID: "token-1", UserID: "user-2", Name: "ci",
+		Scopes: []apitoken.Scope{apitoken.DeploymentsWrite}, ProjectID: &project,
+	})
+	expired := a.apiToken(t, apitoken.Token{ID: "token-2", UserID: "user-2", Name: "old", ExpiresAt: &past})
+
+	wrongIssuer := a.session("user-1", "authenticated", time.Hour)
+	wrongIssuer.Issuer = "https://attacker.example/auth/v1"
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		// Whether the JWT or the API token path ran
+		jwt, apiToken bool
+		check         func(t *testing.T, u *AuthUser)
+	}{
+		{name: "no header", status: http.StatusUnauthorized},
+		{name: "not a bearer token", authorization: "Basic dXNlcjpwYXNz", status: http.StatusUnauthorized},
+		{
+			name: "session", authorization: "Bearer " + a.jwt(t, a.key, a.session("user-1", "authenticated", time.Hour)),
+			status: http.StatusOK, jwt: true,
+			check: func(t *testing.T, u *AuthUser) {
+				if u.ID != "user-1" || u.Email != "user-1@example.com" || u.TokenID != "" || u.ProjectID != "" {
+					t.Fatalf("user = %+v, want the session of user-1", u)
+				}
+			},
+		},
+		{
+			name:          "expired session",
+			authorization: "Bearer " + a.jwt(t, a.key, a.session("user-1", "authenticated", -10*time.Minute)),
+			status:        http.StatusUnauthorized, jwt: true,
+		},
+		{
+			name:          "session from another issuer",
+			authorization: "Bearer " + a.jwt(t, a.key, wrongIssuer),
+			status:        http.StatusUnauthorized, jwt: true,
+		},
+		{
+			name:          "session signed with another key",
+			authorization: "Bearer " + a.jwt(t, otherKey, a.session("user-1", "authenticated", time.Hour)),
+			status:        http.StatusUnauthorized, jwt: true,
+		},
+		{
+			name:          "role not allowed",
+			authorization: "Bearer " + a.jwt(t, a.key, a.session("user-1", "anon", time.Hour)),
+			status:        http.StatusForbidden, jwt: true,
+		},
+		{
+			name: "API token", authorization: "Bearer " + valid,
+			status: http.StatusOK, apiToken: true,
+			check: func(t *testing.T, u *AuthUser) {
+				if u.ID != "user-2" || u.TokenID != "token-1" || u.ProjectID != project || u.Role != tokenRole {
+					t.Fatalf("user = %+v, want token-1 of user-2 restricted to %s", u, project)
+				}
+				if len(u.Scopes) != 1 || u.Scopes[0] != apitoken.DeploymentsWrite {
+					t.Fatalf("scopes = %v, want [%s]", u.Scopes, apitoken.DeploymentsWrite)
+				}
+			},
+		},
+		{name: "expired API token", authorization: "Bearer " + expired, status: http.StatusUnauthorized, apiToken: true},
+		{name: "unknown API token", authorization: "Bearer " + apitokens.TokenPrefix + "unknown", status: http.StatusUnauthorized, apiToken: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A fresh cache, so every JWT makes the JWKS be fetched
+			a.jwks = auth.NewJWKSCache(a.issuer+"/.well-known/jwks.json", time.Hour)
+			a.jwksFetches.Store(0)
+			a.store.lookups.Store(0)
+
+			status, user := serve(tt.authorization, AuthMiddleware(a.jwks, a.tokens, "authenticated"))
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+			if got := a.jwksFetches.Load() > 0; got != tt.jwt {
+				t.Fatalf("JWT verified: %v, want %v", got, tt.jwt)
+			}
+			if got := a.store.lookups.Load() > 0; got != tt.apiToken {
+				t.Fatalf("API token looked up: %v, want %v", got, tt.apiToken)
+			}
+			if tt.status != http.StatusOK {
+				if user != nil {
+					t.Fatalf("rejected request reached the handler as %+v", user)
+				}
+				return
+			}
+			if tt.check != nil {
+				tt.check(t, user)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareTokenStoreError(t *testing.T) {
+	a := newAuthTest(t)
+	a.store.err = errors.New("connection refused")
+
+	status, _ := serve("Bearer "+apitokens.TokenPrefix+"anything", AuthMiddleware(a.jwks, a.tokens))
+	if status != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", status, http.StatusInternalServerError)
+	}
+}
+
+// withUser stands in for AuthMiddleware
+func withUser(u *AuthUser) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if u != nil {
+				r = r.WithContext(context.WithValue(r.Context(), UserContextKey, u))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	session := &AuthUser{ID: "user-1"}
+	token := func(scopes ...apitoken.Scope) *AuthUser {
+		return &AuthUser{ID: "user-1", TokenID: "token-1", Scopes: scopes}
+	}
+
+	tests := []struct {
+		name   string
+		user   *AuthUser
+		scope  apitoken.Scope
+		status int
+	}{
+		{"not authenticated", nil, apitoken.ProjectsRead, http.StatusUnauthorized},
+		{"session", session, apitoken.WebhooksWrite, http.StatusOK},
+		{"token with the scope", token(apitoken.ProjectsRead), apitoken.ProjectsRead, http.StatusOK},
+		{"write includes read", token(apitoken.DeploymentsWrite), apitoken.DeploymentsRead, http.StatusOK},
+		{"read does not include write", token(apitoken.DeploymentsRead), apitoken.DeploymentsWrite, http.StatusForbidden},
+		{"other resource", token(apitoken.DeploymentsWrite), apitoken.ProjectsRead, http.StatusForbidden},
+		{"no scopes", token(), apitoken.ProjectsRead, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _ := serve("", withUser(tt.user), RequireScope(tt.scope))
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+		})
+	}
+}
+
+func TestRequireSession(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   *AuthUser
+		status int
+	}{
+		{"not authenticated", nil, http.StatusUnauthorized},
+		{"session", &AuthUser{ID: "user-1"}, http.StatusOK},
+		// Tokens cannot create or revoke tokens, even with every scope
+		{"API token", &AuthUser{ID: "user-1", TokenID: "token-1", Scopes: apitoken.Scopes}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _ := serve("", withUser(tt.user), RequireSession)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+		})
+	}
+}
+
+func TestCanAccessProject(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    AuthUser
+		project string
+		want    bool
+	}{
+		{"session", AuthUser{ID: "user-1"}, "project-1", true},
+		{"unrestricted token", AuthUser{ID: "user-1", TokenID: "token-1"}, "project-1", true},
+		{"token for the project", AuthUser{ID: "user-1", TokenID: "token-1", ProjectID: "project-1"}, "project-1", true},
+		{"token for another project", AuthUser{ID: "user-1", TokenID: "token-1", ProjectID: "project-1"}, "project-2", false},
+		{"token for a project, no project given", AuthUser{ID: "user-1", TokenID: "token-1", ProjectID: "project-1"}, "", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.user.CanAccessProject(tt.project); got != tt.want {
+			t.Errorf("%s: CanAccessProject(%q) = %v, want %v", tt.name, tt.project, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/buildauth"
+)
+
+// BuildTokenMiddleware authenticates build containers by the build token of
+// the deployment in the {id} URL parameter
+func BuildTokenMiddleware(tokens *buildauth.Service) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if !strings.HasPrefix(authHeader, "Bearer ") {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			grant, err := tokens.Authenticate(r.Context(), chi.URLParam(r, "id"), strings.TrimPrefix(authHeader, "Bearer "))
+			switch {
+			case errors.Is(err, buildauth.ErrInvalidToken):
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			case errors.Is(err, buildauth.ErrBuildFinished):
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			case err != nil:
+				log.Printf("Error in verifying build token: %s", err.Error())
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), BuildGrantContextKey, &grant)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -2,6 +2,9 @@ package middleware
 
 import (
 	"context"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/apitoken"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/buildauth"
 )
 
 type contextKey string
@@ -12,9 +15,36 @@ type AuthUser struct {
 	ID    string
 	Email string
 	Role  string
+
+	// TokenID is set when the request was authenticated with an API token
+	// instead of a Supabase session; Scopes and ProjectID then limit what
+	// it may do
+	TokenID   string
+	Scopes    []apitoken.Scope
+	ProjectID string
+}
+
+// Can reports whether the user may act with scope; a session may do
+// anything its user may
+func (u *AuthUser) Can(scope apitoken.Scope) bool {
+	return u.TokenID == "" || apitoken.Allows(u.Scopes, scope)
+}
+
+// CanAccessProject reports whether the credential covers a project. It does
+// not check ownership.
+func (u *AuthUser) CanAccessProject(projectID string) bool {
+	return u.ProjectID == "" || u.ProjectID == projectID
 }
 
 func GetUserFromContext(ctx context.Context) (*AuthUser, bool) {
 	user, ok := ctx.Value(UserContextKey).(*AuthUser)
 	return user, ok
 }
+
+const BuildGrantContextKey contextKey = "buildGrant"
+
+// GetBuildGrantFromContext returns what the request's build token allows
+func GetBuildGrantFromContext(ctx context.Context) (*buildauth.Grant, bool) {
+	grant, ok := ctx.Value(BuildGrantContextKey).(*buildauth.Grant)
+	return grant, ok
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/apitoken"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
+)
+
+// lastUsedPrecision is how stale last_used_at may be; it is not written on
+// every request
+const lastUsedPrecision = "1 minute"
+
+type Repository struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Create(ctx context.Context, t *domain.Token) error {
+	// Generate UUID v4 if not provided
+	if t.ID == "" {
+		t.ID = utils.GenerateUUID()
+	}
+
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO api_tokens (id, user_id, name, token_hash, prefix, scopes, project_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at
+	`, t.ID, t.UserID, t.Name, t.TokenHash, t.Prefix, pq.Array(scopeStrings(t.Scopes)), t.ProjectID, t.ExpiresAt).Scan(&t.CreatedAt)
+}
+
+// GetByHash returns the token with the given hash, or sql.ErrNoRows
+func (r *Repository) GetByHash(ctx context.Context, tokenHash string) (domain.Token, error) {
+	return scanToken(r.db.QueryRowContext(ctx, `
+		SELECT id, user_id, name, prefix, scopes, project_id, expires_at, last_used_at, created_at, token_hash
+		FROM api_tokens
+		WHERE token_hash = $1
+	`, tokenHash))
+}
+
+// ListByUser returns the tokens of a user, newest first
+func (r *Repository) ListByUser(ctx context.Context, userID string) ([]domain.Token, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, name, prefix, scopes, project_id, expires_at, last_used_at, created_at, token_hash
+		FROM api_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]domain.Token, 0)
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// TouchLastUsed records that a token was used, at most once per
+// lastUsedPrecision
+func (r *Repository) TouchLastUsed(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE api_tokens
+		SET last_used_at = now()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '`+lastUsedPrecision+`')
+	`, id)
+	return err
+}
+
+// Delete revokes a token of a user; it returns sql.ErrNoRows if there is none
+func (r *Repository) Delete(ctx context.Context, userID, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanToken(row rowScanner) (domain.Token, error) {
+	var t domain.Token
+	var scopes []string
+	if err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Prefix, pq.Array(&scopes), &t.ProjectID, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt, &t.TokenHash); err != nil {
+		return domain.Token{}, err
+	}
+	t.Scopes = make([]domain.Scope, len(scopes))
+	for i, s := range scopes {
+		t.Scopes[i] = domain.Scope(s)
+	}
+	return t, nil
+}
+
+func scopeStrings(scopes []domain.Scope) []string {
+	s := make([]string, len(scopes))
+	for i, scope := range scopes {
+		s[i] = string(scope)
+	}
+	return s
+}
@@ -4,8 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"time"
 
+	"github.com/lib/pq"
 	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
+	eventdomain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/event"
+	jobdomain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/job"
+	eventRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/event"
+	jobRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/job"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
 )
 
@@ -17,24 +24,121 @@ func New(db *sql.DB) *Repository {
 	return &Repository{db: db}
 }
 
+// rowQuerier is satisfied by *sql.DB and *sql.Tx
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// txQuerier is satisfied by *sql.DB and *sql.Tx
+type txQuerier interface {
+	rowQuerier
+	eventRepository.Execer
+}
+
 func (r *Repository) Create(ctx context.Context, d *domain.Deployment) (domain.Deployment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+	defer tx.Rollback()
+
+	if err := create(ctx, tx, d); err != nil {
+		return domain.Deployment{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return domain.Deployment{}, err
+	}
+	return *d, nil
+}
+
+// CreateWithBuildJob creates a deployment together with the outbox job that
+// starts its build, in one transaction. Either both exist or neither does.
+// The payload's deployment and project IDs are filled in from d.
+func (r *Repository) CreateWithBuildJob(ctx context.Context, d *domain.Deployment, payload jobdomain.BuildPayload) (domain.Deployment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+	defer tx.Rollback()
+
+	if err := create(ctx, tx, d); err != nil {
+		return domain.Deployment{}, err
+	}
+
+	payload.ProjectID = d.ProjectID
+	payload.DeploymentID = d.ID
+	if err := jobRepository.Insert(ctx, tx, jobdomain.KindDispatchBuild, d.ID, payload); err != nil {
+		return domain.Deployment{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return domain.Deployment{}, err
+	}
+	return *d, nil
+}
+
+// create inserts a deployment and records its initial status as the first
+// event, and in the outbox if it has a domain event
+func create(ctx context.Context, q txQuerier, d *domain.Deployment) error {
 	// Generate UUID v4 if not provided
 	if d.ID == "" {
 		d.ID = utils.GenerateUUID()
 	}
 
-	_, err := r.db.ExecContext(
+	err := q.QueryRowContext(
 		ctx,
-		`INSERT INTO deployments (id, project_id, status)
-		 VALUES ($1, $2, $3)`,
+		`WITH created AS (
+			INSERT INTO deployments (id, project_id, status, commit_sha, git_ref, pull_request)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, status, created_at, updated_at
+		), event AS (
+			INSERT INTO deployment_events (deployment_id, to_status, reason)
+			SELECT id, status, 'created' FROM created
+		)
+		SELECT created_at, updated_at FROM created`,
 		d.ID,
 		d.ProjectID,
 		d.Status,
-	)
+		d.CommitSHA,
+		d.GitRef,
+		d.PullRequest,
+	).Scan(&d.CreatedAt, &d.UpdatedAt)
 	if err != nil {
-		return domain.Deployment{}, err
+		return err
 	}
-	return *d, nil
+
+	return recordDomainEvent(ctx, q, d.ID, nil, d.Status, "created")
+}
+
+// recordDomainEvent writes the domain event of a deployment reaching status
+// to the outbox, if that status has one
+func recordDomainEvent(ctx context.Context, q txQuerier, deploymentID string, from *domain.Status, to domain.Status, reason string) error {
+	eventType, ok := eventdomain.DeploymentType(to)
+	if !ok {
+		return nil
+	}
+
+	data := eventdomain.DeploymentData{
+		DeploymentID:   deploymentID,
+		Status:         to,
+		PreviousStatus: from,
+		Reason:         reason,
+	}
+	if err := q.QueryRowContext(ctx, `
+		SELECT d.project_id, p.user_id, COALESCE(d.commit_sha, ''), COALESCE(d.git_ref, ''), COALESCE(d.pull_request, 0)
+		FROM deployments d
+		JOIN projects p ON p.id = d.project_id
+		WHERE d.id = $1
+	`, deploymentID).Scan(&data.ProjectID, &data.UserID, &data.CommitSHA, &data.GitRef, &data.PullRequest); err != nil {
+		return err
+	}
+
+	e, err := eventdomain.New(eventType, data.ProjectID, data)
+	if err != nil {
+		return err
+	}
+	return eventRepository.Insert(ctx, q, e)
 }
 
 func (r *Repository) GetByProjectID(ctx context.Context, projectID string, userID string) ([]domain.Deployment, error) {
@@ -67,7 +171,9 @@ func (r *Repository) GetByProjectID(ctx context.Context, projectID string, userI
 func (r *Repository) GetByIDWithProject(ctx context.Context, id string, userID string) (domain.Deployment, error) {
 	var d domain.Deployment
 	err := r.db.QueryRowContext(ctx, `
-		SELECT d.id, d.project_id, d.status, d.created_at, d.updated_at
+		SELECT d.id, d.project_id, d.status, d.created_at, d.updated_at, d.started_at, d.finished_at,
+			d.failure_reason, d.failure_excerpt, d.executor, d.build_id,
+			d.commit_sha, d.git_ref, d.pull_request
 		FROM deployments d
 		INNER JOIN projects p ON d.project_id = p.id
 		WHERE d.id = $1 AND p.user_id = $2
@@ -77,16 +183,302 @@ func (r *Repository) GetByIDWithProject(ctx context.Context, id string, userID s
 		&d.Status,
 		&d.CreatedAt,
 		&d.UpdatedAt,
+		&d.StartedAt,
+		&d.FinishedAt,
+		&d.FailureReason,
+		pq.Array(&d.FailureExcerpt),
+		&d.Executor,
+		&d.BuildID,
+		&d.CommitSHA,
+		&d.GitRef,
+		&d.PullRequest,
 	)
 	return d, err
 }
 
-func (r *Repository) DeleteByProjectID(ctx context.Context, projectID string) error {
-	_, err := r.db.ExecContext(ctx, `DELETE FROM deployments WHERE project_id = $1`, projectID)
+// GetCommitReport returns a deployment's current status together with the
+// commit and repository it was built from. It returns sql.ErrNoRows if the
+// deployment does not exist or was not triggered from a commit.
+func (r *Repository) GetCommitReport(ctx context.Context, id string) (domain.CommitReport, error) {
+	var c domain.CommitReport
+	err := r.db.QueryRowContext(ctx, `
+		SELECT d.id, d.status, d.commit_sha, d.git_ref, d.pull_request,
+			p.id, p.name, p.user_id, p.git_url, p.subdomain
+		FROM deployments d
+		INNER JOIN projects p ON d.project_id = p.id
+		WHERE d.id = $1 AND d.commit_sha IS NOT NULL
+	`, id).Scan(
+		&c.DeploymentID,
+		&c.Status,
+		&c.CommitSHA,
+		&c.GitRef,
+		&c.PullRequest,
+		&c.ProjectID,
+		&c.ProjectName,
+		&c.UserID,
+		&c.GitURL,
+		&c.SubDomain,
+	)
+	return c, err
+}
+
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM deployments WHERE id = $1`, id)
+	return err
+}
+
+// DeleteByProjectID deletes every deployment of a project and returns their
+// IDs, so data kept outside the database can be removed as well
+func (r *Repository) DeleteByProjectID(ctx context.Context, projectID string) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `DELETE FROM deployments WHERE project_id = $1 RETURNING id`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Transition moves a deployment to a new status if its current status is one
+// of from, and records the change in deployment_events and its domain event
+// in the outbox. The check and the update happen in one statement, so
+// concurrent transitions cannot interleave.
+// It returns domain.ErrInvalidTransition if the current status is not in from
+// and sql.ErrNoRows if the deployment does not exist.
+func (r *Repository) Transition(ctx context.Context, deploymentID string, from []domain.Status, to domain.Status, reason string) error {
+	allowed := make([]string, len(from))
+	for i, s := range from {
+		allowed[i] = string(s)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previous domain.Status
+	err = tx.QueryRowContext(ctx, `
+		WITH current AS (
+			SELECT id, status FROM deployments WHERE id = $1 FOR UPDATE
+		), updated AS (
+			UPDATE deployments d
+			SET status = $2::deployment_status,
+				updated_at = now(),
+				started_at = CASE WHEN $2::deployment_status = 'IN_PROGRESS'
+					THEN COALESCE(d.started_at, now()) ELSE d.started_at END,
+				finished_at = CASE WHEN $2::deployment_status IN ('READY', 'FAIL', 'CANCELED', 'TIMED_OUT')
+					THEN now() ELSE d.finished_at END
+			FROM current
+			WHERE d.id = current.id AND current.status = ANY($3::deployment_status[])
+			RETURNING d.id, current.status AS from_status
+		), event AS (
+			INSERT INTO deployment_events (deployment_id, from_status, to_status, reason)
+			SELECT id, from_status, $2::deployment_status, $4 FROM updated
+		)
+		SELECT from_status FROM updated
+	`, deploymentID, to, pq.Array(allowed), reason).Scan(&previous)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		var exists bool
+		if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM deployments WHERE id = $1)`, deploymentID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return domain.ErrInvalidTransition
+		}
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := recordDomainEvent(ctx, tx, deploymentID, &previous, to, reason); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetFailure stores the classified cause of a failed build
+func (r *Repository) SetFailure(ctx context.Context, deploymentID string, reason domain.FailureReason, excerpt []string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE deployments SET failure_reason = $2, failure_excerpt = $3, updated_at = now()
+		WHERE id = $1
+	`, deploymentID, reason, pq.Array(excerpt))
+	return err
+}
+
+// SetBuild records which executor started the build and its ID there
+func (r *Repository) SetBuild(ctx context.Context, deploymentID string, executor string, buildID string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE deployments SET executor = $2, build_id = $3, updated_at = now()
+		WHERE id = $1
+	`, deploymentID, executor, buildID)
 	return err
 }
 
-func (r *Repository) UpdateStatus(ctx context.Context, deploymentID string, status domain.Status) error {
-	_, err := r.db.ExecContext(ctx, `UPDATE deployments SET status = $1 WHERE id = $2`, status, deploymentID)
+// SetBuildToken stores the hash of the deployment's build token, replacing
+// any token issued before
+func (r *Repository) SetBuildToken(ctx context.Context, deploymentID string, tokenHash string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE deployments SET
+			build_token_previous_hashes = CASE
+				WHEN build_token_hash IS NULL THEN build_token_previous_hashes
+				ELSE array_append(build_token_previous_hashes, build_token_hash)
+			END,
+			build_token_hash = $2,
+			build_token_expires_at = $3
+		WHERE id = $1
+	`, deploymentID, tokenHash, expiresAt)
 	return err
 }
+
+// GetBuildCredential returns the build token of a deployment. It returns
+// sql.ErrNoRows if the deployment does not exist.
+func (r *Repository) GetBuildCredential(ctx context.Context, deploymentID string) (domain.BuildCredential, error) {
+	c := domain.BuildCredential{DeploymentID: deploymentID}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT project_id, status, build_token_hash, build_token_previous_hashes, build_token_expires_at
+		FROM deployments
+		WHERE id = $1
+	`, deploymentID).Scan(&c.ProjectID, &c.Status, &c.TokenHash, pq.Array(&c.PreviousTokenHashes), &c.ExpiresAt)
+	return c, err
+}
+
+// IngestEvents serializes the ingestion of build events of a deployment
+// across API servers. apply is called with the highest sequence number
+// stored without gaps and the numbers stored above it, and returns the
+// events it stored, which are recorded in the same transaction. The
+// progress apply returns is recorded even if it also returns an error.
+func (r *Repository) IngestEvents(
+	ctx context.Context,
+	deploymentID string,
+	apply func(last int64, above map[int64]bool) (domain.IngestProgress, error),
+) error {
+	// A cancelled request must not roll back progress of events already
+	// stored
+	ctx = context.WithoutCancel(ctx)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Held while events are stored, so a batch another API server received
+	// again waits and then skips them
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('deployment_events:' || $1))`, deploymentID); err != nil {
+		return err
+	}
+
+	var last int64
+	if err := tx.QueryRowContext(ctx, `SELECT last_event_seq FROM deployments WHERE id = $1`, deploymentID).Scan(&last); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT seq FROM deployment_ingested_events WHERE deployment_id = $1`, deploymentID)
+	if err != nil {
+		return err
+	}
+	above := make(map[int64]bool)
+	for rows.Next() {
+		var seq int64
+		if err := rows.Scan(&seq); err != nil {
+			rows.Close()
+			return err
+		}
+		above[seq] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	progress, applyErr := apply(last, above)
+
+	if progress.Last > last {
+		if _, err := tx.ExecContext(ctx, `UPDATE deployments SET last_event_seq = $2 WHERE id = $1`, deploymentID, progress.Last); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM deployment_ingested_events WHERE deployment_id = $1 AND seq <= $2
+		`, deploymentID, progress.Last); err != nil {
+			return err
+		}
+	}
+	if len(progress.Above) > 0 {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO deployment_ingested_events (deployment_id, seq)
+			SELECT $1, unnest($2::bigint[])
+			ON CONFLICT DO NOTHING
+		`, deploymentID, pq.Array(progress.Above)); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return applyErr
+}
+
+// QueuePosition returns where a deployment waits in the build queue, 1 being
+// next, using the scheduler's round-robin order. It returns sql.ErrNoRows if
+// the deployment is not waiting for a build.
+func (r *Repository) QueuePosition(ctx context.Context, deploymentID string) (int, error) {
+	var position int
+	err := r.db.QueryRowContext(ctx, `
+		WITH queued AS (
+			SELECT j.deployment_id, p.user_id,
+				row_number() OVER (PARTITION BY p.user_id ORDER BY j.created_at, j.id) AS user_rank,
+				min(j.created_at) OVER (PARTITION BY p.user_id) AS user_first
+			FROM build_jobs j
+			JOIN deployments d ON d.id = j.deployment_id
+			JOIN projects p ON p.id = d.project_id
+			WHERE j.kind = 'dispatch_build' AND j.status = 'pending'
+		), self AS (
+			SELECT * FROM queued WHERE deployment_id = $1
+		)
+		SELECT (
+			SELECT count(*) FROM queued q
+			WHERE (q.user_rank, q.user_first, q.user_id) < (self.user_rank, self.user_first, self.user_id)
+		) + 1
+		FROM self
+	`, deploymentID).Scan(&position)
+	return position, err
+}
+
+// ListEvents returns the status history of a deployment, oldest first
+func (r *Repository) ListEvents(ctx context.Context, deploymentID string) ([]domain.Event, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, deployment_id, from_status, to_status, reason, created_at
+		FROM deployment_events
+		WHERE deployment_id = $1
+		ORDER BY created_at ASC, id ASC
+	`, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]domain.Event, 0)
+	for rows.Next() {
+		var e domain.Event
+		if err := rows.Scan(&e.ID, &e.DeploymentID, &e.FromStatus, &e.ToStatus, &e.Reason, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/event"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
+)
+
+// Execer is satisfied by *sql.DB and *sql.Tx, so events can be written
+// inside another repository's transaction
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Insert adds an event to the outbox and a delivery for every active
+// webhook of the project subscribed to it. Pass the transaction of the
+// change it describes as ex, so the event exists exactly when the change does.
+func Insert(ctx context.Context, ex Execer, e domain.Event) error {
+	// Generate UUID v4 if not provided
+	if e.ID == "" {
+		e.ID = utils.GenerateUUID()
+	}
+
+	// Webhooks receive the same envelope as the event bus
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = ex.ExecContext(ctx, `
+		WITH inserted AS (
+			INSERT INTO domain_events (event_id, type, version, project_id, data, occurred_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING event_id, type, project_id
+		)
+		INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload)
+		SELECT gen_random_uuid()::text, w.id, i.event_id, i.type, $7
+		FROM inserted i
+		JOIN webhooks w ON w.project_id = i.project_id
+		WHERE w.active AND (cardinality(w.events) = 0 OR i.type = ANY(w.events))
+	`, e.ID, e.Type, e.Version, e.ProjectID, string(e.Data), e.OccurredAt, string(payload))
+	return err
+}
+
+// relayLock is the advisory lock held while events are published, so only
+// one API server publishes at a time and order is kept
+const relayLock = 0x6d76646572 // "mvder"
+
+// PublishFunc publishes one event; an error stops the batch
+type PublishFunc func(ctx context.Context, e domain.Event) error
+
+// PublishPending passes up to limit unpublished events to publish in id
+// order and marks those that succeed. The first failure is recorded on its
+// event and ends the batch, so later events wait rather than overtake it.
+// It returns how many were published, or 0 if another server holds the lock.
+func (r *Repository) PublishPending(ctx context.Context, limit int, publish PublishFunc) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, relayLock).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, event_id, type, version, project_id, data, occurred_at
+		FROM domain_events
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	type pending struct {
+		id    int64
+		event domain.Event
+	}
+	var events []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.event.ID, &p.event.Type, &p.event.Version, &p.event.ProjectID, &p.event.Data, &p.event.OccurredAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		p.event.Source = domain.Source
+		events = append(events, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	published := 0
+	var publishErr error
+	for _, p := range events {
+		if publishErr = publish(ctx, p.event); publishErr != nil {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE domain_events SET attempts = attempts + 1, last_error = $2
+				WHERE id = $1
+			`, p.id, publishErr.Error()); err != nil {
+				return 0, err
+			}
+			break
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE domain_events SET published_at = now(), attempts = attempts + 1, last_error = NULL
+			WHERE id = $1
+		`, p.id); err != nil {
+			return 0, err
+		}
+		published++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return published, publishErr
+}
+
+// DeleteBefore removes events that occurred before cutoff. Unpublished
+// events are only removed if includeUnpublished is set.
+func (r *Repository) DeleteBefore(ctx context.Context, cutoff time.Time, includeUnpublished bool) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM domain_events
+		WHERE occurred_at < $1 AND (published_at IS NOT NULL OR $2)
+	`, cutoff, includeUnpublished)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
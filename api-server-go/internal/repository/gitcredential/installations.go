@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/project"
+)
+
+// CreateLinkState stores the hash of the state of a GitHub linking flow a
+// user started, and drops expired ones
+func (r *Repository) CreateLinkState(ctx context.Context, stateHash, userID string, expiresAt time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM github_link_states WHERE expires_at < now()`); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO github_link_states (state_hash, user_id, expires_at)
+		VALUES ($1, $2, $3)
+	`, stateHash, userID, expiresAt)
+	return err
+}
+
+// ConsumeLinkState deletes the state of a linking flow userID started. It
+// returns sql.ErrNoRows if there is no such state or it expired, so a state
+// can only be used once and only by the user it was issued to.
+func (r *Repository) ConsumeLinkState(ctx context.Context, stateHash, userID string) error {
+	var expiresAt time.Time
+	err := r.db.QueryRowContext(ctx, `
+		DELETE FROM github_link_states WHERE state_hash = $1 AND user_id = $2
+		RETURNING expires_at
+	`, stateHash, userID).Scan(&expiresAt)
+	if err != nil {
+		return err
+	}
+	if !time.Now().Before(expiresAt) {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ReplaceInstallations sets the installations linked to a user to those
+// the user can access on GitHub now, unlinking the others
+func (r *Repository) ReplaceInstallations(ctx context.Context, userID string, installations []domain.GitHubInstallation) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, len(installations))
+	for i, installation := range installations {
+		ids[i] = installation.ID
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM github_installation_links
+		WHERE user_id = $1 AND NOT (installation_id = ANY($2::bigint[]))
+	`, userID, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	for _, installation := range installations {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO github_installation_links (user_id, installation_id, account)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, installation_id) DO UPDATE SET account = EXCLUDED.account
+		`, userID, installation.ID, installation.Account); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListInstallations returns the installations linked to a user
+func (r *Repository) ListInstallations(ctx context.Context, userID string) ([]domain.GitHubInstallation, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT installation_id, account, created_at
+		FROM github_installation_links
+		WHERE user_id = $1
+		ORDER BY account ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	installations := make([]domain.GitHubInstallation, 0)
+	for rows.Next() {
+		var installation domain.GitHubInstallation
+		if err := rows.Scan(&installation.ID, &installation.Account, &installation.LinkedAt); err != nil {
+			return nil, err
+		}
+		installations = append(installations, installation)
+	}
+	return installations, rows.Err()
+}
+
+// IsLinked reports whether a user linked an installation
+func (r *Repository) IsLinked(ctx context.Context, userID string, installationID int64) (bool, error) {
+	var linked bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM github_installation_links
+			WHERE user_id = $1 AND installation_id = $2
+		)
+	`, userID, installationID).Scan(&linked)
+	return linked, err
+}
+
+// UnlinkInstallation removes an installation from a user; it returns
+// sql.ErrNoRows if the user had not linked it
+func (r *Repository) UnlinkInstallation(ctx context.Context, userID string, installationID int64) error {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM github_installation_links WHERE user_id = $1 AND installation_id = $2
+	`, userID, installationID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
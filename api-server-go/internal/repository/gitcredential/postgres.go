@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/project"
+)
+
+// Querier is satisfied by *sql.DB and *sql.Tx, so a credential can be saved
+// in the transaction that creates its project
+type Querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Save stores the credential of a project, replacing any earlier one
+func Save(ctx context.Context, q Querier, c *domain.GitCredential) error {
+	var publicKey, fingerprint *string
+	if c.PublicKey != "" {
+		publicKey = &c.PublicKey
+	}
+	if c.Fingerprint != "" {
+		fingerprint = &c.Fingerprint
+	}
+
+	return q.QueryRowContext(ctx, `
+		INSERT INTO project_git_credentials (project_id, type, public_key, fingerprint, private_key_encrypted)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (project_id) DO UPDATE
+		SET type = EXCLUDED.type,
+			public_key = EXCLUDED.public_key,
+			fingerprint = EXCLUDED.fingerprint,
+			private_key_encrypted = EXCLUDED.private_key_encrypted,
+			updated_at = now()
+		RETURNING created_at, updated_at
+	`, c.ProjectID, c.Type, publicKey, fingerprint, c.PrivateKey).Scan(&c.CreatedAt, &c.UpdatedAt)
+}
+
+func (r *Repository) Save(ctx context.Context, c *domain.GitCredential) error {
+	return Save(ctx, r.db, c)
+}
+
+// Get returns the credential of a project with its encrypted private key
+// but without the public key, or sql.ErrNoRows if the project has none
+func (r *Repository) Get(ctx context.Context, projectID string) (domain.GitCredential, error) {
+	c := domain.GitCredential{ProjectID: projectID}
+	var fingerprint sql.NullString
+	err := r.db.QueryRowContext(ctx, `
+		SELECT type, fingerprint, private_key_encrypted, created_at, updated_at
+		FROM project_git_credentials
+		WHERE project_id = $1
+	`, projectID).Scan(&c.Type, &fingerprint, &c.PrivateKey, &c.CreatedAt, &c.UpdatedAt)
+	c.Fingerprint = fingerprint.String
+	return c, err
+}
+
+// Delete removes the credential of a project; its builds clone anonymously
+func (r *Repository) Delete(ctx context.Context, projectID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM project_git_credentials WHERE project_id = $1`, projectID)
+	return err
+}
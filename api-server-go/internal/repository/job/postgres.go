@@ -0,0 +1,294 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/job"
+)
+
+// Execer is satisfied by *sql.DB and *sql.Tx, so jobs can be written inside
+// another repository's transaction
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Insert adds a pending job. Pass the caller's transaction as ex so the job is
+// only visible if that transaction commits.
+func Insert(ctx context.Context, ex Execer, kind domain.Kind, deploymentID string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = ex.ExecContext(ctx, `
+		INSERT INTO build_jobs (kind, deployment_id, payload)
+		VALUES ($1, $2, $3)
+	`, kind, deploymentID, body)
+	return err
+}
+
+// schedulerLock is the advisory lock held while slots are counted and jobs
+// selected, so concurrency limits are checked against a consistent view
+// across API servers. It only serializes the accounting; the selected rows
+// are still claimed with FOR UPDATE SKIP LOCKED.
+const schedulerLock = 0x6d76627173 // "mvbqs"
+
+// candidateWindow bounds how many runnable jobs are considered per claim
+const candidateWindow = 500
+
+// ClaimPolicy controls which runnable jobs Claim may start
+type ClaimPolicy struct {
+	Limits domain.Limits
+	// SkipSuperseded marks queued builds that have a newer deployment in the
+	// same project, so they are skipped instead of built
+	SkipSuperseded bool
+	// StaleAfter stops a started build from holding a slot once its
+	// deployment has not changed for this long; 0 never releases it
+	StaleAfter time.Duration
+}
+
+// Claim locks up to limit runnable jobs for lease and increments their
+// attempt count. Jobs whose lease expired (the worker died) are claimed again.
+// Builds are taken in round-robin order across users and only while the
+// concurrency limits of policy allow; the rest stay pending. A selected job
+// whose row another transaction holds, such as a worker completing it, is
+// skipped until the next claim rather than waited on.
+func (r *Repository) Claim(ctx context.Context, limit int, lease time.Duration, policy ClaimPolicy) ([]domain.Job, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, schedulerLock); err != nil {
+		return nil, err
+	}
+
+	usage, err := loadUsage(ctx, tx, policy.StaleAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := loadCandidates(ctx, tx, policy.SkipSuperseded)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := domain.Select(candidates, usage, policy.Limits, limit)
+	if len(selected) == 0 {
+		return []domain.Job{}, tx.Commit()
+	}
+
+	ids := make([]int64, len(selected))
+	for i, c := range selected {
+		ids[i] = c.ID
+	}
+
+	locked, err := lockRunnable(ctx, tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	claimed := selected[:0]
+	for _, c := range selected {
+		if locked[c.ID] {
+			claimed = append(claimed, c)
+		}
+	}
+	if len(claimed) == 0 {
+		return []domain.Job{}, tx.Commit()
+	}
+
+	ids = ids[:0]
+	for _, c := range claimed {
+		ids = append(ids, c.ID)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE build_jobs
+		SET status = 'running',
+			attempts = attempts + 1,
+			locked_until = now() + $2 * interval '1 millisecond',
+			updated_at = now()
+		WHERE id = ANY($1)
+	`, pq.Array(ids), lease.Milliseconds()); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	jobs := make([]domain.Job, len(claimed))
+	for i, c := range claimed {
+		j := c.Job
+		j.Status = domain.Running
+		j.Attempts++
+		jobs[i] = j
+	}
+
+	return jobs, nil
+}
+
+// lockRunnable locks the jobs of ids that are still runnable and returns
+// their IDs. Rows locked by another transaction are skipped.
+func lockRunnable(ctx context.Context, tx *sql.Tx, ids []int64) (map[int64]bool, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id FROM build_jobs
+		WHERE id = ANY($1)
+			AND ((status = 'pending' AND run_after <= now())
+				OR (status = 'running' AND locked_until < now()))
+		FOR UPDATE SKIP LOCKED
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locked := make(map[int64]bool, len(ids))
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		locked[id] = true
+	}
+
+	return locked, rows.Err()
+}
+
+// loadUsage counts builds that hold a slot: claimed and not yet started, or
+// started and not yet finished
+func loadUsage(ctx context.Context, tx *sql.Tx, staleAfter time.Duration) (domain.Usage, error) {
+	usage := domain.NewUsage()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT p.user_id, d.project_id
+		FROM build_jobs j
+		JOIN deployments d ON d.id = j.deployment_id
+		JOIN projects p ON p.id = d.project_id
+		WHERE j.kind = 'dispatch_build'
+			AND d.status IN ('QUEUED', 'IN_PROGRESS')
+			AND ((j.status = 'running' AND j.locked_until >= now())
+				OR (j.status = 'done' AND ($1::bigint = 0 OR d.updated_at > now() - $1::bigint * interval '1 millisecond')))
+	`, staleAfter.Milliseconds())
+	if err != nil {
+		return usage, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, projectID string
+		if err := rows.Scan(&userID, &projectID); err != nil {
+			return usage, err
+		}
+		usage.Add(userID, projectID)
+	}
+
+	return usage, rows.Err()
+}
+
+// loadCandidates returns runnable jobs in round-robin order: every user's
+// oldest job, ordered by how long the user has waited, then every user's
+// second oldest, and so on
+func loadCandidates(ctx context.Context, tx *sql.Tx, skipSuperseded bool) ([]domain.Candidate, error) {
+	rows, err := tx.QueryContext(ctx, `
+		WITH runnable AS (
+			SELECT j.*, p.user_id, d.project_id,
+				CASE WHEN $1 AND j.kind = 'dispatch_build' AND j.status = 'pending' THEN (
+					SELECT n.id FROM deployments n
+					WHERE n.project_id = d.project_id
+						AND n.created_at > d.created_at
+						AND n.status IN ('QUEUED', 'IN_PROGRESS', 'READY')
+					ORDER BY n.created_at DESC
+					LIMIT 1
+				) END AS superseded_by
+			FROM build_jobs j
+			JOIN deployments d ON d.id = j.deployment_id
+			JOIN projects p ON p.id = d.project_id
+			WHERE (j.status = 'pending' AND j.run_after <= now())
+				OR (j.status = 'running' AND j.locked_until < now())
+		), ranked AS (
+			SELECT *,
+				row_number() OVER (PARTITION BY user_id ORDER BY created_at, id) AS user_rank,
+				min(created_at) OVER (PARTITION BY user_id) AS user_first
+			FROM runnable
+		)
+		SELECT id, kind, deployment_id, payload, status, attempts, last_error, run_after, created_at,
+			user_id, project_id, COALESCE(superseded_by, '')
+		FROM ranked
+		ORDER BY user_rank, user_first, user_id
+		LIMIT $2
+	`, skipSuperseded, candidateWindow)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := make([]domain.Candidate, 0)
+	for rows.Next() {
+		var c domain.Candidate
+		if err := rows.Scan(
+			&c.ID,
+			&c.Kind,
+			&c.DeploymentID,
+			&c.Payload,
+			&c.Status,
+			&c.Attempts,
+			&c.LastError,
+			&c.RunAfter,
+			&c.CreatedAt,
+			&c.UserID,
+			&c.ProjectID,
+			&c.SupersededBy,
+		); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+
+	return candidates, rows.Err()
+}
+
+// Complete marks a job as done
+func (r *Repository) Complete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE build_jobs SET status = 'done', locked_until = NULL, updated_at = now()
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+// Retry releases a job to run again after delay
+func (r *Repository) Retry(ctx context.Context, id int64, lastErr string, delay time.Duration) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE build_jobs
+		SET status = 'pending',
+			last_error = $2,
+			run_after = now() + $3 * interval '1 millisecond',
+			locked_until = NULL,
+			updated_at = now()
+		WHERE id = $1
+	`, id, lastErr, delay.Milliseconds())
+	return err
+}
+
+// Fail marks a job as permanently failed
+func (r *Repository) Fail(ctx context.Context, id int64, lastErr string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE build_jobs SET status = 'failed', last_error = $2, locked_until = NULL, updated_at = now()
+		WHERE id = $1
+	`, id, lastErr)
+	return err
+}
@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/job"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
+)
+
+const testLease = time.Minute
+
+func TestClaimLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits domain.Limits
+		// Builds claimed by the first and the second claim
+		first, second int
+	}{
+		{"unlimited", domain.Limits{}, 4, 0},
+		{"global", domain.Limits{Global: 2}, 2, 0},
+		{"per user", domain.Limits{PerUser: 1}, 2, 0},
+		{"per project", domain.Limits{PerProject: 1}, 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openPostgres(t)
+			repo := New(db)
+
+			// u1 has two builds of p1 and one of p2, u2 one build of p3
+			seedBuild(t, db, "u1", "p1", 4*time.Minute)
+			seedBuild(t, db, "u1", "p1", 3*time.Minute)
+			seedBuild(t, db, "u1", "p2", 2*time.Minute)
+			seedBuild(t, db, "u2", "p3", time.Minute)
+
+			policy := ClaimPolicy{Limits: tt.limits}
+			first := claim(t, repo, policy)
+			if len(first) != tt.first {
+				t.Fatalf("first claim took %d builds, want %d", len(first), tt.first)
+			}
+			// Claimed builds hold their slots until their lease expires
+			if second := claim(t, repo, policy); len(second) != tt.second {
+				t.Fatalf("second claim took %d builds, want %d", len(second), tt.second)
+			}
+		})
+	}
+}
+
+func TestClaimRoundRobin(t *testing.T) {
+	db := openPostgres(t)
+	repo := New(db)
+
+	a1, _ := seedBuild(t, db, "u1", "p1", 4*time.Minute)
+	seedBuild(t, db, "u1", "p1", 3*time.Minute)
+	b1, _ := seedBuild(t, db, "u2", "p2", 2*time.Minute)
+
+	jobs := claim(t, repo, ClaimPolicy{Limits: domain.Limits{Global: 2}})
+	if len(jobs) != 2 || jobs[0].DeploymentID != a1 || jobs[1].DeploymentID != b1 {
+		t.Fatalf("claimed %v, want the oldest build of each user", deploymentIDs(jobs))
+	}
+}
+
+func TestClaimSkipsSuperseded(t *testing.T) {
+	db := openPostgres(t)
+	repo := New(db)
+
+	older, _ := seedBuild(t, db, "u1", "p1", 2*time.Minute)
+	newer, _ := seedBuild(t, db, "u1", "p1", time.Minute)
+
+	// The superseded build takes no slot, so both are claimed
+	jobs := claim(t, repo, ClaimPolicy{SkipSuperseded: true, Limits: domain.Limits{PerProject: 1}})
+	if len(jobs) != 2 {
+		t.Fatalf("claimed %v, want both builds", deploymentIDs(jobs))
+	}
+	for _, j := range jobs {
+		want := ""
+		if j.DeploymentID == older {
+			want = newer
+		}
+		if j.SupersededBy != want {
+			t.Fatalf("build %s superseded by %q, want %q", j.DeploymentID, j.SupersededBy, want)
+		}
+	}
+}
+
+func TestClaimKeepsSupersededWhenDisabled(t *testing.T) {
+	db := openPostgres(t)
+	repo := New(db)
+
+	seedBuild(t, db, "u1", "p1", 2*time.Minute)
+	seedBuild(t, db, "u1", "p1", time.Minute)
+
+	jobs := claim(t, repo, ClaimPolicy{Limits: domain.Limits{PerProject: 1}})
+	if len(jobs) != 1 || jobs[0].SupersededBy != "" {
+		t.Fatalf("claimed %+v, want the older build to run", jobs)
+	}
+}
+
+func TestClaimSkipsLockedJobs(t *testing.T) {
+	db := openPostgres(t)
+	repo := New(db)
+	ctx := context.Background()
+
+	_, held := seedBuild(t, db, "u1", "p1", 2*time.Minute)
+	free, _ := seedBuild(t, db, "u2", "p2", time.Minute)
+
+	// Another transaction, such as a worker updating the job, holds its row
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `SELECT id FROM build_jobs WHERE id = $1 FOR UPDATE`, held); err != nil {
+		t.Fatal(err)
+	}
+
+	claimCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	jobs, err := repo.Claim(claimCtx, 10, testLease, ClaimPolicy{})
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].DeploymentID != free {
+		t.Fatalf("claimed %v, want only %s", deploymentIDs(jobs), free)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if jobs := claim(t, repo, ClaimPolicy{}); len(jobs) != 1 || jobs[0].ID != held {
+		t.Fatalf("claimed %+v, want the released job", jobs)
+	}
+}
+
+func claim(t *testing.T, repo *Repository, policy ClaimPolicy) []domain.Job {
+	t.Helper()
+
+	jobs, err := repo.Claim(context.Background(), 10, testLease, policy)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	return jobs
+}
+
+func deploymentIDs(jobs []domain.Job) []string {
+	ids := make([]string, len(jobs))
+	for i, j := range jobs {
+		ids[i] = j.DeploymentID
+	}
+	return ids
+}
+
+// seedBuild creates a QUEUED deployment of projectID, owned by userID and
+// created age ago, with its pending build job
+func seedBuild(t *testing.T, db *sql.DB, userID, projectID string, age time.Duration) (string, int64) {
+	t.Helper()
+	ctx := context.Background()
+
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO projects (id, name, git_url, subdomain, user_id)
+		VALUES ($1, $1, 'https://github.com/example/site', $1, $2)
+		ON CONFLICT (id) DO NOTHING
+	`, projectID, userID); err != nil {
+		t.Fatalf("insert project: %v", err)
+	}
+
+	deploymentID := utils.GenerateUUID()
+	createdAt := time.Now().Add(-age)
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO deployments (id, project_id, status, created_at, updated_at)
+		VALUES ($1, $2, 'QUEUED', $3, $3)
+	`, deploymentID, projectID, createdAt); err != nil {
+		t.Fatalf("insert deployment: %v", err)
+	}
+
+	var jobID int64
+	if err := db.QueryRowContext(ctx, `
+		INSERT INTO build_jobs (kind, deployment_id, created_at, run_after)
+		VALUES ($1, $2, $3, $3)
+		RETURNING id
+	`, domain.KindDispatchBuild, deploymentID, createdAt).Scan(&jobID); err != nil {
+		t.Fatalf("insert job: %v", err)
+	}
+
+	return deploymentID, jobID
+}
+
+// openPostgres applies every migration in a new schema of the database at
+// JOBS_TEST_POSTGRES_DSN and returns a connection that uses it. The schema is
+// dropped after the test.
+func openPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("JOBS_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("JOBS_TEST_POSTGRES_DSN is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := "jobstest_" + strings.ReplaceAll(utils.GenerateUUID(), "-", "")
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Logf("drop schema: %v", err)
+		}
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" {
+		t.Fatalf("JOBS_TEST_POSTGRES_DSN must be a postgres:// URL")
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrations, err := filepath.Glob("../../../migrations/*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(migrations)
+	for _, path := range migrations {
+		migration, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			t.Fatalf("apply %s: %v", filepath.Base(path), err)
+		}
+	}
+
+	return db
+}
@@ -0,0 +1,331 @@
+// Package logstoretest is the conformance suite every logs.LogStore backend
+// must pass. Backends call Run from their own tests:
+//
+//	func TestMemoryStore(t *testing.T) {
+//		logstoretest.Run(t, func(t *testing.T) logs.LogStore {
+//			return logs.NewMemoryStore()
+//		})
+//	}
+//
+// Each subtest gets a fresh store and uses random deployment IDs, so shared
+// databases (ClickHouse, PostgreSQL) can be reused between subtests.
+package logstoretest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/logs"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
+)
+
+// Run executes the conformance suite. newStore must return a store that is
+// ready for writes.
+func Run(t *testing.T, newStore func(t *testing.T) logs.LogStore) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s logs.LogStore)
+	}{
+		{"AppendAndQueryInOrder", testAppendAndQueryInOrder},
+		{"CursorPagination", testCursorPagination},
+		{"BatchOrder", testBatchOrder},
+		{"Tail", testTail},
+		{"FilterStream", testFilterStream},
+		{"FilterSubstring", testFilterSubstring},
+		{"FilterRegex", testFilterRegex},
+		{"FilterTimeRange", testFilterTimeRange},
+		{"Count", testCount},
+		{"DeleteByDeployment", testDeleteByDeployment},
+		{"DeleteByProject", testDeleteByProject},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStore(t))
+		})
+	}
+}
+
+// seed appends n lines to a new deployment, one batch per line so stores that
+// assign timestamps on insert keep them in order
+func seed(t *testing.T, s logs.LogStore, projectID string, n int, line func(i int) buildlog.LogEvent) string {
+	t.Helper()
+
+	deploymentID := utils.GenerateUUID()
+	for i := 0; i < n; i++ {
+		e := line(i)
+		e.DeploymentID = deploymentID
+		e.ProjectID = projectID
+		if err := s.Append(context.Background(), []buildlog.LogEvent{e}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		// Give second-resolution backends distinct timestamps to order by
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	return deploymentID
+}
+
+func numbered(i int) buildlog.LogEvent {
+	return buildlog.LogEvent{Log: fmt.Sprintf("line %03d", i)}
+}
+
+func query(t *testing.T, s logs.LogStore, deploymentID string, f logs.Filter) []buildlog.LogEvent {
+	t.Helper()
+
+	out, err := s.Query(context.Background(), deploymentID, f)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	return out
+}
+
+func assertLines(t *testing.T, got []buildlog.LogEvent, want ...string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].Log != want[i] {
+			t.Fatalf("line %d = %q, want %q", i, got[i].Log, want[i])
+		}
+	}
+}
+
+func testAppendAndQueryInOrder(t *testing.T, s logs.LogStore) {
+	id := seed(t, s, utils.GenerateUUID(), 5, numbered)
+
+	got := query(t, s, id, logs.Filter{})
+	assertLines(t, got, "line 000", "line 001", "line 002", "line 003", "line 004")
+
+	for _, e := range got {
+		if e.EventID == "" {
+			t.Fatal("store did not assign an event ID")
+		}
+		if e.Timestamp == nil {
+			t.Fatal("store did not assign a timestamp")
+		}
+		if e.Stream != buildlog.StreamStdout {
+			t.Fatalf("default stream = %q, want stdout", e.Stream)
+		}
+	}
+}
+
+func testCursorPagination(t *testing.T, s logs.LogStore) {
+	id := seed(t, s, utils.GenerateUUID(), 7, numbered)
+
+	var seen []string
+	filter := logs.Filter{Limit: 3}
+	for pages := 0; pages < 10; pages++ {
+		page := query(t, s, id, filter)
+		for _, e := range page {
+			seen = append(seen, e.Log)
+		}
+		if len(page) < filter.Limit {
+			break
+		}
+		cursor := buildlog.CursorOf(page[len(page)-1])
+		// Round-trip through the wire format like the HTTP API does
+		decoded, err := buildlog.DecodeCursor(cursor.Encode())
+		if err != nil {
+			t.Fatalf("DecodeCursor: %v", err)
+		}
+		filter.After = &decoded
+	}
+
+	if len(seen) != 7 {
+		t.Fatalf("paged through %d lines, want 7: %v", len(seen), seen)
+	}
+	for i, l := range seen {
+		if want := fmt.Sprintf("line %03d", i); l != want {
+			t.Fatalf("line %d = %q, want %q", i, l, want)
+		}
+	}
+}
+
+// testBatchOrder appends one batch, as the log consumer does, and expects
+// its lines back in order, also when paging through them
+func testBatchOrder(t *testing.T, s logs.LogStore) {
+	id := utils.GenerateUUID()
+	events := make([]buildlog.LogEvent, 0, 50)
+	for i := 0; i < 50; i++ {
+		e := numbered(i)
+		e.DeploymentID = id
+		events = append(events, e)
+	}
+	if err := s.Append(context.Background(), events); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	var seen []string
+	filter := logs.Filter{Limit: 7}
+	for pages := 0; pages < 20; pages++ {
+		page := query(t, s, id, filter)
+		for _, e := range page {
+			seen = append(seen, e.Log)
+		}
+		if len(page) < filter.Limit {
+			break
+		}
+		cursor := buildlog.CursorOf(page[len(page)-1])
+		filter.After = &cursor
+	}
+
+	if len(seen) != 50 {
+		t.Fatalf("paged through %d lines, want 50", len(seen))
+	}
+	for i, l := range seen {
+		if want := fmt.Sprintf("line %03d", i); l != want {
+			t.Fatalf("line %d = %q, want %q", i, l, want)
+		}
+	}
+
+	got, err := s.Tail(context.Background(), id, logs.Filter{}, 3)
+	if err != nil {
+		t.Fatalf("Tail: %v", err)
+	}
+	assertLines(t, got, "line 047", "line 048", "line 049")
+}
+
+func testTail(t *testing.T, s logs.LogStore) {
+	id := seed(t, s, utils.GenerateUUID(), 6, numbered)
+
+	got, err := s.Tail(context.Background(), id, logs.Filter{}, 2)
+	if err != nil {
+		t.Fatalf("Tail: %v", err)
+	}
+	assertLines(t, got, "line 004", "line 005")
+
+	got, err = s.Tail(context.Background(), id, logs.Filter{}, 100)
+	if err != nil {
+		t.Fatalf("Tail: %v", err)
+	}
+	if len(got) != 6 {
+		t.Fatalf("tail beyond length returned %d lines, want 6", len(got))
+	}
+}
+
+func testFilterStream(t *testing.T, s logs.LogStore) {
+	id := seed(t, s, utils.GenerateUUID(), 4, func(i int) buildlog.LogEvent {
+		e := numbered(i)
+		if i%2 == 1 {
+			e.Stream = buildlog.StreamStderr
+		}
+		return e
+	})
+
+	got := query(t, s, id, logs.Filter{Stream: buildlog.StreamStderr})
+	assertLines(t, got, "line 001", "line 003")
+}
+
+func testFilterSubstring(t *testing.T, s logs.LogStore) {
+	lines := []string{"npm ERR! code E404", "added 10 packages", "100% done", "npm err! again"}
+	id := seed(t, s, utils.GenerateUUID(), len(lines), func(i int) buildlog.LogEvent {
+		return buildlog.LogEvent{Log: lines[i]}
+	})
+
+	assertLines(t, query(t, s, id, logs.Filter{Search: "NPM ERR!"}), lines[0], lines[3])
+	// LIKE wildcards in user input must be matched literally
+	assertLines(t, query(t, s, id, logs.Filter{Search: "0%"}), lines[2])
+	assertLines(t, query(t, s, id, logs.Filter{Search: "_"}))
+}
+
+func testFilterRegex(t *testing.T, s logs.LogStore) {
+	lines := []string{"exit code 1", "exit code 137", "ok"}
+	id := seed(t, s, utils.GenerateUUID(), len(lines), func(i int) buildlog.LogEvent {
+		return buildlog.LogEvent{Log: lines[i]}
+	})
+
+	assertLines(t, query(t, s, id, logs.Filter{Search: `code \d{3}$`, Regex: true}), lines[1])
+}
+
+func testFilterTimeRange(t *testing.T, s logs.LogStore) {
+	base := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	id := utils.GenerateUUID()
+
+	events := make([]buildlog.LogEvent, 0, 4)
+	for i := 0; i < 4; i++ {
+		ts := base.Add(time.Duration(i) * time.Minute)
+		events = append(events, buildlog.LogEvent{DeploymentID: id, Log: fmt.Sprintf("line %03d", i), Timestamp: &ts})
+	}
+	if err := s.Append(context.Background(), events); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	// Stores that always assign server-side timestamps cannot honour this
+	all := query(t, s, id, logs.Filter{})
+	if len(all) != 4 || !all[0].Timestamp.Equal(base) {
+		t.Skip("store assigns its own timestamps")
+	}
+
+	since := base.Add(time.Minute)
+	until := base.Add(2 * time.Minute)
+	assertLines(t, query(t, s, id, logs.Filter{Since: &since, Until: &until}), "line 001", "line 002")
+}
+
+func testCount(t *testing.T, s logs.LogStore) {
+	id := seed(t, s, utils.GenerateUUID(), 3, numbered)
+
+	n, err := s.Count(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("Count = %d, want 3", n)
+	}
+}
+
+// eventually polls for asynchronous deletes (ClickHouse mutations)
+func eventually(t *testing.T, s logs.LogStore, deploymentID string, want int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		n, err := s.Count(context.Background(), deploymentID)
+		if err != nil {
+			t.Fatalf("Count: %v", err)
+		}
+		if n == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Count = %d, want %d", n, want)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func testDeleteByDeployment(t *testing.T, s logs.LogStore) {
+	project := utils.GenerateUUID()
+	gone := seed(t, s, project, 2, numbered)
+	kept := seed(t, s, project, 2, numbered)
+
+	if err := s.DeleteByDeployment(context.Background(), gone); err != nil {
+		t.Fatalf("DeleteByDeployment: %v", err)
+	}
+
+	eventually(t, s, gone, 0)
+	eventually(t, s, kept, 2)
+}
+
+func testDeleteByProject(t *testing.T, s logs.LogStore) {
+	project := utils.GenerateUUID()
+	a := seed(t, s, project, 2, numbered)
+	b := seed(t, s, project, 1, numbered)
+	// Written before lines carried their project
+	legacy := seed(t, s, "", 2, numbered)
+	other := seed(t, s, utils.GenerateUUID(), 2, numbered)
+
+	if err := s.DeleteByProject(context.Background(), project, []string{a, legacy}); err != nil {
+		t.Fatalf("DeleteByProject: %v", err)
+	}
+
+	eventually(t, s, a, 0)
+	eventually(t, s, b, 0)
+	eventually(t, s, legacy, 0)
+	eventually(t, s, other, 2)
+}
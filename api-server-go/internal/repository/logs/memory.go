@@ -0,0 +1,194 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
+)
+
+// MemoryStore keeps logs in process memory. Nothing is persisted; it is
+// meant for local development and tests.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	lines map[string][]buildlog.LogEvent
+	last  time.Time
+}
+
+// NewMemoryStore creates an empty in-memory log store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		lines: make(map[string][]buildlog.LogEvent),
+	}
+}
+
+// Append stores a batch of log lines
+func (s *MemoryStore) Append(ctx context.Context, events []buildlog.LogEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	touched := make(map[string]bool)
+	for _, e := range events {
+		if e.EventID == "" {
+			e.EventID = utils.GenerateUUID()
+		}
+		if e.Stream == "" {
+			e.Stream = buildlog.StreamStdout
+		}
+		if e.Timestamp == nil {
+			// Strictly increasing so insertion order is preserved
+			now := time.Now().UTC()
+			if !now.After(s.last) {
+				now = s.last.Add(time.Nanosecond)
+			}
+			s.last = now
+			e.Timestamp = &now
+		} else {
+			ts := e.Timestamp.UTC()
+			e.Timestamp = &ts
+		}
+
+		s.lines[e.DeploymentID] = append(s.lines[e.DeploymentID], e)
+		touched[e.DeploymentID] = true
+	}
+
+	for id := range touched {
+		lines := s.lines[id]
+		sort.SliceStable(lines, func(i, j int) bool {
+			return buildlog.CursorOf(lines[i]).Before(lines[j])
+		})
+	}
+
+	return nil
+}
+
+// Query returns matching lines in ascending order
+func (s *MemoryStore) Query(ctx context.Context, deploymentID string, filter Filter) ([]buildlog.LogEvent, error) {
+	match, err := memoryMatcher(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]buildlog.LogEvent, 0)
+	for _, e := range s.lines[deploymentID] {
+		if filter.After != nil && !filter.After.Before(e) {
+			continue
+		}
+		if !match(e) {
+			continue
+		}
+		out = append(out, e)
+		if filter.Limit > 0 && len(out) == filter.Limit {
+			break
+		}
+	}
+
+	return out, nil
+}
+
+// Tail returns the last n matching lines in ascending order
+func (s *MemoryStore) Tail(ctx context.Context, deploymentID string, filter Filter, n int) ([]buildlog.LogEvent, error) {
+	match, err := memoryMatcher(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	lines := s.lines[deploymentID]
+	out := make([]buildlog.LogEvent, 0, n)
+	for i := len(lines) - 1; i >= 0 && len(out) < n; i-- {
+		if match(lines[i]) {
+			out = append(out, lines[i])
+		}
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+
+	return out, nil
+}
+
+// Count returns the number of stored lines for a deployment
+func (s *MemoryStore) Count(ctx context.Context, deploymentID string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return int64(len(s.lines[deploymentID])), nil
+}
+
+// DeleteByDeployment removes every line of a deployment
+func (s *MemoryStore) DeleteByDeployment(ctx context.Context, deploymentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.lines, deploymentID)
+	return nil
+}
+
+// DeleteByProject removes every line of a project and of deploymentIDs
+func (s *MemoryStore) DeleteByProject(ctx context.Context, projectID string, deploymentIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range deploymentIDs {
+		delete(s.lines, id)
+	}
+	for id, lines := range s.lines {
+		kept := lines[:0]
+		for _, e := range lines {
+			if e.ProjectID != projectID {
+				kept = append(kept, e)
+			}
+		}
+		if len(kept) == 0 {
+			delete(s.lines, id)
+		} else {
+			s.lines[id] = kept
+		}
+	}
+
+	return nil
+}
+
+// memoryMatcher compiles the non-positional parts of a filter
+func memoryMatcher(filter Filter) (func(buildlog.LogEvent) bool, error) {
+	var re *regexp.Regexp
+	if filter.Search != "" && filter.Regex {
+		var err error
+		if re, err = regexp.Compile(filter.Search); err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+	}
+	needle := strings.ToLower(filter.Search)
+
+	return func(e buildlog.LogEvent) bool {
+		if filter.Since != nil && e.Timestamp.Before(*filter.Since) {
+			return false
+		}
+		if filter.Until != nil && e.Timestamp.After(*filter.Until) {
+			return false
+		}
+		if filter.Stream != "" && e.Stream != filter.Stream {
+			return false
+		}
+		if re != nil {
+			return re.MatchString(e.Log)
+		}
+		if needle != "" {
+			return strings.Contains(strings.ToLower(e.Log), needle)
+		}
+		return true
+	}, nil
+}
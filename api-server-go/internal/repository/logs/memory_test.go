@@ -0,0 +1,14 @@
+package logs_test
+
+import (
+	"testing"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/logs"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/logs/logstoretest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	logstoretest.Run(t, func(t *testing.T) logs.LogStore {
+		return logs.NewMemoryStore()
+	})
+}
@@ -0,0 +1,108 @@
+package logs
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
+)
+
+// MockStore is a mock implementation of LogStore for testing.
+// Unset funcs fall back to an embedded MemoryStore.
+type MockStore struct {
+	mu sync.Mutex
+
+	AppendFunc             func(ctx context.Context, events []buildlog.LogEvent) error
+	QueryFunc              func(ctx context.Context, deploymentID string, filter Filter) ([]buildlog.LogEvent, error)
+	TailFunc               func(ctx context.Context, deploymentID string, filter Filter, n int) ([]buildlog.LogEvent, error)
+	CountFunc              func(ctx context.Context, deploymentID string) (int64, error)
+	DeleteByDeploymentFunc func(ctx context.Context, deploymentID string) error
+	DeleteByProjectFunc    func(ctx context.Context, projectID string, deploymentIDs []string) error
+
+	// Call tracking
+	Calls []MockCall
+
+	fallback *MemoryStore
+}
+
+// MockCall represents a tracked function call
+type MockCall struct {
+	Method string
+	Args   []any
+}
+
+// NewMockStore creates a new mock store
+func NewMockStore() *MockStore {
+	return &MockStore{
+		Calls:    make([]MockCall, 0),
+		fallback: NewMemoryStore(),
+	}
+}
+
+func (m *MockStore) track(method string, args ...any) {
+	m.mu.Lock()
+	m.Calls = append(m.Calls, MockCall{Method: method, Args: args})
+	m.mu.Unlock()
+}
+
+// Append implements LogStore
+func (m *MockStore) Append(ctx context.Context, events []buildlog.LogEvent) error {
+	m.track("Append", events)
+	if m.AppendFunc != nil {
+		return m.AppendFunc(ctx, events)
+	}
+	return m.fallback.Append(ctx, events)
+}
+
+// Query implements LogStore
+func (m *MockStore) Query(ctx context.Context, deploymentID string, filter Filter) ([]buildlog.LogEvent, error) {
+	m.track("Query", deploymentID, filter)
+	if m.QueryFunc != nil {
+		return m.QueryFunc(ctx, deploymentID, filter)
+	}
+	return m.fallback.Query(ctx, deploymentID, filter)
+}
+
+// Tail implements LogStore
+func (m *MockStore) Tail(ctx context.Context, deploymentID string, filter Filter, n int) ([]buildlog.LogEvent, error) {
+	m.track("Tail", deploymentID, filter, n)
+	if m.TailFunc != nil {
+		return m.TailFunc(ctx, deploymentID, filter, n)
+	}
+	return m.fallback.Tail(ctx, deploymentID, filter, n)
+}
+
+// Count implements LogStore
+func (m *MockStore) Count(ctx context.Context, deploymentID string) (int64, error) {
+	m.track("Count", deploymentID)
+	if m.CountFunc != nil {
+		return m.CountFunc(ctx, deploymentID)
+	}
+	return m.fallback.Count(ctx, deploymentID)
+}
+
+// DeleteByDeployment implements LogStore
+func (m *MockStore) DeleteByDeployment(ctx context.Context, deploymentID string) error {
+	m.track("DeleteByDeployment", deploymentID)
+	if m.DeleteByDeploymentFunc != nil {
+		return m.DeleteByDeploymentFunc(ctx, deploymentID)
+	}
+	return m.fallback.DeleteByDeployment(ctx, deploymentID)
+}
+
+// DeleteByProject implements LogStore
+func (m *MockStore) DeleteByProject(ctx context.Context, projectID string, deploymentIDs []string) error {
+	m.track("DeleteByProject", projectID, deploymentIDs)
+	if m.DeleteByProjectFunc != nil {
+		return m.DeleteByProjectFunc(ctx, projectID, deploymentIDs)
+	}
+	return m.fallback.DeleteByProject(ctx, projectID, deploymentIDs)
+}
+
+// Reset clears all tracked calls
+func (m *MockStore) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Calls = make([]MockCall, 0)
+}
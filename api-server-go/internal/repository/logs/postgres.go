@@ -0,0 +1,319 @@
+package logs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
+)
+
+// PartitionMonthsAhead is how many months of partitions are kept ahead of
+// the current one
+const PartitionMonthsAhead = 2
+
+// PostgresStore stores logs in the partitioned log_events table created
+// by migrations/0002_log_events.up.sql
+type PostgresStore struct {
+	db *sql.DB
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// NewPostgresStore creates a log store on an existing PostgreSQL pool
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Append stores a batch of log lines in a single statement
+func (s *PostgresStore) Append(ctx context.Context, events []buildlog.LogEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(events))
+	deployments := make([]string, len(events))
+	projects := make([]string, len(events))
+	lines := make([]string, len(events))
+	streams := make([]string, len(events))
+	timestamps := make([]sql.NullTime, len(events))
+	redacted := make([]bool, len(events))
+
+	for i, e := range events {
+		ids[i] = e.EventID
+		if ids[i] == "" {
+			ids[i] = utils.GenerateUUID()
+		}
+		deployments[i] = e.DeploymentID
+		projects[i] = e.ProjectID
+		lines[i] = e.Log
+		streams[i] = string(e.Stream)
+		if streams[i] == "" {
+			streams[i] = string(buildlog.StreamStdout)
+		}
+		redacted[i] = e.Redacted
+		ts := s.receivedAt()
+		if e.Timestamp != nil {
+			ts = *e.Timestamp
+		}
+		timestamps[i] = sql.NullTime{Time: ts, Valid: true}
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO log_events (event_id, deployment_id, project_id, log, stream, redacted, timestamp)
+		SELECT e.id, e.deployment_id, e.project_id, e.log, e.stream, e.redacted, COALESCE(e.ts, clock_timestamp())
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::boolean[], $7::timestamptz[])
+			AS e(id, deployment_id, project_id, log, stream, redacted, ts)
+	`,
+		pq.Array(ids),
+		pq.Array(deployments),
+		pq.Array(projects),
+		pq.Array(lines),
+		pq.Array(streams),
+		pq.Array(redacted),
+		pq.Array(timestamps),
+	)
+	return err
+}
+
+// receivedAt returns the current time, strictly after the previous call at
+// the microsecond precision of timestamptz, so lines keep their order within
+// and across batches
+func (s *PostgresStore) receivedAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	if !now.After(s.last) {
+		now = s.last.Add(time.Microsecond)
+	}
+	s.last = now
+	return now
+}
+
+// Query returns matching lines in ascending order
+func (s *PostgresStore) Query(ctx context.Context, deploymentID string, filter Filter) ([]buildlog.LogEvent, error) {
+	where, args := s.where(deploymentID, filter, true)
+
+	query := `
+		SELECT event_id, deployment_id, project_id, log, stream, redacted, timestamp
+		FROM log_events
+		WHERE ` + where + `
+		ORDER BY timestamp ASC, event_id ASC`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+
+	return s.scan(ctx, query, args...)
+}
+
+// Tail returns the last n matching lines in ascending order
+func (s *PostgresStore) Tail(ctx context.Context, deploymentID string, filter Filter, n int) ([]buildlog.LogEvent, error) {
+	where, args := s.where(deploymentID, filter, false)
+	args = append(args, n)
+
+	query := `
+		SELECT * FROM (
+			SELECT event_id, deployment_id, project_id, log, stream, redacted, timestamp
+			FROM log_events
+			WHERE ` + where + `
+			ORDER BY timestamp DESC, event_id DESC
+			LIMIT $` + strconv.Itoa(len(args)) + `
+		) t
+		ORDER BY timestamp ASC, event_id ASC`
+
+	return s.scan(ctx, query, args...)
+}
+
+// Count returns the number of stored lines for a deployment
+func (s *PostgresStore) Count(ctx context.Context, deploymentID string) (int64, error) {
+	var count int64
+	err := s.db.QueryRowContext(ctx, `SELECT count(*) FROM log_events WHERE deployment_id = $1`, deploymentID).Scan(&count)
+	return count, err
+}
+
+// DeleteByDeployment removes every line of a deployment
+func (s *PostgresStore) DeleteByDeployment(ctx context.Context, deploymentID string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM log_events WHERE deployment_id = $1`, deploymentID)
+	return err
+}
+
+// DeleteByProject removes every line of a project and of deploymentIDs
+func (s *PostgresStore) DeleteByProject(ctx context.Context, projectID string, deploymentIDs []string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM log_events WHERE project_id = $1 OR deployment_id = ANY($2)`,
+		projectID, pq.Array(deploymentIDs))
+	return err
+}
+
+// EnsurePartitions creates the monthly partitions covering the month of now
+// and the following ahead months. Rows outside them land in the default
+// partition; rows already there for a new month are moved into its
+// partition, which Postgres requires before attaching it. Run it
+// periodically with MaintainPartitions.
+func (s *PostgresStore) EnsurePartitions(ctx context.Context, now time.Time, ahead int) error {
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i <= ahead; i++ {
+		from := start.AddDate(0, i, 0)
+		if err := s.ensurePartition(ctx, from, from.AddDate(0, 1, 0)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensurePartition creates and attaches the partition for [from, to) unless
+// it exists
+func (s *PostgresStore) ensurePartition(ctx context.Context, from, to time.Time) error {
+	name := fmt.Sprintf("log_events_%04d_%02d", from.Year(), int(from.Month()))
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check partition %s: %w", name, err)
+	}
+	if exists {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	bound := fmt.Sprintf("FROM ('%s') TO ('%s')", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	stmts := []string{
+		// Serializes API servers creating the same partition
+		`SELECT pg_advisory_xact_lock(hashtext('log_events_partitions'))`,
+		// Keeps new rows out of the default partition while its rows move
+		`LOCK TABLE log_events_default IN ACCESS EXCLUSIVE MODE`,
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (LIKE log_events INCLUDING DEFAULTS)`, name),
+		fmt.Sprintf(`WITH moved AS (
+			DELETE FROM log_events_default
+			WHERE timestamp >= '%s' AND timestamp < '%s'
+			RETURNING *
+		)
+		INSERT INTO %s SELECT * FROM moved`, from.Format(time.RFC3339), to.Format(time.RFC3339), name),
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to prepare partition %s: %w", name, err)
+		}
+	}
+
+	// Another server may have attached it while this one waited for the lock
+	var attached bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM pg_inherits
+			WHERE inhrelid = $1::regclass AND inhparent = 'log_events'::regclass
+		)
+	`, name).Scan(&attached)
+	if err != nil {
+		return fmt.Errorf("failed to check partition %s: %w", name, err)
+	}
+	if !attached {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE log_events ATTACH PARTITION %s FOR VALUES %s`, name, bound)); err != nil {
+			return fmt.Errorf("failed to attach partition %s: %w", name, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// MaintainPartitions calls EnsurePartitions every interval until ctx is
+// cancelled, so partitions keep being created ahead of the current month
+func (s *PostgresStore) MaintainPartitions(ctx context.Context, interval time.Duration, ahead int) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		runCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		if err := s.EnsurePartitions(runCtx, time.Now(), ahead); err != nil {
+			log.Printf("WARN: Failed to create log partitions: %v", err)
+		}
+		cancel()
+	}
+}
+
+// where renders a filter as a WHERE clause with $N placeholders
+func (s *PostgresStore) where(deploymentID string, filter Filter, withCursor bool) (string, []any) {
+	args := []any{deploymentID}
+	clauses := []string{"deployment_id = $1"}
+
+	next := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if withCursor && filter.After != nil {
+		clauses = append(clauses, fmt.Sprintf("(timestamp, event_id) > (%s, %s)",
+			next(filter.After.Timestamp), next(filter.After.EventID)))
+	}
+	if filter.Since != nil {
+		clauses = append(clauses, "timestamp >= "+next(*filter.Since))
+	}
+	if filter.Until != nil {
+		clauses = append(clauses, "timestamp <= "+next(*filter.Until))
+	}
+	if filter.Stream != "" {
+		clauses = append(clauses, "stream = "+next(string(filter.Stream)))
+	}
+	if filter.Search != "" {
+		if filter.Regex {
+			clauses = append(clauses, "log ~ "+next(filter.Search))
+		} else {
+			clauses = append(clauses, "log ILIKE "+next("%"+EscapeLike(filter.Search)+"%"))
+		}
+	}
+
+	return strings.Join(clauses, " AND "), args
+}
+
+func (s *PostgresStore) scan(ctx context.Context, query string, args ...any) ([]buildlog.LogEvent, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]buildlog.LogEvent, 0)
+	for rows.Next() {
+		var e buildlog.LogEvent
+		var ts time.Time
+		if err := rows.Scan(&e.EventID, &e.DeploymentID, &e.ProjectID, &e.Log, &e.Stream, &e.Redacted, &ts); err != nil {
+			return nil, err
+		}
+		ts = ts.UTC()
+		e.Timestamp = &ts
+		out = append(out, e)
+	}
+
+	return out, rows.Err()
+}
+
+// EscapeLike escapes LIKE wildcards so user input is matched literally
+func EscapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
@@ -0,0 +1,132 @@
+package logs_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/logs"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/logs/logstoretest"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
+)
+
+// logEventsMigrations create log_events, in order
+var logEventsMigrations = []string{
+	"0002_log_events.up.sql",
+	"0003_log_events_project_id.up.sql",
+	"0007_log_events_redacted.up.sql",
+}
+
+// TestPostgresStore runs the suite in a new schema of the database at
+// LOGSTORE_TEST_POSTGRES_DSN
+func TestPostgresStore(t *testing.T) {
+	db := openPostgres(t)
+
+	logstoretest.Run(t, func(t *testing.T) logs.LogStore {
+		return logs.NewPostgresStore(db)
+	})
+}
+
+// TestPostgresEnsurePartitions checks that a month whose rows went to the
+// default partition can still get its own partition
+func TestPostgresEnsurePartitions(t *testing.T) {
+	db := openPostgres(t)
+	store := logs.NewPostgresStore(db)
+	ctx := context.Background()
+
+	// Two months from now has no partition until EnsurePartitions reaches it
+	later := time.Now().UTC().AddDate(0, 3, 0)
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO log_events (event_id, deployment_id, log, timestamp)
+		VALUES ('e1', 'd1', 'early line', $1)
+	`, later); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	if err := store.EnsurePartitions(ctx, later, 0); err != nil {
+		t.Fatalf("EnsurePartitions: %v", err)
+	}
+	// Running again is a no-op
+	if err := store.EnsurePartitions(ctx, later, 0); err != nil {
+		t.Fatalf("EnsurePartitions again: %v", err)
+	}
+
+	var inDefault, total int
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM log_events_default`).Scan(&inDefault); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM log_events WHERE deployment_id = 'd1'`).Scan(&total); err != nil {
+		t.Fatal(err)
+	}
+	if inDefault != 0 || total != 1 {
+		t.Fatalf("default partition has %d rows and the table %d, want 0 and 1", inDefault, total)
+	}
+}
+
+// openPostgres creates a schema with log_events and returns a connection
+// that uses it. The schema is dropped after the test.
+func openPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("LOGSTORE_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("LOGSTORE_TEST_POSTGRES_DSN is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := "logstoretest_" + strings.ReplaceAll(utils.GenerateUUID(), "-", "")
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Logf("drop schema: %v", err)
+		}
+	})
+
+	db, err := sql.Open("postgres", withSearchPath(dsn, schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, name := range logEventsMigrations {
+		migration, err := os.ReadFile("../../../migrations/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			t.Fatalf("apply %s: %v", name, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := logs.NewPostgresStore(db).EnsurePartitions(ctx, time.Now(), logs.PartitionMonthsAhead); err != nil {
+		t.Fatalf("EnsurePartitions: %v", err)
+	}
+
+	return db
+}
+
+// withSearchPath adds a search_path to a URL or key=value DSN
+func withSearchPath(dsn, schema string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return fmt.Sprintf("%s search_path=%s", dsn, schema)
+}
@@ -2,32 +2,55 @@ package logs
 
 import (
 	"context"
+	"time"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
 )
 
-// Repository defines the interface for log storage operations
-// This interface is technology-agnostic and can be implemented by any storage backend
-// (ClickHouse, PostgreSQL WAL, ElasticSearch, etc.)
-type Repository interface {
-	QueryContext(ctx context.Context, query string, args ...any) (Rows, error)
-	QueryRowContext(ctx context.Context, query string, args ...any) Row
-	ExecContext(ctx context.Context, query string, args ...any) (Result, error)
-}
+// LogStore defines the storage operations for build logs.
+// Implementations own their query language; callers only deal in typed
+// filters, so ClickHouse, PostgreSQL and in-memory stores are interchangeable.
+//
+// Every implementation must order lines by (timestamp, event_id) and pass the
+// suite in the logstoretest package.
+type LogStore interface {
+	// Append stores a batch of log lines. Lines without an EventID or
+	// Timestamp get one assigned by the store.
+	Append(ctx context.Context, events []buildlog.LogEvent) error
 
-// Rows interface for iterating over query results
-type Rows interface {
-	Close() error
-	Next() bool
-	Scan(dest ...any) error
-	Err() error
-}
+	// Query returns up to filter.Limit lines of a deployment in ascending
+	// order, starting after filter.After when set.
+	Query(ctx context.Context, deploymentID string, filter Filter) ([]buildlog.LogEvent, error)
+
+	// Tail returns the last n lines of a deployment that match the filter,
+	// in ascending order. filter.After and filter.Limit are ignored.
+	Tail(ctx context.Context, deploymentID string, filter Filter, n int) ([]buildlog.LogEvent, error)
+
+	// Count returns the number of stored lines for a deployment
+	Count(ctx context.Context, deploymentID string) (int64, error)
+
+	// DeleteByDeployment removes every line of a deployment
+	DeleteByDeployment(ctx context.Context, deploymentID string) error
 
-// Row interface for single row queries
-type Row interface {
-	Scan(dest ...any) error
+	// DeleteByProject removes every line of a project, and every line of
+	// deploymentIDs. Lines stored before they carried a project ID are only
+	// found through their deployment.
+	DeleteByProject(ctx context.Context, projectID string, deploymentIDs []string) error
 }
 
-// Result interface for execution results
-type Result interface {
-	LastInsertId() (int64, error)
-	RowsAffected() (int64, error)
+// Filter narrows a log query
+type Filter struct {
+	// After returns only lines strictly after this position
+	After *buildlog.Cursor
+	// Limit caps the number of lines returned; zero means no limit
+	Limit int
+	// Since and Until bound the line timestamp (inclusive)
+	Since *time.Time
+	Until *time.Time
+	// Stream restricts results to stdout or stderr
+	Stream buildlog.Stream
+	// Search matches lines containing the substring (case-insensitive),
+	// or matching the RE2 expression when Regex is set
+	Search string
+	Regex  bool
 }
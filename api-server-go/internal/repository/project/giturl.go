@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+
+	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/project"
+)
+
+// ListLegacyGitURLs returns up to limit projects whose git URL may not be in
+// canonical form, ordered by ID and starting after afterID
+func (r *Repository) ListLegacyGitURLs(ctx context.Context, afterID string, limit int) ([]domain.LegacyGitURL, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, git_url
+		FROM projects
+		WHERE NOT git_url_canonical AND id > $1
+		ORDER BY id ASC
+		LIMIT $2
+	`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	urls := make([]domain.LegacyGitURL, 0)
+	for rows.Next() {
+		var u domain.LegacyGitURL
+		if err := rows.Scan(&u.ProjectID, &u.UserID, &u.GitURL); err != nil {
+			return nil, err
+		}
+		urls = append(urls, u)
+	}
+	return urls, rows.Err()
+}
+
+// SetCanonicalGitURL replaces the legacy git URL of a project with its
+// canonical form, unless the URL was changed since it was listed. It
+// reports whether the owner has another project with the same canonical
+// URL; such projects are kept, as creating them is only refused from now
+// on.
+func (r *Repository) SetCanonicalGitURL(ctx context.Context, legacy domain.LegacyGitURL, gitURL string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Takes the lock project creation takes, so a project created meanwhile
+	// is seen by the duplicate check
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, legacy.UserID); err != nil {
+		return false, err
+	}
+	var duplicate bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM projects
+			WHERE user_id = $1 AND id <> $2 AND lower(git_url) = lower($3)
+		)
+	`, legacy.UserID, legacy.ProjectID, gitURL).Scan(&duplicate)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE projects SET git_url = $3, git_url_canonical = true
+		WHERE id = $1 AND git_url = $2 AND NOT git_url_canonical
+	`, legacy.ProjectID, legacy.GitURL, gitURL); err != nil {
+		return false, err
+	}
+	return duplicate, tx.Commit()
+}
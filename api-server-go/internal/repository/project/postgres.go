@@ -4,9 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 
 	deploymentdomain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
+	eventdomain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/event"
 	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/project"
+	eventRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/event"
+	gitCredentialRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/gitcredential"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
 )
 
@@ -18,18 +22,53 @@ func New(db *sql.DB) *Repository {
 	return &Repository{db: db}
 }
 
+// Create inserts a project and its project.created event
 func (r *Repository) Create(ctx context.Context, p *domain.Project) error {
+	return r.CreateWithGitCredential(ctx, p, nil)
+}
+
+// CreateWithGitCredential creates a project together with the credential
+// its builds clone with, in one transaction. The credential's project ID is
+// filled in from p; a nil credential clones anonymously. It returns
+// domain.ErrDuplicateGitURL if the user already has a project with the same
+// git URL, which callers normalize first.
+func (r *Repository) CreateWithGitCredential(ctx context.Context, p *domain.Project, credential *domain.GitCredential) error {
 	// Generate UUID v4 if not provided
 	if p.ID == "" {
 		p.ID = utils.GenerateUUID()
 	}
+	if p.Plan == "" {
+		p.Plan = domain.PlanHobby
+	}
 
 	query := `
-		INSERT INTO projects (id, name, git_url, subdomain, custom_domain, user_id)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO projects (id, name, git_url, subdomain, custom_domain, user_id, plan)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
 
-	_, err := r.db.ExecContext(
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Serializes project creation per user, so two requests cannot both
+	// pass the duplicate check
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, p.UserID); err != nil {
+		return err
+	}
+	var exists bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM projects WHERE user_id = $1 AND lower(git_url) = lower($2))
+	`, p.UserID, p.GitURL).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return domain.ErrDuplicateGitURL
+	}
+
+	_, err = tx.ExecContext(
 		ctx,
 		query,
 		p.ID,
@@ -38,9 +77,32 @@ func (r *Repository) Create(ctx context.Context, p *domain.Project) error {
 		p.SubDomain,
 		p.CustomDomain,
 		p.UserID,
+		p.Plan,
 	)
+	if err != nil {
+		return err
+	}
+
+	if credential != nil {
+		credential.ProjectID = p.ID
+		if err := gitCredentialRepository.Save(ctx, tx, credential); err != nil {
+			return err
+		}
+	}
 
-	return err
+	if err := recordDomainEvent(ctx, tx, eventdomain.ProjectCreated, p.ID, eventdomain.NewProjectData(*p)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// recordDomainEvent writes a project event to the outbox within tx
+func recordDomainEvent(ctx context.Context, tx *sql.Tx, t eventdomain.Type, projectID string, data any) error {
+	e, err := eventdomain.New(t, projectID, data)
+	if err != nil {
+		return err
+	}
+	return eventRepository.Insert(ctx, tx, e)
 }
 
 func (r *Repository) ListByUser(ctx context.Context, userID string) ([]domain.Project, error) {
@@ -62,6 +124,9 @@ func (r *Repository) ListByUser(ctx context.Context, userID string) ([]domain.Pr
 			p.subdomain,
 			p.custom_domain,
 			p.user_id,
+			p.plan,
+			p.build_preset,
+			p.use_spot,
 			p.created_at,
 			p.updated_at,
 			COALESCE(
@@ -79,7 +144,7 @@ func (r *Repository) ListByUser(ctx context.Context, userID string) ([]domain.Pr
 		FROM projects p
 		LEFT JOIN latest_deployments d ON p.id = d.project_id
 		WHERE p.user_id = $1
-		GROUP BY p.id, p.name, p.git_url, p.subdomain, p.custom_domain, p.user_id, p.created_at, p.updated_at
+		GROUP BY p.id, p.name, p.git_url, p.subdomain, p.custom_domain, p.user_id, p.plan, p.build_preset, p.use_spot, p.created_at, p.updated_at
 		ORDER BY p.created_at DESC
 	`
 
@@ -101,6 +166,9 @@ func (r *Repository) ListByUser(ctx context.Context, userID string) ([]domain.Pr
 			&p.SubDomain,
 			&p.CustomDomain,
 			&p.UserID,
+			&p.Plan,
+			&p.BuildPreset,
+			&p.UseSpot,
 			&p.CreatedAt,
 			&p.UpdatedAt,
 			&deploymentsJSON,
@@ -134,6 +202,9 @@ func (r *Repository) GetByIDAndUserID(ctx context.Context, projectID, userID str
 			p.subdomain,
 			p.custom_domain,
 			p.user_id,
+			p.plan,
+			p.build_preset,
+			p.use_spot,
 			p.created_at,
 			p.updated_at,
 			COALESCE(
@@ -152,7 +223,7 @@ func (r *Repository) GetByIDAndUserID(ctx context.Context, projectID, userID str
 		FROM projects p
 		LEFT JOIN deployments d ON p.id = d.project_id
 		WHERE p.id = $1 AND p.user_id = $2
-		GROUP BY p.id, p.name, p.git_url, p.subdomain, p.custom_domain, p.user_id, p.created_at, p.updated_at
+		GROUP BY p.id, p.name, p.git_url, p.subdomain, p.custom_domain, p.user_id, p.plan, p.build_preset, p.use_spot, p.created_at, p.updated_at
 	`
 
 	var p domain.Project
@@ -165,6 +236,9 @@ func (r *Repository) GetByIDAndUserID(ctx context.Context, projectID, userID str
 		&p.SubDomain,
 		&p.CustomDomain,
 		&p.UserID,
+		&p.Plan,
+		&p.BuildPreset,
+		&p.UseSpot,
 		&p.CreatedAt,
 		&p.UpdatedAt,
 		&deploymentsJSON,
@@ -188,7 +262,68 @@ func (r *Repository) GetByIDAndUserID(ctx context.Context, projectID, userID str
 	return p, nil
 }
 
+// Update saves the editable fields of a project and records
+// project.updated with the whole project, so p must be fully loaded
+func (r *Repository) Update(ctx context.Context, p *domain.Project) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRowContext(ctx, `
+		UPDATE projects
+		SET name = $2, build_preset = $3, use_spot = $4, updated_at = now()
+		WHERE id = $1
+		RETURNING updated_at
+	`, p.ID, p.Name, p.BuildPreset, p.UseSpot).Scan(&p.UpdatedAt); err != nil {
+		return err
+	}
+
+	if err := recordDomainEvent(ctx, tx, eventdomain.ProjectUpdated, p.ID, eventdomain.NewProjectData(*p)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete removes a project and records project.deleted. Deleting a project
+// that does not exist is not an error and records nothing.
 func (r *Repository) Delete(ctx context.Context, projectID string) error {
-	_, err := r.db.ExecContext(ctx, `DELETE FROM projects WHERE id = $1`, projectID)
-	return err
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	err = tx.QueryRowContext(ctx, `DELETE FROM projects WHERE id = $1 RETURNING user_id`, projectID).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := recordDomainEvent(ctx, tx, eventdomain.ProjectDeleted, projectID, eventdomain.ProjectDeletedData{
+		ProjectID: projectID,
+		UserID:    userID,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPlan returns the billing plan of a project regardless of owner
+func (r *Repository) GetPlan(ctx context.Context, projectID string) (domain.Plan, error) {
+	var plan domain.Plan
+	err := r.db.QueryRowContext(ctx, `SELECT plan FROM projects WHERE id = $1`, projectID).Scan(&plan)
+	return plan, err
+}
+
+// GetGitURL returns the repository of a project and the user who owns it,
+// without checking who asks
+func (r *Repository) GetGitURL(ctx context.Context, projectID string) (string, string, error) {
+	var gitURL, userID string
+	err := r.db.QueryRowContext(ctx, `SELECT git_url, user_id FROM projects WHERE id = $1`, projectID).Scan(&gitURL, &userID)
+	return gitURL, userID, err
 }
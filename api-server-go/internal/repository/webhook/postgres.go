@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	eventdomain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/event"
+	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/webhook"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Create(ctx context.Context, w *domain.Webhook) error {
+	// Generate UUID v4 if not provided
+	if w.ID == "" {
+		w.ID = utils.GenerateUUID()
+	}
+
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO webhooks (id, project_id, url, secret, events, active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, updated_at
+	`, w.ID, w.ProjectID, w.URL, w.Secret, pq.Array(typeStrings(w.Events)), w.Active).Scan(&w.CreatedAt, &w.UpdatedAt)
+}
+
+// ListByProject returns the webhooks of a project without their secrets
+func (r *Repository) ListByProject(ctx context.Context, projectID string) ([]domain.Webhook, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, project_id, url, events, active, created_at, updated_at
+		FROM webhooks
+		WHERE project_id = $1
+		ORDER BY created_at
+	`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]domain.Webhook, 0)
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+// Get returns a webhook of a project without its secret, or sql.ErrNoRows
+func (r *Repository) Get(ctx context.Context, projectID, id string) (domain.Webhook, error) {
+	return scanWebhook(r.db.QueryRowContext(ctx, `
+		SELECT id, project_id, url, events, active, created_at, updated_at
+		FROM webhooks
+		WHERE id = $1 AND project_id = $2
+	`, id, projectID))
+}
+
+// Update saves the URL, event filter and active flag of a webhook
+func (r *Repository) Update(ctx context.Context, w *domain.Webhook) error {
+	return r.db.QueryRowContext(ctx, `
+		UPDATE webhooks
+		SET url = $3, events = $4, active = $5, updated_at = now()
+		WHERE id = $1 AND project_id = $2
+		RETURNING updated_at
+	`, w.ID, w.ProjectID, w.URL, pq.Array(typeStrings(w.Events)), w.Active).Scan(&w.UpdatedAt)
+}
+
+// Delete removes a webhook and its deliveries. It returns sql.ErrNoRows if
+// the project has no such webhook.
+func (r *Repository) Delete(ctx context.Context, projectID, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1 AND project_id = $2`, id, projectID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWebhook(row rowScanner) (domain.Webhook, error) {
+	var w domain.Webhook
+	var events []string
+	if err := row.Scan(&w.ID, &w.ProjectID, &w.URL, pq.Array(&events), &w.Active, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		return w, err
+	}
+	w.Events = make([]eventdomain.Type, len(events))
+	for i, e := range events {
+		w.Events[i] = eventdomain.Type(e)
+	}
+	return w, nil
+}
+
+func typeStrings(types []eventdomain.Type) []string {
+	s := make([]string, len(types))
+	for i, t := range types {
+		s[i] = string(t)
+	}
+	return s
+}
+
+// deliveryColumns selects a delivery aliased as d
+const deliveryColumns = `d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+	CASE WHEN d.status IN ('pending', 'sending') THEN d.next_attempt_at END,
+	d.response_code, d.error, d.duration_ms, d.redelivery_of, d.delivered_at, d.created_at`
+
+func scanDelivery(row rowScanner, extra ...any) (domain.Delivery, error) {
+	var d domain.Delivery
+	dest := []any{
+		&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.ResponseCode, &d.Error, &d.DurationMs,
+		&d.RedeliveryOf, &d.DeliveredAt, &d.CreatedAt,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	return d, err
+}
+
+// ListDeliveries returns the most recent deliveries of a webhook, newest first
+func (r *Repository) ListDeliveries(ctx context.Context, webhookID string, limit int) ([]domain.Delivery, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d
+		WHERE d.webhook_id = $1
+		ORDER BY d.created_at DESC, d.id
+		LIMIT $2
+	`, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]domain.Delivery, 0)
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetDelivery returns a delivery of a webhook, or sql.ErrNoRows
+func (r *Repository) GetDelivery(ctx context.Context, webhookID, id string) (domain.Delivery, error) {
+	return scanDelivery(r.db.QueryRowContext(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d
+		WHERE d.id = $1 AND d.webhook_id = $2
+	`, id, webhookID))
+}
+
+// Redeliver queues a new delivery of the same payload as original
+func (r *Repository) Redeliver(ctx context.Context, original domain.Delivery) (domain.Delivery, error) {
+	return scanDelivery(r.db.QueryRowContext(ctx, `
+		INSERT INTO webhook_deliveries AS d (id, webhook_id, event_id, event_type, payload, redelivery_of)
+		SELECT $1, o.webhook_id, o.event_id, o.event_type, o.payload, o.id
+		FROM webhook_deliveries o
+		WHERE o.id = $2
+		RETURNING `+deliveryColumns,
+		utils.GenerateUUID(), original.ID))
+}
+
+// ClaimDue locks up to limit deliveries that are due for lease. Deliveries
+// whose lease expired (the worker died) are claimed again.
+func (r *Repository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]domain.Target, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE (status = 'pending' AND next_attempt_at <= now())
+				OR (status = 'sending' AND locked_until < now())
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE webhook_deliveries d
+			SET status = 'sending',
+				locked_until = now() + $2 * interval '1 millisecond',
+				updated_at = now()
+			FROM due
+			WHERE d.id = due.id
+			RETURNING d.*
+		)
+		SELECT `+deliveryColumns+`, w.url, w.secret
+		FROM claimed d
+		JOIN webhooks w ON w.id = d.webhook_id
+	`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var targets []domain.Target
+	for rows.Next() {
+		var t domain.Target
+		d, err := scanDelivery(rows, &t.URL, &t.Secret)
+		if err != nil {
+			return nil, err
+		}
+		t.Delivery = d
+		targets = append(targets, t)
+	}
+	return targets, rows.Err()
+}
+
+// RecordAttempt stores the outcome of sending a delivery. If retryAt is
+// set a failed delivery is tried again then, otherwise it is final.
+func (r *Repository) RecordAttempt(ctx context.Context, id string, a domain.Attempt, retryAt *time.Time) error {
+	status := domain.Succeeded
+	if !a.OK() {
+		status = domain.Failed
+		if retryAt != nil {
+			status = domain.Pending
+		}
+	}
+
+	var responseCode *int
+	if a.ResponseCode != 0 {
+		responseCode = &a.ResponseCode
+	}
+	var errMsg *string
+	if a.Err != nil {
+		msg := a.Err.Error()
+		errMsg = &msg
+	}
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2,
+			attempts = attempts + 1,
+			next_attempt_at = COALESCE($3, next_attempt_at),
+			locked_until = NULL,
+			response_code = $4,
+			error = $5,
+			duration_ms = $6,
+			delivered_at = CASE WHEN $2 = 'succeeded' THEN now() ELSE delivered_at END,
+			updated_at = now()
+		WHERE id = $1
+	`, id, status, retryAt, responseCode, errMsg, a.Duration.Milliseconds())
+	return err
+}
+
+// DeleteDeliveriesBefore removes finished deliveries created before cutoff
+func (r *Repository) DeleteDeliveriesBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM webhook_deliveries
+		WHERE created_at < $1 AND status IN ('succeeded', 'failed')
+	`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
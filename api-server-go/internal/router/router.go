@@ -10,12 +10,22 @@ import (
 	"github.com/go-chi/cors"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/auth"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/config"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/handler/apitoken"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/handler/build"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/handler/deployment"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/handler/github"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/handler/health"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/handler/project"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/handler/webhook"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/kafka/consumer"
+	apiTokenRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/apitoken"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/apitokens"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/gitcredentials"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/giturl"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/logs"
 )
 
-func New(db *sql.DB) *chi.Mux {
+func New(db *sql.DB, logsService *logs.Service, processor *consumer.Processor, gitCredentials *gitcredentials.Service, gitURLs *giturl.Validator) *chi.Mux {
 	r := chi.NewRouter()
 
 	config.InitSupabase()
@@ -24,6 +34,8 @@ func New(db *sql.DB) *chi.Mux {
 		config.SupabaseURL+"/auth/v1/.well-known/jwks.json",
 		5*time.Minute,
 	)
+	// Personal access tokens are accepted wherever a session is
+	tokens := apitokens.New(apiTokenRepository.New(db))
 
 	// Middleware
 	r.Use(middleware.Logger)
@@ -44,8 +56,14 @@ func New(db *sql.DB) *chi.Mux {
 	r.Mount("/health", health.Routes())
 
 	// Protected routes (auth required)
-	r.Mount("/projects", project.Routes(db, jwks))
-	r.Mount("/", deployment.Routes(db, jwks))
+	r.Mount("/projects", project.Routes(db, jwks, tokens, logsService, gitCredentials, gitURLs))
+	r.Mount("/projects/{projectId}/webhooks", webhook.Routes(db, jwks, tokens, gitURLs))
+	r.Mount("/tokens", apitoken.Routes(db, jwks, tokens))
+	r.Mount("/github", github.Routes(jwks, tokens, gitCredentials))
+	r.Mount("/", deployment.Routes(db, jwks, tokens, logsService, gitURLs))
+
+	// Build container routes (build token required)
+	r.Mount("/internal", build.Routes(db, processor, gitCredentials))
 
 	return r
 }
@@ -0,0 +1,114 @@
+// Package apitokens issues and checks personal access tokens, the API keys
+// users create for scripts and CI. Only a hash of each token is stored.
+package apitokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/apitoken"
+)
+
+// TokenPrefix makes personal access tokens recognizable in logs and secret
+// scanners, and tells them apart from JWTs
+const TokenPrefix = "mvp_"
+
+// displayPrefixLength is how much of a token is kept to recognize it
+const displayPrefixLength = len(TokenPrefix) + 8
+
+// ErrInvalidToken is returned for unknown or expired tokens
+var ErrInvalidToken = errors.New("invalid API token")
+
+// Store keeps tokens by the hash of the token. It is implemented by the
+// apitoken repository.
+type Store interface {
+	Create(ctx context.Context, t *domain.Token) error
+	// GetByHash returns sql.ErrNoRows if no token has the hash
+	GetByHash(ctx context.Context, tokenHash string) (domain.Token, error)
+	ListByUser(ctx context.Context, userID string) ([]domain.Token, error)
+	TouchLastUsed(ctx context.Context, id string) error
+	Delete(ctx context.Context, userID, id string) error
+}
+
+// Service issues and checks personal access tokens
+type Service struct {
+	repo Store
+}
+
+func New(repo Store) *Service {
+	return &Service{repo: repo}
+}
+
+// Create issues a token and stores its hash in t, which has the user, name,
+// scopes and restrictions set. The token is returned only here.
+func (s *Service) Create(ctx context.Context, t *domain.Token) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	t.TokenHash = hashToken(token)
+	t.Prefix = token[:displayPrefixLength]
+	if err := s.repo.Create(ctx, t); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Authenticate returns the stored token for a presented one and records
+// that it was used
+func (s *Service) Authenticate(ctx context.Context, token string) (domain.Token, error) {
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return domain.Token{}, ErrInvalidToken
+	}
+
+	// The lookup is by hash, so the token is never compared as a string
+	t, err := s.repo.GetByHash(ctx, hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Token{}, ErrInvalidToken
+	}
+	if err != nil {
+		return domain.Token{}, err
+	}
+	if t.ExpiresAt != nil && !time.Now().Before(*t.ExpiresAt) {
+		return domain.Token{}, ErrInvalidToken
+	}
+
+	if err := s.repo.TouchLastUsed(ctx, t.ID); err != nil {
+		// Not worth failing the request over
+		log.Printf("Warning: failed to record use of API token %s: %v", t.ID, err)
+	}
+	return t, nil
+}
+
+// List returns the tokens of a user, without the tokens themselves
+func (s *Service) List(ctx context.Context, userID string) ([]domain.Token, error) {
+	return s.repo.ListByUser(ctx, userID)
+}
+
+// Revoke deletes a token of a user; it returns sql.ErrNoRows if there is none
+func (s *Service) Revoke(ctx context.Context, userID, id string) error {
+	return s.repo.Delete(ctx, userID, id)
+}
+
+// newToken returns a random token with 256 bits of entropy
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return TokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,220 @@
+package apitokens
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/apitoken"
+)
+
+// fakeStore keeps tokens in memory, like the apitoken repository
+type fakeStore struct {
+	mu       sync.Mutex
+	tokens   map[string]domain.Token
+	touched  []string
+	getErr   error
+	touchErr error
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{tokens: make(map[string]domain.Token)}
+}
+
+func (s *fakeStore) Create(ctx context.Context, t *domain.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.ID == "" {
+		t.ID = "tok-" + t.Name
+	}
+	t.CreatedAt = time.Now()
+	s.tokens[t.TokenHash] = *t
+	return nil
+}
+
+func (s *fakeStore) GetByHash(ctx context.Context, tokenHash string) (domain.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.getErr != nil {
+		return domain.Token{}, s.getErr
+	}
+	t, ok := s.tokens[tokenHash]
+	if !ok {
+		return domain.Token{}, sql.ErrNoRows
+	}
+	return t, nil
+}
+
+func (s *fakeStore) ListByUser(ctx context.Context, userID string) ([]domain.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens := make([]domain.Token, 0)
+	for _, t := range s.tokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens, nil
+}
+
+func (s *fakeStore) TouchLastUsed(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.touched = append(s.touched, id)
+	return s.touchErr
+}
+
+func (s *fakeStore) Delete(ctx context.Context, userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, t := range s.tokens {
+		if t.ID == id && t.UserID == userID {
+			delete(s.tokens, hash)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func TestCreateStoresOnlyTheHash(t *testing.T) {
+	store := newFakeStore()
+	s := New(store)
+
+	token := &domain.Token{UserID: "user-1", Name: "ci", Scopes: []domain.Scope{domain.DeploymentsWrite}}
+	secret, err := s.Create(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if !strings.HasPrefix(secret, TokenPrefix) {
+		t.Fatalf("token %q lacks the %s prefix", secret, TokenPrefix)
+	}
+	if token.Prefix != secret[:displayPrefixLength] {
+		t.Fatalf("prefix = %q, want %q", token.Prefix, secret[:displayPrefixLength])
+	}
+	if len(store.tokens) != 1 {
+		t.Fatalf("stored %d tokens, want 1", len(store.tokens))
+	}
+	for hash := range store.tokens {
+		if hash != hashToken(secret) || hash == secret {
+			t.Fatalf("stored %q, want the SHA-256 of the token", hash)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	store := newFakeStore()
+	s := New(store)
+	ctx := context.Background()
+
+	project := "project-1"
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Second)
+
+	create := func(name string, expiresAt *time.Time) string {
+		secret, err := s.Create(ctx, &domain.Token{UserID: "user-1", Name: name, ProjectID: &project, ExpiresAt: expiresAt})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		return secret
+	}
+	valid := create("valid", nil)
+	notExpired := create("not-expired", &future)
+	expired := create("expired", &past)
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"valid", valid, true},
+		{"not yet expired", notExpired, true},
+		{"expired", expired, false},
+		{"unknown", TokenPrefix + "unknown", false},
+		{"altered", valid[:len(valid)-1] + "x", false},
+		{"without prefix", strings.TrimPrefix(valid, TokenPrefix), false},
+		{"JWT", "eyJhbGciOiJSUzI1NiJ9.eyJzdWIiOiJ1c2VyLTEifQ.c2ln", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.touched = nil
+
+			got, err := s.Authenticate(ctx, tt.token)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Authenticate = %v, want ErrInvalidToken", err)
+				}
+				if len(store.touched) != 0 {
+					t.Fatal("a rejected token was recorded as used")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if got.UserID != "user-1" || got.ProjectID == nil || *got.ProjectID != project {
+				t.Fatalf("token = %+v, want user-1 restricted to %s", got, project)
+			}
+			if len(store.touched) != 1 || store.touched[0] != got.ID {
+				t.Fatalf("touched %v, want [%s]", store.touched, got.ID)
+			}
+		})
+	}
+}
+
+func TestAuthenticateStoreErrors(t *testing.T) {
+	store := newFakeStore()
+	s := New(store)
+	ctx := context.Background()
+
+	secret, err := s.Create(ctx, &domain.Token{UserID: "user-1", Name: "ci"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Failing to record the use does not fail the request
+	store.touchErr = errors.New("connection reset")
+	if _, err := s.Authenticate(ctx, secret); err != nil {
+		t.Fatalf("Authenticate with a failing touch: %v", err)
+	}
+
+	// A failed lookup is not reported as an invalid token
+	lookupErr := errors.New("connection refused")
+	store.getErr = lookupErr
+	if _, err := s.Authenticate(ctx, secret); !errors.Is(err, lookupErr) {
+		t.Fatalf("Authenticate = %v, want the lookup error", err)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	s := New(newFakeStore())
+	ctx := context.Background()
+
+	token := &domain.Token{UserID: "user-1", Name: "ci"}
+	secret, err := s.Create(ctx, token)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if err := s.Revoke(ctx, "user-2", token.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Revoke by another user = %v, want sql.ErrNoRows", err)
+	}
+	if err := s.Revoke(ctx, "user-1", token.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := s.Authenticate(ctx, secret); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Authenticate after revoke = %v, want ErrInvalidToken", err)
+	}
+}
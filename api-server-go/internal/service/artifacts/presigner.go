@@ -0,0 +1,131 @@
+// Package artifacts hands out presigned URLs for uploading build output to
+// the artifact bucket, so build containers never hold bucket credentials.
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ErrInvalidPath is returned for upload paths that would leave the prefix
+var ErrInvalidPath = errors.New("invalid artifact path")
+
+// Upload is a presigned request for one file
+type Upload struct {
+	Path   string `json:"path"`
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Headers are sent with the upload; signed ones must match exactly
+	Headers map[string]string `json:"headers"`
+}
+
+// Presigner signs uploads to an S3-compatible bucket
+type Presigner struct {
+	client *s3.PresignClient
+	bucket string
+}
+
+// Config locates the bucket. Endpoint is derived from AccountID for R2 when
+// empty.
+type Config struct {
+	AccountID       string
+	AccessKeyID     string
+	SecretAccessKey string
+	Bucket          string
+	Endpoint        string
+}
+
+// NewPresigner creates a presigner, or returns nil if the bucket is not
+// configured
+func NewPresigner(cfg Config) *Presigner {
+	if cfg.Bucket == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		if cfg.AccountID == "" {
+			return nil
+		}
+		endpoint = fmt.Sprintf("https://%s.r2.cloudflarestorage.com", cfg.AccountID)
+	}
+
+	client := s3.New(s3.Options{
+		Region:       "auto",
+		BaseEndpoint: aws.String(endpoint),
+		Credentials:  credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		UsePathStyle: true,
+	})
+
+	return &Presigner{client: s3.NewPresignClient(client), bucket: cfg.Bucket}
+}
+
+// PresignUpload signs a PUT of file under prefix, valid for ttl
+func (p *Presigner) PresignUpload(ctx context.Context, prefix, file, contentType string, ttl time.Duration) (Upload, error) {
+	clean, err := CleanPath(file)
+	if err != nil {
+		return Upload{}, err
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(clean))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	req, err := p.client.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(p.bucket),
+		Key:         aws.String(strings.TrimSuffix(prefix, "/") + "/" + clean),
+		ContentType: aws.String(contentType),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return Upload{}, err
+	}
+
+	// The object is served with the Content-Type it was uploaded with
+	headers := map[string]string{"Content-Type": contentType}
+	for name, values := range req.SignedHeader {
+		// Host is set by the HTTP client from the URL
+		if !strings.EqualFold(name, "Host") && len(values) > 0 {
+			headers[http.CanonicalHeaderKey(name)] = values[0]
+		}
+	}
+
+	return Upload{Path: clean, Method: req.Method, URL: req.URL, Headers: headers}, nil
+}
+
+// Prefix is where the output of a deployment is stored:
+// <projectID>/<deploymentID>. The reverse proxy serves a project's latest
+// ready deployment from it.
+func Prefix(projectID, deploymentID string) string {
+	return projectID + "/" + deploymentID
+}
+
+// CleanPath normalizes a relative artifact path and rejects paths that are
+// absolute or escape their prefix
+func CleanPath(file string) (string, error) {
+	if file == "" || strings.ContainsAny(file, "\\\x00") || strings.HasPrefix(file, "/") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPath, file)
+	}
+	for _, part := range strings.Split(file, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("%w: %q", ErrInvalidPath, file)
+		}
+	}
+
+	clean := path.Clean(file)
+	if clean == "." {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPath, file)
+	}
+	return clean, nil
+}
@@ -0,0 +1,63 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPresignUploadUsesDeploymentPrefix(t *testing.T) {
+	p := NewPresigner(Config{
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+		Bucket:          "outputs",
+		Endpoint:        "https://storage.example.com",
+	})
+
+	upload, err := p.PresignUpload(context.Background(), Prefix("proj-1", "dep-1"), "assets/app.js", "", time.Minute)
+	if err != nil {
+		t.Fatalf("PresignUpload: %v", err)
+	}
+	u, err := url.Parse(upload.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/outputs/proj-1/dep-1/assets/app.js" {
+		t.Fatalf("upload path = %q, want /outputs/proj-1/dep-1/assets/app.js", u.Path)
+	}
+	if upload.Headers["Content-Type"] != "text/javascript; charset=utf-8" {
+		t.Fatalf("Content-Type = %q", upload.Headers["Content-Type"])
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"index.html", "index.html"},
+		{"assets//app.js", "assets/app.js"},
+		{"./a/./b.css", "a/b.css"},
+		{"", ""},
+		{"/etc/passwd", ""},
+		{"../dep-2/index.html", ""},
+		{"a/../../index.html", ""},
+		{`a\b`, ""},
+		{".", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := CleanPath(tt.file)
+		if tt.want == "" {
+			if !errors.Is(err, ErrInvalidPath) {
+				t.Errorf("CleanPath(%q) = %q, %v, want ErrInvalidPath", tt.file, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("CleanPath(%q) = %q, %v, want %q", tt.file, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Package buildauth issues the per-deployment tokens build containers use
+// instead of platform secrets. A token only works for its own deployment and
+// stops working when the token expires or the deployment reaches a terminal
+// status, whichever comes first.
+package buildauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	repository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/deployment"
+)
+
+// tokenPrefix makes build tokens recognizable in logs and secret scanners
+const tokenPrefix = "mvb_"
+
+var (
+	// ErrInvalidToken is returned for unknown, mismatched or expired tokens
+	ErrInvalidToken = errors.New("invalid build token")
+	// ErrBuildFinished is returned once the deployment reached a terminal
+	// status; its credentials are no longer honored
+	ErrBuildFinished = errors.New("deployment build has finished")
+)
+
+// Grant is what a valid build token allows access to
+type Grant struct {
+	DeploymentID string
+	ProjectID    string
+	// ExpiresAt bounds anything issued on the strength of the token
+	ExpiresAt time.Time
+}
+
+// Service issues and checks build tokens
+type Service struct {
+	repo *repository.Repository
+	ttl  time.Duration
+}
+
+// New creates a service issuing tokens valid for ttl
+func New(repo *repository.Repository, ttl time.Duration) *Service {
+	if ttl <= 0 {
+		ttl = 2 * time.Hour
+	}
+	return &Service{repo: repo, ttl: ttl}
+}
+
+// Issue creates a build token for a deployment. Tokens issued earlier stay
+// valid, since a retried dispatch may keep the build an earlier attempt
+// started, and all of them expire with the newest one. Only its hash is
+// stored, so the token must be handed to the build now.
+func (s *Service) Issue(ctx context.Context, deploymentID string) (string, time.Time, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(s.ttl)
+	if err := s.repo.SetBuildToken(ctx, deploymentID, hashToken(token), expiresAt); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// Authenticate checks a token presented for a deployment
+func (s *Service) Authenticate(ctx context.Context, deploymentID, token string) (Grant, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return Grant{}, ErrInvalidToken
+	}
+
+	c, err := s.repo.GetBuildCredential(ctx, deploymentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Grant{}, ErrInvalidToken
+	}
+	if err != nil {
+		return Grant{}, err
+	}
+
+	if c.TokenHash == nil || c.ExpiresAt == nil {
+		return Grant{}, ErrInvalidToken
+	}
+	if !matchesAny(hashToken(token), *c.TokenHash, c.PreviousTokenHashes) {
+		return Grant{}, ErrInvalidToken
+	}
+	if !time.Now().Before(*c.ExpiresAt) {
+		return Grant{}, ErrInvalidToken
+	}
+	if c.Status.Terminal() {
+		return Grant{}, ErrBuildFinished
+	}
+
+	return Grant{
+		DeploymentID: c.DeploymentID,
+		ProjectID:    c.ProjectID,
+		ExpiresAt:    *c.ExpiresAt,
+	}, nil
+}
+
+// newToken returns a random token with 256 bits of entropy
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// matchesAny reports whether hash equals current or one of previous, in
+// constant time for each
+func matchesAny(hash, current string, previous []string) bool {
+	match := subtle.ConstantTimeCompare([]byte(current), []byte(hash))
+	for _, p := range previous {
+		match |= subtle.ConstantTimeCompare([]byte(p), []byte(hash))
+	}
+	return match == 1
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,41 @@
+package buildauth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchesAny(t *testing.T) {
+	current, first, second := hashToken("mvb_3"), hashToken("mvb_1"), hashToken("mvb_2")
+
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{"mvb_3", true},
+		// Tokens of earlier dispatch attempts, whose builds may still run
+		{"mvb_1", true},
+		{"mvb_2", true},
+		{"mvb_4", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := matchesAny(hashToken(tt.token), current, []string{first, second}); got != tt.want {
+			t.Errorf("matchesAny(%q) = %v, want %v", tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	a, err := newToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := newToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(a, tokenPrefix) || a == b {
+		t.Fatalf("tokens %q and %q, want distinct %s tokens", a, b, tokenPrefix)
+	}
+}
@@ -2,7 +2,9 @@ package deployment
 
 import (
 	"context"
+	"errors"
 
+	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
 	repository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/deployment"
 )
 
@@ -16,14 +18,58 @@ func NewDeploymentService(repo *repository.Repository) *DeploymentService {
 	}
 }
 
+// Transition moves a deployment to a new status, enforcing the deployment
+// state machine. It returns domain.ErrInvalidTransition when the move is not
+// allowed from the current status.
+func (s *DeploymentService) Transition(ctx context.Context, deploymentID string, to domain.Status, reason string) error {
+	return s.repo.Transition(ctx, deploymentID, domain.SourcesOf(to), to, reason)
+}
+
 func (s *DeploymentService) MarkInProgress(ctx context.Context, deploymentID string) error {
-	return s.repo.UpdateStatus(ctx, deploymentID, "IN_PROGRESS")
+	return s.Transition(ctx, deploymentID, domain.InProgress, "build started")
+}
+
+// Finish moves a deployment to READY or FAIL. A deployment whose build
+// finished before it was recorded as started, e.g. because the builder's
+// start line was lost or it never started, passes through IN_PROGRESS, so
+// its events and timings show every phase.
+func (s *DeploymentService) Finish(ctx context.Context, deploymentID string, to domain.Status, reason string) error {
+	err := s.Transition(ctx, deploymentID, to, reason)
+	if !errors.Is(err, domain.ErrInvalidTransition) {
+		return err
+	}
+
+	// Not QUEUED anymore if this is refused: the build was started
+	// meanwhile, or it already finished, which the last transition reports
+	err = s.Transition(ctx, deploymentID, domain.InProgress, "start not reported")
+	if err != nil && !errors.Is(err, domain.ErrInvalidTransition) {
+		return err
+	}
+	return s.Transition(ctx, deploymentID, to, reason)
 }
 
 func (s *DeploymentService) MarkReady(ctx context.Context, deploymentID string) error {
-	return s.repo.UpdateStatus(ctx, deploymentID, "READY")
+	return s.Finish(ctx, deploymentID, domain.Ready, "build completed")
 }
 
 func (s *DeploymentService) MarkFailed(ctx context.Context, deploymentID string) error {
-	return s.repo.UpdateStatus(ctx, deploymentID, "FAIL")
+	return s.Finish(ctx, deploymentID, domain.Fail, "build failed")
+}
+
+func (s *DeploymentService) MarkCanceled(ctx context.Context, deploymentID string, reason string) error {
+	return s.Transition(ctx, deploymentID, domain.Canceled, reason)
+}
+
+func (s *DeploymentService) MarkTimedOut(ctx context.Context, deploymentID string, reason string) error {
+	return s.Transition(ctx, deploymentID, domain.TimedOut, reason)
+}
+
+// RecordFailure stores why a build failed
+func (s *DeploymentService) RecordFailure(ctx context.Context, deploymentID string, reason domain.FailureReason, excerpt []string) error {
+	return s.repo.SetFailure(ctx, deploymentID, reason, excerpt)
+}
+
+// RecordBuild stores where the build of a deployment is running
+func (s *DeploymentService) RecordBuild(ctx context.Context, deploymentID string, executor string, buildID string) error {
+	return s.repo.SetBuild(ctx, deploymentID, executor, buildID)
 }
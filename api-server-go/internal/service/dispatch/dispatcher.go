@@ -0,0 +1,260 @@
+// Package dispatch starts builds for deployments by draining the build_jobs
+// outbox table.
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	deploymentdomain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/job"
+	jobRepository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/job"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/buildauth"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/deployment"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/executor"
+)
+
+// Config controls polling and retries
+type Config struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// MaxAttempts is how many times a build is tried before the deployment fails
+	MaxAttempts int
+	// RetryBackoff is the delay before the second attempt; it doubles each time
+	RetryBackoff time.Duration
+	// Lease is how long a claimed job is owned before another worker may take it
+	Lease time.Duration
+
+	// Limits caps concurrent builds; builds over the limits stay QUEUED
+	Limits job.Limits
+	// SkipSuperseded cancels queued builds that a newer deployment of the
+	// same project replaces
+	SkipSuperseded bool
+	// StaleAfter releases the slot of a build whose deployment has not
+	// changed for this long
+	StaleAfter time.Duration
+
+	// APIURL is where build containers exchange their build token for
+	// upload URLs and log credentials
+	APIURL string
+}
+
+// Dispatcher schedules build jobs and starts them on the build executor
+type Dispatcher struct {
+	jobs          *jobRepository.Repository
+	deploymentSvc *deployment.DeploymentService
+	executor      executor.BuildExecutor
+	tokens        *buildauth.Service
+	cfg           Config
+}
+
+// New creates a dispatcher. buildExecutor may be nil, in which case every
+// attempt fails until the deployment is marked FAIL. tokens issues the
+// credential each build runs with.
+func New(jobs *jobRepository.Repository, deploymentSvc *deployment.DeploymentService, buildExecutor executor.BuildExecutor, tokens *buildauth.Service, cfg Config) *Dispatcher {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 10
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 5 * time.Second
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 2 * time.Minute
+	}
+
+	return &Dispatcher{
+		jobs:          jobs,
+		deploymentSvc: deploymentSvc,
+		executor:      buildExecutor,
+		tokens:        tokens,
+		cfg:           cfg,
+	}
+}
+
+// Run polls for jobs until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep draining while full batches come back
+		for {
+			if n := d.poll(ctx); n < d.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll claims and processes one batch and returns its size
+func (d *Dispatcher) poll(ctx context.Context) int {
+	if ctx.Err() != nil {
+		return 0
+	}
+
+	jobs, err := d.jobs.Claim(ctx, d.cfg.BatchSize, d.cfg.Lease, jobRepository.ClaimPolicy{
+		Limits:         d.cfg.Limits,
+		SkipSuperseded: d.cfg.SkipSuperseded,
+		StaleAfter:     d.cfg.StaleAfter,
+	})
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			log.Printf("ERROR: failed to claim build jobs: %v", err)
+		}
+		return 0
+	}
+
+	for _, j := range jobs {
+		d.process(ctx, j)
+	}
+
+	return len(jobs)
+}
+
+func (d *Dispatcher) process(ctx context.Context, j job.Job) {
+	// Job bookkeeping must finish even if shutdown starts mid-attempt
+	bookkeeping := context.WithoutCancel(ctx)
+
+	if j.SupersededBy != "" {
+		d.skip(bookkeeping, j)
+		return
+	}
+
+	err := d.execute(ctx, j)
+	if err == nil {
+		if err := d.jobs.Complete(bookkeeping, j.ID); err != nil {
+			log.Printf("ERROR: failed to complete build job %d: %v", j.ID, err)
+		}
+		return
+	}
+
+	if j.Attempts < d.cfg.MaxAttempts {
+		delay := d.cfg.RetryBackoff << (j.Attempts - 1)
+		log.Printf("WARN: build job %d for deployment %s failed (attempt %d/%d), retrying in %s: %v",
+			j.ID, j.DeploymentID, j.Attempts, d.cfg.MaxAttempts, delay, err)
+		if err := d.jobs.Retry(bookkeeping, j.ID, err.Error(), delay); err != nil {
+			log.Printf("ERROR: failed to reschedule build job %d: %v", j.ID, err)
+		}
+		return
+	}
+
+	log.Printf("ERROR: build job %d for deployment %s failed after %d attempts: %v",
+		j.ID, j.DeploymentID, j.Attempts, err)
+
+	if err := d.jobs.Fail(bookkeeping, j.ID, err.Error()); err != nil {
+		log.Printf("ERROR: failed to mark build job %d failed: %v", j.ID, err)
+	}
+
+	reason := fmt.Sprintf("build could not be started after %d attempts: %v", j.Attempts, err)
+	if err := d.deploymentSvc.Finish(bookkeeping, j.DeploymentID, deploymentdomain.Fail, reason); err != nil {
+		log.Printf("ERROR: failed to mark deployment %s failed: %v", j.DeploymentID, err)
+		return
+	}
+	if err := d.deploymentSvc.RecordFailure(bookkeeping, j.DeploymentID, deploymentdomain.FailureDispatch, []string{reason}); err != nil {
+		log.Printf("ERROR: failed to record failure reason for deployment %s: %v", j.DeploymentID, err)
+	}
+}
+
+// skip cancels a queued deployment that a newer one replaces
+func (d *Dispatcher) skip(ctx context.Context, j job.Job) {
+	reason := fmt.Sprintf("superseded by deployment %s", j.SupersededBy)
+	err := d.deploymentSvc.MarkCanceled(ctx, j.DeploymentID, reason)
+	if err != nil && !errors.Is(err, deploymentdomain.ErrInvalidTransition) {
+		log.Printf("ERROR: failed to cancel superseded deployment %s: %v", j.DeploymentID, err)
+		if err := d.jobs.Retry(ctx, j.ID, err.Error(), d.cfg.RetryBackoff); err != nil {
+			log.Printf("ERROR: failed to reschedule build job %d: %v", j.ID, err)
+		}
+		return
+	}
+
+	log.Printf("Skipped build of deployment %s: %s", j.DeploymentID, reason)
+	if err := d.jobs.Complete(ctx, j.ID); err != nil {
+		log.Printf("ERROR: failed to complete build job %d: %v", j.ID, err)
+	}
+}
+
+// execute performs one attempt of a job
+func (d *Dispatcher) execute(ctx context.Context, j job.Job) error {
+	switch j.Kind {
+	case job.KindDispatchBuild:
+		var payload job.BuildPayload
+		if err := json.Unmarshal(j.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid payload: %w", err)
+		}
+		return d.startBuild(ctx, payload)
+
+	default:
+		return fmt.Errorf("unknown job kind %q", j.Kind)
+	}
+}
+
+func (d *Dispatcher) startBuild(ctx context.Context, payload job.BuildPayload) error {
+	if d.executor == nil {
+		return errors.New("build executor is not configured")
+	}
+
+	// Each attempt gets a new token. Earlier attempts' tokens stay valid, as
+	// the executor keeps a build of an earlier attempt that is running.
+	token, _, err := d.tokens.Issue(ctx, payload.DeploymentID)
+	if err != nil {
+		return fmt.Errorf("failed to issue build token: %w", err)
+	}
+
+	spec := executor.BuildSpec{
+		DeploymentID: payload.DeploymentID,
+		ProjectID:    payload.ProjectID,
+		Env:          buildEnv(payload, token, d.cfg.APIURL),
+		Plan:         payload.Plan,
+		Preset:       payload.Preset,
+		Spot:         payload.Spot,
+	}
+	if payload.CPU > 0 && payload.MemoryMB > 0 {
+		spec.Resources = &executor.Resources{CPU: payload.CPU, MemoryMB: payload.MemoryMB}
+	}
+
+	buildID, err := d.executor.Start(ctx, spec)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Started build for deployment %s on %s: %s", payload.DeploymentID, d.executor.Name(), buildID)
+
+	// The build is running; failing to record its ID must not start it again
+	if err := d.deploymentSvc.RecordBuild(context.WithoutCancel(ctx), payload.DeploymentID, d.executor.Name(), buildID); err != nil {
+		log.Printf("ERROR: failed to record build %s for deployment %s: %v", buildID, payload.DeploymentID, err)
+	}
+	return nil
+}
+
+// buildEnv is the environment of the build container. It carries no
+// platform secrets: the build token is exchanged with the API for upload
+// URLs and a log credential, and stops working when the deployment finishes.
+func buildEnv(payload job.BuildPayload, token string, apiURL string) []executor.EnvVar {
+	env := []executor.EnvVar{
+		{Name: "PROJECT_ID", Value: payload.ProjectID},
+		{Name: "GIT_REPOSITORY_URL", Value: payload.GitURL},
+		{Name: "DEPLOYMENT_ID", Value: payload.DeploymentID},
+		{Name: "API_URL", Value: apiURL},
+		{Name: "BUILD_TOKEN", Value: token},
+	}
+	if payload.CommitSHA != "" {
+		env = append(env, executor.EnvVar{Name: "GIT_COMMIT_SHA", Value: payload.CommitSHA})
+	}
+	return env
+}
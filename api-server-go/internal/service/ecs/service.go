@@ -2,12 +2,29 @@ package ecs
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 )
 
+// Tags set on every build task. ECS task state change events are matched
+// back to deployments through them.
+const (
+	TagDeploymentID = "mini-vercel:deployment-id"
+	TagProjectID    = "mini-vercel:project-id"
+
+	// TagPlan and TagBuildPreset let cost allocation reports group builds
+	TagPlan        = "mini-vercel:plan"
+	TagBuildPreset = "mini-vercel:build-preset"
+)
+
 type EnvVar struct {
 	Name  string
 	Value string
@@ -23,6 +40,10 @@ type Service struct {
 	launchType     types.LaunchType
 	count          int32
 	imageName      string
+
+	spotStrategy     []types.CapacityProviderStrategyItem
+	onDemandProvider string
+	tags             map[string]string
 }
 
 func New(cfg aws.Config, cluster, taskDef string, subnets []string, securityGrp, assignPublicIP, launchType, imageName string, count int) *Service {
@@ -51,23 +72,159 @@ func New(cfg aws.Config, cluster, taskDef string, subnets []string, securityGrp,
 	}
 }
 
-// RunTask triggers an ECS task with the provided environment variables
-func (s *Service) RunTask(ctx context.Context, envVars []EnvVar) (*string, error) {
+// CapacityProvider is one entry of a capacity provider strategy
+type CapacityProvider struct {
+	Name   string
+	Weight int32
+	Base   int32
+}
+
+// ParseCapacityProviderStrategy parses "name:weight[:base]" entries separated
+// by commas, e.g. "FARGATE_SPOT:4,FARGATE:1:1"
+func ParseCapacityProviderStrategy(s string) ([]CapacityProvider, error) {
+	var strategy []CapacityProvider
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid capacity provider %q: want name:weight[:base]", entry)
+		}
+
+		p := CapacityProvider{Name: parts[0], Weight: 1}
+		for i, field := range []*int32{&p.Weight, &p.Base} {
+			if len(parts) <= i+1 {
+				break
+			}
+			n, err := strconv.ParseInt(parts[i+1], 10, 32)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid capacity provider %q: want name:weight[:base]", entry)
+			}
+			*field = int32(n)
+		}
+		strategy = append(strategy, p)
+	}
+	return strategy, nil
+}
+
+// SetCapacityProviders configures Spot builds. spot is the strategy Spot
+// tasks run with; when it has no capacity they run on onDemand, or with the
+// launch type if onDemand is empty. Without a spot strategy every task uses
+// the launch type.
+func (s *Service) SetCapacityProviders(spot []CapacityProvider, onDemand string) {
+	s.spotStrategy = nil
+	for _, p := range spot {
+		s.spotStrategy = append(s.spotStrategy, types.CapacityProviderStrategyItem{
+			CapacityProvider: aws.String(p.Name),
+			Weight:           p.Weight,
+			Base:             p.Base,
+		})
+	}
+	s.onDemandProvider = onDemand
+}
+
+// SetTags sets tags added to every task, e.g. for cost allocation
+func (s *Service) SetTags(tags map[string]string) {
+	s.tags = tags
+}
+
+// TaskOptions describes one task to run
+type TaskOptions struct {
+	Env  []EnvVar
+	Tags map[string]string
+	// CPU (in CPU units) and MemoryMB override the task definition when set
+	CPU      int
+	MemoryMB int
+	// Spot runs the task with the Spot capacity provider strategy, falling
+	// back to on-demand capacity when Spot has none
+	Spot bool
+}
+
+// RunTask triggers an ECS task and returns its ARN
+func (s *Service) RunTask(ctx context.Context, opts TaskOptions) (*string, error) {
+	input := s.runTaskInput(opts)
+
+	if !opts.Spot || len(s.spotStrategy) == 0 {
+		input.LaunchType = s.launchType
+		return s.runTask(ctx, input)
+	}
+
+	input.CapacityProviderStrategy = s.spotStrategy
+	taskArn, err := s.runTask(ctx, input)
+	var capacityErr *capacityError
+	if !errors.As(err, &capacityErr) {
+		return taskArn, err
+	}
+
+	log.Printf("Spot capacity unavailable, running task on demand: %v", err)
+	input.CapacityProviderStrategy = nil
+	if s.onDemandProvider != "" {
+		input.CapacityProviderStrategy = []types.CapacityProviderStrategyItem{
+			{CapacityProvider: aws.String(s.onDemandProvider), Weight: 1},
+		}
+	} else {
+		input.LaunchType = s.launchType
+	}
+	return s.runTask(ctx, input)
+}
+
+// runTaskInput builds the request for a task, without its launch type or
+// capacity provider strategy
+func (s *Service) runTaskInput(opts TaskOptions) *ecs.RunTaskInput {
 	// Convert EnvVar to ECS KeyValuePair
 	var ecsEnvVars []types.KeyValuePair
-	for _, env := range envVars {
+	for _, env := range opts.Env {
 		ecsEnvVars = append(ecsEnvVars, types.KeyValuePair{
 			Name:  aws.String(env.Name),
 			Value: aws.String(env.Value),
 		})
 	}
 
-	// Run the ECS task
-	input := &ecs.RunTaskInput{
+	// Static tags first, so per-task tags win
+	tags := make(map[string]string, len(s.tags)+len(opts.Tags))
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+	for k, v := range opts.Tags {
+		tags[k] = v
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var ecsTags []types.Tag
+	for _, k := range keys {
+		ecsTags = append(ecsTags, types.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+
+	overrides := &types.TaskOverride{
+		ContainerOverrides: []types.ContainerOverride{
+			{
+				Name:        aws.String(s.imageName),
+				Environment: ecsEnvVars,
+			},
+		},
+	}
+	if opts.CPU > 0 {
+		overrides.Cpu = aws.String(strconv.Itoa(opts.CPU))
+	}
+	if opts.MemoryMB > 0 {
+		overrides.Memory = aws.String(strconv.Itoa(opts.MemoryMB))
+	}
+
+	return &ecs.RunTaskInput{
 		Cluster:        aws.String(s.cluster),
 		TaskDefinition: aws.String(s.taskDef),
-		LaunchType:     s.launchType,
 		Count:          aws.Int32(s.count),
+		Tags:           ecsTags,
+		// Adds the cluster name tag AWS cost allocation reports can group by
+		EnableECSManagedTags: true,
 		NetworkConfiguration: &types.NetworkConfiguration{
 			AwsvpcConfiguration: &types.AwsVpcConfiguration{
 				Subnets:        s.subnets,
@@ -75,16 +232,21 @@ func (s *Service) RunTask(ctx context.Context, envVars []EnvVar) (*string, error
 				AssignPublicIp: s.assignPublicIP,
 			},
 		},
-		Overrides: &types.TaskOverride{
-			ContainerOverrides: []types.ContainerOverride{
-				{
-					Name:        aws.String(s.imageName),
-					Environment: ecsEnvVars,
-				},
-			},
-		},
+		Overrides: overrides,
 	}
+}
 
+// capacityError is returned when ECS accepted the request but had no
+// capacity to place the task
+type capacityError struct {
+	reasons []string
+}
+
+func (e *capacityError) Error() string {
+	return "no capacity to run task: " + strings.Join(e.reasons, "; ")
+}
+
+func (s *Service) runTask(ctx context.Context, input *ecs.RunTaskInput) (*string, error) {
 	result, err := s.client.RunTask(ctx, input)
 	if err != nil {
 		return nil, err
@@ -95,5 +257,68 @@ func (s *Service) RunTask(ctx context.Context, envVars []EnvVar) (*string, error
 		return result.Tasks[0].TaskArn, nil
 	}
 
-	return nil, nil
+	// RunTask reports placement problems as failures, not as an error
+	var (
+		reasons  []string
+		capacity bool
+	)
+	for _, f := range result.Failures {
+		reason := aws.ToString(f.Reason)
+		if detail := aws.ToString(f.Detail); detail != "" {
+			reason += " (" + detail + ")"
+		}
+		reasons = append(reasons, reason)
+		if strings.Contains(strings.ToLower(reason), "capacity") {
+			capacity = true
+		}
+	}
+	if capacity {
+		return nil, &capacityError{reasons: reasons}
+	}
+	if len(reasons) > 0 {
+		return nil, fmt.Errorf("ECS did not start a task: %s", strings.Join(reasons, "; "))
+	}
+	return nil, errors.New("ECS did not start a task")
+}
+
+// StopTask stops a running task
+func (s *Service) StopTask(ctx context.Context, taskArn, reason string) error {
+	_, err := s.client.StopTask(ctx, &ecs.StopTaskInput{
+		Cluster: aws.String(s.cluster),
+		Task:    aws.String(taskArn),
+		Reason:  aws.String(reason),
+	})
+	return err
+}
+
+// DescribeTask returns the current state of a task, including its tags
+func (s *Service) DescribeTask(ctx context.Context, taskArn string) (*types.Task, error) {
+	result, err := s.client.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+		Cluster: aws.String(s.cluster),
+		Tasks:   []string{taskArn},
+		Include: []types.TaskField{types.TaskFieldTags},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Tasks) == 0 {
+		return nil, fmt.Errorf("task %s not found", taskArn)
+	}
+
+	return &result.Tasks[0], nil
+}
+
+// TaskTags returns the tags of a task
+func (s *Service) TaskTags(ctx context.Context, taskArn string) (map[string]string, error) {
+	task, err := s.DescribeTask(ctx, taskArn)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string, len(task.Tags))
+	for _, t := range task.Tags {
+		tags[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+	return tags, nil
 }
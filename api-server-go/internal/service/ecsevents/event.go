@@ -0,0 +1,134 @@
+// Package ecsevents reconciles deployments with the real state of their ECS
+// build tasks. ECS task state change events reach an SQS queue through an
+// EventBridge rule; a task that stops without its build reporting a result
+// turns the deployment into FAIL.
+package ecsevents
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	eventSource     = "aws.ecs"
+	eventDetailType = "ECS Task State Change"
+
+	// stopCodeContainerExited is the normal end of a task: the build
+	// container exited by itself
+	stopCodeContainerExited = "EssentialContainerExited"
+)
+
+// envelope is an EventBridge event as delivered to SQS
+type envelope struct {
+	Source     string          `json:"source"`
+	DetailType string          `json:"detail-type"`
+	Detail     json.RawMessage `json:"detail"`
+}
+
+// Tag is an ECS resource tag
+type Tag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Container is the state of one container of the task
+type Container struct {
+	Name       string `json:"name"`
+	LastStatus string `json:"lastStatus"`
+	ExitCode   *int   `json:"exitCode"`
+	Reason     string `json:"reason"`
+}
+
+// TaskStateChange is the detail of an ECS task state change event
+type TaskStateChange struct {
+	TaskArn       string      `json:"taskArn"`
+	LastStatus    string      `json:"lastStatus"`
+	DesiredStatus string      `json:"desiredStatus"`
+	StopCode      string      `json:"stopCode"`
+	StoppedReason string      `json:"stoppedReason"`
+	Containers    []Container `json:"containers"`
+	// Tags are not part of the events ECS emits today; when absent they are
+	// looked up with DescribeTasks
+	Tags []Tag `json:"tags"`
+}
+
+// ParseEvent decodes a queue message. ok is false for events that are not
+// ECS task state changes.
+func ParseEvent(body string) (change TaskStateChange, ok bool, err error) {
+	var env envelope
+	if err := json.Unmarshal([]byte(body), &env); err != nil {
+		return change, false, fmt.Errorf("invalid event: %w", err)
+	}
+	if env.Source != eventSource || env.DetailType != eventDetailType {
+		return change, false, nil
+	}
+
+	if err := json.Unmarshal(env.Detail, &change); err != nil {
+		return change, false, fmt.Errorf("invalid task state change: %w", err)
+	}
+	return change, true, nil
+}
+
+// Tag returns the value of a tag carried by the event
+func (c TaskStateChange) Tag(key string) string {
+	for _, t := range c.Tags {
+		if t.Key == key {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+// Failure describes a task that stopped without a successful build
+type Failure struct {
+	// Reason is a one-line summary for the deployment history
+	Reason string
+	// Details are the stop reason and per-container reasons
+	Details []string
+	// OutOfMemory is set when a container was killed for exceeding its memory
+	OutOfMemory bool
+}
+
+// Failure reports whether a stopped task ended badly: a container exited
+// with a non-zero code, or ECS stopped the task itself (image pull errors,
+// capacity, Spot interruption). ok is false for running tasks and for tasks
+// whose build container exited cleanly.
+func (c TaskStateChange) Failure() (f Failure, ok bool) {
+	if c.LastStatus != "STOPPED" {
+		return f, false
+	}
+
+	if c.StoppedReason != "" {
+		f.Details = append(f.Details, c.StoppedReason)
+	}
+	if c.StopCode != "" && c.StopCode != stopCodeContainerExited {
+		ok = true
+		f.Reason = fmt.Sprintf("build task stopped (%s): %s", c.StopCode, c.StoppedReason)
+	}
+
+	for _, container := range c.Containers {
+		if container.Reason != "" {
+			f.Details = append(f.Details, fmt.Sprintf("%s: %s", container.Name, container.Reason))
+		}
+		if strings.Contains(container.Reason, "OutOfMemory") {
+			f.OutOfMemory = true
+		}
+
+		switch {
+		case container.ExitCode != nil && *container.ExitCode != 0:
+			if !ok {
+				f.Reason = fmt.Sprintf("build container %s exited with code %d", container.Name, *container.ExitCode)
+			}
+			ok = true
+		case container.ExitCode == nil && container.Reason != "":
+			// The container never ran, e.g. CannotPullContainerError
+			if !ok {
+				f.Reason = fmt.Sprintf("build container %s did not run: %s", container.Name, container.Reason)
+			}
+			ok = true
+		}
+	}
+
+	return f, ok
+}
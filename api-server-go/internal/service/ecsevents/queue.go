@@ -0,0 +1,163 @@
+package ecsevents
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// Message is one event received from the queue
+type Message struct {
+	ID   string
+	Body string
+	// ReceiptHandle is passed back to Delete
+	ReceiptHandle string
+}
+
+// Queue is where task state change events are received from. Messages that
+// are not deleted are delivered again.
+type Queue interface {
+	// Receive waits for messages until some arrive, the wait time passes or
+	// ctx is cancelled
+	Receive(ctx context.Context) ([]Message, error)
+	Delete(ctx context.Context, receiptHandle string) error
+}
+
+// SQSQueue receives events from an SQS queue
+type SQSQueue struct {
+	client      *sqs.Client
+	url         string
+	waitTime    time.Duration
+	maxMessages int
+}
+
+// NewSQSQueue creates a queue that long-polls url
+func NewSQSQueue(client *sqs.Client, url string, waitTime time.Duration, maxMessages int) *SQSQueue {
+	return &SQSQueue{
+		client:      client,
+		url:         url,
+		waitTime:    waitTime,
+		maxMessages: maxMessages,
+	}
+}
+
+func (q *SQSQueue) Receive(ctx context.Context) ([]Message, error) {
+	result, err := q.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(q.url),
+		MaxNumberOfMessages: int32(q.maxMessages),
+		WaitTimeSeconds:     int32(q.waitTime / time.Second),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, len(result.Messages))
+	for i, m := range result.Messages {
+		messages[i] = Message{
+			ID:            aws.ToString(m.MessageId),
+			Body:          aws.ToString(m.Body),
+			ReceiptHandle: aws.ToString(m.ReceiptHandle),
+		}
+	}
+	return messages, nil
+}
+
+func (q *SQSQueue) Delete(ctx context.Context, receiptHandle string) error {
+	_, err := q.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.url),
+		ReceiptHandle: aws.String(receiptHandle),
+	})
+	return err
+}
+
+// MemoryQueue is an in-process stand-in for SQS, for development and tests.
+// Received messages stay in flight until deleted and are redelivered after
+// the visibility timeout, like SQS.
+type MemoryQueue struct {
+	visibility time.Duration
+
+	mu       sync.Mutex
+	nextID   int
+	queued   []Message
+	inFlight map[string]inFlight
+	notify   chan struct{}
+}
+
+type inFlight struct {
+	msg       Message
+	visibleAt time.Time
+}
+
+// NewMemoryQueue creates an empty in-memory queue
+func NewMemoryQueue(visibility time.Duration) *MemoryQueue {
+	return &MemoryQueue{
+		visibility: visibility,
+		inFlight:   make(map[string]inFlight),
+		notify:     make(chan struct{}),
+	}
+}
+
+// Send adds a message to the queue
+func (q *MemoryQueue) Send(body string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextID++
+	id := strconv.Itoa(q.nextID)
+	q.queued = append(q.queued, Message{ID: id, Body: body})
+
+	close(q.notify)
+	q.notify = make(chan struct{})
+}
+
+// Len returns how many messages are waiting or in flight
+func (q *MemoryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.queued) + len(q.inFlight)
+}
+
+func (q *MemoryQueue) Receive(ctx context.Context) ([]Message, error) {
+	for {
+		q.mu.Lock()
+		now := time.Now()
+		for handle, f := range q.inFlight {
+			if !now.Before(f.visibleAt) {
+				delete(q.inFlight, handle)
+				q.queued = append(q.queued, f.msg)
+			}
+		}
+
+		if len(q.queued) > 0 {
+			messages := q.queued
+			q.queued = nil
+			for i := range messages {
+				q.nextID++
+				messages[i].ReceiptHandle = messages[i].ID + "-" + strconv.Itoa(q.nextID)
+				q.inFlight[messages[i].ReceiptHandle] = inFlight{msg: messages[i], visibleAt: now.Add(q.visibility)}
+			}
+			q.mu.Unlock()
+			return messages, nil
+		}
+		notify := q.notify
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-notify:
+		case <-time.After(q.visibility):
+		}
+	}
+}
+
+func (q *MemoryQueue) Delete(ctx context.Context, receiptHandle string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.inFlight, receiptHandle)
+	return nil
+}
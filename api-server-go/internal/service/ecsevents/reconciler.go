@@ -0,0 +1,143 @@
+package ecsevents
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	deploymentdomain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/ecs"
+)
+
+// TagLookup returns the tags of a task. It is used when an event does not
+// carry them.
+type TagLookup func(ctx context.Context, taskArn string) (map[string]string, error)
+
+// Deployments changes the status of deployments. It is implemented by
+// deployment.DeploymentService.
+type Deployments interface {
+	Finish(ctx context.Context, deploymentID string, to deploymentdomain.Status, reason string) error
+	RecordFailure(ctx context.Context, deploymentID string, reason deploymentdomain.FailureReason, excerpt []string) error
+}
+
+// Reconciler consumes task state change events and fails deployments whose
+// build task died
+type Reconciler struct {
+	queue         Queue
+	deploymentSvc Deployments
+	lookupTags    TagLookup
+}
+
+// NewReconciler creates a reconciler. lookupTags may be nil, in which case
+// events without tags are ignored.
+func NewReconciler(queue Queue, deploymentSvc Deployments, lookupTags TagLookup) *Reconciler {
+	return &Reconciler{
+		queue:         queue,
+		deploymentSvc: deploymentSvc,
+		lookupTags:    lookupTags,
+	}
+}
+
+// Run receives events until ctx is cancelled
+func (r *Reconciler) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		messages, err := r.queue.Receive(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("ERROR: failed to receive ECS events: %v", err)
+				// Avoid a hot loop while the queue is unreachable
+				select {
+				case <-ctx.Done():
+				case <-time.After(5 * time.Second):
+				}
+			}
+			continue
+		}
+
+		for _, msg := range messages {
+			r.process(ctx, msg)
+		}
+	}
+}
+
+// process handles one message and deletes it unless it should be retried
+func (r *Reconciler) process(ctx context.Context, msg Message) {
+	// Finish the deployment update even if shutdown starts
+	ctx = context.WithoutCancel(ctx)
+
+	if err := r.Handle(ctx, msg.Body); err != nil {
+		// Left on the queue; it is delivered again after the visibility timeout
+		log.Printf("ERROR: failed to handle ECS event %s: %v", msg.ID, err)
+		return
+	}
+
+	if err := r.queue.Delete(ctx, msg.ReceiptHandle); err != nil {
+		log.Printf("ERROR: failed to delete ECS event %s: %v", msg.ID, err)
+	}
+}
+
+// Handle applies one event. Events that are malformed, unrelated or about
+// healthy tasks are accepted and dropped; an error means it should be retried.
+func (r *Reconciler) Handle(ctx context.Context, body string) error {
+	change, ok, err := ParseEvent(body)
+	if err != nil {
+		log.Printf("WARN: dropping malformed ECS event: %v", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+
+	failure, failed := change.Failure()
+	if !failed {
+		return nil
+	}
+
+	deploymentID, err := r.deploymentID(ctx, change)
+	if err != nil {
+		return err
+	}
+	if deploymentID == "" {
+		// Not a build task
+		return nil
+	}
+
+	err = r.deploymentSvc.Finish(ctx, deploymentID, deploymentdomain.Fail, failure.Reason)
+	if errors.Is(err, deploymentdomain.ErrInvalidTransition) || errors.Is(err, sql.ErrNoRows) {
+		// The build already reported a result, or the deployment was
+		// canceled or deleted
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	reason := deploymentdomain.FailureBuilderStopped
+	if failure.OutOfMemory {
+		reason = deploymentdomain.FailureOutOfMemory
+	}
+	if err := r.deploymentSvc.RecordFailure(ctx, deploymentID, reason, failure.Details); err != nil {
+		log.Printf("ERROR: failed to record failure reason for deployment %s: %v", deploymentID, err)
+	}
+
+	log.Printf("Deployment %s failed: %s", deploymentID, failure.Reason)
+	return nil
+}
+
+// deploymentID finds the deployment of a task through its tags
+func (r *Reconciler) deploymentID(ctx context.Context, change TaskStateChange) (string, error) {
+	if id := change.Tag(ecs.TagDeploymentID); id != "" {
+		return id, nil
+	}
+	if r.lookupTags == nil || change.TaskArn == "" {
+		return "", nil
+	}
+
+	tags, err := r.lookupTags(ctx, change.TaskArn)
+	if err != nil {
+		return "", err
+	}
+	return tags[ecs.TagDeploymentID], nil
+}
@@ -0,0 +1,274 @@
+package ecsevents
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	deploymentdomain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/ecs"
+)
+
+const testTaskArn = "arn:aws:ecs:us-east-1:123456789012:task/builds/abc"
+
+// fakeDeployments applies the deployment state machine in memory
+type fakeDeployments struct {
+	mu       sync.Mutex
+	status   map[string]deploymentdomain.Status
+	failures map[string][]deploymentdomain.FailureReason
+	// failNext makes the next Transition calls return an error
+	failNext int
+}
+
+func newFakeDeployments(status map[string]deploymentdomain.Status) *fakeDeployments {
+	return &fakeDeployments{status: status, failures: make(map[string][]deploymentdomain.FailureReason)}
+}
+
+func (f *fakeDeployments) Transition(ctx context.Context, deploymentID string, to deploymentdomain.Status, reason string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failNext > 0 {
+		f.failNext--
+		return errors.New("database unavailable")
+	}
+	from, ok := f.status[deploymentID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if !deploymentdomain.CanTransition(from, to) {
+		return deploymentdomain.ErrInvalidTransition
+	}
+	f.status[deploymentID] = to
+	return nil
+}
+
+// Finish passes through IN_PROGRESS like deployment.DeploymentService
+func (f *fakeDeployments) Finish(ctx context.Context, deploymentID string, to deploymentdomain.Status, reason string) error {
+	err := f.Transition(ctx, deploymentID, to, reason)
+	if !errors.Is(err, deploymentdomain.ErrInvalidTransition) {
+		return err
+	}
+	if err := f.Transition(ctx, deploymentID, deploymentdomain.InProgress, "start not reported"); err != nil && !errors.Is(err, deploymentdomain.ErrInvalidTransition) {
+		return err
+	}
+	return f.Transition(ctx, deploymentID, to, reason)
+}
+
+func (f *fakeDeployments) RecordFailure(ctx context.Context, deploymentID string, reason deploymentdomain.FailureReason, excerpt []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[deploymentID] = append(f.failures[deploymentID], reason)
+	return nil
+}
+
+func (f *fakeDeployments) get(deploymentID string) (deploymentdomain.Status, []deploymentdomain.FailureReason) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status[deploymentID], f.failures[deploymentID]
+}
+
+// taskEvent renders an ECS task state change as delivered to SQS
+func taskEvent(t *testing.T, change TaskStateChange) string {
+	t.Helper()
+
+	detail, err := json.Marshal(change)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(envelope{Source: eventSource, DetailType: eventDetailType, Detail: detail})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func exitCode(code int) *int {
+	return &code
+}
+
+func stoppedTask(deploymentID string, code int, reason string) TaskStateChange {
+	return TaskStateChange{
+		TaskArn:       testTaskArn,
+		LastStatus:    "STOPPED",
+		StopCode:      stopCodeContainerExited,
+		StoppedReason: "Essential container in task exited",
+		Containers:    []Container{{Name: "build", LastStatus: "STOPPED", ExitCode: exitCode(code), Reason: reason}},
+		Tags:          []Tag{{Key: ecs.TagDeploymentID, Value: deploymentID}},
+	}
+}
+
+func runningTask(deploymentID string) TaskStateChange {
+	return TaskStateChange{
+		TaskArn:    testTaskArn,
+		LastStatus: "RUNNING",
+		Containers: []Container{{Name: "build", LastStatus: "RUNNING"}},
+		Tags:       []Tag{{Key: ecs.TagDeploymentID, Value: deploymentID}},
+	}
+}
+
+// runReconciler starts a reconciler on a memory queue and stops it when the
+// test ends
+func runReconciler(t *testing.T, deployments Deployments, lookupTags TagLookup) *MemoryQueue {
+	t.Helper()
+
+	queue := NewMemoryQueue(50 * time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewReconciler(queue, deployments, lookupTags).Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return queue
+}
+
+// waitDrained waits until every message was handled and deleted
+func waitDrained(t *testing.T, queue *MemoryQueue) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for queue.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d messages are still on the queue", queue.Len())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReconcilerFailsDeploymentOfStoppedTask(t *testing.T) {
+	deployments := newFakeDeployments(map[string]deploymentdomain.Status{"dep-1": deploymentdomain.InProgress})
+	queue := runReconciler(t, deployments, nil)
+
+	queue.Send(taskEvent(t, stoppedTask("dep-1", 1, "")))
+	waitDrained(t, queue)
+
+	status, failures := deployments.get("dep-1")
+	if status != deploymentdomain.Fail {
+		t.Fatalf("status = %s, want FAIL", status)
+	}
+	if len(failures) != 1 || failures[0] != deploymentdomain.FailureBuilderStopped {
+		t.Fatalf("failures = %v, want [%s]", failures, deploymentdomain.FailureBuilderStopped)
+	}
+}
+
+func TestReconcilerRecordsOutOfMemory(t *testing.T) {
+	deployments := newFakeDeployments(map[string]deploymentdomain.Status{"dep-1": deploymentdomain.InProgress})
+	queue := runReconciler(t, deployments, nil)
+
+	queue.Send(taskEvent(t, stoppedTask("dep-1", 137, "OutOfMemoryError: Container killed due to memory usage")))
+	waitDrained(t, queue)
+
+	if _, failures := deployments.get("dep-1"); len(failures) != 1 || failures[0] != deploymentdomain.FailureOutOfMemory {
+		t.Fatalf("failures = %v, want [%s]", failures, deploymentdomain.FailureOutOfMemory)
+	}
+}
+
+func TestReconcilerIgnoresDuplicateEvents(t *testing.T) {
+	deployments := newFakeDeployments(map[string]deploymentdomain.Status{"dep-1": deploymentdomain.InProgress})
+	queue := runReconciler(t, deployments, nil)
+
+	// EventBridge and SQS deliver at least once
+	event := taskEvent(t, stoppedTask("dep-1", 1, ""))
+	queue.Send(event)
+	queue.Send(event)
+	waitDrained(t, queue)
+
+	status, failures := deployments.get("dep-1")
+	if status != deploymentdomain.Fail || len(failures) != 1 {
+		t.Fatalf("status = %s with failures %v, want FAIL recorded once", status, failures)
+	}
+}
+
+func TestReconcilerHandlesOutOfOrderEvents(t *testing.T) {
+	deployments := newFakeDeployments(map[string]deploymentdomain.Status{
+		"dep-1": deploymentdomain.Queued,
+		"dep-2": deploymentdomain.InProgress,
+	})
+	queue := runReconciler(t, deployments, nil)
+
+	// dep-1: the STOPPED event arrives before the RUNNING one
+	queue.Send(taskEvent(t, stoppedTask("dep-1", 1, "")))
+	queue.Send(taskEvent(t, runningTask("dep-1")))
+	waitDrained(t, queue)
+
+	if status, failures := deployments.get("dep-1"); status != deploymentdomain.Fail || len(failures) != 1 {
+		t.Fatalf("dep-1 status = %s with failures %v, want FAIL recorded once", status, failures)
+	}
+
+	// dep-2: the build reported success before the STOPPED event of its
+	// task arrived with a non-zero exit code; the build result stands
+	if err := deployments.Transition(context.Background(), "dep-2", deploymentdomain.Ready, "build completed"); err != nil {
+		t.Fatal(err)
+	}
+	queue.Send(taskEvent(t, stoppedTask("dep-2", 2, "")))
+	waitDrained(t, queue)
+
+	if status, failures := deployments.get("dep-2"); status != deploymentdomain.Ready || len(failures) != 0 {
+		t.Fatalf("dep-2 status = %s with failures %v, want READY without failures", status, failures)
+	}
+}
+
+func TestReconcilerDropsUnrelatedEvents(t *testing.T) {
+	deployments := newFakeDeployments(map[string]deploymentdomain.Status{"dep-1": deploymentdomain.InProgress})
+	queue := runReconciler(t, deployments, nil)
+
+	clean := stoppedTask("dep-1", 0, "")
+	untagged := stoppedTask("", 1, "")
+	untagged.Tags = nil
+
+	queue.Send(`not json`)
+	queue.Send(`{"source":"aws.ec2","detail-type":"EC2 Instance State-change Notification","detail":{}}`)
+	queue.Send(taskEvent(t, clean))
+	queue.Send(taskEvent(t, untagged))
+	queue.Send(taskEvent(t, stoppedTask("deleted", 1, "")))
+	waitDrained(t, queue)
+
+	if status, failures := deployments.get("dep-1"); status != deploymentdomain.InProgress || len(failures) != 0 {
+		t.Fatalf("status = %s with failures %v, want IN_PROGRESS without failures", status, failures)
+	}
+}
+
+func TestReconcilerRedeliversAfterError(t *testing.T) {
+	deployments := newFakeDeployments(map[string]deploymentdomain.Status{"dep-1": deploymentdomain.InProgress})
+	deployments.failNext = 2
+	queue := runReconciler(t, deployments, nil)
+
+	queue.Send(taskEvent(t, stoppedTask("dep-1", 1, "")))
+	waitDrained(t, queue)
+
+	if status, failures := deployments.get("dep-1"); status != deploymentdomain.Fail || len(failures) != 1 {
+		t.Fatalf("status = %s with failures %v, want FAIL recorded once", status, failures)
+	}
+}
+
+func TestReconcilerLooksUpTags(t *testing.T) {
+	deployments := newFakeDeployments(map[string]deploymentdomain.Status{"dep-1": deploymentdomain.InProgress})
+	lookups := 0
+	queue := runReconciler(t, deployments, func(ctx context.Context, taskArn string) (map[string]string, error) {
+		lookups++
+		if lookups == 1 {
+			return nil, errors.New("throttled")
+		}
+		if taskArn != testTaskArn {
+			t.Errorf("looked up %s, want %s", taskArn, testTaskArn)
+		}
+		return map[string]string{ecs.TagDeploymentID: "dep-1"}, nil
+	})
+
+	change := stoppedTask("dep-1", 1, "")
+	change.Tags = nil
+	queue.Send(taskEvent(t, change))
+	waitDrained(t, queue)
+
+	if status, _ := deployments.get("dep-1"); status != deploymentdomain.Fail {
+		t.Fatalf("status = %s, want FAIL", status)
+	}
+}
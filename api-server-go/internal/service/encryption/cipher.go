@@ -0,0 +1,76 @@
+// Package encryption encrypts secrets stored in the database, such as the
+// private deploy keys of projects.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// version is the first byte of every ciphertext, so the format or key can
+// change later without guessing which one old values use
+const version byte = 1
+
+// ErrDecrypt is returned for ciphertext that was tampered with or
+// encrypted with another key
+var ErrDecrypt = errors.New("failed to decrypt secret")
+
+// Cipher encrypts with AES-256-GCM
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// New creates a cipher from a base64 encoded 32 byte key, e.g. the output
+// of `openssl rand -base64 32`
+func New(key string) (*Cipher, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not valid base64: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt returns version || nonce || sealed plaintext. associated binds
+// the ciphertext to its owner, e.g. a project ID; Decrypt must be given the
+// same value, so a ciphertext copied to another row does not decrypt.
+func (c *Cipher) Encrypt(plaintext, associated []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, 1+len(nonce)+len(plaintext)+c.aead.Overhead())
+	out = append(out, version)
+	out = append(out, nonce...)
+	return c.aead.Seal(out, nonce, plaintext, associated), nil
+}
+
+// Decrypt opens a value made by Encrypt
+func (c *Cipher) Decrypt(ciphertext, associated []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(ciphertext) < 1+n || ciphertext[0] != version {
+		return nil, ErrDecrypt
+	}
+
+	plaintext, err := c.aead.Open(nil, ciphertext[1:1+n], ciphertext[1+n:], associated)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
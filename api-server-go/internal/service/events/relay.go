@@ -0,0 +1,113 @@
+// Package events publishes domain events from the outbox table to the
+// event bus.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/event"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/eventbus"
+	repository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/event"
+)
+
+// pruneInterval is how often events past their retention are deleted
+const pruneInterval = time.Hour
+
+type Config struct {
+	Topic        string
+	PollInterval time.Duration
+	BatchSize    int
+	Retention    time.Duration
+}
+
+// Relay publishes outbox events in the order they were written. Delivery is
+// at least once: an event whose publish succeeded but was not marked is sent
+// again, so consumers deduplicate by event ID.
+type Relay struct {
+	repo *repository.Repository
+	bus  eventbus.Bus
+	cfg  Config
+}
+
+// New creates a relay. With a nil bus nothing is published and events are
+// only pruned.
+func New(repo *repository.Repository, bus eventbus.Bus, cfg Config) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	return &Relay{repo: repo, bus: bus, cfg: cfg}
+}
+
+// Run publishes events until ctx is cancelled
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	var lastPrune time.Time
+	for {
+		if time.Since(lastPrune) >= pruneInterval {
+			r.prune(ctx)
+			lastPrune = time.Now()
+		}
+
+		// Keep draining while full batches come back
+		for {
+			if n := r.poll(ctx); n < r.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll publishes one batch and returns its size
+func (r *Relay) poll(ctx context.Context) int {
+	if r.bus == nil || ctx.Err() != nil {
+		return 0
+	}
+
+	n, err := r.repo.PublishPending(ctx, r.cfg.BatchSize, r.publish)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Printf("ERROR: failed to publish domain events: %v", err)
+	}
+	return n
+}
+
+func (r *Relay) publish(ctx context.Context, e domain.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return r.bus.Publish(ctx, r.cfg.Topic, []byte(e.ProjectID), body)
+}
+
+// prune deletes events past retention. Without a bus they can never be
+// published, so unpublished ones go too.
+func (r *Relay) prune(ctx context.Context) {
+	if r.cfg.Retention <= 0 {
+		return
+	}
+
+	n, err := r.repo.DeleteBefore(ctx, time.Now().Add(-r.cfg.Retention), r.bus == nil)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			log.Printf("ERROR: failed to prune domain events: %v", err)
+		}
+		return
+	}
+	if n > 0 {
+		log.Printf("Pruned %d domain events older than %s", n, r.cfg.Retention)
+	}
+}
@@ -0,0 +1,19 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNewDefaultsPollInterval(t *testing.T) {
+	r := New(nil, nil, Config{PollInterval: 0})
+	if r.cfg.PollInterval <= 0 {
+		t.Fatalf("PollInterval = %s, want a positive default", r.cfg.PollInterval)
+	}
+
+	// Without a bus and retention Run only waits on the ticker
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r.Run(ctx)
+}
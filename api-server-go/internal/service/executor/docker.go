@@ -0,0 +1,187 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DockerConfig configures the local Docker executor
+type DockerConfig struct {
+	// Binary is the docker CLI to run (default "docker")
+	Binary string
+	// Image is the build image, e.g. mini-vercel-builder:latest
+	Image string
+	// Network optionally attaches build containers to a Docker network
+	Network string
+}
+
+// DockerExecutor runs builds as containers on the local Docker daemon through
+// the docker CLI. It is meant for development on a laptop.
+type DockerExecutor struct {
+	cfg DockerConfig
+}
+
+// NewDockerExecutor creates a Docker executor
+func NewDockerExecutor(cfg DockerConfig) *DockerExecutor {
+	if cfg.Binary == "" {
+		cfg.Binary = "docker"
+	}
+	return &DockerExecutor{cfg: cfg}
+}
+
+func (e *DockerExecutor) Name() string {
+	return "docker"
+}
+
+// Start runs a detached build container named after the deployment.
+// Values are passed through the CLI's environment (-e NAME) so secrets do
+// not appear in the process list. A retried dispatch keeps the container of
+// an earlier attempt if it is running, whose token stays valid, and
+// replaces it otherwise.
+func (e *DockerExecutor) Start(ctx context.Context, spec BuildSpec) (string, error) {
+	name := "mini-vercel-build-" + spec.DeploymentID
+
+	status, err := e.Status(ctx, name)
+	switch {
+	case errors.Is(err, ErrBuildNotFound):
+	case err != nil:
+		return "", err
+	case status.Phase == PhaseRunning:
+		return name, nil
+	default:
+		// Exited, or created but never started
+		if _, err := e.run(exec.CommandContext(ctx, e.cfg.Binary, "rm", "--force", name)); err != nil {
+			return "", fmt.Errorf("failed to remove build container from an earlier attempt: %w", err)
+		}
+	}
+
+	args := []string{"run", "--detach", "--name", name,
+		"--label", "mini-vercel.deployment=" + spec.DeploymentID,
+		"--label", "mini-vercel.project=" + spec.ProjectID,
+	}
+	if e.cfg.Network != "" {
+		args = append(args, "--network", e.cfg.Network)
+	}
+	if r := spec.Resources; r != nil {
+		args = append(args,
+			"--cpus", strconv.FormatFloat(float64(r.CPU)/1024, 'f', -1, 64),
+			"--memory", strconv.Itoa(r.MemoryMB)+"m",
+		)
+	}
+
+	env := os.Environ()
+	for _, v := range spec.Env {
+		args = append(args, "--env", v.Name)
+		env = append(env, v.Name+"="+v.Value)
+	}
+	args = append(args, e.cfg.Image)
+
+	cmd := exec.CommandContext(ctx, e.cfg.Binary, args...)
+	cmd.Env = env
+	if _, err := e.run(cmd); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// Stop stops the build container
+func (e *DockerExecutor) Stop(ctx context.Context, buildID string) error {
+	_, err := e.run(exec.CommandContext(ctx, e.cfg.Binary, "stop", buildID))
+	if err != nil && strings.Contains(err.Error(), "No such container") {
+		return nil
+	}
+	return err
+}
+
+// dockerState is the subset of `docker inspect` .State that is used
+type dockerState struct {
+	Status     string    `json:"Status"`
+	ExitCode   int       `json:"ExitCode"`
+	OOMKilled  bool      `json:"OOMKilled"`
+	Error      string    `json:"Error"`
+	StartedAt  time.Time `json:"StartedAt"`
+	FinishedAt time.Time `json:"FinishedAt"`
+}
+
+// Status inspects the build container
+func (e *DockerExecutor) Status(ctx context.Context, buildID string) (BuildStatus, error) {
+	out, err := e.run(exec.CommandContext(ctx, e.cfg.Binary, "inspect", "--format", "{{json .State}}", buildID))
+	if err != nil {
+		if strings.Contains(err.Error(), "No such object") || strings.Contains(err.Error(), "No such container") {
+			return BuildStatus{}, ErrBuildNotFound
+		}
+		return BuildStatus{}, err
+	}
+
+	var state dockerState
+	if err := json.Unmarshal(out, &state); err != nil {
+		return BuildStatus{}, fmt.Errorf("failed to parse container state: %w", err)
+	}
+
+	status := BuildStatus{Reason: state.Error}
+	if !state.StartedAt.IsZero() {
+		status.StartedAt = &state.StartedAt
+	}
+
+	switch state.Status {
+	case "created":
+		status.Phase = PhasePending
+	case "running", "paused", "restarting":
+		status.Phase = PhaseRunning
+	case "exited", "dead":
+		code := state.ExitCode
+		status.ExitCode = &code
+		status.FinishedAt = &state.FinishedAt
+		status.Phase = PhaseFailed
+		if code == 0 {
+			status.Phase = PhaseSucceeded
+		}
+		if state.OOMKilled {
+			status.Reason = "OOMKilled"
+		}
+	default:
+		status.Phase = PhaseUnknown
+	}
+
+	return status, nil
+}
+
+// Logs streams the combined output of the build container
+func (e *DockerExecutor) Logs(ctx context.Context, buildID string) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, e.cfg.Binary, "logs", "--follow", buildID)
+
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go func() {
+		pw.CloseWithError(cmd.Wait())
+	}()
+
+	return pr, nil
+}
+
+// run executes a docker command and includes its stderr in errors
+func (e *DockerExecutor) run(cmd *exec.Cmd) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", strings.Join(cmd.Args[:2], " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
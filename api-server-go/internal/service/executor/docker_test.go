@@ -0,0 +1,90 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeDocker is a docker CLI that keeps one container's state in a file and
+// records the commands it runs
+const fakeDocker = `#!/bin/sh
+dir="$FAKE_DOCKER_DIR"
+echo "$1" >> "$dir/calls"
+case "$1" in
+inspect)
+	if [ -f "$dir/state" ]; then cat "$dir/state"; exit 0; fi
+	echo "Error: No such object: $5" >&2; exit 1 ;;
+rm)
+	rm -f "$dir/state"; exit 0 ;;
+run)
+	if [ -f "$dir/state" ]; then echo "Conflict. The container name is already in use" >&2; exit 125; fi
+	echo '{"Status":"running","StartedAt":"2026-01-01T00:00:00Z"}' > "$dir/state"
+	echo "container-id"; exit 0 ;;
+esac
+exit 1
+`
+
+func newTestDockerExecutor(t *testing.T, state string) (*DockerExecutor, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "docker")
+	if err := os.WriteFile(binary, []byte(fakeDocker), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if state != "" {
+		if err := os.WriteFile(filepath.Join(dir, "state"), []byte(state), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("FAKE_DOCKER_DIR", dir)
+
+	return NewDockerExecutor(DockerConfig{Binary: binary, Image: "builder:latest"}), dir
+}
+
+func dockerCalls(t *testing.T, dir string) string {
+	t.Helper()
+	calls, err := os.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(strings.Fields(string(calls)), " ")
+}
+
+func TestDockerStart(t *testing.T) {
+	tests := []struct {
+		name  string
+		state string
+		calls string
+	}{
+		{"new", "", "inspect run"},
+		{"retried while running", `{"Status":"running","StartedAt":"2026-01-01T00:00:00Z"}`, "inspect"},
+		{"retried after it exited", `{"Status":"exited","ExitCode":1}`, "inspect rm run"},
+		{"retried after it was only created", `{"Status":"created"}`, "inspect rm run"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, dir := newTestDockerExecutor(t, tt.state)
+
+			name, err := e.Start(context.Background(), testSpec("mvb_second"))
+			if err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			if name != "mini-vercel-build-dep-1" {
+				t.Fatalf("build ID = %q", name)
+			}
+			if got := dockerCalls(t, dir); got != tt.calls {
+				t.Fatalf("docker commands = %q, want %q", got, tt.calls)
+			}
+
+			status, err := e.Status(context.Background(), name)
+			if err != nil || status.Phase != PhaseRunning {
+				t.Fatalf("Status = %+v, %v, want running", status, err)
+			}
+		})
+	}
+}
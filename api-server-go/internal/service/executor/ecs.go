@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/ecs"
+)
+
+// ECSExecutor runs builds as ECS tasks
+type ECSExecutor struct {
+	service *ecs.Service
+}
+
+// NewECSExecutor wraps an ECS service
+func NewECSExecutor(service *ecs.Service) *ECSExecutor {
+	return &ECSExecutor{service: service}
+}
+
+func (e *ECSExecutor) Name() string {
+	return "ecs"
+}
+
+// Start runs the build task and returns its ARN
+func (e *ECSExecutor) Start(ctx context.Context, spec BuildSpec) (string, error) {
+	env := make([]ecs.EnvVar, len(spec.Env))
+	for i, v := range spec.Env {
+		env[i] = ecs.EnvVar{Name: v.Name, Value: v.Value}
+	}
+
+	tags := map[string]string{
+		ecs.TagDeploymentID: spec.DeploymentID,
+		ecs.TagProjectID:    spec.ProjectID,
+	}
+	if spec.Plan != "" {
+		tags[ecs.TagPlan] = spec.Plan
+	}
+	if spec.Preset != "" {
+		tags[ecs.TagBuildPreset] = spec.Preset
+	}
+
+	opts := ecs.TaskOptions{Env: env, Tags: tags, Spot: spec.Spot}
+	if spec.Resources != nil {
+		opts.CPU = spec.Resources.CPU
+		opts.MemoryMB = spec.Resources.MemoryMB
+	}
+
+	taskArn, err := e.service.RunTask(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	if taskArn == nil {
+		return "", errors.New("ECS did not start a task")
+	}
+
+	return *taskArn, nil
+}
+
+// Stop stops the build task
+func (e *ECSExecutor) Stop(ctx context.Context, buildID string) error {
+	return e.service.StopTask(ctx, buildID, "stopped by mini-vercel")
+}
+
+// Status maps the ECS task state to a build phase
+func (e *ECSExecutor) Status(ctx context.Context, buildID string) (BuildStatus, error) {
+	task, err := e.service.DescribeTask(ctx, buildID)
+	if err != nil {
+		return BuildStatus{}, err
+	}
+
+	status := BuildStatus{
+		StartedAt:  task.StartedAt,
+		FinishedAt: task.StoppedAt,
+		Reason:     aws.ToString(task.StoppedReason),
+	}
+
+	switch aws.ToString(task.LastStatus) {
+	case "PROVISIONING", "PENDING", "ACTIVATING":
+		status.Phase = PhasePending
+	case "RUNNING", "DEACTIVATING", "STOPPING", "DEPROVISIONING":
+		status.Phase = PhaseRunning
+	case "STOPPED":
+		// The build container is the only essential container
+		status.Phase = PhaseFailed
+		for _, c := range task.Containers {
+			if c.ExitCode == nil {
+				continue
+			}
+			code := int(*c.ExitCode)
+			status.ExitCode = &code
+			if code == 0 {
+				status.Phase = PhaseSucceeded
+			}
+		}
+	default:
+		status.Phase = PhaseUnknown
+	}
+
+	return status, nil
+}
+
+// Logs is not supported; ECS task output goes to CloudWatch
+func (e *ECSExecutor) Logs(ctx context.Context, buildID string) (io.ReadCloser, error) {
+	return nil, ErrLogsUnsupported
+}
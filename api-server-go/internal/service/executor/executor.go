@@ -0,0 +1,88 @@
+// Package executor runs build containers. Each backend (ECS, Kubernetes,
+// local Docker) implements BuildExecutor; the one in use is chosen by
+// BUILD_EXECUTOR.
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrLogsUnsupported is returned by Logs when a backend cannot read container
+// output. Build logs always reach the API server through Kafka as well.
+var ErrLogsUnsupported = errors.New("executor does not support reading build logs")
+
+// ErrBuildNotFound is returned when the backend no longer knows a build
+var ErrBuildNotFound = errors.New("build not found")
+
+// EnvVar is an environment variable of the build container
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// Resources are the CPU and memory of a build container
+type Resources struct {
+	// CPU is in ECS CPU units; 1024 is one vCPU
+	CPU int
+	// MemoryMB is the memory limit in MiB
+	MemoryMB int
+}
+
+// BuildSpec describes one build to run
+type BuildSpec struct {
+	DeploymentID string
+	ProjectID    string
+	Env          []EnvVar
+
+	// Plan and Preset label the build for cost allocation
+	Plan   string
+	Preset string
+	// Resources overrides the backend's default size when set
+	Resources *Resources
+	// Spot asks for interruptible capacity where the backend has it
+	Spot bool
+}
+
+// Phase is the lifecycle stage of a build container
+type Phase string
+
+const (
+	PhasePending   Phase = "PENDING"
+	PhaseRunning   Phase = "RUNNING"
+	PhaseSucceeded Phase = "SUCCEEDED"
+	PhaseFailed    Phase = "FAILED"
+	PhaseUnknown   Phase = "UNKNOWN"
+)
+
+// Done reports whether the build container has exited
+func (p Phase) Done() bool {
+	return p == PhaseSucceeded || p == PhaseFailed
+}
+
+// BuildStatus is what the backend reports about a build
+type BuildStatus struct {
+	Phase      Phase
+	Reason     string
+	ExitCode   *int
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+// BuildExecutor starts and controls build containers.
+// Build IDs are backend specific (task ARN, Job name, container name) and
+// are stored on the deployment so later calls reach the same build.
+type BuildExecutor interface {
+	// Name identifies the backend, e.g. "ecs"
+	Name() string
+	// Start launches the build and returns its ID
+	Start(ctx context.Context, spec BuildSpec) (string, error)
+	// Stop terminates a build; stopping a finished build is not an error
+	Stop(ctx context.Context, buildID string) error
+	// Status reports the state of a build
+	Status(ctx context.Context, buildID string) (BuildStatus, error)
+	// Logs streams the raw container output
+	Logs(ctx context.Context, buildID string) (io.ReadCloser, error)
+}
@@ -0,0 +1,316 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// KubernetesConfig configures the Kubernetes Job executor
+type KubernetesConfig struct {
+	// Kubeconfig is a kubeconfig path; empty uses the in-cluster service account
+	Kubeconfig string
+	Namespace  string
+	Image      string
+	// ServiceAccount optionally runs build pods under this account
+	ServiceAccount string
+	// TTLSecondsAfterFinished lets Kubernetes garbage-collect finished Jobs
+	TTLSecondsAfterFinished int32
+	// ActiveDeadlineSeconds kills builds that run longer; 0 means no limit
+	ActiveDeadlineSeconds int64
+}
+
+// KubernetesExecutor runs each build as a batch/v1 Job. The build environment
+// is stored in a Secret owned by the Job, so it is not readable from the Job
+// spec and is deleted together with the Job.
+type KubernetesExecutor struct {
+	client kubernetes.Interface
+	cfg    KubernetesConfig
+}
+
+// NewKubernetesExecutor creates an executor on an existing clientset. Tests
+// pass the client-go fake clientset here.
+func NewKubernetesExecutor(client kubernetes.Interface, cfg KubernetesConfig) *KubernetesExecutor {
+	if cfg.Namespace == "" {
+		cfg.Namespace = "default"
+	}
+	return &KubernetesExecutor{client: client, cfg: cfg}
+}
+
+// NewKubernetesClient builds a clientset from a kubeconfig path, or from the
+// in-cluster configuration when the path is empty
+func NewKubernetesClient(kubeconfig string) (kubernetes.Interface, error) {
+	var (
+		restCfg *rest.Config
+		err     error
+	)
+	if kubeconfig == "" {
+		restCfg, err = rest.InClusterConfig()
+	} else {
+		restCfg, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubernetes config: %w", err)
+	}
+
+	return kubernetes.NewForConfig(restCfg)
+}
+
+func (e *KubernetesExecutor) Name() string {
+	return "kubernetes"
+}
+
+func jobName(deploymentID string) string {
+	return "mini-vercel-build-" + deploymentID
+}
+
+// Start creates the environment Secret and the build Job
+func (e *KubernetesExecutor) Start(ctx context.Context, spec BuildSpec) (string, error) {
+	name := jobName(spec.DeploymentID)
+	labels := map[string]string{
+		"app.kubernetes.io/name":       "mini-vercel-build",
+		"app.kubernetes.io/managed-by": "mini-vercel-api",
+		"mini-vercel.io/deployment":    spec.DeploymentID,
+		"mini-vercel.io/project":       spec.ProjectID,
+	}
+	if spec.Plan != "" {
+		labels["mini-vercel.io/plan"] = spec.Plan
+	}
+	if spec.Preset != "" {
+		labels["mini-vercel.io/build-preset"] = spec.Preset
+	}
+
+	data := make(map[string]string, len(spec.Env))
+	for _, v := range spec.Env {
+		data[v.Name] = v.Value
+	}
+
+	secret, err := e.applySecret(ctx, name, labels, data)
+	if err != nil {
+		return "", err
+	}
+
+	backoffLimit := int32(0)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: e.cfg.ServiceAccount,
+					Containers: []corev1.Container{{
+						Name:  "build",
+						Image: e.cfg.Image,
+						EnvFrom: []corev1.EnvFromSource{{
+							SecretRef: &corev1.SecretEnvSource{
+								LocalObjectReference: corev1.LocalObjectReference{Name: name},
+							},
+						}},
+						Resources: containerResources(spec.Resources),
+					}},
+				},
+			},
+		},
+	}
+	if e.cfg.TTLSecondsAfterFinished > 0 {
+		ttl := e.cfg.TTLSecondsAfterFinished
+		job.Spec.TTLSecondsAfterFinished = &ttl
+	}
+	if e.cfg.ActiveDeadlineSeconds > 0 {
+		deadline := e.cfg.ActiveDeadlineSeconds
+		job.Spec.ActiveDeadlineSeconds = &deadline
+	}
+
+	created, err := e.client.BatchV1().Jobs(e.cfg.Namespace).Create(ctx, job, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		// A retried dispatch of the same deployment
+		created, err = e.existingJob(ctx, name)
+		if err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		e.deleteSecret(name)
+		return "", fmt.Errorf("failed to create build job: %w", err)
+	}
+
+	// Hand the Secret to the Job so both are garbage-collected together
+	secret.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+		Name:       created.Name,
+		UID:        created.UID,
+	}}
+	if _, err := e.client.CoreV1().Secrets(e.cfg.Namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		// The build is running; its token stops working when the deployment
+		// finishes, so a Secret left without an owner holds nothing usable
+		log.Printf("WARN: Failed to set owner of build secret %s: %v", name, err)
+	}
+
+	return name, nil
+}
+
+// applySecret creates the environment Secret, or replaces the data of the
+// one left by an earlier attempt. The dispatcher issues a new build token on
+// every attempt, which a pod that has not started yet reads. Its owner is
+// cleared so that deleting a finished earlier Job does not take the Secret
+// with it.
+func (e *KubernetesExecutor) applySecret(ctx context.Context, name string, labels, data map[string]string) (*corev1.Secret, error) {
+	secrets := e.client.CoreV1().Secrets(e.cfg.Namespace)
+
+	secret, err := secrets.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		StringData: data,
+	}, metav1.CreateOptions{})
+	if err == nil {
+		return secret, nil
+	}
+	if !apierrors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("failed to create build secret: %w", err)
+	}
+
+	secret, err = secrets.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read build secret: %w", err)
+	}
+	secret.Labels = labels
+	secret.OwnerReferences = nil
+	secret.Data = nil
+	secret.StringData = data
+
+	secret, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update build secret: %w", err)
+	}
+	return secret, nil
+}
+
+// existingJob checks a Job left by an earlier attempt. A Job that is
+// pending or running is kept: a pending pod reads the updated Secret, and a
+// running build keeps working with the earlier attempt's token, which stays
+// valid. A finished Job is deleted and an error is returned; the
+// dispatcher's next attempt creates the Job again.
+func (e *KubernetesExecutor) existingJob(ctx context.Context, name string) (*batchv1.Job, error) {
+	job, err := e.client.BatchV1().Jobs(e.cfg.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read build job: %w", err)
+	}
+
+	if job.DeletionTimestamp != nil {
+		return nil, fmt.Errorf("build job %s from an earlier attempt is still being deleted", name)
+	}
+	if job.Status.Succeeded == 0 && job.Status.Failed == 0 {
+		return job, nil
+	}
+
+	if err := e.Stop(ctx, name); err != nil {
+		return nil, fmt.Errorf("failed to delete build job from an earlier attempt: %w", err)
+	}
+	return nil, fmt.Errorf("build job %s from an earlier attempt had finished; it was deleted", name)
+}
+
+// deleteSecret removes the Secret of a Job that could not be created. It
+// has no owner yet, so nothing else would delete it.
+func (e *KubernetesExecutor) deleteSecret(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := e.client.CoreV1().Secrets(e.cfg.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Printf("WARN: Failed to delete build secret %s: %v", name, err)
+	}
+}
+
+// Stop deletes the Job and its pods
+func (e *KubernetesExecutor) Stop(ctx context.Context, buildID string) error {
+	propagation := metav1.DeletePropagationBackground
+	err := e.client.BatchV1().Jobs(e.cfg.Namespace).Delete(ctx, buildID, metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Status maps the Job conditions to a build phase
+func (e *KubernetesExecutor) Status(ctx context.Context, buildID string) (BuildStatus, error) {
+	job, err := e.client.BatchV1().Jobs(e.cfg.Namespace).Get(ctx, buildID, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return BuildStatus{}, ErrBuildNotFound
+	}
+	if err != nil {
+		return BuildStatus{}, err
+	}
+
+	status := BuildStatus{Phase: PhasePending}
+	if job.Status.StartTime != nil {
+		t := job.Status.StartTime.Time
+		status.StartedAt = &t
+	}
+	if job.Status.Active > 0 {
+		status.Phase = PhaseRunning
+	}
+
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			status.Phase = PhaseSucceeded
+		case batchv1.JobFailed:
+			status.Phase = PhaseFailed
+			status.Reason = c.Reason
+		default:
+			continue
+		}
+		finished := c.LastTransitionTime.Time
+		status.FinishedAt = &finished
+	}
+
+	return status, nil
+}
+
+// Logs streams the output of the Job's pod
+func (e *KubernetesExecutor) Logs(ctx context.Context, buildID string) (io.ReadCloser, error) {
+	pods, err := e.client.CoreV1().Pods(e.cfg.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "job-name=" + buildID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, ErrBuildNotFound
+	}
+
+	return e.client.CoreV1().Pods(e.cfg.Namespace).
+		GetLogs(pods.Items[0].Name, &corev1.PodLogOptions{Container: "build", Follow: true}).
+		Stream(ctx)
+}
+
+// containerResources requests and limits the build pod to the preset. ECS
+// CPU units are converted to millicores.
+func containerResources(r *Resources) corev1.ResourceRequirements {
+	if r == nil {
+		return corev1.ResourceRequirements{}
+	}
+
+	list := corev1.ResourceList{
+		corev1.ResourceCPU:    *resource.NewMilliQuantity(int64(r.CPU)*1000/1024, resource.DecimalSI),
+		corev1.ResourceMemory: *resource.NewQuantity(int64(r.MemoryMB)*1024*1024, resource.BinarySI),
+	}
+	return corev1.ResourceRequirements{Requests: list, Limits: list}
+}
@@ -0,0 +1,217 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testNamespace = "builds"
+
+func newTestKubernetesExecutor(objects ...runtime.Object) (*KubernetesExecutor, *fake.Clientset) {
+	client := fake.NewSimpleClientset(objects...)
+	// The fake does not assign UIDs; owner references need one
+	client.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		job := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+		job.UID = types.UID("uid-" + job.Name)
+		return false, nil, nil
+	})
+
+	return NewKubernetesExecutor(client, KubernetesConfig{Namespace: testNamespace, Image: "builder:latest"}), client
+}
+
+func testSpec(token string) BuildSpec {
+	return BuildSpec{
+		DeploymentID: "dep-1",
+		ProjectID:    "proj-1",
+		Env: []EnvVar{
+			{Name: "DEPLOYMENT_ID", Value: "dep-1"},
+			{Name: "BUILD_TOKEN", Value: token},
+		},
+	}
+}
+
+func getSecret(t *testing.T, client *fake.Clientset) *corev1.Secret {
+	t.Helper()
+	secret, err := client.CoreV1().Secrets(testNamespace).Get(context.Background(), jobName("dep-1"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get secret: %v", err)
+	}
+	return secret
+}
+
+func TestKubernetesStartCreatesSecretOwnedByJob(t *testing.T) {
+	e, client := newTestKubernetesExecutor()
+
+	name, err := e.Start(context.Background(), testSpec("mvb_first"))
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if name != jobName("dep-1") {
+		t.Fatalf("build ID = %q, want %q", name, jobName("dep-1"))
+	}
+
+	job, err := client.BatchV1().Jobs(testNamespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	ref := job.Spec.Template.Spec.Containers[0].EnvFrom[0].SecretRef
+	if ref == nil || ref.Name != name {
+		t.Fatalf("job does not read its env from secret %s", name)
+	}
+
+	secret := getSecret(t, client)
+	if got := secret.StringData["BUILD_TOKEN"]; got != "mvb_first" {
+		t.Fatalf("BUILD_TOKEN = %q, want mvb_first", got)
+	}
+	if len(secret.OwnerReferences) != 1 {
+		t.Fatalf("secret has %d owners, want 1", len(secret.OwnerReferences))
+	}
+	owner := secret.OwnerReferences[0]
+	if owner.Kind != "Job" || owner.Name != name || owner.UID != job.UID {
+		t.Fatalf("secret owner = %+v, want job %s (%s)", owner, name, job.UID)
+	}
+}
+
+func TestKubernetesStartReplacesTokenOfPendingJob(t *testing.T) {
+	e, client := newTestKubernetesExecutor()
+
+	if _, err := e.Start(context.Background(), testSpec("mvb_first")); err != nil {
+		t.Fatalf("first Start: %v", err)
+	}
+	// The Job's pod has not started, so it will read the updated Secret
+	if _, err := e.Start(context.Background(), testSpec("mvb_second")); err != nil {
+		t.Fatalf("second Start: %v", err)
+	}
+
+	secret := getSecret(t, client)
+	if got := secret.StringData["BUILD_TOKEN"]; got != "mvb_second" {
+		t.Fatalf("BUILD_TOKEN = %q, want mvb_second", got)
+	}
+	if len(secret.OwnerReferences) != 1 || secret.OwnerReferences[0].UID != types.UID("uid-"+jobName("dep-1")) {
+		t.Fatalf("secret owners = %+v, want the existing job", secret.OwnerReferences)
+	}
+}
+
+func TestKubernetesStartKeepsRunningJob(t *testing.T) {
+	now := metav1.Now()
+	running := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: jobName("dep-1"), Namespace: testNamespace, UID: "uid-running"},
+		Status:     batchv1.JobStatus{StartTime: &now, Active: 1},
+	}
+	e, client := newTestKubernetesExecutor(running)
+
+	// A dispatch that timed out after the Job was created is retried
+	name, err := e.Start(context.Background(), testSpec("mvb_second"))
+	if err != nil {
+		t.Fatalf("retried Start: %v", err)
+	}
+	if name != jobName("dep-1") {
+		t.Fatalf("build ID = %q, want %q", name, jobName("dep-1"))
+	}
+
+	job, err := client.BatchV1().Jobs(testNamespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("running job was deleted: %v", err)
+	}
+	if job.UID != "uid-running" {
+		t.Fatalf("job was replaced")
+	}
+	if owners := getSecret(t, client).OwnerReferences; len(owners) != 1 || owners[0].UID != "uid-running" {
+		t.Fatalf("secret owners = %+v, want the running job", owners)
+	}
+}
+
+func TestKubernetesStartReplacesFinishedJob(t *testing.T) {
+	now := metav1.Now()
+	failed := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: jobName("dep-1"), Namespace: testNamespace},
+		Status:     batchv1.JobStatus{StartTime: &now, Failed: 1},
+	}
+	e, client := newTestKubernetesExecutor(failed)
+
+	if _, err := e.Start(context.Background(), testSpec("mvb_second")); err == nil {
+		t.Fatal("Start succeeded with a finished job")
+	}
+
+	_, err := client.BatchV1().Jobs(testNamespace).Get(context.Background(), jobName("dep-1"), metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("finished job was not deleted: %v", err)
+	}
+	// The Secret is kept for the next attempt, which recreates the Job
+	if got := getSecret(t, client).StringData["BUILD_TOKEN"]; got != "mvb_second" {
+		t.Fatalf("BUILD_TOKEN = %q, want mvb_second", got)
+	}
+
+	if _, err := e.Start(context.Background(), testSpec("mvb_third")); err != nil {
+		t.Fatalf("retried Start: %v", err)
+	}
+	if got := getSecret(t, client).StringData["BUILD_TOKEN"]; got != "mvb_third" {
+		t.Fatalf("BUILD_TOKEN = %q, want mvb_third", got)
+	}
+}
+
+func TestKubernetesStartDeletesSecretWhenJobFails(t *testing.T) {
+	e, client := newTestKubernetesExecutor()
+	client.PrependReactor("create", "jobs", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("quota exceeded")
+	})
+
+	if _, err := e.Start(context.Background(), testSpec("mvb_first")); err == nil {
+		t.Fatal("Start succeeded although the job could not be created")
+	}
+
+	_, err := client.CoreV1().Secrets(testNamespace).Get(context.Background(), jobName("dep-1"), metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("orphaned secret was not deleted: %v", err)
+	}
+}
+
+func TestKubernetesStatus(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name   string
+		status batchv1.JobStatus
+		want   Phase
+	}{
+		{"pending", batchv1.JobStatus{}, PhasePending},
+		{"running", batchv1.JobStatus{StartTime: &now, Active: 1}, PhaseRunning},
+		{"succeeded", batchv1.JobStatus{StartTime: &now, Conditions: []batchv1.JobCondition{
+			{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: now},
+		}}, PhaseSucceeded},
+		{"failed", batchv1.JobStatus{StartTime: &now, Conditions: []batchv1.JobCondition{
+			{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "DeadlineExceeded", LastTransitionTime: now},
+		}}, PhaseFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestKubernetesExecutor(&batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: testNamespace},
+				Status:     tt.status,
+			})
+
+			status, err := e.Status(context.Background(), "build")
+			if err != nil {
+				t.Fatalf("Status: %v", err)
+			}
+			if status.Phase != tt.want {
+				t.Fatalf("phase = %q, want %q", status.Phase, tt.want)
+			}
+		})
+	}
+
+	e, _ := newTestKubernetesExecutor()
+	if _, err := e.Status(context.Background(), "missing"); !errors.Is(err, ErrBuildNotFound) {
+		t.Fatalf("Status of a missing job = %v, want ErrBuildNotFound", err)
+	}
+}
@@ -0,0 +1,439 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/artifacts"
+)
+
+// LogSink receives build output. It is the same entry point the Kafka
+// consumer uses, so status transitions and storage behave identically.
+type LogSink func(ctx context.Context, event buildlog.Event) error
+
+// LocalConfig configures the local process executor
+type LocalConfig struct {
+	// WorkDir holds one temporary workspace per build
+	WorkDir string
+	// OriginDir receives artifacts as
+	// <OriginDir>/<projectID>/<deploymentID>/<file>, the same layout the
+	// builder uses in R2
+	OriginDir string
+	// InstallCommand and BuildCommand run through `sh -c` in the workspace
+	InstallCommand string
+	BuildCommand   string
+	// OutputDir is the build output directory inside the workspace
+	OutputDir string
+	// KeepWorkspace leaves the workspace on disk after the build
+	KeepWorkspace bool
+}
+
+// LocalExecutor runs the build steps of build-project/script.js as local
+// subprocesses: clone (or copy) the source, install, build and copy the
+// output to a local origin directory. It needs no AWS, Kafka or R2, which
+// makes it suitable for development and end-to-end tests.
+type LocalExecutor struct {
+	cfg  LocalConfig
+	sink LogSink
+
+	mu     sync.Mutex
+	builds map[string]*localBuild
+}
+
+type localBuild struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	status BuildStatus
+	output bytes.Buffer
+}
+
+// NewLocalExecutor creates a local executor that reports output to sink
+func NewLocalExecutor(cfg LocalConfig, sink LogSink) *LocalExecutor {
+	if cfg.WorkDir == "" {
+		cfg.WorkDir = filepath.Join(os.TempDir(), "mini-vercel-builds")
+	}
+	if cfg.InstallCommand == "" {
+		cfg.InstallCommand = "npm install"
+	}
+	if cfg.BuildCommand == "" {
+		cfg.BuildCommand = "npm run build"
+	}
+	if cfg.OutputDir == "" {
+		cfg.OutputDir = "dist"
+	}
+
+	return &LocalExecutor{
+		cfg:    cfg,
+		sink:   sink,
+		builds: make(map[string]*localBuild),
+	}
+}
+
+func (e *LocalExecutor) Name() string {
+	return "local"
+}
+
+// Start runs the build in the background. The build outlives ctx; use Stop
+// to cancel it.
+func (e *LocalExecutor) Start(ctx context.Context, spec BuildSpec) (string, error) {
+	if e.cfg.OriginDir == "" {
+		return "", errors.New("local executor has no origin directory")
+	}
+
+	buildID := "local-" + spec.DeploymentID
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if b, ok := e.builds[buildID]; ok {
+		select {
+		case <-b.done:
+		default:
+			// A retried dispatch of the same deployment; the build is already running
+			return buildID, nil
+		}
+	}
+
+	buildCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	b := &localBuild{
+		cancel: cancel,
+		done:   make(chan struct{}),
+		status: BuildStatus{Phase: PhasePending},
+	}
+	e.builds[buildID] = b
+
+	go func() {
+		defer close(b.done)
+		defer cancel()
+		e.run(buildCtx, b, buildID, spec)
+	}()
+
+	return buildID, nil
+}
+
+// Stop cancels a running build and waits for its processes to exit
+func (e *LocalExecutor) Stop(ctx context.Context, buildID string) error {
+	b, ok := e.build(buildID)
+	if !ok {
+		return nil
+	}
+
+	b.cancel()
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status reports the state of a build started by this process
+func (e *LocalExecutor) Status(ctx context.Context, buildID string) (BuildStatus, error) {
+	b, ok := e.build(buildID)
+	if !ok {
+		return BuildStatus{}, ErrBuildNotFound
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status, nil
+}
+
+// Logs returns the output the build has produced so far
+func (e *LocalExecutor) Logs(ctx context.Context, buildID string) (io.ReadCloser, error) {
+	b, ok := e.build(buildID)
+	if !ok {
+		return nil, ErrBuildNotFound
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return io.NopCloser(bytes.NewReader(bytes.Clone(b.output.Bytes()))), nil
+}
+
+// Wait blocks until a build has finished
+func (e *LocalExecutor) Wait(ctx context.Context, buildID string) error {
+	b, ok := e.build(buildID)
+	if !ok {
+		return ErrBuildNotFound
+	}
+
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *LocalExecutor) build(buildID string) (*localBuild, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	b, ok := e.builds[buildID]
+	return b, ok
+}
+
+// run mirrors build-project/script.js, including its INFO/ERROR lines, so
+// the log processor and failure classifier see the same output as from a
+// container build
+func (e *LocalExecutor) run(ctx context.Context, b *localBuild, buildID string, spec BuildSpec) {
+	started := time.Now()
+	b.mu.Lock()
+	b.status.Phase = PhaseRunning
+	b.status.StartedAt = &started
+	b.mu.Unlock()
+
+	env := os.Environ()
+	var source, commit string
+	for _, v := range spec.Env {
+		env = append(env, v.Name+"="+v.Value)
+		switch v.Name {
+		case "GIT_REPOSITORY_URL":
+			source = v.Value
+		case "GIT_COMMIT_SHA":
+			commit = v.Value
+		}
+	}
+
+	workspace := filepath.Join(e.cfg.WorkDir, buildID)
+	if !e.cfg.KeepWorkspace {
+		defer os.RemoveAll(workspace)
+	}
+
+	emit := func(stream buildlog.Stream, line string) {
+		b.mu.Lock()
+		b.output.WriteString(line)
+		b.output.WriteByte('\n')
+		b.mu.Unlock()
+
+		if e.sink == nil {
+			return
+		}
+		event := buildlog.Event{ProjectID: spec.ProjectID, DeploymentID: spec.DeploymentID, Log: line}
+		if stream == buildlog.StreamStderr {
+			event.Stream = string(stream)
+		}
+		if err := e.sink(context.WithoutCancel(ctx), event); err != nil {
+			log.Printf("ERROR: Failed to ingest local build log for deployment %s: %v", spec.DeploymentID, err)
+		}
+	}
+
+	err := e.pipeline(ctx, workspace, source, commit, env, artifacts.Prefix(spec.ProjectID, spec.DeploymentID), emit)
+
+	finished := time.Now()
+	code := 0
+	b.mu.Lock()
+	b.status.FinishedAt = &finished
+	b.status.Phase = PhaseSucceeded
+	if err != nil {
+		code = 1
+		b.status.Phase = PhaseFailed
+		b.status.Reason = err.Error()
+	}
+	b.status.ExitCode = &code
+	b.mu.Unlock()
+}
+
+func (e *LocalExecutor) pipeline(
+	ctx context.Context,
+	workspace, source, commit string,
+	env []string,
+	prefix string,
+	emit func(buildlog.Stream, string),
+) (err error) {
+	emit(buildlog.StreamStdout, "INFO: Starting build pipeline...")
+	defer func() {
+		if err != nil {
+			emit(buildlog.StreamStdout, fmt.Sprintf("ERROR: %s, Pipeline failed.", err))
+			return
+		}
+		emit(buildlog.StreamStdout, "INFO: Pipeline completed successfully.")
+	}()
+
+	if err := os.RemoveAll(workspace); err != nil {
+		return err
+	}
+	if err := e.fetchSource(ctx, source, commit, workspace, env, emit); err != nil {
+		return err
+	}
+
+	emit(buildlog.StreamStdout, "INFO: Running npm install...")
+	if err := e.command(ctx, workspace, env, e.cfg.InstallCommand, emit); err != nil {
+		return err
+	}
+
+	emit(buildlog.StreamStdout, "INFO: Running npm run build...")
+	if err := e.command(ctx, workspace, env, e.cfg.BuildCommand, emit); err != nil {
+		return err
+	}
+
+	emit(buildlog.StreamStdout, "INFO: Build completed. Uploading artifacts...")
+	return e.publish(filepath.Join(workspace, e.cfg.OutputDir), filepath.Join(e.cfg.OriginDir, filepath.FromSlash(prefix)), emit)
+}
+
+// fetchSource clones the repository into workspace and checks out commit,
+// if set. A local directory that is not a git repository is copied instead.
+func (e *LocalExecutor) fetchSource(ctx context.Context, source, commit, workspace string, env []string, emit func(buildlog.Stream, string)) error {
+	if source == "" {
+		return errors.New("GIT_REPOSITORY_URL is not set")
+	}
+
+	if info, err := os.Stat(source); err == nil && info.IsDir() {
+		if _, err := os.Stat(filepath.Join(source, ".git")); errors.Is(err, fs.ErrNotExist) {
+			emit(buildlog.StreamStdout, "Copying "+source)
+			return copyTree(source, workspace)
+		}
+	}
+
+	if commit == "" {
+		cmd := exec.CommandContext(ctx, "git", "clone", "--depth", "1", source, workspace)
+		cmd.Env = env
+		return e.stream(cmd, emit)
+	}
+
+	// The commit may not be the tip of the default branch, so the history
+	// is needed to check it out
+	cmd := exec.CommandContext(ctx, "git", "clone", "--no-checkout", source, workspace)
+	cmd.Env = env
+	if err := e.stream(cmd, emit); err != nil {
+		return err
+	}
+	cmd = exec.CommandContext(ctx, "git", "-C", workspace, "checkout", "--detach", commit)
+	cmd.Env = env
+	return e.stream(cmd, emit)
+}
+
+// command runs a shell command in the workspace
+func (e *LocalExecutor) command(ctx context.Context, dir string, env []string, command string, emit func(buildlog.Stream, string)) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Env = env
+	if err := e.stream(cmd, emit); err != nil {
+		return fmt.Errorf("%s: %w", command, err)
+	}
+	return nil
+}
+
+// stream runs cmd and emits its stdout and stderr line by line
+func (e *LocalExecutor) stream(cmd *exec.Cmd, emit func(buildlog.Stream, string)) error {
+	stdoutR, stdoutW := io.Pipe()
+	stderrR, stderrW := io.Pipe()
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+	// npm leaves child processes behind that keep the output open after a
+	// cancelled command has been killed
+	cmd.WaitDelay = 5 * time.Second
+
+	var wg sync.WaitGroup
+	scan := func(r io.Reader, s buildlog.Stream) {
+		defer wg.Done()
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			emit(s, scanner.Text())
+		}
+		// Keep draining after an oversized line so the command never blocks
+		io.Copy(io.Discard, r)
+	}
+	wg.Add(2)
+	go scan(stdoutR, buildlog.StreamStdout)
+	go scan(stderrR, buildlog.StreamStderr)
+
+	err := cmd.Run()
+	stdoutW.Close()
+	stderrW.Close()
+	wg.Wait()
+
+	return err
+}
+
+// publish copies the build output to the origin, replacing the previous
+// deployment of the project
+func (e *LocalExecutor) publish(outputDir, target string, emit func(buildlog.Stream, string)) error {
+	if info, err := os.Stat(outputDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s folder does not exist. Build may have failed.", filepath.Base(outputDir))
+	}
+
+	if err := os.RemoveAll(target); err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(outputDir, path)
+		if err != nil {
+			return err
+		}
+		if err := copyFile(path, filepath.Join(target, rel)); err != nil {
+			return err
+		}
+		emit(buildlog.StreamStdout, "Uploaded: "+filepath.ToSlash(rel))
+		return nil
+	})
+}
+
+// copyTree copies the regular files and directories under src to dst
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == "node_modules" && path != src {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(dst, rel), 0o755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return copyFile(path, filepath.Join(dst, rel))
+	})
+}
+
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
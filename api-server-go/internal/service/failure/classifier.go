@@ -0,0 +1,72 @@
+// Package failure explains why a build failed by matching its log against a
+// set of pluggable rules.
+package failure
+
+import (
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
+)
+
+const (
+	// excerptBefore and excerptAfter are the lines kept around the matched line
+	excerptBefore = 5
+	excerptAfter  = 14
+)
+
+// Result is the outcome of classifying a failed build
+type Result struct {
+	Reason deployment.FailureReason
+	// Excerpt is the matched line with some context, or the end of the log
+	// when nothing matched
+	Excerpt []string
+}
+
+// Classifier runs matchers over a build log
+type Classifier struct {
+	matchers []Matcher
+}
+
+// NewClassifier creates a classifier. Matchers are tried in order and the
+// first one that matches any line wins; with none, DefaultRules are used.
+func NewClassifier(matchers ...Matcher) *Classifier {
+	if len(matchers) == 0 {
+		matchers = DefaultRules()
+	}
+	return &Classifier{matchers: matchers}
+}
+
+// Classify inspects log lines in chronological order
+func (c *Classifier) Classify(logs []buildlog.LogEvent) Result {
+	lines := make([]Line, len(logs))
+	phase := PhaseSetup
+	for i, e := range logs {
+		if p, ok := phaseMarkers[e.Log]; ok {
+			phase = p
+		}
+		lines[i] = Line{Text: e.Log, Stream: e.Stream, Phase: phase}
+	}
+
+	for _, m := range c.matchers {
+		for i, line := range lines {
+			if reason, ok := m.Match(line); ok {
+				return Result{Reason: reason, Excerpt: excerpt(lines, i-excerptBefore, i+excerptAfter+1)}
+			}
+		}
+	}
+
+	return Result{
+		Reason:  deployment.FailureUnknown,
+		Excerpt: excerpt(lines, len(lines)-(excerptBefore+excerptAfter+1), len(lines)),
+	}
+}
+
+func excerpt(lines []Line, from, to int) []string {
+	from = max(from, 0)
+	to = min(to, len(lines))
+
+	out := make([]string, 0, max(to-from, 0))
+	for _, l := range lines[from:max(to, from)] {
+		out = append(out, l.Text)
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package failure
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
+)
+
+// readLog loads a recorded build log from testdata. Lines starting with
+// "[stderr] " were written to stderr.
+func readLog(t *testing.T, name string) []buildlog.LogEvent {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var events []buildlog.LogEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		e := buildlog.LogEvent{Log: scanner.Text(), Stream: buildlog.StreamStdout}
+		if text, ok := strings.CutPrefix(e.Log, "[stderr] "); ok {
+			e.Log, e.Stream = text, buildlog.StreamStderr
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestClassifyRecordedLogs(t *testing.T) {
+	tests := []struct {
+		log  string
+		want deployment.FailureReason
+		// line is a line the excerpt must contain
+		line string
+	}{
+		{"oom_heap.log", deployment.FailureOutOfMemory, "FATAL ERROR: Reached heap limit Allocation failed - JavaScript heap out of memory"},
+		{"oom_killed.log", deployment.FailureOutOfMemory, "Killed"},
+		{"build_error_mentions_killed.log", deployment.FailureBuildScript, "src/player.ts(42,7): error TS2322: Type 'string' is not assignable to type 'number'."},
+		{"dependency_install.log", deployment.FailureDependencyInstall, "npm error code E404"},
+		{"vite_error.log", deployment.FailureBuildScript, "ERROR: npm exited with code 1, Pipeline failed."},
+		{"missing_output.log", deployment.FailureMissingOutput, "ERROR: dist folder does not exist, Pipeline failed."},
+		{"clone_failed.log", deployment.FailureCloneFailed, "fatal: could not read Username for 'https://github.com': No such device or address"},
+		{"install_timeout.log", deployment.FailureDependencyInstall, "npm error code ETIMEDOUT"},
+		{"unknown.log", deployment.FailureUnknown, "ERROR: Something unexpected happened, Pipeline failed."},
+	}
+
+	c := NewClassifier()
+	for _, tt := range tests {
+		t.Run(tt.log, func(t *testing.T) {
+			got := c.Classify(readLog(t, tt.log))
+			if got.Reason != tt.want {
+				t.Fatalf("Reason = %s, want %s; excerpt:\n%s", got.Reason, tt.want, strings.Join(got.Excerpt, "\n"))
+			}
+			found := false
+			for _, l := range got.Excerpt {
+				if l == tt.line {
+					found = true
+				}
+			}
+			if !found {
+				t.Fatalf("excerpt does not contain %q:\n%s", tt.line, strings.Join(got.Excerpt, "\n"))
+			}
+		})
+	}
+}
+
+func TestOutOfMemoryRuleIgnoresOrdinaryOutput(t *testing.T) {
+	rules := DefaultRules()
+	oom := rules[0]
+
+	for _, text := range []string{
+		"14 enemies killed in the last wave",
+		"Killed 3 of 12 mutants",
+		"worker killed after idle timeout, restarting",
+		"allocation failed for texture atlas, using fallback",
+	} {
+		if reason, ok := oom.Match(Line{Text: text, Phase: PhaseBuild}); ok {
+			t.Errorf("%q matched as %s", text, reason)
+		}
+	}
+
+	for _, text := range []string{
+		"Killed",
+		"Killed\n",
+		"ERROR: npm exited with code 137, Pipeline failed.",
+		"FATAL ERROR: Reached heap limit Allocation failed - JavaScript heap out of memory",
+	} {
+		if _, ok := oom.Match(Line{Text: text, Phase: PhaseBuild}); !ok {
+			t.Errorf("%q did not match", text)
+		}
+	}
+}
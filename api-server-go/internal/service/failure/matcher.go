@@ -0,0 +1,102 @@
+package failure
+
+import (
+	"regexp"
+	"slices"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
+)
+
+// Phase is the step of the build pipeline a log line was written in
+type Phase string
+
+const (
+	PhaseSetup   Phase = "setup"
+	PhaseInstall Phase = "install"
+	PhaseBuild   Phase = "build"
+	PhaseUpload  Phase = "upload"
+)
+
+// phaseMarkers are the lines build-project/script.js writes when it starts a step
+var phaseMarkers = map[string]Phase{
+	"INFO: Running npm install...":                  PhaseInstall,
+	"INFO: Running npm run build...":                PhaseBuild,
+	"INFO: Build completed. Uploading artifacts...": PhaseUpload,
+}
+
+// Line is a build log line as seen by a Matcher
+type Line struct {
+	Text   string
+	Stream buildlog.Stream
+	Phase  Phase
+}
+
+// Matcher recognises the log line that explains a failure
+type Matcher interface {
+	Match(line Line) (deployment.FailureReason, bool)
+}
+
+// MatcherFunc adapts a function to the Matcher interface
+type MatcherFunc func(line Line) (deployment.FailureReason, bool)
+
+// Match calls f(line)
+func (f MatcherFunc) Match(line Line) (deployment.FailureReason, bool) {
+	return f(line)
+}
+
+// Rule matches lines against a regular expression, optionally only within
+// some phases of the pipeline
+type Rule struct {
+	Reason  deployment.FailureReason
+	Pattern *regexp.Regexp
+	// Phases limits the rule to these phases; empty means any phase
+	Phases []Phase
+}
+
+// Match implements Matcher
+func (r Rule) Match(line Line) (deployment.FailureReason, bool) {
+	if len(r.Phases) > 0 && !slices.Contains(r.Phases, line.Phase) {
+		return "", false
+	}
+	if !r.Pattern.MatchString(line.Text) {
+		return "", false
+	}
+	return r.Reason, true
+}
+
+// DefaultRules returns the built-in matchers, most specific first
+func DefaultRules() []Matcher {
+	return []Matcher{
+		Rule{
+			// Only the runtime's own messages and how the builder reports a
+			// killed step: build output mentioning "killed" is not an OOM
+			Reason:  deployment.FailureOutOfMemory,
+			Pattern: regexp.MustCompile(`(?im)JavaScript heap out of memory|Allocation failed - |\bENOMEM\b|exited with code 137\b|^\s*Killed\s*$|exited with code null.*SIGKILL`),
+		},
+		Rule{
+			Reason:  deployment.FailureCloneFailed,
+			Pattern: regexp.MustCompile(`(?im)^fatal: |could not read username|repository .* not found|npm (ERR!|error) enoent.*package\.json`),
+		},
+		Rule{
+			Reason:  deployment.FailureMissingOutput,
+			Pattern: regexp.MustCompile(`(?i)dist folder does not exist|no such file or directory.*\bdist\b`),
+		},
+		Rule{
+			// Network timeouts while installing are dependency failures
+			Reason:  deployment.FailureTimeout,
+			Pattern: regexp.MustCompile(`(?i)\btimed out\b|timeout exceeded|\bETIMEDOUT\b`),
+			Phases:  []Phase{PhaseSetup, PhaseBuild, PhaseUpload},
+		},
+		Rule{
+			Reason:  deployment.FailureDependencyInstall,
+			Pattern: regexp.MustCompile(`(?i)npm (ERR!|error)|\bERESOLVE\b|ERR_PNPM|exited with code [1-9]`),
+			Phases:  []Phase{PhaseInstall},
+		},
+		Rule{
+			Reason:  deployment.FailureBuildScript,
+			Pattern: regexp.MustCompile(`(?i)npm (ERR!|error)|error TS\d+|SyntaxError|\[vite\].*error|build failed|exited with code [1-9]`),
+			Phases:  []Phase{PhaseBuild},
+		},
+	}
+}
@@ -0,0 +1,223 @@
+package gitcredentials
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// uploadPackAdvertisement is the content type of a smart HTTP ref listing
+const uploadPackAdvertisement = "application/x-git-upload-pack-advertisement"
+
+// checkAnonymous checks that an HTTP(S) repository can be cloned without a
+// credential. SSH URLs and local paths are not checked: SSH always needs a
+// key, and local paths only exist where the build runs.
+func (s *Service) checkAnonymous(ctx context.Context, gitURL string) error {
+	if !s.cfg.CheckAccess || !isHTTPURL(gitURL) {
+		return nil
+	}
+	return s.checkHTTP(ctx, gitURL)
+}
+
+// checkHTTP lists the refs of a repository the way git clone starts, over
+// the smart HTTP protocol
+func (s *Service) checkHTTP(ctx context.Context, gitURL string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	infoRefs := strings.TrimSuffix(gitURL, "/") + "/info/refs?service=git-upload-pack"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, infoRefs, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNoAccess, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode == http.StatusOK && strings.HasPrefix(resp.Header.Get("Content-Type"), uploadPackAdvertisement):
+		return nil
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound:
+		// Git hosts answer 401 or 404 for private repositories
+		return fmt.Errorf("%w: the repository is private or does not exist", ErrNoAccess)
+	}
+	return fmt.Errorf("%w: %s is not a git repository (status %d)", ErrNoAccess, gitURL, resp.StatusCode)
+}
+
+// checkSSH authenticates with privateKey and starts git-upload-pack, which
+// fails if the key has no access to the repository
+func (s *Service) checkSSH(ctx context.Context, cloneURL string, privateKey []byte) error {
+	if !s.cfg.CheckAccess {
+		return nil
+	}
+
+	user, addr, path, err := parseSSHURL(cloneURL)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(path, "'") {
+		return fmt.Errorf("%w: unsupported path %q", ErrInvalidURL, path)
+	}
+
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to parse deploy key: %w", err)
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	conn, err := s.cfg.Dial(dialCtx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNoAccess, err)
+	}
+	defer conn.Close()
+	// Bounds the handshake and the ref listing as well
+	conn.SetDeadline(time.Now().Add(s.cfg.Timeout))
+
+	config := &ssh.ClientConfig{
+		User: user,
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		// Only whether the key is accepted matters here; nothing is sent to
+		// the server that would need its identity verified first
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         s.cfg.Timeout,
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		if strings.Contains(err.Error(), "unable to authenticate") {
+			return fmt.Errorf("%w: the deploy key was not accepted; add it to the repository", ErrNoAccess)
+		}
+		return fmt.Errorf("%w: ssh handshake failed: %v", ErrNoAccess, err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := session.Start("git-upload-pack '" + path + "'"); err != nil {
+		return err
+	}
+
+	// The first pkt-line starts with its length as 4 hex digits
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(stdout, header); err != nil {
+		// Waits for the server's error message on stderr
+		session.Wait()
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = "the deploy key has no access to the repository"
+		}
+		return fmt.Errorf("%w: %s", ErrNoAccess, message)
+	}
+	if _, err := strconv.ParseUint(string(header), 16, 16); err != nil {
+		return fmt.Errorf("%w: %s is not a git repository", ErrNoAccess, cloneURL)
+	}
+	return nil
+}
+
+// parseSSHURL splits ssh://[user@]host[:port]/path and scp-like
+// [user@]host:path URLs. The user defaults to git.
+func parseSSHURL(cloneURL string) (user, addr, path string, err error) {
+	user, port := "git", "22"
+	var host string
+
+	if strings.HasPrefix(cloneURL, "ssh://") {
+		u, err := url.Parse(cloneURL)
+		if err != nil {
+			return "", "", "", fmt.Errorf("%w: %v", ErrInvalidURL, err)
+		}
+		if u.User != nil && u.User.Username() != "" {
+			user = u.User.Username()
+		}
+		host = u.Hostname()
+		if u.Port() != "" {
+			port = u.Port()
+		}
+		path = strings.TrimPrefix(u.Path, "/")
+	} else {
+		rest := cloneURL
+		if at := strings.Index(rest, "@"); at >= 0 {
+			user, rest = rest[:at], rest[at+1:]
+		}
+		var ok bool
+		if host, path, ok = strings.Cut(rest, ":"); !ok {
+			return "", "", "", fmt.Errorf("%w: %q", ErrInvalidURL, cloneURL)
+		}
+	}
+
+	if host == "" || path == "" {
+		return "", "", "", fmt.Errorf("%w: %q", ErrInvalidURL, cloneURL)
+	}
+	return user, net.JoinHostPort(host, port), path, nil
+}
+
+// sshCloneURL returns the SSH URL of a repository, converting an HTTPS URL
+// to the scp-like git@host:path form git hosts use for deploy keys
+func sshCloneURL(gitURL string) (string, error) {
+	if strings.HasPrefix(gitURL, "ssh://") || isSCPURL(gitURL) {
+		return gitURL, nil
+	}
+	if !isHTTPURL(gitURL) {
+		return "", fmt.Errorf("%w: deploy keys need a remote repository", ErrInvalidURL)
+	}
+
+	u, err := url.Parse(gitURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	path := strings.Trim(u.Path, "/")
+	if path == "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidURL, gitURL)
+	}
+	return "git@" + u.Hostname() + ":" + path, nil
+}
+
+// httpsCloneURL returns the HTTPS URL of a repository, converting SSH URLs
+func httpsCloneURL(gitURL string) string {
+	if isHTTPURL(gitURL) {
+		return gitURL
+	}
+	_, addr, path, err := parseSSHURL(gitURL)
+	if err != nil {
+		return gitURL
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	return "https://" + host + "/" + path
+}
+
+func isHTTPURL(gitURL string) bool {
+	return strings.HasPrefix(gitURL, "https://") || strings.HasPrefix(gitURL, "http://")
+}
+
+// isSCPURL reports whether gitURL has the scp-like git@host:path syntax
+func isSCPURL(gitURL string) bool {
+	if strings.Contains(gitURL, "://") {
+		return false
+	}
+	at := strings.Index(gitURL, "@")
+	colon := strings.Index(gitURL, ":")
+	return at > 0 && colon > at+1
+}
@@ -0,0 +1,107 @@
+package gitcredentials
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/project"
+)
+
+// linkStateTTL is how long a user has to authorize the GitHub App after
+// starting to link installations
+const linkStateTTL = 10 * time.Minute
+
+// ErrInvalidLinkState is returned when a linking flow is completed with a
+// state that is unknown, expired, used, or was issued to another user
+var ErrInvalidLinkState = errors.New("invalid or expired link state")
+
+// GitHubLink is where a user goes to link GitHub App installations
+type GitHubLink struct {
+	// AuthorizeURL links the installations the user can already access
+	AuthorizeURL string `json:"authorizeUrl"`
+	// InstallURL installs the app on another account first; it is empty if
+	// the app's slug is not configured
+	InstallURL string    `json:"installUrl,omitempty"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// StartGitHubLink starts linking the GitHub App installations userID can
+// access. GitHub redirects the user to the app's callback URL with a code
+// and the state of the returned URLs, which are passed to
+// CompleteGitHubLink.
+func (s *Service) StartGitHubLink(ctx context.Context, userID string) (GitHubLink, error) {
+	if s.github == nil {
+		return GitHubLink{}, ErrGitHubAppDisabled
+	}
+	if !s.github.CanLink() {
+		return GitHubLink{}, ErrLinkingDisabled
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return GitHubLink{}, err
+	}
+	state := base64.RawURLEncoding.EncodeToString(b)
+
+	expiresAt := time.Now().Add(linkStateTTL)
+	if err := s.repo.CreateLinkState(ctx, hashState(state), userID, expiresAt); err != nil {
+		return GitHubLink{}, err
+	}
+
+	return GitHubLink{
+		AuthorizeURL: s.github.AuthorizeURL(state),
+		InstallURL:   s.github.InstallURL(state),
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+// CompleteGitHubLink links the installations of the GitHub user who
+// authorized the app to userID, replacing those linked before. The state
+// must have been issued to userID, so a user cannot be made to link their
+// installations to someone else's account.
+func (s *Service) CompleteGitHubLink(ctx context.Context, userID, state, code string) ([]project.GitHubInstallation, error) {
+	if s.github == nil {
+		return nil, ErrGitHubAppDisabled
+	}
+
+	err := s.repo.ConsumeLinkState(ctx, hashState(state), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidLinkState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	installations, err := s.github.UserInstallations(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list GitHub installations: %w", err)
+	}
+	if err := s.repo.ReplaceInstallations(ctx, userID, installations); err != nil {
+		return nil, err
+	}
+	return s.repo.ListInstallations(ctx, userID)
+}
+
+// GitHubInstallations returns the installations linked to userID
+func (s *Service) GitHubInstallations(ctx context.Context, userID string) ([]project.GitHubInstallation, error) {
+	return s.repo.ListInstallations(ctx, userID)
+}
+
+// UnlinkGitHubInstallation unlinks an installation from userID; builds of
+// their projects in its repositories can no longer clone with github_app
+// credentials. It returns sql.ErrNoRows if it was not linked.
+func (s *Service) UnlinkGitHubInstallation(ctx context.Context, userID string, installationID int64) error {
+	return s.repo.UnlinkInstallation(ctx, userID, installationID)
+}
+
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,196 @@
+package gitcredentials
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/project"
+	repository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/gitcredential"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/gitprovider"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
+)
+
+const testRepoURL = "https://github.com/octo/site"
+
+// newFakeGitHub serves the GitHub endpoints linking and clone tokens use:
+// code "code-1" authorizes a GitHub user with access to installation 7,
+// which covers octo/site
+func newFakeGitHub(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/login/oauth/access_token":
+			r.ParseForm()
+			if r.PostForm.Get("code") != "code-1" {
+				json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "ghu_user"})
+		case r.URL.Path == "/user/installations" && r.Header.Get("Authorization") == "Bearer ghu_user":
+			fmt.Fprint(w, `{"total_count":1,"installations":[{"id":7,"account":{"login":"octo"}}]}`)
+		case r.URL.Path == "/repos/octo/site/installation":
+			fmt.Fprint(w, `{"id":7}`)
+		case r.URL.Path == "/app/installations/7/access_tokens":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token":"ghs_clone","expires_at":%q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"Not Found"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestGitHubLinking links installations through the OAuth flow in a new
+// schema of the database at GITCREDENTIALS_TEST_POSTGRES_DSN and checks
+// that github_app credentials only work for the user who linked them
+func TestGitHubLinking(t *testing.T) {
+	db := openPostgres(t)
+	server := newFakeGitHub(t)
+	ctx := context.Background()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := repository.New(db)
+	github := gitprovider.NewGitHub(gitprovider.GitHubConfig{
+		AppID:        1,
+		PrivateKey:   key,
+		APIURL:       server.URL,
+		WebURL:       server.URL,
+		ClientID:     "Iv1.test",
+		ClientSecret: "secret",
+		Links:        repo,
+	}, server.Client())
+	s := New(repo, nil, github, Config{})
+
+	// Before linking, the app's installation on the repository is not
+	// usable by anyone
+	if _, err := s.Prepare(ctx, "user-1", "proj-1", testRepoURL, project.GitCredentialGitHubApp); !errors.Is(err, ErrNoAccess) {
+		t.Fatalf("Prepare before linking = %v, want ErrNoAccess", err)
+	}
+
+	link, err := s.StartGitHubLink(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("StartGitHubLink: %v", err)
+	}
+	u, err := url.Parse(link.AuthorizeURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := u.Query().Get("state")
+
+	// Another user cannot complete the flow with user-1's state, e.g. after
+	// being sent the authorize URL
+	if _, err := s.CompleteGitHubLink(ctx, "user-2", state, "code-1"); !errors.Is(err, ErrInvalidLinkState) {
+		t.Fatalf("CompleteGitHubLink by another user = %v, want ErrInvalidLinkState", err)
+	}
+
+	installations, err := s.CompleteGitHubLink(ctx, "user-1", state, "code-1")
+	if err != nil {
+		t.Fatalf("CompleteGitHubLink: %v", err)
+	}
+	if len(installations) != 1 || installations[0].ID != 7 || installations[0].Account != "octo" {
+		t.Fatalf("installations = %+v, want installation 7 of octo", installations)
+	}
+
+	// A state is used once
+	if _, err := s.CompleteGitHubLink(ctx, "user-1", state, "code-1"); !errors.Is(err, ErrInvalidLinkState) {
+		t.Fatalf("CompleteGitHubLink with a used state = %v, want ErrInvalidLinkState", err)
+	}
+
+	if _, err := s.Prepare(ctx, "user-1", "proj-1", testRepoURL, project.GitCredentialGitHubApp); err != nil {
+		t.Fatalf("Prepare after linking: %v", err)
+	}
+	// Another tenant's project naming the same repository is refused
+	if _, err := s.Prepare(ctx, "user-2", "proj-2", testRepoURL, project.GitCredentialGitHubApp); !errors.Is(err, ErrNoAccess) {
+		t.Fatalf("Prepare for another user = %v, want ErrNoAccess", err)
+	}
+
+	if err := s.UnlinkGitHubInstallation(ctx, "user-1", 7); err != nil {
+		t.Fatalf("UnlinkGitHubInstallation: %v", err)
+	}
+	if _, err := s.Prepare(ctx, "user-1", "proj-1", testRepoURL, project.GitCredentialGitHubApp); !errors.Is(err, ErrNoAccess) {
+		t.Fatalf("Prepare after unlinking = %v, want ErrNoAccess", err)
+	}
+	if err := s.UnlinkGitHubInstallation(ctx, "user-1", 7); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("UnlinkGitHubInstallation again = %v, want sql.ErrNoRows", err)
+	}
+}
+
+// openPostgres applies every migration in a new schema and returns a
+// connection that uses it. The schema is dropped after the test.
+func openPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("GITCREDENTIALS_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("GITCREDENTIALS_TEST_POSTGRES_DSN is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := "gitcredentialstest_" + strings.ReplaceAll(utils.GenerateUUID(), "-", "")
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Logf("drop schema: %v", err)
+		}
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" {
+		t.Fatalf("GITCREDENTIALS_TEST_POSTGRES_DSN must be a postgres:// URL")
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrations, err := filepath.Glob("../../../migrations/*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(migrations)
+	for _, path := range migrations {
+		migration, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			t.Fatalf("apply %s: %v", filepath.Base(path), err)
+		}
+	}
+
+	return db
+}
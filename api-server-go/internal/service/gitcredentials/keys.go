@@ -0,0 +1,44 @@
+package gitcredentials
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// deployKey is a generated SSH key pair
+type deployKey struct {
+	// privateKey is the OpenSSH PEM of the private key
+	privateKey []byte
+	// publicKey is in authorized_keys format, as git hosts accept it
+	publicKey   string
+	fingerprint string
+}
+
+// generateDeployKey generates an Ed25519 key pair for a project. The comment
+// names the project so the key can be recognized in the repository settings.
+func generateDeployKey(projectID string) (deployKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return deployKey{}, err
+	}
+
+	comment := "mini-vercel-" + projectID
+	block, err := ssh.MarshalPrivateKey(private, comment)
+	if err != nil {
+		return deployKey{}, err
+	}
+	sshPublic, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return deployKey{}, err
+	}
+
+	return deployKey{
+		privateKey:  pem.EncodeToMemory(block),
+		publicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublic))) + " " + comment,
+		fingerprint: ssh.FingerprintSHA256(sshPublic),
+	}, nil
+}
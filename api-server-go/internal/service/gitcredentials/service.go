@@ -0,0 +1,276 @@
+// Package gitcredentials manages how builds authenticate to the repository
+// of a project: anonymously, with a generated SSH deploy key, or with a
+// GitHub App installation token. Builds never get a credential in their
+// environment; they exchange their build token for it while they run.
+package gitcredentials
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/project"
+	repository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/gitcredential"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/encryption"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/gitprovider"
+)
+
+var (
+	// ErrNoAccess is returned when the repository cannot be read with the
+	// credential, e.g. a private repository cloned anonymously
+	ErrNoAccess = errors.New("repository is not accessible")
+	// ErrDeployKeysDisabled is returned when no encryption key is configured
+	ErrDeployKeysDisabled = errors.New("deploy keys are not configured")
+	// ErrGitHubAppDisabled is returned when no GitHub App is configured
+	ErrGitHubAppDisabled = errors.New("the GitHub App is not configured")
+	// ErrLinkingDisabled is returned when the GitHub App has no OAuth
+	// client, so users cannot link its installations
+	ErrLinkingDisabled = gitprovider.ErrLinkingDisabled
+	// ErrNotGitHub is returned for a github_app credential of a repository
+	// that is not on GitHub
+	ErrNotGitHub = errors.New("github_app credentials need a repository on GitHub")
+	// ErrInvalidURL is returned for a repository URL the credential cannot
+	// be used with
+	ErrInvalidURL = errors.New("invalid repository URL")
+)
+
+// BuildCredential is what a build clones the repository with
+type BuildCredential struct {
+	// Type is "none", "ssh" or "token"
+	Type string `json:"type"`
+	// CloneURL is the URL to clone, without credentials
+	CloneURL string `json:"cloneUrl"`
+	// Username and Password are set for "token", for HTTPS basic auth
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// PrivateKey is the OpenSSH private key for "ssh"
+	PrivateKey string     `json:"privateKey,omitempty"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+}
+
+type Config struct {
+	// CheckAccess checks that the repository can be read when a credential
+	// is chosen
+	CheckAccess bool
+	// Timeout bounds one access check
+	Timeout time.Duration
+	// Dial connects to repository hosts; nil uses net.Dialer. Set it to
+	// giturl.Validator.DialContext to keep checks away from internal
+	// addresses.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Service creates, checks and hands out git credentials
+type Service struct {
+	repo   *repository.Repository
+	cipher *encryption.Cipher
+	github *gitprovider.GitHub
+	cfg    Config
+	client *http.Client
+}
+
+// New creates the service. cipher may be nil to disable deploy keys and
+// github may be nil to disable GitHub App credentials.
+func New(repo *repository.Repository, cipher *encryption.Cipher, github *gitprovider.GitHub, cfg Config) *Service {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Dial == nil {
+		var dialer net.Dialer
+		cfg.Dial = dialer.DialContext
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Connections go straight to the repository host, so Dial checks the
+	// host rather than a proxy
+	transport.Proxy = nil
+	transport.DialContext = cfg.Dial
+	return &Service{
+		repo:   repo,
+		cipher: cipher,
+		github: github,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout, Transport: transport},
+	}
+}
+
+// Prepare creates a credential of type t for the repository of a project
+// of userID, to be saved with it. Access is checked where it can be: a new
+// deploy key has not been added to the repository yet, so it is checked
+// with Verify once it has. It returns nil for GitCredentialNone.
+func (s *Service) Prepare(ctx context.Context, userID, projectID, gitURL string, t project.GitCredentialType) (*project.GitCredential, error) {
+	switch t {
+	case project.GitCredentialNone:
+		return nil, s.checkAnonymous(ctx, gitURL)
+
+	case project.GitCredentialGitHubApp:
+		if err := s.checkGitHubApp(ctx, userID, gitURL); err != nil {
+			return nil, err
+		}
+		return &project.GitCredential{ProjectID: projectID, Type: t}, nil
+
+	case project.GitCredentialDeployKey:
+		if s.cipher == nil {
+			return nil, ErrDeployKeysDisabled
+		}
+		if _, err := sshCloneURL(gitURL); err != nil {
+			return nil, err
+		}
+
+		key, err := generateDeployKey(projectID)
+		if err != nil {
+			return nil, err
+		}
+		encrypted, err := s.cipher.Encrypt(key.privateKey, []byte(projectID))
+		if err != nil {
+			return nil, err
+		}
+		return &project.GitCredential{
+			ProjectID:   projectID,
+			Type:        t,
+			PublicKey:   key.publicKey,
+			Fingerprint: key.fingerprint,
+			PrivateKey:  encrypted,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown git credential type %q", t)
+}
+
+// Get returns the credential of a project, or nil if it clones anonymously
+func (s *Service) Get(ctx context.Context, projectID string) (*project.GitCredential, error) {
+	c, err := s.repo.Get(ctx, projectID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Save stores the credential of an existing project, or removes it for nil
+func (s *Service) Save(ctx context.Context, projectID string, c *project.GitCredential) error {
+	if c == nil {
+		return s.repo.Delete(ctx, projectID)
+	}
+	c.ProjectID = projectID
+	return s.repo.Save(ctx, c)
+}
+
+// Verify checks that builds can read the repository of a project of userID
+// with its current credential
+func (s *Service) Verify(ctx context.Context, userID, projectID, gitURL string) error {
+	c, err := s.Get(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return s.checkAnonymous(ctx, gitURL)
+	}
+
+	switch c.Type {
+	case project.GitCredentialGitHubApp:
+		return s.checkGitHubApp(ctx, userID, gitURL)
+	case project.GitCredentialDeployKey:
+		privateKey, err := s.decrypt(*c)
+		if err != nil {
+			return err
+		}
+		cloneURL, err := sshCloneURL(gitURL)
+		if err != nil {
+			return err
+		}
+		return s.checkSSH(ctx, cloneURL, privateKey)
+	}
+	return fmt.Errorf("unknown git credential type %q", c.Type)
+}
+
+// ForBuild returns the credential a build of a project of userID clones
+// with. A GitHub App token is created for each build, only if userID linked
+// the installation covering the repository; it can only read the project's
+// repository, and expires after an hour.
+func (s *Service) ForBuild(ctx context.Context, userID, projectID, gitURL string) (BuildCredential, error) {
+	c, err := s.Get(ctx, projectID)
+	if err != nil {
+		return BuildCredential{}, err
+	}
+	if c == nil {
+		return BuildCredential{Type: "none", CloneURL: gitURL}, nil
+	}
+
+	switch c.Type {
+	case project.GitCredentialGitHubApp:
+		if s.github == nil {
+			return BuildCredential{}, ErrGitHubAppDisabled
+		}
+		repo, ok := s.github.ParseRepository(gitURL)
+		if !ok {
+			return BuildCredential{}, ErrNotGitHub
+		}
+		token, expiresAt, err := s.github.CloneToken(ctx, userID, repo)
+		if err != nil {
+			return BuildCredential{}, err
+		}
+		return BuildCredential{
+			Type:      "token",
+			CloneURL:  httpsCloneURL(gitURL),
+			Username:  "x-access-token",
+			Password:  token,
+			ExpiresAt: &expiresAt,
+		}, nil
+
+	case project.GitCredentialDeployKey:
+		privateKey, err := s.decrypt(*c)
+		if err != nil {
+			return BuildCredential{}, err
+		}
+		cloneURL, err := sshCloneURL(gitURL)
+		if err != nil {
+			return BuildCredential{}, err
+		}
+		return BuildCredential{
+			Type:       "ssh",
+			CloneURL:   cloneURL,
+			PrivateKey: string(privateKey),
+		}, nil
+	}
+	return BuildCredential{}, fmt.Errorf("unknown git credential type %q", c.Type)
+}
+
+func (s *Service) decrypt(c project.GitCredential) ([]byte, error) {
+	if s.cipher == nil {
+		return nil, ErrDeployKeysDisabled
+	}
+	return s.cipher.Decrypt(c.PrivateKey, []byte(c.ProjectID))
+}
+
+// checkGitHubApp checks that userID linked the installation covering the
+// repository. Unlike the other checks it runs even without CheckAccess,
+// since it keeps users from cloning other tenants' repositories.
+func (s *Service) checkGitHubApp(ctx context.Context, userID, gitURL string) error {
+	if s.github == nil {
+		return ErrGitHubAppDisabled
+	}
+	repo, ok := s.github.ParseRepository(gitURL)
+	if !ok {
+		return ErrNotGitHub
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	if err := s.github.CheckAccess(ctx, userID, repo); err != nil {
+		switch {
+		case errors.Is(err, gitprovider.ErrNotInstalled):
+			return fmt.Errorf("%w: the GitHub App is not installed on %s", ErrNoAccess, repo)
+		case errors.Is(err, gitprovider.ErrNotLinked):
+			return fmt.Errorf("%w: link the GitHub App installation covering %s to your account first", ErrNoAccess, repo)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package gitprovider
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitHub API limits
+const (
+	// maxDescription is the longest commit status description GitHub accepts
+	maxDescription = 140
+	// commentsPerPage and maxCommentPages bound the search for an existing
+	// pull request comment
+	commentsPerPage = 100
+	maxCommentPages = 10
+)
+
+// GitHubConfig identifies the GitHub App the API server acts as
+type GitHubConfig struct {
+	AppID      int64
+	PrivateKey *rsa.PrivateKey
+	// APIURL is the REST API root, e.g. https://api.github.com or
+	// https://github.example.com/api/v3 for GitHub Enterprise Server
+	APIURL string
+	// Host is the host of repository URLs, e.g. github.com
+	Host string
+	// WebURL is the root of the web UI, where users authorize the app,
+	// e.g. https://github.com; it defaults to https://{Host}
+	WebURL string
+	// ClientID and ClientSecret are the app's OAuth credentials; users
+	// cannot link installations without them
+	ClientID     string
+	ClientSecret string
+	// Slug is the app's URL name, for the link that installs it
+	Slug string
+	// Links tells which installations users linked. Without it no
+	// repository can be accessed on behalf of a user.
+	Links InstallationLinks
+}
+
+// GitHub reports to repositories the GitHub App is installed on, using
+// short-lived installation tokens. It only acts on a repository for a user
+// who linked the installation covering it.
+type GitHub struct {
+	cfg    GitHubConfig
+	client *http.Client
+
+	mu sync.Mutex
+	// installations maps "owner/name" to the installation that covers it
+	installations map[string]int64
+	tokens        map[int64]installationToken
+}
+
+// NewGitHub creates the GitHub provider. client may be nil to use one with
+// a 10 second timeout.
+func NewGitHub(cfg GitHubConfig, client *http.Client) *GitHub {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = "https://api.github.com"
+	}
+	cfg.APIURL = strings.TrimSuffix(cfg.APIURL, "/")
+	if cfg.Host == "" {
+		cfg.Host = "github.com"
+	}
+	if cfg.WebURL == "" {
+		cfg.WebURL = "https://" + cfg.Host
+	}
+	cfg.WebURL = strings.TrimSuffix(cfg.WebURL, "/")
+
+	return &GitHub{
+		cfg:           cfg,
+		client:        client,
+		installations: make(map[string]int64),
+		tokens:        make(map[int64]installationToken),
+	}
+}
+
+func (g *GitHub) Name() string {
+	return "github"
+}
+
+// ParseRepository accepts https://, ssh:// and scp-like (git@host:owner/repo)
+// URLs on the configured host, with or without a .git suffix
+func (g *GitHub) ParseRepository(gitURL string) (Repository, bool) {
+	var host, path string
+	if u, err := url.Parse(gitURL); err == nil && u.Scheme != "" && u.Host != "" {
+		switch u.Scheme {
+		case "https", "http", "ssh", "git":
+		default:
+			return Repository{}, false
+		}
+		host, path = u.Hostname(), u.Path
+	} else if at := strings.Index(gitURL, "@"); at >= 0 {
+		// scp-like syntax has no scheme: git@github.com:owner/repo.git
+		var ok bool
+		host, path, ok = strings.Cut(gitURL[at+1:], ":")
+		if !ok {
+			return Repository{}, false
+		}
+	} else {
+		return Repository{}, false
+	}
+
+	if !strings.EqualFold(host, g.cfg.Host) {
+		return Repository{}, false
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	owner, name, ok := strings.Cut(path, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return Repository{}, false
+	}
+	return Repository{Owner: owner, Name: name}, true
+}
+
+func (g *GitHub) SetCommitStatus(ctx context.Context, repo Repository, sha string, status CommitStatus) error {
+	description := status.Description
+	if len(description) > maxDescription {
+		description = description[:maxDescription-3] + "..."
+	}
+
+	body := map[string]string{
+		"state":       string(status.State),
+		"context":     status.Context,
+		"description": description,
+	}
+	if status.TargetURL != "" {
+		body["target_url"] = status.TargetURL
+	}
+
+	return g.repoRequest(ctx, repo, http.MethodPost, "/statuses/"+sha, body, nil)
+}
+
+func (g *GitHub) UpsertPullRequestComment(ctx context.Context, repo Repository, number int, marker, body string) error {
+	type comment struct {
+		ID   int64  `json:"id"`
+		Body string `json:"body"`
+	}
+
+	for page := 1; page <= maxCommentPages; page++ {
+		var comments []comment
+		path := fmt.Sprintf("/issues/%d/comments?per_page=%d&page=%d", number, commentsPerPage, page)
+		if err := g.repoRequest(ctx, repo, http.MethodGet, path, nil, &comments); err != nil {
+			return err
+		}
+
+		for _, c := range comments {
+			if strings.Contains(c.Body, marker) {
+				if c.Body == body {
+					return nil
+				}
+				return g.repoRequest(ctx, repo, http.MethodPatch, fmt.Sprintf("/issues/comments/%d", c.ID), map[string]string{"body": body}, nil)
+			}
+		}
+
+		if len(comments) < commentsPerPage {
+			break
+		}
+	}
+
+	return g.repoRequest(ctx, repo, http.MethodPost, fmt.Sprintf("/issues/%d/comments", number), map[string]string{"body": body}, nil)
+}
+
+// repoRequest calls an endpoint under /repos/{owner}/{name} with an
+// installation token
+func (g *GitHub) repoRequest(ctx context.Context, repo Repository, method, path string, body, out any) error {
+	token, err := g.token(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	err = g.do(ctx, method, "/repos/"+repo.String()+path, token, body, out)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+		// The token was revoked; look up the installation again next time
+		g.forgetInstallation(repo)
+	}
+	return err
+}
+
+// APIError is a GitHub API response with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("GitHub API responded with %d: %s", e.StatusCode, e.Message)
+}
+
+// do sends a request to the GitHub API and decodes the JSON response into
+// out, if given
+func (g *GitHub) do(ctx context.Context, method, path, token string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, g.cfg.APIURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(raw, &apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(raw))
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: apiErr.Message}
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
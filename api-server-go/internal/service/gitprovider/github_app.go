@@ -0,0 +1,205 @@
+package gitprovider
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// appJWTLifetime is how long an app JWT is valid; GitHub allows at most 10
+// minutes
+const appJWTLifetime = 9 * time.Minute
+
+// tokenRefreshMargin renews installation tokens this long before they expire
+const tokenRefreshMargin = 5 * time.Minute
+
+// InstallationLinks records which app installations users linked to their
+// account by authorizing the app
+type InstallationLinks interface {
+	IsLinked(ctx context.Context, userID string, installationID int64) (bool, error)
+}
+
+// installationToken is an access token of one GitHub App installation
+type installationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ParsePrivateKey parses the PEM private key of a GitHub App. value is
+// either the PEM itself, with newlines possibly escaped as \n, or the path
+// of the .pem file GitHub generated.
+func ParsePrivateKey(value string) (*rsa.PrivateKey, error) {
+	data := []byte(strings.ReplaceAll(value, `\n`, "\n"))
+	if !strings.Contains(value, "-----BEGIN") {
+		var err error
+		if data, err = os.ReadFile(value); err != nil {
+			return nil, err
+		}
+	}
+	return jwt.ParseRSAPrivateKeyFromPEM(data)
+}
+
+// appJWT returns a JWT that authenticates as the app itself. It is only
+// used to find installations and create their tokens.
+func (g *GitHub) appJWT() (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		// Backdated to allow for clock drift, as GitHub recommends
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(appJWTLifetime)),
+		Issuer:    strconv.FormatInt(g.cfg.AppID, 10),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(g.cfg.PrivateKey)
+}
+
+// token returns an installation token with access to repo. Installations
+// and tokens are cached; tokens are renewed before they expire.
+func (g *GitHub) token(ctx context.Context, repo Repository) (string, error) {
+	installation, err := g.installation(ctx, repo)
+	if err != nil {
+		return "", err
+	}
+
+	g.mu.Lock()
+	cached, ok := g.tokens[installation]
+	g.mu.Unlock()
+	if ok && time.Until(cached.ExpiresAt) > tokenRefreshMargin {
+		return cached.Token, nil
+	}
+
+	appToken, err := g.appJWT()
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+
+	var token installationToken
+	path := fmt.Sprintf("/app/installations/%d/access_tokens", installation)
+	if err := g.do(ctx, http.MethodPost, path, appToken, nil, &token); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			// The app was uninstalled since the installation was looked up
+			g.forgetInstallation(repo)
+			return "", ErrNotInstalled
+		}
+		return "", fmt.Errorf("failed to create installation token: %w", err)
+	}
+
+	g.mu.Lock()
+	g.tokens[installation] = token
+	g.mu.Unlock()
+	return token.Token, nil
+}
+
+// installation returns the ID of the app installation that covers repo
+func (g *GitHub) installation(ctx context.Context, repo Repository) (int64, error) {
+	g.mu.Lock()
+	id, ok := g.installations[repo.String()]
+	g.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	appToken, err := g.appJWT()
+	if err != nil {
+		return 0, fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+
+	var installation struct {
+		ID int64 `json:"id"`
+	}
+	if err := g.do(ctx, http.MethodGet, "/repos/"+repo.String()+"/installation", appToken, nil, &installation); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return 0, ErrNotInstalled
+		}
+		return 0, fmt.Errorf("failed to find installation: %w", err)
+	}
+
+	g.mu.Lock()
+	g.installations[repo.String()] = installation.ID
+	g.mu.Unlock()
+	return installation.ID, nil
+}
+
+// forgetInstallation drops the cached installation of repo and its token,
+// so the next request looks them up again
+func (g *GitHub) forgetInstallation(repo Repository) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if id, ok := g.installations[repo.String()]; ok {
+		delete(g.tokens, id)
+		delete(g.installations, repo.String())
+	}
+}
+
+// UserInstallation returns the installation that covers repo if userID
+// linked it. It returns ErrNotInstalled if the app is not installed on
+// repo and ErrNotLinked if the user did not link its installation, e.g.
+// for another tenant's repository.
+func (g *GitHub) UserInstallation(ctx context.Context, userID string, repo Repository) (int64, error) {
+	installation, err := g.installation(ctx, repo)
+	if err != nil {
+		return 0, err
+	}
+	if g.cfg.Links == nil || userID == "" {
+		return 0, fmt.Errorf("%w: %s", ErrNotLinked, repo)
+	}
+
+	linked, err := g.cfg.Links.IsLinked(ctx, userID, installation)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check installation link: %w", err)
+	}
+	if !linked {
+		return 0, fmt.Errorf("%w: the installation covering %s is not linked", ErrNotLinked, repo)
+	}
+	return installation, nil
+}
+
+// CheckAccess returns ErrNotInstalled or ErrNotLinked unless userID linked
+// the installation that covers repo
+func (g *GitHub) CheckAccess(ctx context.Context, userID string, repo Repository) error {
+	_, err := g.UserInstallation(ctx, userID, repo)
+	return err
+}
+
+// CloneToken creates an installation token that can only read the contents
+// of repo, for a build of a project of userID to clone it with. It is only
+// created if userID linked the installation covering repo. Unlike the
+// tokens the provider uses itself it is not cached, so each build gets its
+// own.
+func (g *GitHub) CloneToken(ctx context.Context, userID string, repo Repository) (string, time.Time, error) {
+	installation, err := g.UserInstallation(ctx, userID, repo)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	appToken, err := g.appJWT()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+
+	body := map[string]any{
+		"repositories": []string{repo.Name},
+		"permissions":  map[string]string{"contents": "read"},
+	}
+	var token installationToken
+	path := fmt.Sprintf("/app/installations/%d/access_tokens", installation)
+	if err := g.do(ctx, http.MethodPost, path, appToken, body, &token); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusUnprocessableEntity) {
+			// Uninstalled, or the installation no longer includes repo
+			g.forgetInstallation(repo)
+			return "", time.Time{}, ErrNotInstalled
+		}
+		return "", time.Time{}, fmt.Errorf("failed to create clone token: %w", err)
+	}
+	return token.Token, token.ExpiresAt, nil
+}
@@ -0,0 +1,129 @@
+package gitprovider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	projectdomain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/project"
+)
+
+// installationsPerPage and maxInstallationPages bound the listing of a
+// user's installations
+const (
+	installationsPerPage = 100
+	maxInstallationPages = 10
+)
+
+var (
+	// ErrLinkingDisabled is returned when the app has no OAuth client, so
+	// users cannot link installations
+	ErrLinkingDisabled = errors.New("the GitHub App has no OAuth client configured")
+	// ErrAuthorizationFailed is returned when GitHub does not accept the
+	// code of an authorization, e.g. one that expired or was used
+	ErrAuthorizationFailed = errors.New("GitHub did not accept the authorization")
+)
+
+// CanLink reports whether users can link installations
+func (g *GitHub) CanLink() bool {
+	return g.cfg.ClientID != "" && g.cfg.ClientSecret != ""
+}
+
+// AuthorizeURL is where a user authorizes the app, so the installations
+// they can access can be listed. GitHub redirects back to the app's
+// callback URL with a code and state.
+func (g *GitHub) AuthorizeURL(state string) string {
+	q := url.Values{"client_id": {g.cfg.ClientID}, "state": {state}}
+	return g.cfg.WebURL + "/login/oauth/authorize?" + q.Encode()
+}
+
+// InstallURL is where a user installs the app on an account. With "Request
+// user authorization (OAuth) during installation" enabled GitHub redirects
+// back like after AuthorizeURL. It is empty if the app's slug is unknown.
+func (g *GitHub) InstallURL(state string) string {
+	if g.cfg.Slug == "" {
+		return ""
+	}
+	q := url.Values{"state": {state}}
+	return g.cfg.WebURL + "/apps/" + url.PathEscape(g.cfg.Slug) + "/installations/new?" + q.Encode()
+}
+
+// UserInstallations exchanges the code of an authorization for a user
+// access token and returns the installations of the app the user can
+// access. The user token is only used for this and is not kept.
+func (g *GitHub) UserInstallations(ctx context.Context, code string) ([]projectdomain.GitHubInstallation, error) {
+	if !g.CanLink() {
+		return nil, ErrLinkingDisabled
+	}
+
+	userToken, err := g.exchangeCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	var installations []projectdomain.GitHubInstallation
+	for page := 1; page <= maxInstallationPages; page++ {
+		var resp struct {
+			TotalCount    int `json:"total_count"`
+			Installations []struct {
+				ID      int64 `json:"id"`
+				Account struct {
+					Login string `json:"login"`
+				} `json:"account"`
+			} `json:"installations"`
+		}
+		path := fmt.Sprintf("/user/installations?per_page=%d&page=%d", installationsPerPage, page)
+		if err := g.do(ctx, http.MethodGet, path, userToken, nil, &resp); err != nil {
+			return nil, fmt.Errorf("failed to list user installations: %w", err)
+		}
+
+		for _, i := range resp.Installations {
+			installations = append(installations, projectdomain.GitHubInstallation{ID: i.ID, Account: i.Account.Login})
+		}
+		if len(resp.Installations) < installationsPerPage || len(installations) >= resp.TotalCount {
+			break
+		}
+	}
+	return installations, nil
+}
+
+// exchangeCode turns the code of an authorization into a user access token
+func (g *GitHub) exchangeCode(ctx context.Context, code string) (string, error) {
+	form := url.Values{
+		"client_id":     {g.cfg.ClientID},
+		"client_secret": {g.cfg.ClientSecret},
+		"code":          {code},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.cfg.WebURL+"/login/oauth/access_token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Errors are reported with a 200 status and an error field
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode != http.StatusOK || json.Unmarshal(raw, &token) != nil {
+		return "", &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(raw))}
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("%w: %s %s", ErrAuthorizationFailed, token.Error, token.ErrorDescription)
+	}
+	return token.AccessToken, nil
+}
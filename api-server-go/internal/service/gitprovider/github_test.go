@@ -0,0 +1,571 @@
+package gitprovider
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testAppID        = 42
+	testInstallation = 7
+	testSHA          = "0123456789abcdef0123456789abcdef01234567"
+	// testUser linked testInstallation; otherUser did not
+	testUser  = "user-1"
+	otherUser = "user-2"
+	// testCode is the OAuth code the fake accepts
+	testCode = "oauth-code"
+)
+
+var testRepo = Repository{Owner: "octo", Name: "site"}
+
+// fakeComment is a pull request comment on the fake GitHub
+type fakeComment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+}
+
+// fakeGitHub serves the parts of the GitHub REST API the provider uses for
+// one app installation covering testRepo
+type fakeGitHub struct {
+	t   *testing.T
+	key *rsa.PrivateKey
+
+	mu       sync.Mutex
+	tokens   []string
+	statuses []map[string]string
+	comments map[int][]fakeComment
+	nextID   int64
+	// calls counts requests by "METHOD path"
+	calls map[string]int
+	// revoked makes the next repository requests fail with 401
+	revoked int
+	// linked maps users to the installations they linked
+	linked map[string][]int64
+	// cloneTokens holds the bodies of clone token requests
+	cloneTokens []map[string]any
+}
+
+func newFakeGitHub(t *testing.T) (*fakeGitHub, *GitHub) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeGitHub{
+		t:        t,
+		key:      key,
+		comments: make(map[int][]fakeComment),
+		nextID:   1000,
+		calls:    make(map[string]int),
+		linked:   map[string][]int64{testUser: {testInstallation}},
+	}
+
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	// A trailing slash is trimmed like one in GITHUB_API_URL
+	return f, NewGitHub(GitHubConfig{
+		AppID:        testAppID,
+		PrivateKey:   key,
+		APIURL:       server.URL + "/",
+		WebURL:       server.URL,
+		ClientID:     "Iv1.test",
+		ClientSecret: "secret",
+		Slug:         "mini-vercel",
+		Links:        f,
+	}, server.Client())
+}
+
+func (f *fakeGitHub) IsLinked(ctx context.Context, userID string, installationID int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range f.linked[userID] {
+		if id == installationID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls[r.Method+" "+r.URL.Path]++
+	if r.URL.Path == "/login/oauth/access_token" {
+		f.serveAccessToken(w, r)
+		return
+	}
+	if r.Header.Get("Accept") != "application/vnd.github+json" || r.Header.Get("X-GitHub-Api-Version") == "" {
+		f.t.Errorf("%s %s without the GitHub media type and API version", r.Method, r.URL.Path)
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	repoPath := "/repos/" + testRepo.String()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == repoPath+"/installation":
+		if !f.appAuthenticated(w, token) {
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int64{"id": testInstallation})
+
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/installation"):
+		if !f.appAuthenticated(w, token) {
+			return
+		}
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+
+	case r.Method == http.MethodPost && r.URL.Path == fmt.Sprintf("/app/installations/%d/access_tokens", testInstallation):
+		if !f.appAuthenticated(w, token) {
+			return
+		}
+		var body map[string]any
+		if json.NewDecoder(r.Body).Decode(&body) == nil {
+			// A clone token, scoped to a repository; it does not replace
+			// the provider's own token
+			f.cloneTokens = append(f.cloneTokens, body)
+			writeJSON(w, http.StatusCreated, installationToken{Token: "ghs_clone", ExpiresAt: time.Now().Add(time.Hour)})
+			return
+		}
+		issued := fmt.Sprintf("ghs_%d", len(f.tokens)+1)
+		f.tokens = append(f.tokens, issued)
+		writeJSON(w, http.StatusCreated, installationToken{Token: issued, ExpiresAt: time.Now().Add(time.Hour)})
+
+	case r.Method == http.MethodGet && r.URL.Path == "/user/installations":
+		if token != "ghu_user" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "Bad credentials"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"total_count": 2,
+			"installations": []map[string]any{
+				{"id": testInstallation, "account": map[string]string{"login": "octo"}},
+				{"id": 8, "account": map[string]string{"login": "octo-org"}},
+			},
+		})
+
+	case strings.HasPrefix(r.URL.Path, repoPath+"/"):
+		if !f.installationAuthenticated(w, token) {
+			return
+		}
+		f.serveRepository(w, r, strings.TrimPrefix(r.URL.Path, repoPath))
+
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+	}
+}
+
+// serveAccessToken exchanges an OAuth code for a user token; like GitHub it
+// reports errors with a 200 status
+func (f *fakeGitHub) serveAccessToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Header.Get("Accept") != "application/json" {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+		return
+	}
+	r.ParseForm()
+	if r.PostForm.Get("client_id") != "Iv1.test" || r.PostForm.Get("client_secret") != "secret" {
+		writeJSON(w, http.StatusOK, map[string]string{"error": "incorrect_client_credentials"})
+		return
+	}
+	if r.PostForm.Get("code") != testCode {
+		writeJSON(w, http.StatusOK, map[string]string{"error": "bad_verification_code", "error_description": "The code passed is incorrect or expired."})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": "ghu_user", "token_type": "bearer"})
+}
+
+// appAuthenticated checks the app JWT of a request
+func (f *fakeGitHub) appAuthenticated(w http.ResponseWriter, token string) bool {
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return &f.key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer(strconv.Itoa(testAppID)))
+	if err != nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "A JSON web token could not be decoded"})
+		return false
+	}
+	return true
+}
+
+// installationAuthenticated checks that a request uses the newest token
+func (f *fakeGitHub) installationAuthenticated(w http.ResponseWriter, token string) bool {
+	if f.revoked > 0 {
+		f.revoked--
+		f.tokens = nil
+	}
+	if len(f.tokens) == 0 || token != f.tokens[len(f.tokens)-1] {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "Bad credentials"})
+		return false
+	}
+	return true
+}
+
+func (f *fakeGitHub) serveRepository(w http.ResponseWriter, r *http.Request, path string) {
+	number, isComments := pathNumber(path, "/issues/", "/comments")
+	id, isComment := pathNumber(path, "/issues/comments/", "")
+
+	switch {
+	case r.Method == http.MethodPost && strings.HasPrefix(path, "/statuses/"):
+		var status map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Problems parsing JSON"})
+			return
+		}
+		status["sha"] = strings.TrimPrefix(path, "/statuses/")
+		f.statuses = append(f.statuses, status)
+		writeJSON(w, http.StatusCreated, status)
+
+	case r.Method == http.MethodGet && isComments:
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		all := f.comments[int(number)]
+		from := min((page-1)*perPage, len(all))
+		writeJSON(w, http.StatusOK, all[from:min(from+perPage, len(all))])
+
+	case r.Method == http.MethodPost && isComments:
+		var c fakeComment
+		json.NewDecoder(r.Body).Decode(&c)
+		f.nextID++
+		c.ID = f.nextID
+		f.comments[int(number)] = append(f.comments[int(number)], c)
+		writeJSON(w, http.StatusCreated, c)
+
+	case r.Method == http.MethodPatch && isComment:
+		var update fakeComment
+		json.NewDecoder(r.Body).Decode(&update)
+		for number, comments := range f.comments {
+			for i := range comments {
+				if comments[i].ID == id {
+					f.comments[number][i].Body = update.Body
+					writeJSON(w, http.StatusOK, f.comments[number][i])
+					return
+				}
+			}
+		}
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+	}
+}
+
+// pathNumber returns the number in a path of the form prefix + number +
+// suffix
+func pathNumber(path, prefix, suffix string) (int64, bool) {
+	if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(path, prefix), suffix), 10, 64)
+	return n, err == nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeGitHub) commentsOn(number int) []fakeComment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeComment(nil), f.comments[number]...)
+}
+
+func (f *fakeGitHub) count(call string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[call]
+}
+
+func TestGitHubSetCommitStatus(t *testing.T) {
+	f, g := newFakeGitHub(t)
+
+	err := g.SetCommitStatus(context.Background(), testRepo, testSHA, CommitStatus{
+		State:       StateSuccess,
+		Context:     "mini-vercel",
+		Description: "Deployment ready",
+		TargetURL:   "https://site.example.com",
+	})
+	if err != nil {
+		t.Fatalf("SetCommitStatus: %v", err)
+	}
+
+	want := map[string]string{
+		"sha":         testSHA,
+		"state":       "success",
+		"context":     "mini-vercel",
+		"description": "Deployment ready",
+		"target_url":  "https://site.example.com",
+	}
+	if len(f.statuses) != 1 || fmt.Sprint(f.statuses[0]) != fmt.Sprint(want) {
+		t.Fatalf("statuses = %v, want [%v]", f.statuses, want)
+	}
+}
+
+func TestGitHubSetCommitStatusTruncatesDescription(t *testing.T) {
+	f, g := newFakeGitHub(t)
+
+	err := g.SetCommitStatus(context.Background(), testRepo, testSHA, CommitStatus{
+		State:       StateFailure,
+		Context:     "mini-vercel",
+		Description: strings.Repeat("x", 200),
+	})
+	if err != nil {
+		t.Fatalf("SetCommitStatus: %v", err)
+	}
+
+	description := f.statuses[0]["description"]
+	if len(description) != maxDescription || !strings.HasSuffix(description, "...") {
+		t.Fatalf("description = %q, want %d characters ending in ...", description, maxDescription)
+	}
+	if _, ok := f.statuses[0]["target_url"]; ok {
+		t.Fatal("an empty target URL was sent")
+	}
+}
+
+func TestGitHubCachesInstallationToken(t *testing.T) {
+	f, g := newFakeGitHub(t)
+
+	for i := 0; i < 3; i++ {
+		if err := g.SetCommitStatus(context.Background(), testRepo, testSHA, CommitStatus{State: StatePending, Context: "mini-vercel"}); err != nil {
+			t.Fatalf("SetCommitStatus: %v", err)
+		}
+	}
+
+	if n := f.count("GET /repos/octo/site/installation"); n != 1 {
+		t.Fatalf("installation looked up %d times, want 1", n)
+	}
+	if n := f.count(fmt.Sprintf("POST /app/installations/%d/access_tokens", testInstallation)); n != 1 {
+		t.Fatalf("%d tokens created, want 1", n)
+	}
+}
+
+func TestGitHubRenewsRevokedToken(t *testing.T) {
+	f, g := newFakeGitHub(t)
+	status := CommitStatus{State: StatePending, Context: "mini-vercel"}
+
+	if err := g.SetCommitStatus(context.Background(), testRepo, testSHA, status); err != nil {
+		t.Fatalf("SetCommitStatus: %v", err)
+	}
+
+	f.mu.Lock()
+	f.revoked = 1
+	f.mu.Unlock()
+
+	err := g.SetCommitStatus(context.Background(), testRepo, testSHA, status)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "Bad credentials" {
+		t.Fatalf("SetCommitStatus with a revoked token = %v, want a 401 APIError", err)
+	}
+
+	// The next report looks up the installation and creates a new token
+	if err := g.SetCommitStatus(context.Background(), testRepo, testSHA, status); err != nil {
+		t.Fatalf("SetCommitStatus after revocation: %v", err)
+	}
+	if n := f.count("GET /repos/octo/site/installation"); n != 2 {
+		t.Fatalf("installation looked up %d times, want 2", n)
+	}
+}
+
+func TestGitHubNotInstalled(t *testing.T) {
+	f, g := newFakeGitHub(t)
+	other := Repository{Owner: "someone", Name: "else"}
+
+	err := g.SetCommitStatus(context.Background(), other, testSHA, CommitStatus{State: StatePending, Context: "mini-vercel"})
+	if !errors.Is(err, ErrNotInstalled) {
+		t.Fatalf("SetCommitStatus = %v, want ErrNotInstalled", err)
+	}
+	if len(f.statuses) != 0 {
+		t.Fatalf("statuses = %v, want none", f.statuses)
+	}
+}
+
+func TestGitHubUpsertPullRequestComment(t *testing.T) {
+	f, g := newFakeGitHub(t)
+	ctx := context.Background()
+	marker := commentMarker("proj-1")
+
+	// Another project's comment on the same pull request is left alone
+	if err := g.UpsertPullRequestComment(ctx, testRepo, 12, commentMarker("proj-2"), commentMarker("proj-2")+"\nother"); err != nil {
+		t.Fatalf("UpsertPullRequestComment: %v", err)
+	}
+
+	if err := g.UpsertPullRequestComment(ctx, testRepo, 12, marker, marker+"\nBuilding"); err != nil {
+		t.Fatalf("UpsertPullRequestComment: %v", err)
+	}
+	if err := g.UpsertPullRequestComment(ctx, testRepo, 12, marker, marker+"\nReady"); err != nil {
+		t.Fatalf("UpsertPullRequestComment: %v", err)
+	}
+
+	comments := f.commentsOn(12)
+	if len(comments) != 2 {
+		t.Fatalf("comments = %+v, want 2", comments)
+	}
+	if comments[0].Body != commentMarker("proj-2")+"\nother" || comments[1].Body != marker+"\nReady" {
+		t.Fatalf("comments = %+v", comments)
+	}
+
+	// An unchanged comment is not updated
+	if err := g.UpsertPullRequestComment(ctx, testRepo, 12, marker, marker+"\nReady"); err != nil {
+		t.Fatalf("UpsertPullRequestComment: %v", err)
+	}
+	if n := f.count("PATCH /repos/octo/site/issues/comments/1002"); n != 1 {
+		t.Fatalf("comment updated %d times, want 1", n)
+	}
+}
+
+func TestGitHubUpsertPullRequestCommentPaginates(t *testing.T) {
+	f, g := newFakeGitHub(t)
+	marker := commentMarker("proj-1")
+
+	f.mu.Lock()
+	for i := 0; i < commentsPerPage+5; i++ {
+		f.nextID++
+		body := "looks good"
+		if i == commentsPerPage+2 {
+			body = marker + "\nBuilding"
+		}
+		f.comments[3] = append(f.comments[3], fakeComment{ID: f.nextID, Body: body})
+	}
+	f.mu.Unlock()
+
+	if err := g.UpsertPullRequestComment(context.Background(), testRepo, 3, marker, marker+"\nReady"); err != nil {
+		t.Fatalf("UpsertPullRequestComment: %v", err)
+	}
+
+	comments := f.commentsOn(3)
+	if len(comments) != commentsPerPage+5 {
+		t.Fatalf("%d comments, want %d: the comment on page 2 was not found", len(comments), commentsPerPage+5)
+	}
+	if got := comments[commentsPerPage+2].Body; got != marker+"\nReady" {
+		t.Fatalf("comment = %q, want it updated", got)
+	}
+}
+
+func TestGitHubParseRepository(t *testing.T) {
+	g := NewGitHub(GitHubConfig{}, nil)
+
+	tests := []struct {
+		url  string
+		want Repository
+		ok   bool
+	}{
+		{"https://github.com/octo/site", testRepo, true},
+		{"https://github.com/octo/site.git", testRepo, true},
+		{"https://GitHub.com/octo/site/", testRepo, true},
+		{"ssh://git@github.com/octo/site.git", testRepo, true},
+		{"git@github.com:octo/site.git", testRepo, true},
+		{"https://gitlab.com/octo/site", Repository{}, false},
+		{"https://github.com/octo", Repository{}, false},
+		{"https://github.com/octo/site/tree/main", Repository{}, false},
+		{"file:///github.com/octo/site", Repository{}, false},
+		{"/srv/site", Repository{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := g.ParseRepository(tt.url)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseRepository(%q) = %v, %v, want %v, %v", tt.url, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGitHubCloneTokenNeedsLinkedInstallation(t *testing.T) {
+	f, g := newFakeGitHub(t)
+
+	token, expiresAt, err := g.CloneToken(context.Background(), testUser, testRepo)
+	if err != nil {
+		t.Fatalf("CloneToken: %v", err)
+	}
+	if token != "ghs_clone" || expiresAt.IsZero() {
+		t.Fatalf("CloneToken = %q, %v", token, expiresAt)
+	}
+	body := f.cloneTokens[0]
+	if fmt.Sprint(body["repositories"]) != "[site]" || fmt.Sprint(body["permissions"]) != "map[contents:read]" {
+		t.Fatalf("clone token request = %v, want read access to site only", body)
+	}
+
+	// The app is installed on the repository, but another user's project
+	// naming it gets no token
+	if _, _, err := g.CloneToken(context.Background(), otherUser, testRepo); !errors.Is(err, ErrNotLinked) {
+		t.Fatalf("CloneToken for %s = %v, want ErrNotLinked", otherUser, err)
+	}
+	if _, _, err := g.CloneToken(context.Background(), "", testRepo); !errors.Is(err, ErrNotLinked) {
+		t.Fatalf("CloneToken without a user = %v, want ErrNotLinked", err)
+	}
+	if len(f.cloneTokens) != 1 {
+		t.Fatalf("%d clone tokens created, want 1", len(f.cloneTokens))
+	}
+
+	if err := g.CheckAccess(context.Background(), testUser, Repository{Owner: "someone", Name: "else"}); !errors.Is(err, ErrNotInstalled) {
+		t.Fatalf("CheckAccess of a repository without the app = %v, want ErrNotInstalled", err)
+	}
+}
+
+func TestGitHubCloneTokenWithoutLinks(t *testing.T) {
+	f, _ := newFakeGitHub(t)
+	g := NewGitHub(GitHubConfig{AppID: testAppID, PrivateKey: f.key, APIURL: "http://127.0.0.1:1"}, nil)
+	g.installations[testRepo.String()] = testInstallation
+
+	if _, _, err := g.CloneToken(context.Background(), testUser, testRepo); !errors.Is(err, ErrNotLinked) {
+		t.Fatalf("CloneToken without installation links = %v, want ErrNotLinked", err)
+	}
+}
+
+func TestGitHubLinkURLs(t *testing.T) {
+	g := NewGitHub(GitHubConfig{ClientID: "Iv1.test", ClientSecret: "secret", Slug: "mini-vercel"}, nil)
+
+	if got, want := g.AuthorizeURL("s t"), "https://github.com/login/oauth/authorize?client_id=Iv1.test&state=s+t"; got != want {
+		t.Errorf("AuthorizeURL = %q, want %q", got, want)
+	}
+	if got, want := g.InstallURL("abc"), "https://github.com/apps/mini-vercel/installations/new?state=abc"; got != want {
+		t.Errorf("InstallURL = %q, want %q", got, want)
+	}
+
+	g = NewGitHub(GitHubConfig{Host: "github.example.com"}, nil)
+	if g.CanLink() {
+		t.Error("CanLink without an OAuth client")
+	}
+	if got := g.InstallURL("abc"); got != "" {
+		t.Errorf("InstallURL without a slug = %q", got)
+	}
+	if got := g.AuthorizeURL("abc"); !strings.HasPrefix(got, "https://github.example.com/login/oauth/authorize?") {
+		t.Errorf("AuthorizeURL on GitHub Enterprise Server = %q", got)
+	}
+}
+
+func TestGitHubUserInstallations(t *testing.T) {
+	_, g := newFakeGitHub(t)
+
+	installations, err := g.UserInstallations(context.Background(), testCode)
+	if err != nil {
+		t.Fatalf("UserInstallations: %v", err)
+	}
+	if len(installations) != 2 || installations[0].ID != testInstallation || installations[0].Account != "octo" || installations[1].Account != "octo-org" {
+		t.Fatalf("installations = %+v", installations)
+	}
+
+	if _, err := g.UserInstallations(context.Background(), "used-code"); !errors.Is(err, ErrAuthorizationFailed) {
+		t.Fatalf("UserInstallations with a bad code = %v, want ErrAuthorizationFailed", err)
+	}
+
+	g = NewGitHub(GitHubConfig{}, nil)
+	if _, err := g.UserInstallations(context.Background(), testCode); !errors.Is(err, ErrLinkingDisabled) {
+		t.Fatalf("UserInstallations without an OAuth client = %v, want ErrLinkingDisabled", err)
+	}
+}
@@ -0,0 +1,66 @@
+// Package gitprovider reports deployments back to the git host of their
+// repository: a status on the commit that was built and a comment on its
+// pull request. GitHub is the first provider.
+package gitprovider
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotInstalled is returned when the provider has no access to a
+// repository, e.g. the GitHub App is not installed on it
+var ErrNotInstalled = errors.New("git provider integration is not installed on the repository")
+
+// ErrNotLinked is returned when the owner of a project has not linked the
+// installation that covers its repository to their account, so the
+// platform may not act on the repository for them
+var ErrNotLinked = errors.New("git provider installation is not linked to the project owner")
+
+// State is the state of a commit status
+type State string
+
+const (
+	StatePending State = "pending"
+	StateSuccess State = "success"
+	StateFailure State = "failure"
+	StateError   State = "error"
+)
+
+// Repository identifies a repository on a provider
+type Repository struct {
+	Owner string
+	Name  string
+}
+
+func (r Repository) String() string {
+	return r.Owner + "/" + r.Name
+}
+
+// CommitStatus is the status of a deployment shown on its commit
+type CommitStatus struct {
+	State State
+	// Context names the check; a new status replaces the previous one with
+	// the same context
+	Context     string
+	Description string
+	// TargetURL is linked from the status; may be empty
+	TargetURL string
+}
+
+// Provider is a git host the API server reports deployments to
+type Provider interface {
+	// Name identifies the provider, e.g. "github"
+	Name() string
+	// ParseRepository returns the repository a git URL points to, and false
+	// if the URL is not hosted by this provider
+	ParseRepository(gitURL string) (Repository, bool)
+	// CheckAccess returns ErrNotInstalled or ErrNotLinked unless the
+	// provider may act on repo for the projects of userID
+	CheckAccess(ctx context.Context, userID string, repo Repository) error
+	// SetCommitStatus sets the status of a commit
+	SetCommitStatus(ctx context.Context, repo Repository, sha string, status CommitStatus) error
+	// UpsertPullRequestComment updates the comment of a pull request that
+	// contains marker to body, or creates it. body must contain marker.
+	UpsertPullRequestComment(ctx context.Context, repo Repository, number int, marker, body string) error
+}
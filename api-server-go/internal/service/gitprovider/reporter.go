@@ -0,0 +1,223 @@
+package gitprovider
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	deploymentdomain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/event"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/eventbus"
+)
+
+// lockStripes is how many locks serialize reports; a deployment always uses
+// the same one
+const lockStripes = 64
+
+type Config struct {
+	// StatusContext names the commit status, e.g. "mini-vercel"
+	StatusContext string
+	// PreviewURL is the URL of a ready deployment; {subdomain} is replaced
+	// with the project's subdomain
+	PreviewURL string
+	// Comments enables the pull request comment
+	Comments bool
+	// MaxAttempts is how often a report is tried before it is given up;
+	// the next transition of the deployment reports again
+	MaxAttempts  int
+	RetryBackoff time.Duration
+}
+
+// CommitReports reads what is reported about a deployment;
+// *repository.Repository of internal/repository/deployment implements it
+type CommitReports interface {
+	// GetCommitReport returns sql.ErrNoRows for deployments that do not
+	// exist or were not triggered from a commit
+	GetCommitReport(ctx context.Context, deploymentID string) (deploymentdomain.CommitReport, error)
+}
+
+// Reporter reports deployment status transitions to the git provider of
+// the project's repository. It consumes the domain events topic.
+type Reporter struct {
+	deployments CommitReports
+	providers   []Provider
+	cfg         Config
+	locks       [lockStripes]sync.Mutex
+}
+
+// New creates a reporter. A repository is reported to the first provider
+// that recognizes its URL.
+func New(deployments CommitReports, providers []Provider, cfg Config) *Reporter {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 1
+	}
+	return &Reporter{
+		deployments: deployments,
+		providers:   providers,
+		cfg:         cfg,
+	}
+}
+
+// Handle reports the deployment of a deployment.* event. Other events and
+// deployments without a commit are acknowledged without a report.
+func (r *Reporter) Handle(ctx context.Context, msg eventbus.Message, ack eventbus.AckFunc) error {
+	var e event.Event
+	if err := json.Unmarshal(msg.Value, &e); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(string(e.Type), "deployment.") {
+		ack(nil)
+		return nil
+	}
+
+	var data event.DeploymentData
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return err
+	}
+	if data.CommitSHA == "" {
+		ack(nil)
+		return nil
+	}
+
+	// A failed report is not redelivered, so retries happen here
+	for attempt := 1; ; attempt++ {
+		err := r.Report(ctx, data.DeploymentID)
+		if err == nil || errors.Is(err, ErrNotInstalled) || errors.Is(err, ErrNotLinked) {
+			break
+		}
+		if attempt >= r.cfg.MaxAttempts || ctx.Err() != nil {
+			log.Printf("ERROR: failed to report deployment %s to git provider: %v", data.DeploymentID, err)
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(r.cfg.RetryBackoff << (attempt - 1)):
+		}
+	}
+
+	ack(nil)
+	return nil
+}
+
+// Flush has nothing to do; reports are acknowledged once sent
+func (r *Reporter) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Report sends the current status of a deployment to its git provider.
+// Events may be handled out of order, so the status is read from the
+// database rather than taken from the event, and reports of one deployment
+// are serialized.
+func (r *Reporter) Report(ctx context.Context, deploymentID string) error {
+	lock := r.lock(deploymentID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	c, err := r.deployments.GetCommitReport(ctx, deploymentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Deleted, or not triggered from a commit
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	provider, repo, ok := r.provider(c.GitURL)
+	if !ok {
+		return nil
+	}
+	// A project may name any repository; only those of installations its
+	// owner linked are reported to
+	if err := provider.CheckAccess(ctx, c.UserID, repo); err != nil {
+		return err
+	}
+
+	status := r.commitStatus(c)
+	if err := provider.SetCommitStatus(ctx, repo, c.CommitSHA, status); err != nil {
+		return fmt.Errorf("failed to set %s commit status on %s: %w", provider.Name(), repo, err)
+	}
+
+	if r.cfg.Comments && c.PullRequest != nil {
+		marker := commentMarker(c.ProjectID)
+		body := r.comment(c, status, marker)
+		if err := provider.UpsertPullRequestComment(ctx, repo, *c.PullRequest, marker, body); err != nil {
+			return fmt.Errorf("failed to comment on %s pull request %s#%d: %w", provider.Name(), repo, *c.PullRequest, err)
+		}
+	}
+	return nil
+}
+
+func (r *Reporter) lock(deploymentID string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(deploymentID))
+	return &r.locks[h.Sum32()%lockStripes]
+}
+
+func (r *Reporter) provider(gitURL string) (Provider, Repository, bool) {
+	for _, p := range r.providers {
+		if repo, ok := p.ParseRepository(gitURL); ok {
+			return p, repo, true
+		}
+	}
+	return nil, Repository{}, false
+}
+
+// commitStatus maps a deployment status to the status of its commit
+func (r *Reporter) commitStatus(c deploymentdomain.CommitReport) CommitStatus {
+	status := CommitStatus{Context: r.cfg.StatusContext}
+
+	switch c.Status {
+	case deploymentdomain.InProgress:
+		status.State, status.Description = StatePending, "Building"
+	case deploymentdomain.Ready:
+		status.State, status.Description = StateSuccess, "Deployment ready"
+		status.TargetURL = r.previewURL(c)
+	case deploymentdomain.Fail:
+		status.State, status.Description = StateFailure, "Build failed"
+	case deploymentdomain.TimedOut:
+		status.State, status.Description = StateFailure, "Build timed out"
+	case deploymentdomain.Canceled:
+		status.State, status.Description = StateError, "Deployment canceled"
+	default:
+		status.State, status.Description = StatePending, "Deployment queued"
+	}
+	return status
+}
+
+func (r *Reporter) previewURL(c deploymentdomain.CommitReport) string {
+	if r.cfg.PreviewURL == "" {
+		return ""
+	}
+	return strings.ReplaceAll(r.cfg.PreviewURL, "{subdomain}", c.SubDomain)
+}
+
+// commentMarker identifies the comment of a project, so projects built
+// from the same repository each keep their own comment
+func commentMarker(projectID string) string {
+	return "<!-- mini-vercel:project:" + projectID + " -->"
+}
+
+// comment renders the pull request comment for the latest deployment
+func (r *Reporter) comment(c deploymentdomain.CommitReport, status CommitStatus, marker string) string {
+	preview := "-"
+	if status.TargetURL != "" {
+		preview = fmt.Sprintf("[%s](%s)", status.TargetURL, status.TargetURL)
+	}
+
+	// GitHub links and shortens a full commit hash
+	var b strings.Builder
+	b.WriteString(marker + "\n")
+	fmt.Fprintf(&b, "**%s** deployment `%s` of commit %s\n\n", c.ProjectName, c.DeploymentID, c.CommitSHA)
+	b.WriteString("| Status | Preview |\n")
+	b.WriteString("|--------|---------|\n")
+	fmt.Fprintf(&b, "| %s | %s |\n", status.Description, preview)
+	return b.String()
+}
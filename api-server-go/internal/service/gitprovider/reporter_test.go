@@ -0,0 +1,229 @@
+package gitprovider
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	deploymentdomain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/event"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/eventbus"
+)
+
+// fakeCommitReports holds commit reports by deployment ID
+type fakeCommitReports struct {
+	mu      sync.Mutex
+	reports map[string]deploymentdomain.CommitReport
+}
+
+func (f *fakeCommitReports) GetCommitReport(ctx context.Context, deploymentID string) (deploymentdomain.CommitReport, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.reports[deploymentID]
+	if !ok {
+		return c, sql.ErrNoRows
+	}
+	return c, nil
+}
+
+func (f *fakeCommitReports) setStatus(deploymentID string, status deploymentdomain.Status) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.reports[deploymentID]
+	c.Status = status
+	f.reports[deploymentID] = c
+}
+
+func newTestReporter(t *testing.T, pullRequest *int) (*fakeGitHub, *fakeCommitReports, *Reporter) {
+	t.Helper()
+
+	f, g := newFakeGitHub(t)
+	reports := &fakeCommitReports{reports: map[string]deploymentdomain.CommitReport{
+		"dep-1": {
+			DeploymentID: "dep-1",
+			Status:       deploymentdomain.Queued,
+			CommitSHA:    testSHA,
+			PullRequest:  pullRequest,
+			ProjectID:    "proj-1",
+			ProjectName:  "site",
+			UserID:       testUser,
+			GitURL:       "https://github.com/octo/site.git",
+			SubDomain:    "brave-otter",
+		},
+	}}
+	r := New(reports, []Provider{g}, Config{
+		StatusContext: "mini-vercel",
+		PreviewURL:    "https://{subdomain}.example.com",
+		Comments:      true,
+		MaxAttempts:   1,
+	})
+	return f, reports, r
+}
+
+// deploymentEvent renders a deployment event as published on the bus
+func deploymentEvent(t *testing.T, typ event.Type, data event.DeploymentData) eventbus.Message {
+	t.Helper()
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := json.Marshal(event.Event{ID: "evt-1", Type: typ, Version: 1, ProjectID: data.ProjectID, Data: raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return eventbus.Message{Value: value}
+}
+
+func TestReporterReportsStatusTransitions(t *testing.T) {
+	pullRequest := 12
+	f, reports, r := newTestReporter(t, &pullRequest)
+	ctx := context.Background()
+
+	tests := []struct {
+		status     deploymentdomain.Status
+		state      string
+		targetURL  string
+		commentHas string
+	}{
+		{deploymentdomain.Queued, "pending", "", "Deployment queued"},
+		{deploymentdomain.InProgress, "pending", "", "Building"},
+		{deploymentdomain.Ready, "success", "https://brave-otter.example.com", "[https://brave-otter.example.com](https://brave-otter.example.com)"},
+	}
+
+	for i, tt := range tests {
+		reports.setStatus("dep-1", tt.status)
+		if err := r.Report(ctx, "dep-1"); err != nil {
+			t.Fatalf("Report %s: %v", tt.status, err)
+		}
+
+		status := f.statuses[i]
+		if status["state"] != tt.state || status["target_url"] != tt.targetURL || status["sha"] != testSHA || status["context"] != "mini-vercel" {
+			t.Fatalf("status after %s = %v, want %s with target %q", tt.status, status, tt.state, tt.targetURL)
+		}
+
+		// One comment per project, updated on every transition
+		comments := f.commentsOn(pullRequest)
+		if len(comments) != 1 || !strings.HasPrefix(comments[0].Body, commentMarker("proj-1")) || !strings.Contains(comments[0].Body, tt.commentHas) {
+			t.Fatalf("comments after %s = %+v, want one containing %q", tt.status, comments, tt.commentHas)
+		}
+	}
+}
+
+func TestReporterMapsFailures(t *testing.T) {
+	tests := []struct {
+		status deploymentdomain.Status
+		state  string
+	}{
+		{deploymentdomain.Fail, "failure"},
+		{deploymentdomain.TimedOut, "failure"},
+		{deploymentdomain.Canceled, "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			f, reports, r := newTestReporter(t, nil)
+			reports.setStatus("dep-1", tt.status)
+
+			if err := r.Report(context.Background(), "dep-1"); err != nil {
+				t.Fatalf("Report: %v", err)
+			}
+			if len(f.statuses) != 1 || f.statuses[0]["state"] != tt.state {
+				t.Fatalf("statuses = %v, want one %s", f.statuses, tt.state)
+			}
+			// Without a pull request nothing is commented
+			if n := f.count("GET /repos/octo/site/issues/0/comments"); n != 0 || len(f.comments) != 0 {
+				t.Fatalf("commented on a deployment without a pull request")
+			}
+		})
+	}
+}
+
+func TestReporterHandleSkipsUnreportedEvents(t *testing.T) {
+	f, _, r := newTestReporter(t, nil)
+
+	messages := []eventbus.Message{
+		deploymentEvent(t, event.ProjectCreated, event.DeploymentData{ProjectID: "proj-1"}),
+		// Not triggered from a commit
+		deploymentEvent(t, event.DeploymentReady, event.DeploymentData{DeploymentID: "dep-2", ProjectID: "proj-1"}),
+		// Deleted since the event was written
+		deploymentEvent(t, event.DeploymentReady, event.DeploymentData{DeploymentID: "deleted", ProjectID: "proj-1", CommitSHA: testSHA}),
+	}
+	for _, msg := range messages {
+		acked := false
+		if err := r.Handle(context.Background(), msg, func(err error) { acked = err == nil }); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+		if !acked {
+			t.Fatal("message was not acknowledged")
+		}
+	}
+
+	if len(f.statuses) != 0 {
+		t.Fatalf("statuses = %v, want none", f.statuses)
+	}
+}
+
+func TestReporterHandleReportsDeploymentEvent(t *testing.T) {
+	f, reports, r := newTestReporter(t, nil)
+	reports.setStatus("dep-1", deploymentdomain.Ready)
+
+	msg := deploymentEvent(t, event.DeploymentReady, event.DeploymentData{DeploymentID: "dep-1", ProjectID: "proj-1", CommitSHA: testSHA})
+	acked := false
+	if err := r.Handle(context.Background(), msg, func(err error) { acked = err == nil }); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if !acked {
+		t.Fatal("message was not acknowledged")
+	}
+	if len(f.statuses) != 1 || f.statuses[0]["state"] != "success" {
+		t.Fatalf("statuses = %v, want one success", f.statuses)
+	}
+}
+
+func TestReporterIgnoresOtherProviders(t *testing.T) {
+	f, reports, r := newTestReporter(t, nil)
+	reports.mu.Lock()
+	c := reports.reports["dep-1"]
+	c.GitURL = "https://gitlab.com/octo/site.git"
+	reports.reports["dep-1"] = c
+	reports.mu.Unlock()
+
+	if err := r.Report(context.Background(), "dep-1"); err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	if len(f.statuses) != 0 {
+		t.Fatalf("statuses = %v, want none", f.statuses)
+	}
+}
+
+func TestReporterSkipsUnlinkedRepositories(t *testing.T) {
+	f, reports, r := newTestReporter(t, nil)
+	reports.mu.Lock()
+	// Another user's project naming a repository of testUser's installation
+	c := reports.reports["dep-1"]
+	c.UserID = otherUser
+	reports.reports["dep-1"] = c
+	reports.mu.Unlock()
+
+	if err := r.Report(context.Background(), "dep-1"); !errors.Is(err, ErrNotLinked) {
+		t.Fatalf("Report = %v, want ErrNotLinked", err)
+	}
+
+	// Handle gives up without retrying
+	msg := deploymentEvent(t, event.DeploymentReady, event.DeploymentData{DeploymentID: "dep-1", ProjectID: "proj-1", CommitSHA: testSHA})
+	r.cfg.MaxAttempts = 3
+	r.cfg.RetryBackoff = time.Hour
+	if err := r.Handle(context.Background(), msg, func(error) {}); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	if len(f.statuses) != 0 {
+		t.Fatalf("statuses = %v, want none", f.statuses)
+	}
+}
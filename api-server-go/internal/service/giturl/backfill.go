@@ -0,0 +1,78 @@
+package giturl
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/project"
+)
+
+// DefaultBackfillBatchSize is how many projects Backfill reads at a time if
+// no batch size is given
+const DefaultBackfillBatchSize = 500
+
+// LegacyGitURLs stores the git URLs of projects created before URLs were
+// normalized
+type LegacyGitURLs interface {
+	ListLegacyGitURLs(ctx context.Context, afterID string, limit int) ([]project.LegacyGitURL, error)
+	SetCanonicalGitURL(ctx context.Context, legacy project.LegacyGitURL, gitURL string) (bool, error)
+}
+
+// BackfillResult is what Backfill did
+type BackfillResult struct {
+	// Normalized is the number of projects whose URL is now canonical
+	Normalized int
+	// Invalid are the IDs of projects whose URL is not allowed anymore. They
+	// keep it, and their deployments are refused until they are recreated.
+	Invalid []string
+	// Duplicates are the IDs of normalized projects whose owner has another
+	// project of the same repository
+	Duplicates []string
+}
+
+// Backfill stores the git URLs of projects created before URLs were
+// normalized in canonical form, so they are compared with the URLs of new
+// projects like the URLs of any other project. It does not resolve hosts;
+// deployments validate the URL again. Projects whose URL cannot be
+// normalized are reported and left as they are, and are looked at again
+// by the next Backfill.
+func (v *Validator) Backfill(ctx context.Context, store LegacyGitURLs, batchSize int) (BackfillResult, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBackfillBatchSize
+	}
+
+	var result BackfillResult
+	afterID := ""
+	for {
+		batch, err := store.ListLegacyGitURLs(ctx, afterID, batchSize)
+		if err != nil {
+			return result, err
+		}
+
+		for _, legacy := range batch {
+			afterID = legacy.ProjectID
+
+			normalized, err := v.Normalize(legacy.GitURL)
+			if errors.Is(err, ErrInvalid) || errors.Is(err, ErrNotAllowed) {
+				result.Invalid = append(result.Invalid, legacy.ProjectID)
+				continue
+			}
+			if err != nil {
+				return result, err
+			}
+
+			duplicate, err := store.SetCanonicalGitURL(ctx, legacy, normalized)
+			if err != nil {
+				return result, err
+			}
+			result.Normalized++
+			if duplicate {
+				result.Duplicates = append(result.Duplicates, legacy.ProjectID)
+			}
+		}
+
+		if len(batch) < batchSize {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package giturl
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/project"
+)
+
+// fakeLegacyGitURLs holds projects by ID, like the projects table
+type fakeLegacyGitURLs struct {
+	projects  map[string]*fakeProject
+	listCalls int
+}
+
+type fakeProject struct {
+	userID    string
+	gitURL    string
+	canonical bool
+}
+
+func (f *fakeLegacyGitURLs) ListLegacyGitURLs(ctx context.Context, afterID string, limit int) ([]project.LegacyGitURL, error) {
+	f.listCalls++
+	ids := make([]string, 0, len(f.projects))
+	for id := range f.projects {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var urls []project.LegacyGitURL
+	for _, id := range ids {
+		p := f.projects[id]
+		if p.canonical || id <= afterID || len(urls) == limit {
+			continue
+		}
+		urls = append(urls, project.LegacyGitURL{ProjectID: id, UserID: p.userID, GitURL: p.gitURL})
+	}
+	return urls, nil
+}
+
+func (f *fakeLegacyGitURLs) SetCanonicalGitURL(ctx context.Context, legacy project.LegacyGitURL, gitURL string) (bool, error) {
+	duplicate := false
+	for id, p := range f.projects {
+		if id != legacy.ProjectID && p.userID == legacy.UserID && strings.EqualFold(p.gitURL, gitURL) {
+			duplicate = true
+		}
+	}
+	p := f.projects[legacy.ProjectID]
+	p.gitURL, p.canonical = gitURL, true
+	return duplicate, nil
+}
+
+func TestBackfill(t *testing.T) {
+	store := &fakeLegacyGitURLs{projects: map[string]*fakeProject{
+		"p1": {userID: "u1", gitURL: "https://GitHub.com/octo/site.git/"},
+		"p2": {userID: "u1", gitURL: "git@github.com:octo/api.git"},
+		"p3": {userID: "u1", gitURL: "https://github.com/octo/site", canonical: true},
+		"p4": {userID: "u2", gitURL: "file:///etc/passwd"},
+		"p5": {userID: "u2", gitURL: "https://token@github.com/octo/private.git"},
+		"p6": {userID: "u2", gitURL: "ssh://git@gitlab.com/group/sub/repo.git"},
+		"p7": {userID: "u2", gitURL: "https://github.com/octo/site"},
+	}}
+	v := New(Config{AllowedHosts: []string{"github.com", "gitlab.com"}})
+
+	result, err := v.Backfill(context.Background(), store, 2)
+	if err != nil {
+		t.Fatalf("Backfill: %v", err)
+	}
+
+	want := map[string]string{
+		"p1": "https://github.com/octo/site",
+		"p2": "https://github.com/octo/api",
+		"p3": "https://github.com/octo/site",
+		"p4": "file:///etc/passwd",
+		"p5": "https://token@github.com/octo/private.git",
+		"p6": "https://gitlab.com/group/sub/repo",
+		"p7": "https://github.com/octo/site",
+	}
+	for id, gitURL := range want {
+		if got := store.projects[id].gitURL; got != gitURL {
+			t.Errorf("git URL of %s = %q, want %q", id, got, gitURL)
+		}
+	}
+
+	if result.Normalized != 4 {
+		t.Errorf("Normalized = %d, want 4", result.Normalized)
+	}
+	if !reflect.DeepEqual(result.Invalid, []string{"p4", "p5"}) {
+		t.Errorf("Invalid = %v, want [p4 p5]", result.Invalid)
+	}
+	// p1 matches the canonical URL of another project of u1; p7 is the
+	// same repository but owned by u2
+	if !reflect.DeepEqual(result.Duplicates, []string{"p1"}) {
+		t.Errorf("Duplicates = %v, want [p1]", result.Duplicates)
+	}
+
+	// Projects whose URL cannot be normalized are looked at again, the
+	// others are not
+	store.listCalls = 0
+	result, err = v.Backfill(context.Background(), store, 2)
+	if err != nil {
+		t.Fatalf("second Backfill: %v", err)
+	}
+	if result.Normalized != 0 || !reflect.DeepEqual(result.Invalid, []string{"p4", "p5"}) {
+		t.Errorf("second Backfill = %+v, want only p4 and p5 invalid", result)
+	}
+	if store.listCalls != 2 {
+		t.Errorf("second Backfill listed %d times, want 2", store.listCalls)
+	}
+}
@@ -0,0 +1,264 @@
+// Package giturl validates the repository URLs of projects. Builds pass
+// them to git clone and the API server connects to them to check access,
+// so only https and ssh URLs on allowed git hosts that resolve to public
+// addresses are accepted, in one canonical form.
+package giturl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrInvalid is returned for a value that is not a repository URL
+	ErrInvalid = errors.New("invalid repository URL")
+	// ErrNotAllowed is returned for a repository URL with a scheme, host or
+	// address that is not allowed
+	ErrNotAllowed = errors.New("repository URL is not allowed")
+)
+
+// pathSegment matches one segment of a repository path, e.g. an owner, a
+// group or the repository name
+var pathSegment = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// hostname matches a DNS name
+var hostname = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+
+type Config struct {
+	// AllowedHosts are the git hosts repositories may be on, compared
+	// without port; "*" allows any host
+	AllowedHosts []string
+	// AllowPrivateHosts skips the check that hosts resolve to public
+	// addresses
+	AllowPrivateHosts bool
+	// AllowLocalPaths accepts absolute paths on the build host
+	AllowLocalPaths bool
+}
+
+// Validator normalizes repository URLs and checks where they point
+type Validator struct {
+	cfg      Config
+	hosts    map[string]bool
+	anyHost  bool
+	resolver *net.Resolver
+}
+
+func New(cfg Config) *Validator {
+	v := &Validator{
+		cfg:      cfg,
+		hosts:    make(map[string]bool, len(cfg.AllowedHosts)),
+		resolver: net.DefaultResolver,
+	}
+	for _, host := range cfg.AllowedHosts {
+		if host == "*" {
+			v.anyHost = true
+			continue
+		}
+		v.hosts[strings.TrimSuffix(strings.ToLower(host), ".")] = true
+	}
+	return v
+}
+
+// Normalize returns the canonical form of a repository URL:
+// https://<host>[:port]/<owner>/<name>, with a lowercase host and without
+// credentials, a trailing slash or a .git suffix. https, ssh:// and
+// scp-like (git@host:owner/name) URLs are accepted; an ssh URL is turned
+// into the https URL of the same repository. Absolute local paths are
+// returned cleaned if allowed. It does not resolve the host.
+func (v *Validator) Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("%w: empty", ErrInvalid)
+	}
+	if strings.ContainsFunc(raw, func(r rune) bool { return r <= ' ' || r == 0x7f || r == '\\' }) {
+		return "", fmt.Errorf("%w: contains whitespace, control characters or backslashes", ErrInvalid)
+	}
+
+	if strings.HasPrefix(raw, "/") {
+		if !v.cfg.AllowLocalPaths {
+			return "", fmt.Errorf("%w: local paths are not allowed", ErrNotAllowed)
+		}
+		return filepath.Clean(raw), nil
+	}
+
+	var host, port, path string
+	if isSCPLike(raw) {
+		// user@host:owner/name has no scheme and always uses port 22
+		_, rest, _ := strings.Cut(raw, "@")
+		host, path, _ = strings.Cut(rest, ":")
+	} else {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		if u.Opaque != "" || u.Host == "" {
+			return "", fmt.Errorf("%w: a https or ssh URL is required", ErrInvalid)
+		}
+		if u.RawQuery != "" || u.Fragment != "" || u.ForceQuery {
+			return "", fmt.Errorf("%w: query strings and fragments are not allowed", ErrInvalid)
+		}
+
+		switch strings.ToLower(u.Scheme) {
+		case "https":
+			if u.User != nil {
+				return "", fmt.Errorf("%w: credentials must not be part of the URL; use a git credential instead", ErrNotAllowed)
+			}
+			if u.Port() != "" && u.Port() != "443" {
+				port = u.Port()
+			}
+		case "ssh":
+			// The canonical form is https, which cannot express another
+			// ssh port
+			if u.Port() != "" && u.Port() != "22" {
+				return "", fmt.Errorf("%w: ssh URLs must use port 22", ErrNotAllowed)
+			}
+		default:
+			return "", fmt.Errorf("%w: scheme %q is not allowed; use https or ssh", ErrNotAllowed, u.Scheme)
+		}
+		host, path = u.Hostname(), u.Path
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if err := v.checkHost(host); err != nil {
+		return "", err
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	segments := strings.Split(path, "/")
+	if len(segments) < 2 {
+		return "", fmt.Errorf("%w: the path must name an owner and a repository", ErrInvalid)
+	}
+	for _, segment := range segments {
+		if !pathSegment.MatchString(segment) || segment == "." || segment == ".." {
+			return "", fmt.Errorf("%w: invalid path segment %q", ErrInvalid, segment)
+		}
+	}
+
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return "https://" + host + "/" + strings.Join(segments, "/"), nil
+}
+
+// Validate normalizes a repository URL and checks that its host resolves
+// only to public addresses. Builds clone later and may resolve differently,
+// so a name that resolves to any private address is rejected.
+func (v *Validator) Validate(ctx context.Context, raw string) (string, error) {
+	normalized, err := v.Normalize(raw)
+	if err != nil || strings.HasPrefix(normalized, "/") || v.cfg.AllowPrivateHosts {
+		return normalized, err
+	}
+
+	u, err := url.Parse(normalized)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if _, err := v.resolve(ctx, u.Hostname()); err != nil {
+		return "", err
+	}
+	return normalized, nil
+}
+
+// CheckPublicHost checks that host resolves only to public addresses, unless
+// private hosts are allowed. It is used for other URLs users give the API
+// server to connect to, such as webhooks.
+func (v *Validator) CheckPublicHost(ctx context.Context, host string) error {
+	if v.cfg.AllowPrivateHosts {
+		return nil
+	}
+	_, err := v.resolve(ctx, host)
+	return err
+}
+
+// DialContext dials address like net.Dialer, but only to public addresses
+// unless private hosts are allowed. The address is checked when it is
+// dialed, so a name that resolved to a public address during validation
+// cannot be pointed at an internal one later. Use it for every connection
+// the API server makes to a repository host.
+func (v *Validator) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	var dialer net.Dialer
+	if v.cfg.AllowPrivateHosts {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := v.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, addr := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// resolve returns the addresses of host, or an error if it has none or any
+// of them is not public
+func (v *Validator) resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr}
+	} else {
+		if addrs, err = v.resolver.LookupNetIP(ctx, "ip", host); err != nil {
+			return nil, fmt.Errorf("%w: failed to resolve %s: %v", ErrNotAllowed, host, err)
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("%w: %s does not resolve", ErrNotAllowed, host)
+		}
+	}
+
+	for i, addr := range addrs {
+		addrs[i] = addr.Unmap()
+		if !IsPublic(addrs[i]) {
+			return nil, fmt.Errorf("%w: %s resolves to the non-public address %s", ErrNotAllowed, host, addrs[i])
+		}
+	}
+	return addrs, nil
+}
+
+func (v *Validator) checkHost(host string) error {
+	if host == "" {
+		return fmt.Errorf("%w: missing host", ErrInvalid)
+	}
+	_, ipErr := netip.ParseAddr(host)
+	if ipErr != nil && !hostname.MatchString(host) {
+		return fmt.Errorf("%w: invalid host %q", ErrInvalid, host)
+	}
+
+	if v.hosts[host] {
+		return nil
+	}
+	// An address is only allowed by name, never through "*"
+	if v.anyHost && ipErr != nil {
+		return nil
+	}
+	return fmt.Errorf("%w: host %s is not an allowed git host", ErrNotAllowed, host)
+}
+
+// isSCPLike reports whether raw has the scp-like user@host:path syntax
+func isSCPLike(raw string) bool {
+	if strings.Contains(raw, "://") {
+		return false
+	}
+	at := strings.Index(raw, "@")
+	colon := strings.Index(raw, ":")
+	return at > 0 && colon > at+1
+}
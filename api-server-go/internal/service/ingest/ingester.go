@@ -0,0 +1,175 @@
+// Package ingest accepts build events over HTTP, for installs without
+// Kafka. Events go through the same processor as Kafka messages.
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
+	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/logs"
+)
+
+// Handler applies one event and calls ack once it is durable; it is
+// satisfied by (*consumer.Processor).HandleEvent
+type Handler func(ctx context.Context, event buildlog.Event, ack logs.AckFunc) error
+
+// Store records which events of a deployment are stored; it is satisfied
+// by the deployment repository
+type Store interface {
+	IngestEvents(ctx context.Context, deploymentID string, apply func(last int64, above map[int64]bool) (domain.IngestProgress, error)) error
+}
+
+// Result summarizes one ingested batch
+type Result struct {
+	Accepted   int `json:"accepted"`
+	Duplicates int `json:"duplicates"`
+	// LastSeq is the highest sequence number stored for the deployment with
+	// every event before it
+	LastSeq int64 `json:"lastSeq"`
+}
+
+// Ingester applies batches of events in sequence order and skips events it
+// has already stored, so builders can retry a batch safely
+type Ingester struct {
+	store   Store
+	handler Handler
+
+	// locks serializes batches of the same deployment within this process,
+	// so waiting batches do not each hold a database connection; the store
+	// serializes them across processes
+	mu    sync.Mutex
+	locks map[string]*deploymentLock
+}
+
+type deploymentLock struct {
+	sync.Mutex
+	users int
+}
+
+// New creates an ingester feeding handler
+func New(store Store, handler Handler) *Ingester {
+	return &Ingester{
+		store:   store,
+		handler: handler,
+		locks:   make(map[string]*deploymentLock),
+	}
+}
+
+// Ingest applies the events of one deployment. Events already stored are
+// skipped. If storing an event fails, the events stored before and after
+// it are recorded, so sending the batch again stores only the missing ones.
+func (i *Ingester) Ingest(ctx context.Context, deploymentID, projectID string, events []buildlog.Event) (Result, error) {
+	unlock := i.lock(deploymentID)
+	defer unlock()
+
+	var result Result
+	err := i.store.IngestEvents(ctx, deploymentID, func(last int64, above map[int64]bool) (domain.IngestProgress, error) {
+		var err error
+		result, err = i.apply(ctx, deploymentID, projectID, events, last, above)
+		return domain.IngestProgress{Last: result.LastSeq, Above: aboveSeqs(result.LastSeq, above, events)}, err
+	})
+	return result, err
+}
+
+// apply hands the events that are not stored yet to the handler and waits
+// until each is stored or failed. above is updated with the events stored.
+func (i *Ingester) apply(ctx context.Context, deploymentID, projectID string, events []buildlog.Event, last int64, above map[int64]bool) (Result, error) {
+	sort.SliceStable(events, func(a, b int) bool { return events[a].Seq < events[b].Seq })
+
+	result := Result{LastSeq: last}
+	var pending []buildlog.Event
+	seen := make(map[int64]bool, len(events))
+	for _, event := range events {
+		// Also drops repeats within the batch
+		if event.Seq <= last || above[event.Seq] || seen[event.Seq] {
+			result.Duplicates++
+			continue
+		}
+		seen[event.Seq] = true
+
+		event.DeploymentID = deploymentID
+		event.ProjectID = projectID
+		pending = append(pending, event)
+	}
+
+	// Every event handed over is acked once the writer stored or gave up on
+	// it, so the outcome of each is known before progress is recorded
+	acks := make([]chan error, 0, len(pending))
+	var handleErr error
+	for _, event := range pending {
+		ack := make(chan error, 1)
+		if err := i.handler(ctx, event, func(err error) { ack <- err }); err != nil {
+			handleErr = fmt.Errorf("event %d: %w", event.Seq, err)
+			break
+		}
+		acks = append(acks, ack)
+	}
+
+	var ackErr error
+	for n, ack := range acks {
+		if err := <-ack; err != nil {
+			if ackErr == nil {
+				ackErr = fmt.Errorf("event %d: %w", pending[n].Seq, err)
+			}
+			continue
+		}
+		above[pending[n].Seq] = true
+		result.Accepted++
+	}
+
+	// The stored prefix moves up through the events stored now or before
+	for _, event := range events {
+		if event.Seq <= result.LastSeq {
+			continue
+		}
+		if !above[event.Seq] {
+			break
+		}
+		result.LastSeq = event.Seq
+	}
+
+	if ackErr != nil {
+		return result, ackErr
+	}
+	return result, handleErr
+}
+
+// aboveSeqs returns the stored sequence numbers of the batch above last
+func aboveSeqs(last int64, above map[int64]bool, events []buildlog.Event) []int64 {
+	var seqs []int64
+	for _, event := range events {
+		if event.Seq > last && above[event.Seq] {
+			seqs = append(seqs, event.Seq)
+			delete(above, event.Seq)
+		}
+	}
+	return seqs
+}
+
+// lock takes the per-deployment lock and returns its release
+func (i *Ingester) lock(deploymentID string) func() {
+	i.mu.Lock()
+	l, ok := i.locks[deploymentID]
+	if !ok {
+		l = &deploymentLock{}
+		i.locks[deploymentID] = l
+	}
+	l.users++
+	i.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+
+		i.mu.Lock()
+		l.users--
+		if l.users == 0 {
+			delete(i.locks, deploymentID)
+		}
+		i.mu.Unlock()
+	}
+}
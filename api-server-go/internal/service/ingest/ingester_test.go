@@ -0,0 +1,194 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
+	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/deployment"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/logs"
+)
+
+// fakeStore records progress like the deployment repository: batches of a
+// deployment are serialized and progress is kept even if apply fails
+type fakeStore struct {
+	mu    sync.Mutex
+	last  int64
+	above map[int64]bool
+}
+
+func (s *fakeStore) IngestEvents(ctx context.Context, deploymentID string, apply func(last int64, above map[int64]bool) (domain.IngestProgress, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	above := make(map[int64]bool, len(s.above))
+	for seq := range s.above {
+		above[seq] = true
+	}
+	progress, err := apply(s.last, above)
+
+	if progress.Last > s.last {
+		s.last = progress.Last
+		for seq := range s.above {
+			if seq <= s.last {
+				delete(s.above, seq)
+			}
+		}
+	}
+	for _, seq := range progress.Above {
+		s.above[seq] = true
+	}
+	return err
+}
+
+// fakeLogs stores lines and acks them asynchronously, failing the seqs in
+// failAck once and refusing those in failHandle once
+type fakeLogs struct {
+	mu         sync.Mutex
+	lines      []string
+	failAck    map[int64]bool
+	failHandle map[int64]bool
+}
+
+func (f *fakeLogs) handle(ctx context.Context, event buildlog.Event, ack logs.AckFunc) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failHandle[event.Seq] {
+		delete(f.failHandle, event.Seq)
+		return errors.New("writer closed")
+	}
+	if f.failAck[event.Seq] {
+		delete(f.failAck, event.Seq)
+		go ack(errors.New("store unavailable"))
+		return nil
+	}
+	f.lines = append(f.lines, event.Log)
+	go ack(nil)
+	return nil
+}
+
+func (f *fakeLogs) stored() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	lines := append([]string(nil), f.lines...)
+	sort.Strings(lines)
+	return lines
+}
+
+func batch(from, to int64) []buildlog.Event {
+	var events []buildlog.Event
+	for seq := from; seq <= to; seq++ {
+		events = append(events, buildlog.Event{Seq: seq, Log: fmt.Sprintf("line %d", seq)})
+	}
+	return events
+}
+
+func lines(from, to int64) []string {
+	var l []string
+	for _, event := range batch(from, to) {
+		l = append(l, event.Log)
+	}
+	sort.Strings(l)
+	return l
+}
+
+func TestIngestRetriesDoNotDuplicateLines(t *testing.T) {
+	tests := []struct {
+		name       string
+		failAck    map[int64]bool
+		failHandle map[int64]bool
+		first      Result
+	}{
+		{
+			name:    "event stored after a failed one",
+			failAck: map[int64]bool{3: true},
+			first:   Result{Accepted: 4, LastSeq: 2},
+		},
+		{
+			name:       "handler refuses an event",
+			failHandle: map[int64]bool{4: true},
+			first:      Result{Accepted: 3, LastSeq: 3},
+		},
+		{
+			name:    "first event fails",
+			failAck: map[int64]bool{1: true},
+			first:   Result{Accepted: 4, LastSeq: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{above: make(map[int64]bool)}
+			f := &fakeLogs{failAck: tt.failAck, failHandle: tt.failHandle}
+			ingester := New(store, f.handle)
+			ctx := context.Background()
+
+			result, err := ingester.Ingest(ctx, "dep-1", "proj-1", batch(1, 5))
+			if err == nil {
+				t.Fatal("Ingest succeeded, want the failure")
+			}
+			if result != tt.first {
+				t.Fatalf("first result = %+v, want %+v", result, tt.first)
+			}
+
+			// The builder sends the same batch again
+			result, err = ingester.Ingest(ctx, "dep-1", "proj-1", batch(1, 5))
+			if err != nil {
+				t.Fatalf("retry: %v", err)
+			}
+			if result.Accepted != 5-tt.first.Accepted || result.LastSeq != 5 {
+				t.Fatalf("retry result = %+v, want %d accepted up to 5", result, 5-tt.first.Accepted)
+			}
+
+			if got := f.stored(); !reflect.DeepEqual(got, lines(1, 5)) {
+				t.Fatalf("stored lines = %v, want each of 1-5 once", got)
+			}
+			if len(store.above) != 0 {
+				t.Fatalf("sequence numbers above the prefix = %v, want none", store.above)
+			}
+		})
+	}
+}
+
+func TestIngestConcurrentBatchesStoreOnce(t *testing.T) {
+	store := &fakeStore{above: make(map[int64]bool)}
+	f := &fakeLogs{}
+	// Separate ingesters, like two API servers receiving the same batch
+	a, b := New(store, f.handle), New(store, f.handle)
+
+	var wg sync.WaitGroup
+	for _, ingester := range []*Ingester{a, b, a} {
+		wg.Add(1)
+		go func(ingester *Ingester) {
+			defer wg.Done()
+			if _, err := ingester.Ingest(context.Background(), "dep-1", "proj-1", batch(1, 20)); err != nil {
+				t.Errorf("Ingest: %v", err)
+			}
+		}(ingester)
+	}
+	wg.Wait()
+
+	if got := f.stored(); !reflect.DeepEqual(got, lines(1, 20)) {
+		t.Fatalf("stored %d lines, want each of 1-20 once", len(got))
+	}
+}
+
+func TestIngestSkipsRepeatsWithinBatch(t *testing.T) {
+	store := &fakeStore{above: make(map[int64]bool)}
+	f := &fakeLogs{}
+
+	events := append(batch(1, 3), batch(2, 3)...)
+	result, err := New(store, f.handle).Ingest(context.Background(), "dep-1", "proj-1", events)
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if result != (Result{Accepted: 3, Duplicates: 2, LastSeq: 3}) {
+		t.Fatalf("result = %+v", result)
+	}
+}
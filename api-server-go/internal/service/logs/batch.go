@@ -0,0 +1,226 @@
+package logs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	repologs "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/logs"
+)
+
+// ErrWriterClosed is returned when writing to a BatchWriter after Close
+var ErrWriterClosed = errors.New("batch writer is closed")
+
+// BatchConfig controls when a BatchWriter flushes
+type BatchConfig struct {
+	// MaxSize flushes once this many events are buffered
+	MaxSize int
+	// FlushInterval flushes whatever is buffered at least this often
+	FlushInterval time.Duration
+	// MaxRetries is how many times a failed batch is re-sent before giving up
+	MaxRetries int
+}
+
+// AckFunc is called once the batch containing an event has been written,
+// with nil on success or the final error if the batch was dropped
+type AckFunc func(err error)
+
+type pendingEvent struct {
+	event LogEvent
+	ack   AckFunc
+}
+
+// BatchWriter buffers log events in memory and hands them to the store's
+// Append in batches (the native batch API on ClickHouse), so a chatty build
+// produces a few large parts instead of thousands of single-row inserts.
+type BatchWriter struct {
+	store repologs.LogStore
+	cfg   BatchConfig
+
+	mu     sync.RWMutex
+	closed bool
+
+	in       chan pendingEvent
+	flushReq chan chan struct{}
+	done     chan struct{}
+}
+
+// NewBatchWriter creates a writer and starts its flush loop
+func NewBatchWriter(store repologs.LogStore, cfg BatchConfig) *BatchWriter {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 1000
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+
+	w := &BatchWriter{
+		store:    store,
+		cfg:      cfg,
+		in:       make(chan pendingEvent, cfg.MaxSize*2),
+		flushReq: make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go w.run()
+	return w
+}
+
+// Write queues an event. ack is invoked after the event is durable in the
+// store (or the batch failed). Write blocks while the buffer is full.
+func (w *BatchWriter) Write(ctx context.Context, event LogEvent, ack AckFunc) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return ErrWriterClosed
+	}
+
+	if event.EventID == "" {
+		event.EventID = generateEventID()
+	}
+	if event.Stream == "" {
+		event.Stream = StreamStdout
+	}
+
+	select {
+	case w.in <- pendingEvent{event: event, ack: ack}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush writes out everything queued before the call and waits for it
+func (w *BatchWriter) Flush(ctx context.Context) error {
+	reply := make(chan struct{})
+
+	select {
+	case w.flushReq <- reply:
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-reply:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting events, flushes the remaining buffer and waits for it
+func (w *BatchWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.in)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *BatchWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]pendingEvent, 0, w.cfg.MaxSize)
+
+	for {
+		select {
+		case p, ok := <-w.in:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, p)
+			if len(batch) >= w.cfg.MaxSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+
+		case reply := <-w.flushReq:
+			// Pick up anything that was queued before the flush request
+		drain:
+			for {
+				select {
+				case p, ok := <-w.in:
+					if !ok {
+						break drain
+					}
+					batch = append(batch, p)
+					if len(batch) >= w.cfg.MaxSize {
+						w.flush(batch)
+						batch = batch[:0]
+					}
+				default:
+					break drain
+				}
+			}
+			w.flush(batch)
+			batch = batch[:0]
+			close(reply)
+		}
+	}
+}
+
+// flush sends one batch, retrying with backoff, and acks every event in it
+func (w *BatchWriter) flush(batch []pendingEvent) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var err error
+	backoff := 200 * time.Millisecond
+	for attempt := 0; attempt <= w.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if err = w.send(batch); err == nil {
+			break
+		}
+		log.Printf("WARN: log batch of %d events failed (attempt %d/%d): %v",
+			len(batch), attempt+1, w.cfg.MaxRetries+1, err)
+	}
+
+	for _, p := range batch {
+		if p.ack != nil {
+			p.ack(err)
+		}
+	}
+}
+
+func (w *BatchWriter) send(batch []pendingEvent) error {
+	// Shutdown flushes must not be cut short by a cancelled request context
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	events := make([]LogEvent, len(batch))
+	for i, p := range batch {
+		events[i] = p.event
+	}
+
+	return w.store.Append(ctx, events)
+}
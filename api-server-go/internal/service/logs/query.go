@@ -0,0 +1,146 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
+	repologs "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/logs"
+)
+
+const (
+	// DefaultPageSize is used when a query does not specify a limit
+	DefaultPageSize = 500
+	// MaxPageSize caps the number of rows returned by a single query
+	MaxPageSize = 5000
+)
+
+// Stream identifies which output stream of the build a log line came from
+type Stream = buildlog.Stream
+
+const (
+	StreamStdout = buildlog.StreamStdout
+	StreamStderr = buildlog.StreamStderr
+)
+
+// Cursor marks a position in a deployment's log stream
+type Cursor = buildlog.Cursor
+
+// ErrInvalidCursor is returned when a cursor string cannot be decoded
+var ErrInvalidCursor = buildlog.ErrInvalidCursor
+
+// ParseStream validates a stream name coming from user input
+func ParseStream(s string) (Stream, error) {
+	return buildlog.ParseStream(s)
+}
+
+// DecodeCursor parses a cursor previously produced by Cursor.Encode
+func DecodeCursor(s string) (Cursor, error) {
+	return buildlog.DecodeCursor(s)
+}
+
+// Query describes a filtered, paginated read of a deployment's logs
+type Query struct {
+	// After returns only logs strictly after this position
+	After *Cursor
+	// Limit is the maximum number of rows to return (DefaultPageSize if zero)
+	Limit int
+	// Since and Until bound the log timestamp (inclusive)
+	Since *time.Time
+	Until *time.Time
+	// Stream restricts results to stdout or stderr
+	Stream Stream
+	// Search matches log lines containing the substring (case-insensitive),
+	// or matching the RE2 expression when Regex is set
+	Search string
+	Regex  bool
+	// Tail returns the last N matching lines instead of paging forward
+	Tail int
+}
+
+// Validate normalises the limit and checks the search expression
+func (q *Query) Validate() error {
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	if q.Limit > MaxPageSize {
+		q.Limit = MaxPageSize
+	}
+	if q.Tail < 0 {
+		return fmt.Errorf("tail must be positive")
+	}
+	if q.Tail > MaxPageSize {
+		q.Tail = MaxPageSize
+	}
+	if q.Tail > 0 && q.After != nil {
+		return fmt.Errorf("tail cannot be combined with a cursor")
+	}
+	if q.Since != nil && q.Until != nil && q.Until.Before(*q.Since) {
+		return fmt.Errorf("until must not be before since")
+	}
+	if q.Regex {
+		if q.Search == "" {
+			return fmt.Errorf("regex search requires a pattern")
+		}
+		// ClickHouse uses RE2 as well, so Go's parser is a faithful pre-check
+		if _, err := regexp.Compile(q.Search); err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+	}
+	return nil
+}
+
+// Page is one window of query results
+type Page struct {
+	Logs []LogEvent `json:"logs"`
+	// NextCursor points after the last returned row; pass it back as the cursor
+	// to continue paging or to follow new output. Empty when no rows matched.
+	NextCursor string `json:"nextCursor,omitempty"`
+	// HasMore reports whether further rows existed at query time
+	HasMore bool `json:"hasMore"`
+}
+
+// QueryLogs runs a cursor-paginated, filtered query against a deployment's logs.
+// Only one page is ever held in memory.
+func (s *Service) QueryLogs(ctx context.Context, deploymentID string, q Query) (Page, error) {
+	if err := q.Validate(); err != nil {
+		return Page{}, err
+	}
+
+	filter := repologs.Filter{
+		After:  q.After,
+		Since:  q.Since,
+		Until:  q.Until,
+		Stream: q.Stream,
+		Search: q.Search,
+		Regex:  q.Regex,
+	}
+
+	var (
+		page Page
+		err  error
+	)
+
+	if q.Tail > 0 {
+		page.Logs, err = s.store.Tail(ctx, deploymentID, filter, q.Tail)
+	} else {
+		// Fetch one extra row to know whether another page exists
+		filter.Limit = q.Limit + 1
+		page.Logs, err = s.store.Query(ctx, deploymentID, filter)
+		if err == nil && len(page.Logs) > q.Limit {
+			page.HasMore = true
+			page.Logs = page.Logs[:q.Limit]
+		}
+	}
+	if err != nil {
+		return Page{}, fmt.Errorf("failed to query logs: %w", err)
+	}
+
+	if n := len(page.Logs); n > 0 {
+		page.NextCursor = buildlog.CursorOf(page.Logs[n-1]).Encode()
+	}
+
+	return page, nil
+}
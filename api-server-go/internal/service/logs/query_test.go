@@ -0,0 +1,190 @@
+package logs
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	repologs "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/logs"
+)
+
+func TestQueryValidate(t *testing.T) {
+	since := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	before := since.Add(-time.Second)
+	cursor := Cursor{Timestamp: since, EventID: "e1"}
+
+	tests := []struct {
+		name      string
+		query     Query
+		ok        bool
+		wantLimit int
+		wantTail  int
+	}{
+		{"defaults", Query{}, true, DefaultPageSize, 0},
+		{"limit kept", Query{Limit: 10}, true, 10, 0},
+		{"limit capped", Query{Limit: MaxPageSize + 1}, true, MaxPageSize, 0},
+		{"negative limit defaults", Query{Limit: -1}, true, DefaultPageSize, 0},
+		{"tail kept", Query{Tail: 20}, true, DefaultPageSize, 20},
+		{"tail capped", Query{Tail: MaxPageSize + 1}, true, DefaultPageSize, MaxPageSize},
+		{"negative tail", Query{Tail: -1}, false, 0, 0},
+		{"tail with cursor", Query{Tail: 5, After: &cursor}, false, 0, 0},
+		{"since equals until", Query{Since: &since, Until: &since}, true, DefaultPageSize, 0},
+		{"until before since", Query{Since: &since, Until: &before}, false, 0, 0},
+		{"substring search", Query{Search: "(unbalanced"}, true, DefaultPageSize, 0},
+		{"regex", Query{Search: `^error:\s+\d+`, Regex: true}, true, DefaultPageSize, 0},
+		{"regex without pattern", Query{Regex: true}, false, 0, 0},
+		{"invalid regex", Query{Search: "(unbalanced", Regex: true}, false, 0, 0},
+		// RE2 has no backreferences, so ClickHouse would reject it too
+		{"backreference", Query{Search: `(a)\1`, Regex: true}, false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query
+			err := q.Validate()
+			if (err == nil) != tt.ok {
+				t.Fatalf("Validate() = %v, want ok %v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			if q.Limit != tt.wantLimit || q.Tail != tt.wantTail {
+				t.Fatalf("limit %d tail %d, want %d and %d", q.Limit, q.Tail, tt.wantLimit, tt.wantTail)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []Cursor{
+		{Timestamp: time.Date(2026, 3, 1, 8, 30, 0, 123456789, time.UTC), EventID: "2f1c7a9e-0d4b-4c8e-9a51-6b3e2d7f0c11"},
+		{Timestamp: time.Unix(0, 0).UTC(), EventID: "e"},
+		// Event IDs are opaque; a colon in one must survive
+		{Timestamp: time.Date(2026, 3, 1, 8, 30, 0, 1, time.UTC), EventID: "a:b"},
+	}
+
+	for _, want := range tests {
+		got, err := DecodeCursor(want.Encode())
+		if err != nil {
+			t.Fatalf("DecodeCursor(%+v): %v", want, err)
+		}
+		if !got.Timestamp.Equal(want.Timestamp) || got.EventID != want.EventID {
+			t.Fatalf("round trip = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+	valid := Cursor{Timestamp: time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC), EventID: "e1"}.Encode()
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!!!"},
+		{"padded", base64.URLEncoding.EncodeToString([]byte("10:e"))},
+		{"standard alphabet", "+/+/"},
+		{"truncated", valid[:len(valid)-1] + "="},
+		{"no separator", encode("1700000000000000000")},
+		{"no event ID", encode("1700000000000000000:")},
+		{"no timestamp", encode(":e1")},
+		{"timestamp not a number", encode("17000000000000000x0:e1")},
+		{"timestamp overflows", encode("99999999999999999999:e1")},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("DecodeCursor(%q) = %v, want ErrInvalidCursor", tt.cursor, err)
+			}
+		})
+	}
+}
+
+func TestQueryLogsHasMore(t *testing.T) {
+	const limit = 10
+
+	tests := []struct {
+		lines     int
+		wantLines int
+		hasMore   bool
+	}{
+		{0, 0, false},
+		{limit - 1, limit - 1, false},
+		{limit, limit, false},
+		{limit + 1, limit, true},
+		{2 * limit, limit, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d lines", tt.lines), func(t *testing.T) {
+			s := New(repologs.NewMemoryStore())
+			seedLines(t, s, "dep-1", tt.lines)
+
+			page, err := s.QueryLogs(context.Background(), "dep-1", Query{Limit: limit})
+			if err != nil {
+				t.Fatalf("QueryLogs: %v", err)
+			}
+			if len(page.Logs) != tt.wantLines || page.HasMore != tt.hasMore {
+				t.Fatalf("%d lines, hasMore %v; want %d and %v", len(page.Logs), page.HasMore, tt.wantLines, tt.hasMore)
+			}
+			if (page.NextCursor == "") != (tt.wantLines == 0) {
+				t.Fatalf("next cursor %q with %d lines", page.NextCursor, len(page.Logs))
+			}
+		})
+	}
+}
+
+func TestQueryLogsPagesWithCursor(t *testing.T) {
+	s := New(repologs.NewMemoryStore())
+	seedLines(t, s, "dep-1", 25)
+
+	var (
+		got   []string
+		query = Query{Limit: 10}
+		pages int
+	)
+	for {
+		page, err := s.QueryLogs(context.Background(), "dep-1", query)
+		if err != nil {
+			t.Fatalf("QueryLogs: %v", err)
+		}
+		pages++
+		for _, e := range page.Logs {
+			got = append(got, e.Log)
+		}
+		if !page.HasMore {
+			break
+		}
+
+		cursor, err := DecodeCursor(page.NextCursor)
+		if err != nil {
+			t.Fatalf("DecodeCursor: %v", err)
+		}
+		query.After = &cursor
+	}
+
+	if pages != 3 || len(got) != 25 {
+		t.Fatalf("read %d lines in %d pages, want 25 in 3", len(got), pages)
+	}
+	for i, line := range got {
+		if want := fmt.Sprintf("line %02d", i); line != want {
+			t.Fatalf("line %d = %q, want %q", i, line, want)
+		}
+	}
+}
+
+func seedLines(t *testing.T, s *Service, deploymentID string, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		if err := s.InsertLog(context.Background(), LogEvent{DeploymentID: deploymentID, Log: fmt.Sprintf("line %02d", i)}); err != nil {
+			t.Fatalf("InsertLog: %v", err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package logs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// PlanLookup resolves the billing plan of a project
+type PlanLookup func(ctx context.Context, projectID string) (string, error)
+
+// planCacheTTL is how long a resolved plan is reused before looking it up again
+const planCacheTTL = 5 * time.Minute
+
+// maxCachedPlans bounds the plan cache; it is cleared when full
+const maxCachedPlans = 10000
+
+type cachedPlan struct {
+	days    int
+	expires time.Time
+}
+
+// RetentionPolicy decides how many days a project's logs are kept based on
+// its plan. Stores with TTL support (ClickHouse) expire rows on their own.
+type RetentionPolicy struct {
+	defaultDays int
+	planDays    map[string]int
+	lookup      PlanLookup
+
+	mu    sync.Mutex
+	cache map[string]cachedPlan
+}
+
+// NewRetentionPolicy creates a policy. defaultDays applies to unknown plans
+// and to projects whose plan cannot be resolved.
+func NewRetentionPolicy(defaultDays int, planDays map[string]int, lookup PlanLookup) *RetentionPolicy {
+	return &RetentionPolicy{
+		defaultDays: defaultDays,
+		planDays:    planDays,
+		lookup:      lookup,
+		cache:       make(map[string]cachedPlan),
+	}
+}
+
+// Days returns the retention in days for a project's logs
+func (p *RetentionPolicy) Days(ctx context.Context, projectID string) int {
+	if projectID == "" || p.lookup == nil {
+		return p.defaultDays
+	}
+
+	now := time.Now()
+
+	p.mu.Lock()
+	if c, ok := p.cache[projectID]; ok && now.Before(c.expires) {
+		p.mu.Unlock()
+		return c.days
+	}
+	p.mu.Unlock()
+
+	plan, err := p.lookup(ctx, projectID)
+	if err != nil {
+		log.Printf("WARN: failed to resolve plan for project %s, using default log retention: %v", projectID, err)
+		return p.defaultDays
+	}
+
+	days, ok := p.planDays[plan]
+	if !ok || days <= 0 {
+		days = p.defaultDays
+	}
+
+	p.mu.Lock()
+	if len(p.cache) >= maxCachedPlans {
+		p.cache = make(map[string]cachedPlan)
+	}
+	p.cache[projectID] = cachedPlan{days: days, expires: now.Add(planCacheTTL)}
+	p.mu.Unlock()
+
+	return days
+}
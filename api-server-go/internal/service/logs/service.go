@@ -3,151 +3,62 @@ package logs
 import (
 	"context"
 	"fmt"
+	"log"
+	"sync"
 	"time"
 
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/buildlog"
 	repologs "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/logs"
 	"github.com/ujjwalkirti/mini-vercel-api-server/internal/utils"
 )
 
 // LogEvent represents a log entry
-type LogEvent struct {
-	EventID      string     `json:"event_id"`
-	DeploymentID string     `json:"deployment_id"`
-	Log          string     `json:"log"`
-	Timestamp    *time.Time `json:"timestamp,omitempty"`
-}
+type LogEvent = buildlog.LogEvent
 
 // Service handles business logic for deployment logs
 type Service struct {
-	repo repologs.Repository
+	store     repologs.LogStore
+	writer    *BatchWriter
+	retention *RetentionPolicy
+
+	// deletions tracks background log deletions so Close can wait for them
+	deletions sync.WaitGroup
 }
 
 // New creates a new logs service
-func New(repo repologs.Repository) *Service {
+func New(store repologs.LogStore) *Service {
 	return &Service{
-		repo: repo,
+		store: store,
 	}
 }
 
-// GetDeploymentLogs retrieves all logs for a specific deployment
-func (s *Service) GetDeploymentLogs(ctx context.Context, deploymentID string) ([]LogEvent, error) {
-	query := `
-		SELECT event_id, deployment_id, log, timestamp
-		FROM log_events
-		WHERE deployment_id = ?
-		ORDER BY timestamp ASC
-	`
-
-	rows, err := s.repo.QueryContext(ctx, query, deploymentID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query logs: %w", err)
-	}
-	defer rows.Close()
-
-	var logs []LogEvent
-	for rows.Next() {
-		var log LogEvent
-		var timestamp time.Time
-		if err := rows.Scan(&log.EventID, &log.DeploymentID, &log.Log, &timestamp); err != nil {
-			return nil, fmt.Errorf("failed to scan log row: %w", err)
-		}
-		log.Timestamp = &timestamp
-		logs = append(logs, log)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating logs: %w", err)
+// NewWithBatchWriter creates a logs service whose ingestion path goes through
+// a BatchWriter instead of single-row inserts
+func NewWithBatchWriter(store repologs.LogStore, writer *BatchWriter) *Service {
+	return &Service{
+		store:  store,
+		writer: writer,
 	}
-
-	return logs, nil
 }
 
-// GetDeploymentLogsWithLimit retrieves logs with pagination
-func (s *Service) GetDeploymentLogsWithLimit(ctx context.Context, deploymentID string, limit, offset int) ([]LogEvent, error) {
-	query := `
-		SELECT event_id, deployment_id, log, timestamp
-		FROM log_events
-		WHERE deployment_id = ?
-		ORDER BY timestamp ASC
-		LIMIT ? OFFSET ?
-	`
-
-	rows, err := s.repo.QueryContext(ctx, query, deploymentID, limit, offset)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query logs: %w", err)
-	}
-	defer rows.Close()
-
-	var logs []LogEvent
-	for rows.Next() {
-		var log LogEvent
-		var timestamp time.Time
-		if err := rows.Scan(&log.EventID, &log.DeploymentID, &log.Log, &timestamp); err != nil {
-			return nil, fmt.Errorf("failed to scan log row: %w", err)
-		}
-		log.Timestamp = &timestamp
-		logs = append(logs, log)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating logs: %w", err)
-	}
-
-	return logs, nil
-}
-
-// GetLogsInTimeRange retrieves logs within a specific time range
-func (s *Service) GetLogsInTimeRange(ctx context.Context, deploymentID string, startTime, endTime time.Time) ([]LogEvent, error) {
-	query := `
-		SELECT event_id, deployment_id, log, timestamp
-		FROM log_events
-		WHERE deployment_id = ?
-		  AND timestamp >= ?
-		  AND timestamp <= ?
-		ORDER BY timestamp ASC
-	`
-
-	rows, err := s.repo.QueryContext(ctx, query, deploymentID, startTime, endTime)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query logs: %w", err)
-	}
-	defer rows.Close()
-
-	var logs []LogEvent
-	for rows.Next() {
-		var log LogEvent
-		var timestamp time.Time
-		if err := rows.Scan(&log.EventID, &log.DeploymentID, &log.Log, &timestamp); err != nil {
-			return nil, fmt.Errorf("failed to scan log row: %w", err)
-		}
-		log.Timestamp = &timestamp
-		logs = append(logs, log)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating logs: %w", err)
-	}
-
-	return logs, nil
+// SetRetentionPolicy makes ingested lines carry their project's retention
+func (s *Service) SetRetentionPolicy(policy *RetentionPolicy) {
+	s.retention = policy
 }
 
 // InsertLog inserts a single log event
 // If EventID is empty, it will be auto-generated
-// Note: timestamp is a MATERIALIZED column in ClickHouse and should not be inserted
 func (s *Service) InsertLog(ctx context.Context, log LogEvent) error {
-	query := `
-		INSERT INTO log_events (event_id, deployment_id, log)
-		VALUES (?, ?, ?)
-	`
-
 	// Auto-generate event ID if not provided
-	eventID := log.EventID
-	if eventID == "" {
-		eventID = generateEventID()
+	if log.EventID == "" {
+		log.EventID = generateEventID()
+	}
+	if log.Stream == "" {
+		log.Stream = StreamStdout
 	}
+	s.stampRetention(ctx, &log)
 
-	_, err := s.repo.ExecContext(ctx, query, eventID, log.DeploymentID, log.Log)
-	if err != nil {
+	if err := s.store.Append(ctx, []LogEvent{log}); err != nil {
 		return fmt.Errorf("failed to insert log: %w", err)
 	}
 
@@ -156,14 +67,7 @@ func (s *Service) InsertLog(ctx context.Context, log LogEvent) error {
 
 // GetLogCount returns the total number of logs for a deployment
 func (s *Service) GetLogCount(ctx context.Context, deploymentID string) (int64, error) {
-	query := `
-		SELECT count(*)
-		FROM log_events
-		WHERE deployment_id = ?
-	`
-
-	var count int64
-	err := s.repo.QueryRowContext(ctx, query, deploymentID).Scan(&count)
+	count, err := s.store.Count(ctx, deploymentID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count logs: %w", err)
 	}
@@ -173,20 +77,58 @@ func (s *Service) GetLogCount(ctx context.Context, deploymentID string) (int64,
 
 // DeleteDeploymentLogs deletes all logs for a specific deployment
 func (s *Service) DeleteDeploymentLogs(ctx context.Context, deploymentID string) error {
-	query := `
-		ALTER TABLE log_events DELETE WHERE deployment_id = ?
-	`
+	if err := s.store.DeleteByDeployment(ctx, deploymentID); err != nil {
+		return fmt.Errorf("failed to delete logs: %w", err)
+	}
 
-	_, err := s.repo.ExecContext(ctx, query, deploymentID)
-	if err != nil {
+	return nil
+}
+
+// DeleteProjectLogs deletes all logs of a project and of its deployments,
+// given by ID since older lines do not carry the project
+func (s *Service) DeleteProjectLogs(ctx context.Context, projectID string, deploymentIDs []string) error {
+	if err := s.store.DeleteByProject(ctx, projectID, deploymentIDs); err != nil {
 		return fmt.Errorf("failed to delete logs: %w", err)
 	}
 
 	return nil
 }
 
+// deletionTimeout bounds a background log deletion
+const deletionTimeout = 5 * time.Minute
+
+// ScheduleDeploymentLogDeletion removes a deployment's logs in the background.
+// On ClickHouse the delete is itself an asynchronous mutation.
+func (s *Service) ScheduleDeploymentLogDeletion(deploymentID string) {
+	s.schedule("deployment "+deploymentID, func(ctx context.Context) error {
+		return s.DeleteDeploymentLogs(ctx, deploymentID)
+	})
+}
+
+// ScheduleProjectLogDeletion removes the logs of every deployment of a
+// project in the background
+func (s *Service) ScheduleProjectLogDeletion(projectID string, deploymentIDs []string) {
+	s.schedule("project "+projectID, func(ctx context.Context) error {
+		return s.DeleteProjectLogs(ctx, projectID, deploymentIDs)
+	})
+}
+
+func (s *Service) schedule(target string, fn func(ctx context.Context) error) {
+	s.deletions.Add(1)
+	go func() {
+		defer s.deletions.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), deletionTimeout)
+		defer cancel()
+
+		if err := fn(ctx); err != nil {
+			log.Printf("ERROR: failed to delete logs for %s: %v", target, err)
+		}
+	}()
+}
+
 // Insert is a convenience method that inserts a log with just deployment_id and log text
-// It auto-generates the event_id. This is the preferred method for Kafka consumer usage.
+// It auto-generates the event_id.
 // Internally delegates to InsertLog.
 func (s *Service) Insert(ctx context.Context, deploymentID, log string) error {
 	return s.InsertLog(ctx, LogEvent{
@@ -196,6 +138,91 @@ func (s *Service) Insert(ctx context.Context, deploymentID, log string) error {
 	})
 }
 
+// streamPageSize is how many rows StreamDeploymentLogs reads per round trip
+const streamPageSize = 5000
+
+// StreamDeploymentLogs walks every log line of a deployment in order and
+// calls fn for each one. Rows are read a page at a time with keyset
+// pagination so the full log set is never held in memory. Iteration stops at
+// the first error returned by fn.
+func (s *Service) StreamDeploymentLogs(ctx context.Context, deploymentID string, fn func(LogEvent) error) error {
+	filter := repologs.Filter{Limit: streamPageSize}
+
+	for {
+		page, err := s.store.Query(ctx, deploymentID, filter)
+		if err != nil {
+			return fmt.Errorf("failed to query logs: %w", err)
+		}
+
+		for _, log := range page {
+			if err := fn(log); err != nil {
+				return err
+			}
+		}
+
+		if len(page) < streamPageSize {
+			return nil
+		}
+
+		cursor := buildlog.CursorOf(page[len(page)-1])
+		filter.After = &cursor
+	}
+}
+
+// Enqueue hands a log event to the ingestion path. ack is called once the event
+// is durable. Without a batch writer the event is inserted immediately.
+func (s *Service) Enqueue(ctx context.Context, log LogEvent, ack AckFunc) error {
+	if s.writer != nil {
+		s.stampRetention(ctx, &log)
+		return s.writer.Write(ctx, log, ack)
+	}
+
+	// The insert outcome is reported through ack, like the batched path
+	err := s.InsertLog(ctx, log)
+	if ack != nil {
+		ack(err)
+	}
+	return nil
+}
+
+// Flush forces buffered events to storage
+func (s *Service) Flush(ctx context.Context) error {
+	if s.writer == nil {
+		return nil
+	}
+	return s.writer.Flush(ctx)
+}
+
+// Close flushes and stops the batch writer, if any, and waits for scheduled
+// deletions to finish
+func (s *Service) Close(ctx context.Context) error {
+	if s.writer != nil {
+		if err := s.writer.Close(ctx); err != nil {
+			return err
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.deletions.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stampRetention fills in the retention of a line from its project's plan
+func (s *Service) stampRetention(ctx context.Context, log *LogEvent) {
+	if s.retention != nil && log.RetentionDays == 0 {
+		log.RetentionDays = s.retention.Days(ctx, log.ProjectID)
+	}
+}
+
 // generateEventID generates a UUID for the event ID
 func generateEventID() string {
 	return utils.GenerateUUID()
@@ -0,0 +1,219 @@
+// Package redact removes secrets from build log lines before they are stored.
+package redact
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Placeholder replaces every redacted value
+const Placeholder = "[REDACTED]"
+
+// minSecretLength skips short values (e.g. "admin") that would mangle
+// ordinary output
+const minSecretLength = 6
+
+// replacerTTL is how long the secrets of a deployment are reused
+const replacerTTL = time.Minute
+
+// maxCachedReplacers bounds the per-deployment cache; it is cleared when full
+const maxCachedReplacers = 1000
+
+// privateKeyTTL is how long a deployment is treated as printing a private
+// key after its last line, and how long its key body lines are recognized
+const privateKeyTTL = 10 * time.Minute
+
+// SecretSource returns the secret values a deployment's builder can see
+type SecretSource interface {
+	Secrets(ctx context.Context, deploymentID string) ([]string, error)
+}
+
+// StaticSecrets is a SecretSource that returns the same values for every
+// deployment, such as the credentials the API server hands to every build
+type StaticSecrets []string
+
+// Secrets implements SecretSource
+func (s StaticSecrets) Secrets(ctx context.Context, deploymentID string) ([]string, error) {
+	return s, nil
+}
+
+// pattern is a credential format that is redacted regardless of source
+type pattern struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+var patterns = []pattern{
+	// PEM private keys; a chunk may end before the END line
+	{regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----(?s:.*?)(-----END [A-Z ]*PRIVATE KEY-----|$)`), Placeholder},
+	// AWS access key IDs
+	{regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`), Placeholder},
+	// AWS secret access keys in key=value or key: value form; the key name is kept
+	{regexp.MustCompile(`(?i)(aws_secret_access_key|secret_access_key|secretAccessKey)(["']?\s*[:=]\s*["']?)[A-Za-z0-9/+=]{40,}`), "${1}${2}" + Placeholder},
+	// JWTs
+	{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{4,}\.eyJ[A-Za-z0-9_-]{4,}\.[A-Za-z0-9_-]{8,}`), Placeholder},
+	// GitHub tokens
+	{regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`), Placeholder},
+	// Build tokens (mvb_) and personal access tokens (mvp_) of this platform;
+	// a build can print its own BUILD_TOKEN
+	{regexp.MustCompile(`\bmv[bp]_[A-Za-z0-9_-]{43}`), Placeholder},
+}
+
+var (
+	pemBegin = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)
+	pemEnd   = regexp.MustCompile(`-----END [A-Z ]*PRIVATE KEY-----`)
+	// pemBody matches a base64 line of a PEM block
+	pemBody = regexp.MustCompile(`^\s*[A-Za-z0-9+/]{40,}={0,2}\s*$`)
+)
+
+// keyState tracks a private key a deployment is printing line by line
+type keyState struct {
+	// open is set between a BEGIN line and its END line
+	open bool
+	seen time.Time
+}
+
+type cachedReplacer struct {
+	replacer *strings.Replacer
+	expires  time.Time
+}
+
+// Redactor masks known secret values and common credential patterns
+type Redactor struct {
+	source SecretSource
+
+	mu    sync.Mutex
+	cache map[string]cachedReplacer
+	keys  map[string]keyState
+}
+
+// New creates a redactor. source may be nil to only apply the built-in patterns.
+func New(source SecretSource) *Redactor {
+	return &Redactor{
+		source: source,
+		cache:  make(map[string]cachedReplacer),
+		keys:   make(map[string]keyState),
+	}
+}
+
+// Redact returns line with secrets replaced by Placeholder and whether
+// anything was replaced. A private key printed over several lines is masked
+// from its BEGIN line through its END line, so lines of a deployment should
+// be redacted in the order they were written; base64 lines shortly after a
+// key are masked even when they arrive out of order.
+func (r *Redactor) Redact(ctx context.Context, deploymentID, line string) (string, bool) {
+	out, prefix := line, ""
+
+	open, recent := r.keyState(deploymentID)
+	if open {
+		loc := pemEnd.FindStringIndex(out)
+		if loc == nil {
+			r.setKeyState(deploymentID, true)
+			return Placeholder, true
+		}
+		r.setKeyState(deploymentID, false)
+		prefix, out = Placeholder, out[loc[1]:]
+	} else if recent && (pemBody.MatchString(out) || pemEnd.MatchString(out)) {
+		return Placeholder, true
+	}
+
+	// A key whose END line has not been written yet continues on the next lines
+	if begins := pemBegin.FindAllStringIndex(out, -1); len(begins) > 0 {
+		last := begins[len(begins)-1]
+		r.setKeyState(deploymentID, !pemEnd.MatchString(out[last[1]:]))
+	}
+
+	if replacer := r.replacer(ctx, deploymentID); replacer != nil {
+		out = replacer.Replace(out)
+	}
+
+	for _, p := range patterns {
+		out = p.re.ReplaceAllString(out, p.replacement)
+	}
+
+	out = prefix + out
+	return out, out != line
+}
+
+// keyState reports whether a deployment is printing a private key, and
+// whether it printed one recently
+func (r *Redactor) keyState(deploymentID string) (open, recent bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k, ok := r.keys[deploymentID]
+	if !ok || time.Since(k.seen) > privateKeyTTL {
+		return false, false
+	}
+	return k.open, true
+}
+
+func (r *Redactor) setKeyState(deploymentID string, open bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := r.keys[deploymentID]; !ok && len(r.keys) >= maxCachedReplacers {
+		for id, k := range r.keys {
+			if now.Sub(k.seen) > privateKeyTTL {
+				delete(r.keys, id)
+			}
+		}
+	}
+	r.keys[deploymentID] = keyState{open: open, seen: now}
+}
+
+// replacer returns a cached replacer for the deployment's secret values
+func (r *Redactor) replacer(ctx context.Context, deploymentID string) *strings.Replacer {
+	if r.source == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	r.mu.Lock()
+	if c, ok := r.cache[deploymentID]; ok && now.Before(c.expires) {
+		r.mu.Unlock()
+		return c.replacer
+	}
+	r.mu.Unlock()
+
+	secrets, err := r.source.Secrets(ctx, deploymentID)
+	if err != nil {
+		// Patterns still apply; the lookup is retried on the next line
+		log.Printf("WARN: failed to load secrets for deployment %s: %v", deploymentID, err)
+		return nil
+	}
+
+	values := make([]string, 0, len(secrets))
+	for _, s := range secrets {
+		if len(s) >= minSecretLength {
+			values = append(values, s)
+		}
+	}
+	// Longest first so a secret containing another is masked whole
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	var replacer *strings.Replacer
+	if len(values) > 0 {
+		pairs := make([]string, 0, len(values)*2)
+		for _, v := range values {
+			pairs = append(pairs, v, Placeholder)
+		}
+		replacer = strings.NewReplacer(pairs...)
+	}
+
+	r.mu.Lock()
+	if len(r.cache) >= maxCachedReplacers {
+		r.cache = make(map[string]cachedReplacer)
+	}
+	r.cache[deploymentID] = cachedReplacer{replacer: replacer, expires: now.Add(replacerTTL)}
+	r.mu.Unlock()
+
+	return replacer
+}
@@ -0,0 +1,231 @@
+// Package webhook sends domain events to the webhooks projects subscribe,
+// signed and retried with exponential backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/webhook"
+	repository "github.com/ujjwalkirti/mini-vercel-api-server/internal/repository/webhook"
+)
+
+// pruneInterval is how often old deliveries are deleted
+const pruneInterval = time.Hour
+
+// userAgent identifies deliveries to receivers
+const userAgent = "mini-vercel-webhooks/1"
+
+type Config struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// Timeout bounds one request to a receiver
+	Timeout time.Duration
+	// Lease is how long a claimed delivery is held before another worker
+	// may retry it
+	Lease time.Duration
+	// MaxAttempts is how often a delivery is tried before it is failed
+	MaxAttempts int
+	// RetryBackoff is the wait after the first failed attempt; it doubles
+	// with every attempt up to MaxBackoff
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+	// Retention is how long finished deliveries are kept
+	Retention time.Duration
+	// Dial connects to receivers; nil uses net.Dialer. Set it to
+	// giturl.Validator.DialContext to keep deliveries away from internal
+	// addresses.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Worker sends due deliveries
+type Worker struct {
+	repo   *repository.Repository
+	client *http.Client
+	cfg    Config
+}
+
+// New creates a worker. client may be nil to use one with cfg.Timeout that
+// connects with cfg.Dial.
+func New(repo *repository.Repository, client *http.Client, cfg Config) *Worker {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = time.Minute
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 10 * time.Second
+	}
+	if cfg.MaxBackoff < cfg.RetryBackoff {
+		cfg.MaxBackoff = cfg.RetryBackoff
+	}
+	if cfg.Dial == nil {
+		var dialer net.Dialer
+		cfg.Dial = dialer.DialContext
+	}
+	if client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		// Connections go straight to the receiver, so Dial checks the
+		// receiver rather than a proxy
+		transport.Proxy = nil
+		transport.DialContext = cfg.Dial
+
+		client = &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: transport,
+			// A redirect counts as a failed delivery rather than sending the
+			// payload somewhere the webhook does not name
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 20
+	}
+	return &Worker{repo: repo, client: client, cfg: cfg}
+}
+
+// Run sends deliveries until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	var lastPrune time.Time
+	for {
+		if time.Since(lastPrune) >= pruneInterval {
+			w.prune(ctx)
+			lastPrune = time.Now()
+		}
+
+		// Keep draining while full batches come back
+		for {
+			if n := w.poll(ctx); n < w.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll sends one batch concurrently and returns its size
+func (w *Worker) poll(ctx context.Context) int {
+	if ctx.Err() != nil {
+		return 0
+	}
+
+	targets, err := w.repo.ClaimDue(ctx, w.cfg.BatchSize, w.cfg.Lease)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			log.Printf("ERROR: failed to claim webhook deliveries: %v", err)
+		}
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.deliver(ctx, t)
+		}()
+	}
+	wg.Wait()
+
+	return len(targets)
+}
+
+// deliver sends one delivery and records the outcome
+func (w *Worker) deliver(ctx context.Context, t domain.Target) {
+	attempt := w.Send(ctx, t)
+
+	var retryAt *time.Time
+	if !attempt.OK() && t.Attempts+1 < w.cfg.MaxAttempts {
+		at := time.Now().Add(w.backoff(t.Attempts + 1))
+		retryAt = &at
+	}
+
+	// The outcome is recorded even when shutdown interrupted the request
+	if err := w.repo.RecordAttempt(context.WithoutCancel(ctx), t.ID, attempt, retryAt); err != nil {
+		log.Printf("ERROR: failed to record webhook delivery %s: %v", t.ID, err)
+	}
+}
+
+// Send posts a delivery's payload to its webhook once
+func (w *Worker) Send(ctx context.Context, t domain.Target) domain.Attempt {
+	start := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(t.Payload))
+	if err != nil {
+		return domain.Attempt{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderEvent, string(t.EventType))
+	req.Header.Set(HeaderDelivery, t.ID)
+	req.Header.Set(HeaderSignature, Sign(t.Secret, start, t.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return domain.Attempt{Err: err, Duration: time.Since(start)}
+	}
+	// Only the status code is kept; the body is drained so the connection
+	// can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+
+	attempt := domain.Attempt{
+		ResponseCode: resp.StatusCode,
+		Duration:     time.Since(start),
+	}
+	if !attempt.OK() {
+		attempt.Err = fmt.Errorf("receiver responded with %d", resp.StatusCode)
+	}
+	return attempt
+}
+
+// backoff returns the wait after the given number of failed attempts
+func (w *Worker) backoff(attempts int) time.Duration {
+	d := w.cfg.RetryBackoff
+	for i := 1; i < attempts && d < w.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, w.cfg.MaxBackoff)
+}
+
+func (w *Worker) prune(ctx context.Context) {
+	if w.cfg.Retention <= 0 {
+		return
+	}
+
+	n, err := w.repo.DeleteDeliveriesBefore(ctx, time.Now().Add(-w.cfg.Retention))
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			log.Printf("ERROR: failed to prune webhook deliveries: %v", err)
+		}
+		return
+	}
+	if n > 0 {
+		log.Printf("Pruned %d webhook deliveries older than %s", n, w.cfg.Retention)
+	}
+}
@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	domain "github.com/ujjwalkirti/mini-vercel-api-server/internal/domain/webhook"
+	"github.com/ujjwalkirti/mini-vercel-api-server/internal/service/giturl"
+)
+
+func TestNewDefaultsNonPositiveSettings(t *testing.T) {
+	w := New(nil, nil, Config{PollInterval: -time.Second})
+
+	if w.cfg.PollInterval <= 0 || w.cfg.Timeout <= 0 || w.cfg.Lease <= 0 || w.cfg.MaxAttempts <= 0 || w.cfg.RetryBackoff <= 0 {
+		t.Fatalf("non-positive settings were kept: %+v", w.cfg)
+	}
+	if w.cfg.MaxBackoff < w.cfg.RetryBackoff {
+		t.Fatalf("MaxBackoff %s is below RetryBackoff %s", w.cfg.MaxBackoff, w.cfg.RetryBackoff)
+	}
+}
+
+func testTarget(url string) domain.Target {
+	return domain.Target{
+		Delivery: domain.Delivery{ID: "del-1", EventType: "deployment.ready", Payload: []byte(`{"id":"evt-1"}`)},
+		URL:      url,
+		Secret:   "whsec_0123456789abcdef",
+	}
+}
+
+func TestSendSignsDelivery(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		rw.WriteHeader(http.StatusAccepted)
+		io.WriteString(rw, "internal response")
+	}))
+	defer receiver.Close()
+
+	urls := giturl.New(giturl.Config{AllowPrivateHosts: true})
+	w := New(nil, nil, Config{Dial: urls.DialContext})
+
+	attempt := w.Send(context.Background(), testTarget(receiver.URL))
+	if !attempt.OK() || attempt.ResponseCode != http.StatusAccepted {
+		t.Fatalf("attempt = %+v, want 202", attempt)
+	}
+	if got.Header.Get(HeaderDelivery) != "del-1" || got.Header.Get(HeaderEvent) != "deployment.ready" {
+		t.Fatalf("headers = %v", got.Header)
+	}
+	if err := Verify("whsec_0123456789abcdef", got.Header.Get(HeaderSignature), body, time.Minute, time.Now()); err != nil {
+		t.Fatalf("signature does not verify: %v", err)
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	called := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer receiver.Close()
+
+	urls := giturl.New(giturl.Config{})
+	w := New(nil, nil, Config{Dial: urls.DialContext})
+
+	attempt := w.Send(context.Background(), testTarget(receiver.URL))
+	if attempt.OK() || attempt.Err == nil {
+		t.Fatalf("attempt = %+v, want a refused connection", attempt)
+	}
+	if called {
+		t.Fatal("the worker connected to a loopback address")
+	}
+}
+
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		t.Error("the redirect was followed")
+	}))
+	defer internal.Close()
+	receiver := httptest.NewServer(http.RedirectHandler(internal.URL, http.StatusFound))
+	defer receiver.Close()
+
+	urls := giturl.New(giturl.Config{AllowPrivateHosts: true})
+	w := New(nil, nil, Config{Dial: urls.DialContext})
+
+	if attempt := w.Send(context.Background(), testTarget(receiver.URL)); attempt.OK() || attempt.ResponseCode != http.StatusFound {
+		t.Fatalf("attempt = %+v, want a failed 302", attempt)
+	}
+}
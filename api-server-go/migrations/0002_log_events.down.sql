@@ -0,0 +1,2 @@
+-- 0002_log_events.down.sql
+DROP TABLE IF EXISTS log_events;
@@ -0,0 +1,13 @@
+-- Build logs for installs that store them in PostgreSQL (LOG_STORE=postgres)
+-- Monthly partitions are created by the API server while it runs; the default
+-- partition catches anything outside them.
+CREATE TABLE log_events (
+    event_id TEXT NOT NULL,
+    deployment_id TEXT NOT NULL,
+    log TEXT NOT NULL,
+    stream TEXT NOT NULL DEFAULT 'stdout',
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT clock_timestamp(),
+    PRIMARY KEY (deployment_id, timestamp, event_id)
+) PARTITION BY RANGE (timestamp);
+
+CREATE TABLE log_events_default PARTITION OF log_events DEFAULT;
@@ -0,0 +1,4 @@
+-- 0003_log_events_project_id.down.sql
+DROP INDEX IF EXISTS idx_log_events_project_id;
+
+ALTER TABLE log_events DROP COLUMN IF EXISTS project_id;
@@ -0,0 +1,4 @@
+-- Lets a project's logs be deleted without enumerating its deployments
+ALTER TABLE log_events ADD COLUMN project_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_log_events_project_id ON log_events (project_id);
@@ -0,0 +1,2 @@
+-- 0004_projects_plan.down.sql
+ALTER TABLE projects DROP COLUMN IF EXISTS plan;
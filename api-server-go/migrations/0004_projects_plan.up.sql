@@ -0,0 +1,2 @@
+-- Billing plan; decides how long build logs are retained
+ALTER TABLE projects ADD COLUMN plan TEXT NOT NULL DEFAULT 'hobby';
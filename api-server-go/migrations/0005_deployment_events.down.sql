@@ -0,0 +1,6 @@
+-- 0005_deployment_events.down.sql
+-- Enum values cannot be dropped; CANCELED and TIMED_OUT stay in deployment_status
+DROP TABLE IF EXISTS deployment_events;
+
+ALTER TABLE deployments DROP COLUMN IF EXISTS finished_at;
+ALTER TABLE deployments DROP COLUMN IF EXISTS started_at;
@@ -0,0 +1,18 @@
+-- Statuses for builds that are stopped by the user or by the build timeout
+ALTER TYPE deployment_status ADD VALUE IF NOT EXISTS 'CANCELED';
+ALTER TYPE deployment_status ADD VALUE IF NOT EXISTS 'TIMED_OUT';
+
+ALTER TABLE deployments ADD COLUMN started_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE deployments ADD COLUMN finished_at TIMESTAMP WITH TIME ZONE;
+
+-- Every status transition of a deployment
+CREATE TABLE deployment_events (
+    id BIGSERIAL PRIMARY KEY,
+    deployment_id TEXT NOT NULL REFERENCES deployments (id) ON DELETE CASCADE,
+    from_status deployment_status,
+    to_status deployment_status NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_deployment_events_deployment_id ON deployment_events (deployment_id, created_at);
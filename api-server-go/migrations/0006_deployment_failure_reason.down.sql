@@ -0,0 +1,3 @@
+-- 0006_deployment_failure_reason.down.sql
+ALTER TABLE deployments DROP COLUMN IF EXISTS failure_excerpt;
+ALTER TABLE deployments DROP COLUMN IF EXISTS failure_reason;
@@ -0,0 +1,3 @@
+-- Why a FAIL deployment failed, classified from its build log
+ALTER TABLE deployments ADD COLUMN failure_reason TEXT;
+ALTER TABLE deployments ADD COLUMN failure_excerpt TEXT[];
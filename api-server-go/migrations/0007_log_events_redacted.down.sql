@@ -0,0 +1,2 @@
+-- 0007_log_events_redacted.down.sql
+ALTER TABLE log_events DROP COLUMN IF EXISTS redacted;
@@ -0,0 +1,2 @@
+-- 0008_build_jobs.down.sql
+DROP TABLE IF EXISTS build_jobs;
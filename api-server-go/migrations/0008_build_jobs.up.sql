@@ -0,0 +1,17 @@
+-- Outbox of work that must follow a committed state change, such as
+-- starting the build of a newly created deployment
+CREATE TABLE build_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    deployment_id TEXT NOT NULL REFERENCES deployments (id) ON DELETE CASCADE,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    run_after TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    locked_until TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_build_jobs_claim ON build_jobs (status, run_after) WHERE status IN ('pending', 'running');
@@ -0,0 +1,3 @@
+-- 0009_deployment_build.down.sql
+ALTER TABLE deployments DROP COLUMN IF EXISTS build_id;
+ALTER TABLE deployments DROP COLUMN IF EXISTS executor;
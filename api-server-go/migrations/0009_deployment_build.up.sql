@@ -0,0 +1,4 @@
+-- Which executor backend ran the build and its backend-specific ID
+-- (ECS task ARN, Kubernetes Job name or Docker container name)
+ALTER TABLE deployments ADD COLUMN executor TEXT;
+ALTER TABLE deployments ADD COLUMN build_id TEXT;
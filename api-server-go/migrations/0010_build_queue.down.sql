@@ -0,0 +1,2 @@
+-- 0010_build_queue.down.sql
+DROP INDEX IF EXISTS idx_deployments_project_created;
@@ -0,0 +1,3 @@
+-- The build scheduler looks for newer deployments of the same project to
+-- skip superseded builds
+CREATE INDEX idx_deployments_project_created ON deployments (project_id, created_at);
@@ -0,0 +1,3 @@
+-- 0011_projects_build_settings.down.sql
+ALTER TABLE projects DROP COLUMN IF EXISTS use_spot;
+ALTER TABLE projects DROP COLUMN IF EXISTS build_preset;
@@ -0,0 +1,3 @@
+-- Per-project build resources and Spot usage; NULL uses the plan default
+ALTER TABLE projects ADD COLUMN build_preset TEXT;
+ALTER TABLE projects ADD COLUMN use_spot BOOLEAN;
@@ -0,0 +1,3 @@
+-- 0012_build_tokens.down.sql
+ALTER TABLE deployments DROP COLUMN IF EXISTS build_token_expires_at;
+ALTER TABLE deployments DROP COLUMN IF EXISTS build_token_hash;
@@ -0,0 +1,4 @@
+-- Per-deployment build token. Only its SHA-256 hash is stored; the token is
+-- handed to the build container when the build starts.
+ALTER TABLE deployments ADD COLUMN build_token_hash TEXT;
+ALTER TABLE deployments ADD COLUMN build_token_expires_at TIMESTAMPTZ;
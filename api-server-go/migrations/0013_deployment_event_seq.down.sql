@@ -0,0 +1,2 @@
+-- 0013_deployment_event_seq.down.sql
+ALTER TABLE deployments DROP COLUMN IF EXISTS last_event_seq;
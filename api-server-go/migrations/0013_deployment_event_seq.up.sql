@@ -0,0 +1,3 @@
+-- Highest build event sequence number ingested over HTTP; retried batches
+-- at or below it are skipped
+ALTER TABLE deployments ADD COLUMN last_event_seq BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- 0014_domain_events.down.sql
+DROP TABLE IF EXISTS domain_events;
@@ -0,0 +1,19 @@
+-- Outbox of domain events. Each is written in the same transaction as the
+-- change it describes and published to the event bus by the relay, in id
+-- order. project_id has no foreign key so project.deleted outlives its
+-- project.
+CREATE TABLE domain_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_id TEXT NOT NULL UNIQUE,
+    type TEXT NOT NULL,
+    version INT NOT NULL,
+    project_id TEXT NOT NULL,
+    data JSONB NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    published_at TIMESTAMP WITH TIME ZONE,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT
+);
+
+CREATE INDEX idx_domain_events_unpublished ON domain_events (id) WHERE published_at IS NULL;
+CREATE INDEX idx_domain_events_occurred_at ON domain_events (occurred_at);
@@ -0,0 +1,3 @@
+-- 0015_webhooks.down.sql
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
@@ -0,0 +1,5 @@
+-- 0016_deployment_git_source.down.sql
+ALTER TABLE deployments
+    DROP COLUMN IF EXISTS pull_request,
+    DROP COLUMN IF EXISTS git_ref,
+    DROP COLUMN IF EXISTS commit_sha;
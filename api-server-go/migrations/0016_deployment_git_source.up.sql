@@ -0,0 +1,6 @@
+-- The commit a deployment was triggered from, if the caller named it. Its
+-- status is reported back to the git provider.
+ALTER TABLE deployments
+    ADD COLUMN commit_sha TEXT,
+    ADD COLUMN git_ref TEXT,
+    ADD COLUMN pull_request INT;
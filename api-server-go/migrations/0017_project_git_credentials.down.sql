@@ -0,0 +1,2 @@
+-- 0017_project_git_credentials.down.sql
+DROP TABLE IF EXISTS project_git_credentials;
@@ -0,0 +1,14 @@
+-- Credentials builds clone a project's repository with. Projects without a
+-- row clone anonymously.
+CREATE TABLE project_git_credentials (
+    project_id TEXT PRIMARY KEY REFERENCES projects (id) ON DELETE CASCADE,
+    -- deploy_key or github_app
+    type TEXT NOT NULL,
+    -- deploy_key: OpenSSH public key, its SHA256 fingerprint and the
+    -- private key encrypted with GIT_CREDENTIALS_KEY
+    public_key TEXT,
+    fingerprint TEXT,
+    private_key_encrypted BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
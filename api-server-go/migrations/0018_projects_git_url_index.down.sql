@@ -0,0 +1,2 @@
+-- 0018_projects_git_url_index.down.sql
+DROP INDEX IF EXISTS idx_projects_user_git_url;
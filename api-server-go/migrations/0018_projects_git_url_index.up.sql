@@ -0,0 +1,2 @@
+-- Duplicate projects are detected by user and normalized git URL
+CREATE INDEX idx_projects_user_git_url ON projects (user_id, lower(git_url));
@@ -0,0 +1,2 @@
+-- 0019_api_tokens.down.sql
+DROP TABLE IF EXISTS api_tokens;
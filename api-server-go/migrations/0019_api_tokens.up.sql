@@ -0,0 +1,18 @@
+-- Personal access tokens for scripts and CI. Only the SHA-256 of a token is
+-- stored; it is shown to the user once, when it is created.
+CREATE TABLE api_tokens (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    -- The start of the token, to recognize it in lists
+    prefix TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    -- Restricts the token to one project when set
+    project_id TEXT REFERENCES projects (id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_api_tokens_user_id ON api_tokens (user_id);
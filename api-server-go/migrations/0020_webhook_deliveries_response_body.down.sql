@@ -0,0 +1,2 @@
+-- 0020_webhook_deliveries_response_body.down.sql
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS response_body TEXT;
@@ -0,0 +1,3 @@
+-- Receivers' response bodies are no longer stored; they could echo
+-- responses of internal services back to the project owner
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS response_body;
@@ -0,0 +1,3 @@
+-- 0021_github_installation_links.down.sql
+DROP TABLE IF EXISTS github_link_states;
+DROP TABLE IF EXISTS github_installation_links;
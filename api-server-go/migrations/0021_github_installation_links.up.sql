@@ -0,0 +1,19 @@
+-- GitHub App installations users linked to their account by authorizing
+-- the app. github_app credentials and commit statuses only use
+-- installations the project owner linked.
+CREATE TABLE github_installation_links (
+    user_id TEXT NOT NULL,
+    installation_id BIGINT NOT NULL,
+    -- Login of the user or organization the app is installed on
+    account TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, installation_id)
+);
+
+-- Linking flows in progress. Only the SHA-256 of the state sent to GitHub
+-- is stored; it ties the callback to the user who started the flow.
+CREATE TABLE github_link_states (
+    state_hash TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
@@ -0,0 +1,2 @@
+-- 0022_projects_git_url_canonical.down.sql
+ALTER TABLE projects DROP COLUMN IF EXISTS git_url_canonical;
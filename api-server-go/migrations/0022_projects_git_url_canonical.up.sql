@@ -0,0 +1,5 @@
+-- Projects created before URL validation may store a git URL that is not in
+-- canonical form. They are marked so the API server normalizes them at
+-- startup; projects created from now on are stored normalized.
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS git_url_canonical BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE projects ALTER COLUMN git_url_canonical SET DEFAULT true;
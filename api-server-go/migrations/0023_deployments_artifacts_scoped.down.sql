@@ -0,0 +1,2 @@
+-- 0023_deployments_artifacts_scoped.down.sql
+ALTER TABLE deployments DROP COLUMN IF EXISTS artifacts_scoped;
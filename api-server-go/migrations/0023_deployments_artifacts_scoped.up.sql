@@ -0,0 +1,5 @@
+-- Build output is uploaded under <project_id>/<deployment_id>/, so a build
+-- cannot overwrite files of other deployments. Deployments built before
+-- keep being served from <project_id>/.
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS artifacts_scoped BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE deployments ALTER COLUMN artifacts_scoped SET DEFAULT true;
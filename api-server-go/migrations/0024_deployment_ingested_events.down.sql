@@ -0,0 +1,2 @@
+-- 0024_deployment_ingested_events.down.sql
+DROP TABLE IF EXISTS deployment_ingested_events;
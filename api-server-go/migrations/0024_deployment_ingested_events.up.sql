@@ -0,0 +1,8 @@
+-- Build events stored out of order over HTTP, above last_event_seq. A batch
+-- that failed partway is retried whole, and these are skipped so no line is
+-- stored twice. Rows at or below last_event_seq are removed.
+CREATE TABLE IF NOT EXISTS deployment_ingested_events (
+    deployment_id VARCHAR(36) NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    seq BIGINT NOT NULL,
+    PRIMARY KEY (deployment_id, seq)
+);
@@ -0,0 +1,2 @@
+-- 0025_deployment_previous_build_tokens.down.sql
+ALTER TABLE deployments DROP COLUMN IF EXISTS build_token_previous_hashes;
@@ -0,0 +1,4 @@
+-- Hashes of the build tokens earlier dispatch attempts issued. A retried
+-- dispatch may find the build of an earlier attempt still running and keep
+-- it, so its token stays valid until the deployment finishes.
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS build_token_previous_hashes TEXT[] NOT NULL DEFAULT '{}';
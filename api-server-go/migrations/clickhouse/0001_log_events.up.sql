@@ -0,0 +1,9 @@
+-- Build log table. timestamp is assigned by the server on insert.
+CREATE TABLE IF NOT EXISTS log_events (
+    event_id String,
+    deployment_id String,
+    log String,
+    timestamp DateTime64(3) MATERIALIZED now64(3)
+) ENGINE = MergeTree()
+PARTITION BY toYYYYMM(timestamp)
+ORDER BY (deployment_id, timestamp);
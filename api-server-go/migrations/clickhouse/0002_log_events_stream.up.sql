@@ -0,0 +1,2 @@
+-- Adds the output stream to existing log_events tables
+ALTER TABLE log_events ADD COLUMN IF NOT EXISTS stream LowCardinality(String) DEFAULT 'stdout';
@@ -0,0 +1,2 @@
+-- Lets a project's logs be deleted without enumerating its deployments
+ALTER TABLE log_events ADD COLUMN IF NOT EXISTS project_id String DEFAULT '';
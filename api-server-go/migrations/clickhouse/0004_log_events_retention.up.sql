@@ -0,0 +1,4 @@
+-- Per-row retention, stamped from the project's plan at ingest time
+ALTER TABLE log_events ADD COLUMN IF NOT EXISTS retention_days UInt16 DEFAULT 30;
+
+ALTER TABLE log_events MODIFY TTL toDateTime(timestamp) + toIntervalDay(retention_days);
@@ -0,0 +1,2 @@
+-- Marks lines whose secrets were masked before storage
+ALTER TABLE log_events ADD COLUMN IF NOT EXISTS redacted Bool DEFAULT false;
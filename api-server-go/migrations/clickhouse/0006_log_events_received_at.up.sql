@@ -0,0 +1,4 @@
+-- Orders lines by when the API server received them. timestamp is assigned
+-- per insert, so every line of a batch shared it and their order was lost.
+-- Existing rows keep their insert time.
+ALTER TABLE log_events ADD COLUMN IF NOT EXISTS received_at DateTime64(9) DEFAULT timestamp;
@@ -0,0 +1,11 @@
+-- Keeps a copy of each deployment's lines sorted by received_at, the order
+-- every log query reads in. The table is sorted by (deployment_id, timestamp)
+-- and ClickHouse cannot change an existing sort key to a column added later,
+-- so without the projection each page sorted all of a deployment's lines.
+-- MATERIALIZE rewrites the parts written before this migration.
+ALTER TABLE log_events ADD PROJECTION IF NOT EXISTS by_received_at (
+    SELECT event_id, deployment_id, project_id, log, stream, redacted, received_at
+    ORDER BY deployment_id, received_at, event_id
+);
+
+ALTER TABLE log_events MATERIALIZE PROJECTION by_received_at;
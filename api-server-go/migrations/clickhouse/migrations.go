@@ -0,0 +1,9 @@
+// Package clickhouse embeds the ClickHouse schema migrations so the API
+// server can apply them at startup. Files are named NNNN_description.up.sql
+// and are applied in version order; statements are separated by semicolons.
+package clickhouse
+
+import "embed"
+
+//go:embed *.up.sql
+var FS embed.FS
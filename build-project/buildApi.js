@@ -0,0 +1,88 @@
+import { createReadStream, statSync } from "fs";
+
+// Uploads are presigned in batches of at most this many files
+const PRESIGN_BATCH_SIZE = 500;
+
+/**
+ * Talks to the API server with the deployment's build token. The token only
+ * works for this deployment and stops working when the build finishes, so
+ * the container never holds platform secrets.
+ */
+class BuildApiClient {
+    /**
+     * @param {string} apiUrl - Base URL of the API server.
+     * @param {string} deploymentId - The deployment being built.
+     * @param {string} token - The build token (BUILD_TOKEN).
+     */
+    constructor(apiUrl, deploymentId, token) {
+        this.baseUrl = `${apiUrl.replace(/\/$/, "")}/internal/deployments/${deploymentId}`;
+        this.token = token;
+    }
+
+    async request(method, path, body) {
+        const response = await fetch(`${this.baseUrl}${path}`, {
+            method,
+            headers: {
+                Authorization: `Bearer ${this.token}`,
+                ...(body ? { "Content-Type": "application/json" } : {}),
+            },
+            body: body ? JSON.stringify(body) : undefined,
+        });
+
+        if (!response.ok) {
+            const text = await response.text();
+            throw new Error(`${method} ${path} failed with ${response.status}: ${text.trim()}`);
+        }
+
+        const { data } = await response.json();
+        return data;
+    }
+
+    /**
+     * Returns where build logs are posted: the deployment's events endpoint,
+     * authenticated with the build token.
+     * @returns {Promise<{transport: "http", url: string, expiresAt: string}>}
+     */
+    async getLogCredentials() {
+        return this.request("GET", "/credentials/logs");
+    }
+
+    /**
+     * Returns the credential the repository is cloned with.
+     * @returns {Promise<{type: "none" | "ssh" | "token", cloneUrl: string, username?: string, password?: string, privateKey?: string}>}
+     */
+    async getGitCredentials() {
+        return this.request("GET", "/credentials/git");
+    }
+
+    /**
+     * Uploads files through presigned URLs.
+     * @param {{filePath: string, file: string}[]} files - Local paths and their paths in the output.
+     * @param {(file: string) => Promise<void>} onUploaded - Called after each upload.
+     */
+    async uploadFiles(files, onUploaded) {
+        for (let i = 0; i < files.length; i += PRESIGN_BATCH_SIZE) {
+            const batch = files.slice(i, i + PRESIGN_BATCH_SIZE);
+            const { uploads } = await this.request("POST", "/uploads", {
+                files: batch.map(({ file }) => ({ path: file.split("\\").join("/") })),
+            });
+
+            for (const [j, upload] of uploads.entries()) {
+                const { filePath, file } = batch[j];
+                const response = await fetch(upload.url, {
+                    method: upload.method,
+                    headers: { ...upload.headers, "Content-Length": String(statSync(filePath).size) },
+                    body: createReadStream(filePath),
+                    duplex: "half",
+                });
+                if (!response.ok) {
+                    throw new Error(`Upload of ${file} failed with ${response.status}`);
+                }
+
+                await onUploaded(file);
+            }
+        }
+    }
+}
+
+export default BuildApiClient;
@@ -0,0 +1,87 @@
+import { spawnSync } from "child_process";
+import { chmodSync, mkdtempSync, rmSync, writeFileSync } from "fs";
+import { tmpdir } from "os";
+import path from "path";
+import BuildApiClient from "./buildApi.js";
+
+/*
+Clones the project's repository into the directory given as the first
+argument. With a build token the credential is fetched from the API server
+for this build only: a deploy key is written to a private temporary file and
+a GitHub App token is handed to git through an askpass script, so neither
+ends up in the environment, the command line or .git/config.
+Without a build token the repository is cloned anonymously.
+*/
+
+const target = process.argv[2];
+
+// Answers git's username and password prompts from its own environment
+const ASKPASS_SCRIPT = `#!/bin/sh
+case "$1" in
+  Username*) printf '%s\\n' "$GIT_CLONE_USERNAME" ;;
+  *) printf '%s\\n' "$GIT_CLONE_PASSWORD" ;;
+esac
+`;
+
+function git(args, env = {}) {
+    const result = spawnSync("git", args, {
+        stdio: "inherit",
+        env: { ...process.env, GIT_TERMINAL_PROMPT: "0", ...env },
+    });
+    if (result.status !== 0) {
+        throw new Error(`git ${args[0]} failed with status ${result.status}`);
+    }
+}
+
+async function getCredential() {
+    if (!process.env.BUILD_TOKEN) {
+        return { type: "none", cloneUrl: process.env.GIT_REPOSITORY_URL };
+    }
+    const buildApi = new BuildApiClient(process.env.API_URL, process.env.DEPLOYMENT_ID, process.env.BUILD_TOKEN);
+    return buildApi.getGitCredentials();
+}
+
+async function main() {
+    const credential = await getCredential();
+    const secrets = mkdtempSync(path.join(tmpdir(), "git-credential-"));
+
+    try {
+        switch (credential.type) {
+            case "none":
+                git(["clone", credential.cloneUrl, target]);
+                break;
+
+            case "ssh": {
+                const keyFile = path.join(secrets, "id_deploy");
+                writeFileSync(keyFile, credential.privateKey.replace(/\n?$/, "\n"), { mode: 0o600 });
+                const knownHosts = path.join(secrets, "known_hosts");
+                git(["clone", credential.cloneUrl, target], {
+                    GIT_SSH_COMMAND: `ssh -i ${keyFile} -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new -o UserKnownHostsFile=${knownHosts}`,
+                });
+                break;
+            }
+
+            case "token": {
+                const askpass = path.join(secrets, "askpass.sh");
+                writeFileSync(askpass, ASKPASS_SCRIPT);
+                chmodSync(askpass, 0o700);
+                git(["clone", credential.cloneUrl, target], {
+                    GIT_ASKPASS: askpass,
+                    GIT_CLONE_USERNAME: credential.username,
+                    GIT_CLONE_PASSWORD: credential.password,
+                });
+                break;
+            }
+
+            default:
+                throw new Error(`Unknown git credential type ${credential.type}`);
+        }
+    } finally {
+        rmSync(secrets, { recursive: true, force: true });
+    }
+}
+
+main().catch((error) => {
+    console.error(`Failed to clone the repository: ${error.message}`);
+    process.exit(1);
+});
@@ -0,0 +1,112 @@
+// Events are sent when this many are buffered, or after FLUSH_INTERVAL_MS
+const BATCH_SIZE = 200;
+const FLUSH_INTERVAL_MS = 500;
+const MAX_ATTEMPTS = 8;
+
+const sleep = (ms) => new Promise((resolve) => setTimeout(resolve, ms));
+
+/**
+ * Sends build logs to the API server as batches of NDJSON events, for
+ * installs without Kafka. Every event has a sequence number, so a batch
+ * that failed can be sent again without duplicating lines.
+ * It has the same interface as KafkaProducerService.
+ */
+class HttpLogProducer {
+    /**
+     * @param {string} url - The deployment's events endpoint.
+     * @param {string} token - The build token (BUILD_TOKEN).
+     */
+    constructor(url, token) {
+        this.url = url;
+        this.token = token;
+        this.seq = 0;
+        this.buffer = [];
+        // Settles when the buffered events are sent
+        this.pending = null;
+        this.sending = Promise.resolve();
+        this.timer = null;
+    }
+
+    async connect() {}
+
+    /**
+     * Queues a log line. Lines are sent in order; the returned promise
+     * resolves once the batch holding the line is stored.
+     * @param {string} topic - Unused; kept for KafkaProducerService compatibility.
+     * @param {object} keys - project_id, deployment_id and optionally stream.
+     * @param {string} message - The log line.
+     */
+    generateMessage(topic, keys, message) {
+        this.seq += 1;
+        this.buffer.push({ seq: this.seq, log: message, ...(keys.stream ? { stream: keys.stream } : {}) });
+
+        if (!this.pending) {
+            let resolve, reject;
+            const promise = new Promise((res, rej) => { resolve = res; reject = rej; });
+            this.pending = { promise, resolve, reject };
+        }
+        const sent = this.pending.promise;
+
+        if (this.buffer.length >= BATCH_SIZE) {
+            this.flush();
+        } else if (!this.timer) {
+            this.timer = setTimeout(() => this.flush(), FLUSH_INTERVAL_MS);
+        }
+        return sent;
+    }
+
+    /** Sends everything buffered so far */
+    flush() {
+        clearTimeout(this.timer);
+        this.timer = null;
+
+        const batch = this.buffer;
+        const pending = this.pending;
+        this.buffer = [];
+        this.pending = null;
+        if (batch.length === 0) {
+            return this.sending;
+        }
+
+        // A failed batch does not stop later ones
+        this.sending = this.sending.catch(() => {}).then(() => this.send(batch));
+        this.sending.then(pending.resolve, pending.reject);
+        return this.sending;
+    }
+
+    async send(batch) {
+        const body = batch.map((event) => JSON.stringify(event)).join("\n") + "\n";
+
+        for (let attempt = 1; ; attempt++) {
+            try {
+                const response = await fetch(this.url, {
+                    method: "POST",
+                    headers: {
+                        Authorization: `Bearer ${this.token}`,
+                        "Content-Type": "application/x-ndjson",
+                    },
+                    body,
+                });
+                if (response.ok) {
+                    return;
+                }
+                // The token is no longer valid; retrying cannot help
+                if (response.status === 401 || response.status === 403 || response.status === 400) {
+                    throw Object.assign(new Error(`log ingestion rejected with ${response.status}`), { permanent: true });
+                }
+                throw new Error(`log ingestion failed with ${response.status}`);
+            } catch (err) {
+                if (err.permanent || attempt >= MAX_ATTEMPTS) {
+                    throw err;
+                }
+                await sleep(Math.min(250 * 2 ** attempt, 10000));
+            }
+        }
+    }
+
+    async disconnect() {
+        await this.flush();
+    }
+}
+
+export default HttpLogProducer;
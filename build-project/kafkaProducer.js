@@ -26,7 +26,9 @@ class KafkaProducerService {
         await this.producer.send({
             topic: topic,
             messages: [
-                { key: 'log', value: JSON.stringify({ ...keys, log: message }) }
+                // Keyed by deployment so its lines share a partition and are
+                // consumed in order
+                { key: keys.deployment_id, value: JSON.stringify({ ...keys, log: message }) }
             ]
         })
     }
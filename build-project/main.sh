@@ -2,8 +2,15 @@
 
 export GIT_REPOSITORY_URL="${GIT_REPOSITORY_URL}"
 
-# clone the repo
-git clone "$GIT_REPOSITORY_URL" /home/app/output
+# clone the repo, with the project's git credential if it has one
+node gitClone.js /home/app/output
+
+# build the commit the deployment was triggered from, if given. If it
+# cannot be checked out the source is removed, so the build fails like a
+# failed clone instead of building another commit.
+if [ -n "$GIT_COMMIT_SHA" ]; then
+  git -C /home/app/output checkout --detach "$GIT_COMMIT_SHA" || rm -rf /home/app/output
+fi
 
 # run the js script
 exec node script.js
@@ -15,8 +15,10 @@ class R2BlobService {
         this.bucketName = process.env.R2_BUCKET_NAME;
     }
 
-    async uploadToBlob(filePath, file, projectId) {
-        const key = `${projectId}/${file}`;
+    // prefix is <project_id>/<deployment_id>, where the reverse proxy
+    // serves the deployment from
+    async uploadToBlob(filePath, file, prefix) {
+        const key = `${prefix}/${file}`;
         const fileStream = createReadStream(filePath);
         const contentType = mime.lookup(filePath) || "application/octet-stream";
 
@@ -5,6 +5,8 @@ import R2BlobService from "./r2Blob.js";
 import { fileURLToPath } from "url";
 import { Kafka, logLevel } from "kafkajs";
 import KafkaProducerService from "./kafkaProducer.js";
+import BuildApiClient from "./buildApi.js";
+import HttpLogProducer from "./httpLogProducer.js";
 
 /* following functions need to be implemented:
 1. cd into repo
@@ -22,35 +24,54 @@ const deployment_id = process.env.DEPLOYMENT_ID;
 
 const pemPath = "/tmp/ca.pem";
 
-if (!existsSync(pemPath)) {
-    writeFileSync(
-        pemPath,
-        process.env.CA_PEM,
-        { mode: 0o600 }
-    );
-}
+// With a build token (set by the API server's dispatcher) logs and uploads go
+// through the API; the platform secrets are only read when the builder is run
+// by hand without one
+const buildApi = process.env.BUILD_TOKEN
+    ? new BuildApiClient(process.env.API_URL, deployment_id, process.env.BUILD_TOKEN)
+    : null;
+
+// createLogProducer returns an HttpLogProducer authenticated with the build
+// token. Without one (the builder run by hand) it produces to Kafka with the
+// platform credentials.
+async function createLogProducer() {
+    if (buildApi) {
+        const credentials = await buildApi.getLogCredentials();
+        if (credentials.transport !== "http") {
+            throw new Error(`Unsupported log transport ${credentials.transport}`);
+        }
+        return new HttpLogProducer(credentials.url, process.env.BUILD_TOKEN);
+    }
+
+    // The broker's CA is only needed for a private CA; otherwise the
+    // system's CAs verify it
+    let ssl = true;
+    if (process.env.CA_PEM) {
+        if (!existsSync(pemPath)) {
+            writeFileSync(pemPath, process.env.CA_PEM, { mode: 0o600 });
+        }
+        ssl = { ca: [readFileSync(pemPath, "utf-8")] };
+    }
 
+    const kafkaClient = new Kafka({
+        clientId: `docker-build-server-${deployment_id}`,
+        brokers: [process.env.KAFKA_BROKERS],
+        ssl,
+        sasl: {
+            mechanism: "plain",
+            username: process.env.KAFKA_USERNAME,
+            password: process.env.KAFKA_PASSWORD
+        },
+        logLevel: logLevel.ERROR,
+        connectionTimeout: 30000,
+        requestTimeout: 30000
+    }
+    );
 
-const kafkaClient = new Kafka({
-    clientId: `docker-build-server-${deployment_id}`,
-    brokers: [process.env.KAFKA_BROKERS],
-    ssl: {
-        ca: [readFileSync(pemPath, "utf-8")]
-    },
-    sasl: {
-        mechanism: "plain",
-        username: process.env.KAFKA_USERNAME,
-        password: process.env.KAFKA_PASSWORD
-    },
-    logLevel: logLevel.ERROR,
-    connectionTimeout: 30000,
-    requestTimeout: 30000
+    return new KafkaProducerService(kafkaClient);
 }
-);
-
-const kafkaProducer = new KafkaProducerService(kafkaClient);
 
-const r2BlobService = new R2BlobService();
+let kafkaProducer;
 
 function runCommand(command, args, cwd) {
     return new Promise((resolve, reject) => {
@@ -70,7 +91,7 @@ function runCommand(command, args, cwd) {
         child.stderr.on("data", async (data) => {
             const text = data.toString();
             console.error(text);
-            await kafkaProducer.generateMessage('mini-vercel-build-logs', { project_id, deployment_id }, text);
+            await kafkaProducer.generateMessage('mini-vercel-build-logs', { project_id, deployment_id, stream: "stderr" }, text);
         });
 
         child.on("close", (code) => {
@@ -102,22 +123,30 @@ async function uploadFiles() {
         throw new Error("dist folder does not exist. Build may have failed.");
     }
 
-    const files = readdirSync(distFolderPath, { recursive: true });
-
-    for (const file of files) {
-        const filePath = path.join(distFolderPath, file);
-
-        if (lstatSync(filePath).isDirectory()) continue;
-
-        await r2BlobService.uploadToBlob(filePath, file, project_id);
+    const files = readdirSync(distFolderPath, { recursive: true })
+        .map((file) => ({ file, filePath: path.join(distFolderPath, file) }))
+        .filter(({ filePath }) => !lstatSync(filePath).isDirectory());
 
+    const onUploaded = async (file) => {
         const msg = `Uploaded: ${file}`;
         console.log(msg);
         await kafkaProducer.generateMessage('mini-vercel-build-logs', { project_id, deployment_id }, msg);
+    };
+
+    if (buildApi) {
+        await buildApi.uploadFiles(files, onUploaded);
+        return;
+    }
+
+    const r2BlobService = new R2BlobService();
+    for (const { file, filePath } of files) {
+        await r2BlobService.uploadToBlob(filePath, file, `${project_id}/${deployment_id}`);
+        await onUploaded(file);
     }
 }
 
 async function main() {
+    kafkaProducer = await createLogProducer();
     await kafkaProducer.connect();
     try {
         await kafkaProducer.generateMessage('mini-vercel-build-logs', { project_id, deployment_id }, "INFO: Starting build pipeline...");
@@ -137,8 +166,8 @@ async function main() {
         await kafkaProducer.generateMessage('mini-vercel-build-logs', { project_id, deployment_id }, `ERROR: ${err.message}, Pipeline failed.`);
         console.error(`ERROR: ${err.message}, Pipeline failed.`);
     } finally {
-        await kafkaProducer.producer.disconnect();
-        console.log("Kafka producer disconnected.");
+        await kafkaProducer.disconnect();
+        console.log("Log producer disconnected.");
 
         process.exit(0);
     }
@@ -40,7 +40,7 @@ func (a *App) Run() error {
 	log.Println("Database connection established")
 
 	// Initialize router
-	r := router.New(a.db, a.config.R2PublicURL)
+	r := router.New(a.db, a.config.R2PublicURL, a.config.LocalOriginDir)
 
 	// Create HTTP server
 	a.server = &http.Server{
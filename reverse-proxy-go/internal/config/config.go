@@ -5,16 +5,20 @@ import (
 )
 
 type Config struct {
-	DatabaseURL  string
-	R2PublicURL  string
-	Port         string
+	DatabaseURL string
+	R2PublicURL string
+	// LocalOriginDir serves deployments from disk instead of R2, as written
+	// by the API server's local build executor
+	LocalOriginDir string
+	Port           string
 }
 
 func Load() *Config {
 	return &Config{
-		DatabaseURL:  getEnv("DATABASE_URL", ""),
-		R2PublicURL:  getEnv("R2_PUBLIC_URL", ""),
-		Port:         getEnv("PORT", "8001"),
+		DatabaseURL:    getEnv("DATABASE_URL", ""),
+		R2PublicURL:    getEnv("R2_PUBLIC_URL", ""),
+		LocalOriginDir: getEnv("LOCAL_ORIGIN_DIR", ""),
+		Port:           getEnv("PORT", "8001"),
 	}
 }
 
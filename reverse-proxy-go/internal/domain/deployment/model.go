@@ -13,9 +13,22 @@ const (
 )
 
 type Deployment struct {
-	ID        string    `json:"id"`
-	ProjectID string    `json:"project_id"`
-	Status    Status    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        string `json:"id"`
+	ProjectID string `json:"project_id"`
+	Status    Status `json:"status"`
+	// ArtifactsScoped is false for deployments built before output was
+	// stored per deployment
+	ArtifactsScoped bool      `json:"artifacts_scoped"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// ArtifactPrefix is where the deployment's output is stored:
+// <project_id>/<deployment_id>, or <project_id> for deployments built
+// before output was stored per deployment
+func (d Deployment) ArtifactPrefix() string {
+	if !d.ArtifactsScoped {
+		return d.ProjectID
+	}
+	return d.ProjectID + "/" + d.ID
 }
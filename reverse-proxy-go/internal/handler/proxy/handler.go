@@ -7,6 +7,9 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"os"
+	"path"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -14,14 +17,16 @@ import (
 )
 
 type Handler struct {
-	repo        *project.Repository
-	r2PublicURL string
+	repo           *project.Repository
+	r2PublicURL    string
+	localOriginDir string
 }
 
-func NewHandler(repo *project.Repository, r2PublicURL string) *Handler {
+func NewHandler(repo *project.Repository, r2PublicURL string, localOriginDir string) *Handler {
 	return &Handler{
-		repo:        repo,
-		r2PublicURL: r2PublicURL,
+		repo:           repo,
+		r2PublicURL:    r2PublicURL,
+		localOriginDir: localOriginDir,
 	}
 }
 
@@ -60,7 +65,13 @@ func (h *Handler) ProxyRequest(w http.ResponseWriter, r *http.Request) {
 		targetPath = "/index.html"
 	}
 
-	targetURL := fmt.Sprintf("%s/%s%s", h.r2PublicURL, proj.ID, targetPath)
+	// Local development: files were written to disk by the local build executor
+	if h.localOriginDir != "" {
+		h.serveLocal(w, r, proj.Deployments[0].ArtifactPrefix(), targetPath)
+		return
+	}
+
+	targetURL := fmt.Sprintf("%s/%s%s", h.r2PublicURL, proj.Deployments[0].ArtifactPrefix(), targetPath)
 	log.Printf("Proxying request: %s -> %s", r.URL.Path, targetURL)
 
 	// Parse the target URL
@@ -94,6 +105,21 @@ func (h *Handler) ProxyRequest(w http.ResponseWriter, r *http.Request) {
 	proxy.ServeHTTP(w, r)
 }
 
+// serveLocal serves a deployed file from <localOriginDir>/<prefix>/
+func (h *Handler) serveLocal(w http.ResponseWriter, r *http.Request, prefix, targetPath string) {
+	// path.Clean on a rooted path drops any ".." that would escape the deployment
+	filePath := filepath.Join(h.localOriginDir, filepath.FromSlash(prefix), filepath.FromSlash(path.Clean("/"+targetPath)))
+
+	info, err := os.Stat(filePath)
+	if err != nil || info.IsDir() {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	log.Printf("Serving local file: %s -> %s", r.URL.Path, filePath)
+	http.ServeFile(w, r, filePath)
+}
+
 // extractSubdomain extracts the subdomain from the hostname
 // Example: myapp.localhost:8001 -> myapp
 func (h *Handler) extractSubdomain(hostname string) string {
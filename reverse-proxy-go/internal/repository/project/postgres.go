@@ -23,7 +23,7 @@ func (r *Repository) FindBySubdomain(ctx context.Context, subdomain string) (*pr
 		SELECT
 			p.id, p.name, p.git_url, p.subdomain, p.custom_domain,
 			p.user_id, p.created_at, p.updated_at,
-			d.id, d.project_id, d.status, d.created_at, d.updated_at
+			d.id, d.project_id, d.status, d.artifacts_scoped, d.created_at, d.updated_at
 		FROM projects p
 		LEFT JOIN deployments d ON p.id = d.project_id
 		WHERE p.subdomain = $1
@@ -48,6 +48,7 @@ func (r *Repository) FindBySubdomain(ctx context.Context, subdomain string) (*pr
 		&deploy.ID,
 		&deploy.ProjectID,
 		&deploy.Status,
+		&deploy.ArtifactsScoped,
 		&deploy.CreatedAt,
 		&deploy.UpdatedAt,
 	)
@@ -74,7 +75,7 @@ func (r *Repository) FindByCustomDomain(ctx context.Context, customDomain string
 		SELECT
 			p.id, p.name, p.git_url, p.subdomain, p.custom_domain,
 			p.user_id, p.created_at, p.updated_at,
-			d.id, d.project_id, d.status, d.created_at, d.updated_at
+			d.id, d.project_id, d.status, d.artifacts_scoped, d.created_at, d.updated_at
 		FROM projects p
 		LEFT JOIN deployments d ON p.id = d.project_id
 		WHERE p.custom_domain = $1
@@ -99,6 +100,7 @@ func (r *Repository) FindByCustomDomain(ctx context.Context, customDomain string
 		&deploy.ID,
 		&deploy.ProjectID,
 		&deploy.Status,
+		&deploy.ArtifactsScoped,
 		&deploy.CreatedAt,
 		&deploy.UpdatedAt,
 	)
@@ -12,7 +12,7 @@ import (
 	"reverse-proxy/internal/repository/project"
 )
 
-func New(db *sql.DB, r2PublicURL string) *chi.Mux {
+func New(db *sql.DB, r2PublicURL string, localOriginDir string) *chi.Mux {
 	r := chi.NewRouter()
 
 	// Middleware stack
@@ -35,7 +35,7 @@ func New(db *sql.DB, r2PublicURL string) *chi.Mux {
 	projectRepo := project.NewRepository(db)
 
 	// Initialize handlers
-	proxy := proxyHandler.NewHandler(projectRepo, r2PublicURL)
+	proxy := proxyHandler.NewHandler(projectRepo, r2PublicURL, localOriginDir)
 
 	// Register routes
 	proxy.RegisterRoutes(r)